@@ -1,86 +1,447 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/knadh/koanf/parsers/json"
+	"github.com/knadh/koanf/parsers/toml/v2"
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/providers/rawbytes"
 	"github.com/knadh/koanf/v2"
 
 	"cpgo"
+	"cpgo/githubapi"
+	"cpgo/gobuild"
+	"cpgo/parcaio"
+	"cpgo/pprofio"
+	"cpgo/profilediff"
+	"cpgo/s3io"
+	"cpgo/secretio"
 )
 
+const (
+	secretProviderEnv   = "env"
+	secretProviderFile  = "file"
+	secretProviderVault = "vault"
+)
+
+const (
+	profileSourceHTTP  = "http"
+	profileSourceS3    = "s3"
+	profileSourceParca = "parca"
+	profileSourceFile  = "file"
+)
+
+const (
+	providerGitHub = "github"
+	providerGitLab = "gitlab"
+	providerGitea  = "gitea"
+)
+
+const (
+	collectionSingle  = "single"
+	collectionTwoStep = "two_step"
+)
+
+const (
+	profileTransformIdentity = "identity"
+	profileTransformCommand  = "command"
+)
+
+// profileTransforms maps profile.transform names to converters producing pprof bytes.
+// Builds supporting another payload format register their converter here.
+var profileTransforms = map[string]cpgo.ProfileTransform{
+	profileTransformIdentity: pprofio.IdentityTransform{},
+}
+
 const (
 	defaultOperationTimeout = 2 * time.Minute
 	defaultProfileTimeout   = 45 * time.Second
 	defaultGitHubTimeout    = 30 * time.Second
+	defaultGitLabTimeout    = 30 * time.Second
+	defaultGiteaTimeout     = 30 * time.Second
+	defaultStatusContext    = "cpgo/pgo-profile"
+	defaultConcurrency      = 4
 )
 
 // File is the root cpgo runtime configuration document.
 type File struct {
-	Profile     Profile
-	Repository  Repository
-	GitHub      GitHub
-	PullRequest PullRequest
-	Commit      Commit
-	Runtime     Runtime
+	Provider     string `yaml:"provider"`
+	ProxyURL     string `yaml:"proxy_url"`
+	Profile      Profile
+	Repository   Repository
+	Repositories []RepositoryEntry `yaml:"repositories"`
+	GitHub       GitHub
+	GitLab       GitLab      `yaml:"gitlab"`
+	Gitea        Gitea       `yaml:"gitea"`
+	PullRequest  PullRequest `yaml:"pull_request"`
+	Commit       Commit
+	ExtraFiles   []ExtraFile `yaml:"extra_files"`
+	DiffRules    []DiffRule  `yaml:"diff_rules"`
+	Verify       Verify      `yaml:"verify"`
+	Secrets      Secrets     `yaml:"secrets"`
+	Serve        Serve       `yaml:"serve"`
+	Runtime      Runtime
+}
+
+// Serve configures the HTTP trigger used by the serve command.
+type Serve struct {
+	Listen  string  `yaml:"listen"`
+	Webhook Webhook `yaml:"webhook"`
+}
+
+// Webhook configures signature verification and event filtering for serve triggers.
+type Webhook struct {
+	Secret    string   `yaml:"secret"`
+	SecretRef string   `yaml:"secret_ref"`
+	Events    []string `yaml:"events"`
 }
 
 // Profile configures CPU profile collection from the target service.
 type Profile struct {
-	URL     string            `yaml:"url"`
-	Seconds int               `yaml:"seconds"`
-	Timeout string            `yaml:"timeout"`
-	Headers map[string]string `yaml:"headers"`
+	URL                string            `yaml:"url"`
+	Type               string            `yaml:"type"`
+	Seconds            *int              `yaml:"seconds"`
+	Samples            int               `yaml:"samples"`
+	MinSamples         int               `yaml:"min_samples"`
+	DropOutliers       int               `yaml:"drop_outliers"`
+	MinChange          float64           `yaml:"min_change"`
+	MergeWithBase      bool              `yaml:"merge_with_base"`
+	Decay              float64           `yaml:"decay"`
+	Timeout            string            `yaml:"timeout"`
+	MaxBytes           int64             `yaml:"max_bytes"`
+	Method             string            `yaml:"method"`
+	Body               string            `yaml:"body"`
+	ProxyURL           string            `yaml:"proxy_url"`
+	TLS                ProfileTLS        `yaml:"tls"`
+	InsecureSkipVerify bool              `yaml:"insecure_skip_verify"`
+	AllowedHosts       []string          `yaml:"allowed_hosts"`
+	BlockPrivate       bool              `yaml:"block_private"`
+	Headers            map[string]string `yaml:"headers"`
+	Strictness         string            `yaml:"strictness"`
+	Validation         ProfileValidation `yaml:"validation"`
+	Source             string            `yaml:"source"`
+	Transform          string            `yaml:"transform"`
+	CommandTransform   CommandTransform  `yaml:"command_transform"`
+	S3                 S3                `yaml:"s3"`
+	Parca              Parca             `yaml:"parca"`
+	Collection         string            `yaml:"collection"`
+	TwoStep            TwoStep           `yaml:"two_step"`
+	Retry              ProfileRetry      `yaml:"retry"`
+}
+
+// CommandTransform configures the shell command selected by profile.transform: command.
+type CommandTransform struct {
+	Command string `yaml:"command"`
+	Timeout string `yaml:"timeout"`
+}
+
+// ProfileTLS configures client certificates and a private CA for HTTP profile endpoints.
+type ProfileTLS struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	CAFile   string `yaml:"ca_file"`
+}
+
+// ProfileRetry configures retries of transient profile fetch failures.
+type ProfileRetry struct {
+	MaxAttempts int    `yaml:"max_attempts"`
+	BaseDelay   string `yaml:"base_delay"`
+	MaxDelay    string `yaml:"max_delay"`
+}
+
+// TwoStep configures start-then-collect capture for asynchronous profiling backends.
+type TwoStep struct {
+	StartMethod  string `yaml:"start_method"`
+	FetchURL     string `yaml:"fetch_url"`
+	FetchMethod  string `yaml:"fetch_method"`
+	PollInterval string `yaml:"poll_interval"`
+	CancelURL    string `yaml:"cancel_url"`
+	CancelMethod string `yaml:"cancel_method"`
+}
+
+// S3 configures the object store used when profile source is s3.
+type S3 struct {
+	Region   string `yaml:"region"`
+	Endpoint string `yaml:"endpoint"`
+}
+
+// ProfileValidation configures optional checks applied to fetched profiles.
+type ProfileValidation struct {
+	DefaultSampleType      string   `yaml:"default_sample_type"`
+	RequireCPUSampleType   *bool    `yaml:"require_cpu_sample_type"`
+	CPUSampleTypes         []string `yaml:"cpu_sample_types"`
+	MinSamples             *int64   `yaml:"min_samples"`
+	MaxAge                 string   `yaml:"max_age"`
+	MinSymbolizedFraction  *float64 `yaml:"min_symbolized_fraction"`
+	MaxSingleFunctionRatio *float64 `yaml:"max_single_function_ratio"`
+	MinDurationFraction    float64  `yaml:"min_duration_fraction"`
+	Command                string   `yaml:"command"`
+	CommandTimeout         string   `yaml:"command_timeout"`
 }
 
 // Repository configures where cpgo writes profile updates.
 type Repository struct {
-	Owner      string `yaml:"owner"`
-	Name       string `yaml:"name"`
-	PGOPath    string `yaml:"pgo_path"`
-	BaseBranch string `yaml:"base_branch"`
-	HeadBranch string `yaml:"head_branch"`
+	Owner               string   `yaml:"owner"`
+	Name                string   `yaml:"name"`
+	PGOPath             string   `yaml:"pgo_path"`
+	PGOPaths            []string `yaml:"pgo_paths"`
+	BaseBranch          string   `yaml:"base_branch"`
+	HeadBranch          string   `yaml:"head_branch"`
+	RequireExistingBase bool     `yaml:"require_existing_base"`
+	TagProfiles         bool     `yaml:"tag_profiles"`
+	MaxShrinkRatio      float64  `yaml:"max_shrink_ratio"`
+	AllowShrink         bool     `yaml:"allow_shrink"`
+	BranchPerRun        bool     `yaml:"branch_per_run"`
+	InstanceID          string   `yaml:"instance_id"`
+	MinChangeRatio      float64  `yaml:"min_change_ratio"`
+	MinChangeSamples    int64    `yaml:"min_change_samples"`
+	ChangeThresholdMode string   `yaml:"change_threshold_mode"`
+	HashInFilename      bool     `yaml:"hash_in_filename"`
+	ForcePush           *bool    `yaml:"force_push"`
+	CleanupStaleBranch  bool     `yaml:"cleanup_stale_branch"`
+	CompareRef          string   `yaml:"compare_ref"`
+	CommitToBase        bool     `yaml:"commit_to_base"`
+	RebaseOnConflict    bool     `yaml:"rebase_on_conflict"`
+	PreflightChecks     bool     `yaml:"preflight_checks"`
+}
+
+// RepositoryEntry is one repository of a multi-repository config, with its own profile url.
+type RepositoryEntry struct {
+	Repository `yaml:",squash"`
+	ProfileURL string `yaml:"profile_url"`
+}
+
+// Parca configures the merged profile query used when profile source is parca.
+type Parca struct {
+	ServerURL string `yaml:"server_url"`
+	Query     string `yaml:"query"`
+	Range     string `yaml:"range"`
+	Token     string `yaml:"token"`
 }
 
 // GitHub configures authentication and API timeout behavior.
 type GitHub struct {
-	AppID          int64  `yaml:"app_id"`
-	PrivateKeyPath string `yaml:"private_key_path"`
-	Token          string `yaml:"token"`
-	Timeout        string `yaml:"timeout"`
+	AppID          int64        `yaml:"app_id"`
+	PrivateKeyPath string       `yaml:"private_key_path"`
+	PrivateKeyRef  string       `yaml:"private_key_ref"`
+	Token          string       `yaml:"token"`
+	TokenRef       string       `yaml:"token_ref"`
+	TokenPath      string       `yaml:"token_path"`
+	TokenEnv       string       `yaml:"token_env"`
+	Tokens         []string     `yaml:"tokens"`
+	BaseURL        string       `yaml:"base_url"`
+	UploadURL      string       `yaml:"upload_url"`
+	Timeout        string       `yaml:"timeout"`
+	ReportStatus   ReportStatus `yaml:"report_status"`
+}
+
+// GitLab configures authentication and API timeout behavior when provider is gitlab.
+type GitLab struct {
+	BaseURL  string `yaml:"base_url"`
+	Token    string `yaml:"token"`
+	TokenRef string `yaml:"token_ref"`
+	Timeout  string `yaml:"timeout"`
+}
+
+// Gitea configures authentication and API timeout behavior when provider is gitea.
+type Gitea struct {
+	BaseURL  string `yaml:"base_url"`
+	Token    string `yaml:"token"`
+	TokenRef string `yaml:"token_ref"`
+	Timeout  string `yaml:"timeout"`
+}
+
+// ReportStatus configures the commit status cpgo sets on the base branch head.
+type ReportStatus struct {
+	Enabled bool   `yaml:"enabled"`
+	Context string `yaml:"context"`
 }
 
 // PullRequest configures metadata for cpgo-managed pull requests.
 type PullRequest struct {
-	Title           string `yaml:"title"`
-	Body            string `yaml:"body"`
-	ManagedByMarker string `yaml:"managed_by_marker"`
+	Title                  string      `yaml:"title"`
+	Body                   string      `yaml:"body"`
+	ManagedByMarker        string      `yaml:"managed_by_marker"`
+	ManagedByMarkerAliases []string    `yaml:"managed_by_marker_aliases"`
+	Labels                 []string    `yaml:"labels"`
+	Reviewers              []string    `yaml:"reviewers"`
+	TeamReviewers          []string    `yaml:"team_reviewers"`
+	Assignees              []string    `yaml:"assignees"`
+	MinUpdateInterval      string      `yaml:"min_update_interval"`
+	HotFunctionLabels      bool        `yaml:"hot_function_labels"`
+	Draft                  bool        `yaml:"draft"`
+	DraftBelowChangeRatio  float64     `yaml:"draft_below_change_ratio"`
+	CloseSuperseded        bool        `yaml:"close_superseded"`
+	TextDiff               TextDiff    `yaml:"text_diff"`
+	DiffSummary            DiffSummary `yaml:"diff_summary"`
+}
+
+// TextDiff configures the profile text diff embedded in pull request bodies.
+type TextDiff struct {
+	Enabled      bool `yaml:"enabled"`
+	TopFunctions int  `yaml:"top_functions"`
+	MaxBytes     int  `yaml:"max_bytes"`
+}
+
+// DiffSummary configures the hottest-function change table embedded in pull request bodies.
+type DiffSummary struct {
+	Enabled      bool `yaml:"enabled"`
+	TopFunctions int  `yaml:"top_functions"`
 }
 
 // Commit configures commit metadata for generated updates.
 type Commit struct {
-	Message string `yaml:"message"`
+	Message        string        `yaml:"message"`
+	AuthorName     string        `yaml:"author_name"`
+	AuthorEmail    string        `yaml:"author_email"`
+	CommitterName  string        `yaml:"committer_name"`
+	CommitterEmail string        `yaml:"committer_email"`
+	DCO            bool          `yaml:"dco"`
+	Provenance     bool          `yaml:"provenance"`
+	Signing        CommitSigning `yaml:"signing"`
+}
+
+// CommitSigning configures signed commits, attributed to the committer identity.
+type CommitSigning struct {
+	Enabled       bool   `yaml:"enabled"`
+	Format        string `yaml:"format"`
+	KeyPath       string `yaml:"key_path"`
+	KeyID         string `yaml:"key_id"`
+	PassphraseRef string `yaml:"passphrase_ref"`
+}
+
+// ExtraFile adds a static or command-generated file to the refresh commit.
+type ExtraFile struct {
+	Path    string `yaml:"path"`
+	Content string `yaml:"content"`
+	Command string `yaml:"command"`
+}
+
+// DiffRule asserts how a function must appear in a new profile before it is committed.
+type DiffRule struct {
+	Function      string  `yaml:"function"`
+	Present       bool    `yaml:"present"`
+	MinCumPercent float64 `yaml:"min_cum_percent"`
+}
+
+// Verify configures post-commit checks of the pushed profile.
+type Verify struct {
+	Build VerifyBuild `yaml:"build"`
+}
+
+// VerifyBuild configures a `go build -pgo` check in a local checkout.
+type VerifyBuild struct {
+	Enabled  bool     `yaml:"enabled"`
+	Dir      string   `yaml:"dir"`
+	Command  string   `yaml:"command"`
+	Packages []string `yaml:"packages"`
+	Revert   bool     `yaml:"revert"`
+}
+
+// Secrets selects where token and key references are resolved.
+type Secrets struct {
+	Provider string `yaml:"provider"`
+	Vault    Vault  `yaml:"vault"`
+}
+
+// Vault configures the Vault secret provider; empty values fall back to VAULT_ADDR and VAULT_TOKEN.
+type Vault struct {
+	Address string `yaml:"address"`
+	Token   string `yaml:"token"`
 }
 
 // Runtime configures top-level execution timing.
 type Runtime struct {
-	Timeout string `yaml:"timeout"`
+	Timeout     string   `yaml:"timeout"`
+	Concurrency int      `yaml:"concurrency"`
+	Cache       Cache    `yaml:"cache"`
+	Schedule    Schedule `yaml:"schedule"`
+}
+
+// Schedule restricts runs to a weekly window; runs outside it end as a noop.
+type Schedule struct {
+	Timezone string   `yaml:"timezone"`
+	Weekdays []string `yaml:"weekdays"`
+	Hours    string   `yaml:"hours"`
+}
+
+// Cache configures the in-process cache of already committed profiles.
+type Cache struct {
+	Enabled bool   `yaml:"enabled"`
+	Size    int    `yaml:"size"`
+	TTL     string `yaml:"ttl"`
 }
 
-// Load reads and decodes a cpgo configuration file from disk.
+// stdinConfigPath is the config path that reads the configuration from standard input.
+const stdinConfigPath = "-"
+
+// Load reads and decodes a cpgo configuration file from disk, or YAML from standard input for "-".
+// The file extension selects the format: .toml and .json files are parsed as such and any
+// other file as YAML. ${VAR} and $VAR references in string values are expanded from the
+// environment, and $$ stands for a literal dollar sign.
 func Load(path string) (File, error) {
 	if strings.TrimSpace(path) == "" {
 		return File{}, fmt.Errorf("config path is required")
 	}
 
+	if path == stdinConfigPath {
+		return LoadReader(os.Stdin)
+	}
+
+	return decodeConfig(file.Provider(path), configParser(path))
+}
+
+// LoadReader decodes a YAML cpgo configuration read from reader, such as a generated config piped in CI.
+func LoadReader(reader io.Reader) (File, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return File{}, fmt.Errorf("read config: %w", err)
+	}
+
+	return decodeConfig(rawbytes.Provider(content), yaml.Parser())
+}
+
+// configParser picks the config parser from the file extension, defaulting to YAML.
+func configParser(path string) koanf.Parser {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		return toml.Parser()
+	case ".json":
+		return json.Parser()
+	default:
+		return yaml.Parser()
+	}
+}
+
+// decodeConfig parses the config from provider, expands environment references and maps the result onto File.
+// Every format decodes into the same nested map, so the yaml struct tags name the keys for all of them.
+func decodeConfig(provider koanf.Provider, parser koanf.Parser) (File, error) {
+	raw := koanf.New(".")
+	if err := raw.Load(provider, parser); err != nil {
+		return File{}, fmt.Errorf("decode config file: %w", err)
+	}
+
+	expanded, err := expandEnv(raw.Raw(), "", os.LookupEnv)
+	if err != nil {
+		return File{}, fmt.Errorf("expand config file: %w", err)
+	}
+
 	k := koanf.New(".")
-	if err := k.Load(file.Provider(path), yaml.Parser()); err != nil {
+	if err := k.Load(configMap(expanded.(map[string]any)), nil); err != nil {
 		return File{}, fmt.Errorf("decode config file: %w", err)
 	}
 
@@ -92,39 +453,478 @@ func Load(path string) (File, error) {
 	return cfg, nil
 }
 
+// Validate checks the whole configuration in one pass and reports every problem found,
+// each prefixed with the path of the offending field.
+func (cfg File) Validate() error {
+	var problems []error
+	reported := make(map[string]bool)
+	check := func(fieldPath string, err error) {
+		if err != nil {
+			reported[fieldPath] = true
+			problems = append(problems, fmt.Errorf("%s: %w", fieldPath, err))
+		}
+	}
+
+	provider, err := Provider(cfg)
+	check("provider", err)
+
+	if len(cfg.Repositories) == 0 {
+		check("profile.url", validateProfileURL(cfg))
+		requireRepository("repository", cfg.Repository, check)
+		if provider == providerGitLab {
+			checkGitLabRepository("repository", cfg.Repository, check)
+		}
+		if provider == providerGitea {
+			checkGiteaRepository("repository", cfg.Repository, check)
+		}
+	} else if strings.TrimSpace(cfg.Repository.Owner) != "" || strings.TrimSpace(cfg.Repository.Name) != "" {
+		check("repository", fmt.Errorf("must be empty when repositories is set"))
+	}
+
+	isSharedURLChecked := false
+	for index, entry := range cfg.Repositories {
+		fieldPath := fmt.Sprintf("repositories[%d]", index)
+		requireRepository(fieldPath, entry.Repository, check)
+		if provider == providerGitLab {
+			checkGitLabRepository(fieldPath, entry.Repository, check)
+		}
+		if provider == providerGitea {
+			checkGiteaRepository(fieldPath, entry.Repository, check)
+		}
+
+		entryConfig := cfg
+		if strings.TrimSpace(entry.ProfileURL) != "" {
+			entryConfig.Profile.URL = entry.ProfileURL
+			check(fieldPath+".profile_url", validateProfileURL(entryConfig))
+		} else if !isSharedURLChecked {
+			check("profile.url", validateProfileURL(entryConfig))
+			isSharedURLChecked = true
+		}
+	}
+
+	if cfg.Profile.Seconds != nil && *cfg.Profile.Seconds < 0 {
+		check("profile.seconds", fmt.Errorf("must not be negative"))
+	}
+
+	_, err = ProfileSource(cfg)
+	check("profile.source", err)
+	_, err = ProfileCollection(cfg)
+	check("profile.collection", err)
+	_, err = ProfileTransform(cfg)
+	check("profile.transform", err)
+	check("profile.method", validateProfileMethod(cfg))
+	if collection, _ := ProfileCollection(cfg); collection == collectionTwoStep {
+		// The two-step fetcher neither retries nor requests a profile type.
+		retry := cfg.Profile.Retry
+		if retry.MaxAttempts != 0 || strings.TrimSpace(retry.BaseDelay) != "" || strings.TrimSpace(retry.MaxDelay) != "" {
+			check("profile.retry", fmt.Errorf("only applies to single collection"))
+		}
+
+		if profileType := ProfileType(cfg); profileType != "" && profileType != cpgo.ProfileTypeCPU {
+			check("profile.type", fmt.Errorf("%s profiles are not supported with two_step collection", profileType))
+		}
+	}
+	_, err = parseDurationOrDefault(cfg.Profile.Timeout, defaultProfileTimeout, "duration")
+	check("profile.timeout", err)
+	_, err = ProfileTLSConfig(cfg)
+	check("profile.tls", err)
+	_, err = parseProxyURL(cfg.Profile.ProxyURL)
+	check("profile.proxy_url", err)
+	if cfg.Profile.BlockPrivate && (strings.TrimSpace(cfg.Profile.ProxyURL) != "" || strings.TrimSpace(cfg.ProxyURL) != "") {
+		// Through a proxy only the proxy address is dialed, so the profile host could not be checked.
+		check("profile.block_private", fmt.Errorf("cannot be combined with proxy_url or profile.proxy_url"))
+	}
+	_, err = TwoStepOptions(cfg)
+	check("profile.two_step", err)
+	_, err = FetchRetry(cfg)
+	check("profile.retry", err)
+	if cfg.Profile.MaxBytes < 0 {
+		check("profile.max_bytes", fmt.Errorf("must not be negative"))
+	}
+	_, err = ProfileMerger(cfg)
+	check("profile.decay", err)
+	_, err = ParcaOptions(cfg, nil)
+	check("profile.parca", err)
+	_, err = ValidatorOptions(cfg)
+	check("profile.validation", err)
+	_, err = parseDurationOrDefault(cfg.Profile.Validation.CommandTimeout, 0, "duration")
+	check("profile.validation.command_timeout", err)
+
+	_, err = parseDurationOrDefault(cfg.PullRequest.MinUpdateInterval, 0, "duration")
+	check("pull_request.min_update_interval", err)
+	_, err = parseDurationOrDefault(cfg.GitHub.Timeout, defaultGitHubTimeout, "duration")
+	check("github.timeout", err)
+	_, err = parseDurationOrDefault(cfg.GitLab.Timeout, defaultGitLabTimeout, "duration")
+	check("gitlab.timeout", err)
+	_, err = parseDurationOrDefault(cfg.Gitea.Timeout, defaultGiteaTimeout, "duration")
+	check("gitea.timeout", err)
+	_, err = parseProxyURL(cfg.ProxyURL)
+	check("proxy_url", err)
+	_, err = DiffPolicy(cfg)
+	check("diff_rules", err)
+	_, err = SecretProvider(cfg)
+	check("secrets.provider", err)
+	_, err = OperationTimeout(cfg)
+	check("runtime.timeout", err)
+	_, err = Concurrency(cfg)
+	check("runtime.concurrency", err)
+	_, err = RunCache(cfg)
+	check("runtime.cache", err)
+	_, err = RunSchedule(cfg)
+	check("runtime.schedule", err)
+
+	if provider == providerGitea && cfg.Verify.Build.Enabled && cfg.Verify.Build.Revert {
+		check("verify.build.revert", fmt.Errorf("is not supported with provider gitea, which cannot force-push branches"))
+	}
+
+	if provider == providerGitLab {
+		// GitLab would otherwise only reject these when the commit is written, after the fetch.
+		if strings.TrimSpace(cfg.Commit.CommitterName) != "" || strings.TrimSpace(cfg.Commit.CommitterEmail) != "" {
+			check("commit.committer_name", fmt.Errorf("is not supported with provider gitlab, which commits as the token user"))
+		}
+
+		if cfg.Commit.DCO {
+			check("commit.dco", fmt.Errorf("is not supported with provider gitlab, which commits as the token user"))
+		}
+	}
+
+	// The run request checks the fields cpgo defaults or combines; a field reported above is not repeated.
+	for index, repositoryConfig := range RepositoryConfigs(cfg) {
+		requestProblems, _ := mapRunRequest(repositoryConfig).Validate().(interface{ Unwrap() []error })
+		if requestProblems == nil {
+			continue
+		}
+
+		for _, problem := range requestProblems.Unwrap() {
+			var fieldErr *cpgo.FieldError
+			if !errors.As(problem, &fieldErr) {
+				continue
+			}
+
+			fieldPath := fieldErr.Field
+			if len(cfg.Repositories) > 0 {
+				fieldPath = repositoryEntryFieldPath(index, cfg.Repositories[index], fieldPath)
+			}
+
+			if !reported[fieldPath] {
+				check(fieldPath, fieldErr.Err)
+			}
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// repositoryEntryFieldPath moves a run request field path under the repositories entry
+// at index when the entry sets that field.
+func repositoryEntryFieldPath(index int, entry RepositoryEntry, fieldPath string) string {
+	if repositoryField, ok := strings.CutPrefix(fieldPath, "repository."); ok {
+		return fmt.Sprintf("repositories[%d].%s", index, repositoryField)
+	}
+
+	if fieldPath == "profile.url" && strings.TrimSpace(entry.ProfileURL) != "" {
+		return fmt.Sprintf("repositories[%d].profile_url", index)
+	}
+
+	return fieldPath
+}
+
+// checkGitLabRepository rejects repository settings the GitLab commits API cannot write.
+func checkGitLabRepository(fieldPath string, repository Repository, check func(string, error)) {
+	if repository.HashInFilename {
+		check(fieldPath+".hash_in_filename", fmt.Errorf("is not supported with provider gitlab, which cannot write symlinks"))
+	}
+}
+
+// checkGiteaRepository requires force_push: false for head branch updates, since the Gitea
+// contents API can only add commits on top of an existing branch, and rejects hashed filenames,
+// since it cannot write their symlink.
+func checkGiteaRepository(fieldPath string, repository Repository, check func(string, error)) {
+	if repository.HashInFilename {
+		check(fieldPath+".hash_in_filename", fmt.Errorf("is not supported with provider gitea, which cannot write symlinks"))
+	}
+
+	if repository.CommitToBase {
+		return
+	}
+
+	if repository.ForcePush == nil || *repository.ForcePush {
+		check(fieldPath+".force_push", fmt.Errorf("must be false with provider gitea, which cannot force-push branches"))
+	}
+}
+
+// requireRepository reports a missing owner or name of the repository at fieldPath.
+func requireRepository(fieldPath string, repository Repository, check func(string, error)) {
+	if strings.TrimSpace(repository.Owner) == "" {
+		check(fieldPath+".owner", fmt.Errorf("is required"))
+	}
+
+	if strings.TrimSpace(repository.Name) == "" {
+		check(fieldPath+".name", fmt.Errorf("is required"))
+	}
+}
+
+// RepositoryConfigs splits a multi-repository config into one config per repository,
+// each with its repository and profile url in place; a single-repository config is returned as is.
+func RepositoryConfigs(cfg File) []File {
+	if len(cfg.Repositories) == 0 {
+		return []File{cfg}
+	}
+
+	configs := make([]File, 0, len(cfg.Repositories))
+	for _, entry := range cfg.Repositories {
+		repositoryConfig := cfg
+		repositoryConfig.Repository = entry.Repository
+		repositoryConfig.Repositories = nil
+		if profileURL := strings.TrimSpace(entry.ProfileURL); profileURL != "" {
+			repositoryConfig.Profile.URL = profileURL
+		}
+
+		configs = append(configs, repositoryConfig)
+	}
+
+	return configs
+}
+
+// validateProfileMethod checks that a custom method or body is a supported request
+// of a single http collection, the only fetcher that sends them.
+func validateProfileMethod(cfg File) error {
+	method := strings.ToUpper(strings.TrimSpace(cfg.Profile.Method))
+	if method == "" && cfg.Profile.Body == "" {
+		return nil
+	}
+
+	switch method {
+	case "", http.MethodGet, http.MethodPost:
+	default:
+		return fmt.Errorf("unsupported method %q, want GET or POST", cfg.Profile.Method)
+	}
+
+	if cfg.Profile.Body != "" && method != http.MethodPost {
+		return fmt.Errorf("body requires the POST method")
+	}
+
+	source, _ := ProfileSource(cfg)
+	collection, _ := ProfileCollection(cfg)
+	if source != profileSourceHTTP || collection != collectionSingle {
+		return fmt.Errorf("method and body only apply to single http collection")
+	}
+
+	return nil
+}
+
+// validateProfileURL checks that the profile url, or the parca server url standing in for it,
+// is set and carries a scheme and host, or a path for file urls.
+func validateProfileURL(cfg File) error {
+	rawURL := profileURLString(cfg)
+	if rawURL == "" {
+		return fmt.Errorf("is required")
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	if parsed.Scheme == profileSourceFile {
+		if parsed.Path == "" {
+			return fmt.Errorf("file url must include a path")
+		}
+
+		return nil
+	}
+
+	if parsed.Scheme == "" || parsed.Host == "" {
+		return fmt.Errorf("must include scheme and host")
+	}
+
+	return newProfileHostGuard(cfg).checkURL(parsed)
+}
+
+// profileURLString returns the profile url, or the parca server url standing in for it.
+func profileURLString(cfg File) string {
+	rawURL := strings.TrimSpace(cfg.Profile.URL)
+	if rawURL == "" && strings.EqualFold(strings.TrimSpace(cfg.Profile.Source), profileSourceParca) {
+		rawURL = strings.TrimSpace(cfg.Profile.Parca.ServerURL)
+	}
+
+	return rawURL
+}
+
 // BuildRunRequest maps configuration data into a validated run request.
 func BuildRunRequest(cfg File) (cpgo.RunRequest, error) {
-	profileURLString := strings.TrimSpace(cfg.Profile.URL)
-	if profileURLString == "" {
+	rawURL := profileURLString(cfg)
+	if rawURL == "" {
 		return cpgo.RunRequest{}, fmt.Errorf("profile url is required")
 	}
 
-	profileURL, err := url.Parse(profileURLString)
+	profileURL, err := url.Parse(rawURL)
 	if err != nil {
 		return cpgo.RunRequest{}, fmt.Errorf("parse profile url: %w", err)
 	}
 
+	if err := newProfileHostGuard(cfg).checkURL(profileURL); err != nil {
+		return cpgo.RunRequest{}, err
+	}
+
+	if cfg.Profile.Seconds != nil && *cfg.Profile.Seconds < 0 {
+		return cpgo.RunRequest{}, fmt.Errorf("profile seconds must not be negative")
+	}
+
+	if _, err := parseDurationOrDefault(cfg.PullRequest.MinUpdateInterval, 0, "pull request min update interval"); err != nil {
+		return cpgo.RunRequest{}, err
+	}
+
+	return mapRunRequest(cfg), nil
+}
+
+// mapRunRequest maps configuration data into a run request without validating it;
+// a missing or unparsable profile url or min update interval is left unset.
+func mapRunRequest(cfg File) cpgo.RunRequest {
+	var profileURL *url.URL
+	if rawURL := profileURLString(cfg); rawURL != "" {
+		profileURL, _ = url.Parse(rawURL)
+	}
+
+	profile := cpgo.ProfileSettings{
+		URL:           profileURL,
+		Headers:       cloneHeaders(cfg.Profile.Headers),
+		Type:          ProfileType(cfg),
+		Method:        cfg.Profile.Method,
+		MergeWithBase: cfg.Profile.MergeWithBase,
+	}
+	if cfg.Profile.Body != "" {
+		profile.Body = []byte(cfg.Profile.Body)
+	}
+	if cfg.Profile.Seconds != nil {
+		// An explicit zero selects an instantaneous profile fetched without a seconds query.
+		profile.Seconds = *cfg.Profile.Seconds
+		profile.OmitSeconds = *cfg.Profile.Seconds == 0
+	}
+
+	minUpdateInterval, _ := parseDurationOrDefault(cfg.PullRequest.MinUpdateInterval, 0, "pull request min update interval")
+
 	return cpgo.RunRequest{
-		Profile: cpgo.ProfileSettings{
-			URL:     profileURL,
-			Seconds: cfg.Profile.Seconds,
-			Headers: cloneHeaders(cfg.Profile.Headers),
-		},
+		Profile: profile,
 		Repository: cpgo.RepositorySettings{
-			Owner:      strings.TrimSpace(cfg.Repository.Owner),
-			Name:       strings.TrimSpace(cfg.Repository.Name),
-			PGOPath:    strings.TrimSpace(cfg.Repository.PGOPath),
-			BaseBranch: strings.TrimSpace(cfg.Repository.BaseBranch),
-			HeadBranch: strings.TrimSpace(cfg.Repository.HeadBranch),
+			Owner:               strings.TrimSpace(cfg.Repository.Owner),
+			Name:                strings.TrimSpace(cfg.Repository.Name),
+			PGOPath:             strings.TrimSpace(cfg.Repository.PGOPath),
+			PGOPaths:            cfg.Repository.PGOPaths,
+			BaseBranch:          strings.TrimSpace(cfg.Repository.BaseBranch),
+			HeadBranch:          strings.TrimSpace(cfg.Repository.HeadBranch),
+			RequireExistingBase: cfg.Repository.RequireExistingBase,
+			TagProfiles:         cfg.Repository.TagProfiles,
+			MaxShrinkRatio:      cfg.Repository.MaxShrinkRatio,
+			AllowShrink:         cfg.Repository.AllowShrink,
+			BranchPerRun:        cfg.Repository.BranchPerRun,
+			InstanceID:          strings.TrimSpace(cfg.Repository.InstanceID),
+			MinChangeRatio:      cfg.Repository.MinChangeRatio,
+			MinChangeSamples:    cfg.Repository.MinChangeSamples,
+			MinChange:           cfg.Profile.MinChange,
+			ChangeThresholdMode: strings.TrimSpace(cfg.Repository.ChangeThresholdMode),
+			HashInFilename:      cfg.Repository.HashInFilename,
+			FastForwardOnly:     cfg.Repository.ForcePush != nil && !*cfg.Repository.ForcePush,
+			CleanupStaleBranch:  cfg.Repository.CleanupStaleBranch,
+			CompareRef:          cfg.Repository.CompareRef,
+			CommitToBase:        cfg.Repository.CommitToBase,
+			RebaseOnConflict:    cfg.Repository.RebaseOnConflict,
+			PreflightChecks:     cfg.Repository.PreflightChecks,
 		},
 		PullRequest: cpgo.PullRequestSettings{
-			Title:           strings.TrimSpace(cfg.PullRequest.Title),
-			Body:            strings.TrimSpace(cfg.PullRequest.Body),
-			ManagedByMarker: strings.TrimSpace(cfg.PullRequest.ManagedByMarker),
+			Title:                  strings.TrimSpace(cfg.PullRequest.Title),
+			Body:                   strings.TrimSpace(cfg.PullRequest.Body),
+			ManagedByMarker:        strings.TrimSpace(cfg.PullRequest.ManagedByMarker),
+			ManagedByMarkerAliases: trimmedStrings(cfg.PullRequest.ManagedByMarkerAliases),
+			Labels:                 trimmedStrings(cfg.PullRequest.Labels),
+			Reviewers:              trimmedStrings(cfg.PullRequest.Reviewers),
+			TeamReviewers:          trimmedStrings(cfg.PullRequest.TeamReviewers),
+			Assignees:              trimmedStrings(cfg.PullRequest.Assignees),
+			MinUpdateInterval:      minUpdateInterval,
+			Draft:                  cfg.PullRequest.Draft,
+			DraftBelowChangeRatio:  cfg.PullRequest.DraftBelowChangeRatio,
+			CloseSuperseded:        cfg.PullRequest.CloseSuperseded,
+			HideComparison:         !cfg.PullRequest.TextDiff.Enabled,
 		},
 		Commit: cpgo.CommitSettings{
-			Message: strings.TrimSpace(cfg.Commit.Message),
+			Message:            strings.TrimSpace(cfg.Commit.Message),
+			AuthorName:         strings.TrimSpace(cfg.Commit.AuthorName),
+			AuthorEmail:        strings.TrimSpace(cfg.Commit.AuthorEmail),
+			CommitterName:      strings.TrimSpace(cfg.Commit.CommitterName),
+			CommitterEmail:     strings.TrimSpace(cfg.Commit.CommitterEmail),
+			DCO:                cfg.Commit.DCO,
+			ProvenanceTrailers: cfg.Commit.Provenance,
+		},
+		Verify: cpgo.VerifySettings{
+			RevertOnFailure: cfg.Verify.Build.Enabled && cfg.Verify.Build.Revert,
+		},
+		Status: cpgo.StatusSettings{
+			Context: StatusContext(cfg),
 		},
+	}
+}
+
+// GitHubEnterpriseURLs maps the optional GitHub Enterprise Server endpoints.
+func GitHubEnterpriseURLs(cfg File) githubapi.EnterpriseURLs {
+	return githubapi.EnterpriseURLs{
+		BaseURL:   strings.TrimSpace(cfg.GitHub.BaseURL),
+		UploadURL: strings.TrimSpace(cfg.GitHub.UploadURL),
+	}
+}
+
+// StatusContext resolves the commit status context, empty when status reporting is disabled.
+func StatusContext(cfg File) string {
+	if !cfg.GitHub.ReportStatus.Enabled {
+		return ""
+	}
+
+	if statusContext := strings.TrimSpace(cfg.GitHub.ReportStatus.Context); statusContext != "" {
+		return statusContext
+	}
+
+	return defaultStatusContext
+}
+
+// ServeListen resolves the serve listen address with defaults.
+func ServeListen(cfg File) string {
+	if listen := strings.TrimSpace(cfg.Serve.Listen); listen != "" {
+		return listen
+	}
+
+	return defaultServeListen
+}
+
+// ServeWebhookOptions resolves the webhook secret and event filters for serve triggers.
+func ServeWebhookOptions(cfg File) (WebhookOptions, error) {
+	webhook := cfg.Serve.Webhook
+	secret := strings.TrimSpace(webhook.Secret)
+	if secretRef := strings.TrimSpace(webhook.SecretRef); secretRef != "" {
+		if secret != "" {
+			return WebhookOptions{}, fmt.Errorf("serve webhook secret and secret_ref are mutually exclusive")
+		}
+
+		secrets, err := SecretProvider(cfg)
+		if err != nil {
+			return WebhookOptions{}, err
+		}
+
+		resolved, err := secrets.Get(secretRef)
+		if err != nil {
+			return WebhookOptions{}, fmt.Errorf("resolve serve webhook secret: %w", err)
+		}
+
+		secret = strings.TrimSpace(string(resolved))
+	}
+
+	for _, event := range webhook.Events {
+		if name, _, _ := strings.Cut(strings.TrimSpace(event), ":"); name == "" {
+			return WebhookOptions{}, fmt.Errorf("serve webhook event %q must name an event", event)
+		}
+	}
+
+	return WebhookOptions{
+		Secret: []byte(secret),
+		Events: webhook.Events,
 	}, nil
 }
 
@@ -133,6 +933,63 @@ func OperationTimeout(cfg File) (time.Duration, error) {
 	return parseDurationOrDefault(cfg.Runtime.Timeout, defaultOperationTimeout, "runtime timeout")
 }
 
+// Concurrency resolves how many repositories a multi-repository run refreshes at once.
+func Concurrency(cfg File) (int, error) {
+	switch {
+	case cfg.Runtime.Concurrency < 0:
+		return 0, fmt.Errorf("runtime concurrency must not be negative")
+	case cfg.Runtime.Concurrency == 0:
+		return defaultConcurrency, nil
+	default:
+		return cfg.Runtime.Concurrency, nil
+	}
+}
+
+// RunSchedule builds the optional window outside of which runs are skipped.
+func RunSchedule(cfg File) (*ScheduleWindow, error) {
+	schedule := cfg.Runtime.Schedule
+	if strings.TrimSpace(schedule.Hours) == "" && len(schedule.Weekdays) == 0 {
+		if strings.TrimSpace(schedule.Timezone) != "" {
+			return nil, fmt.Errorf("runtime schedule timezone requires hours or weekdays")
+		}
+
+		return nil, nil
+	}
+
+	window := ScheduleWindow{Location: time.UTC, StartHour: 0, EndHour: 24}
+	if timezone := strings.TrimSpace(schedule.Timezone); timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("load runtime schedule timezone: %w", err)
+		}
+
+		window.Location = location
+	}
+
+	if strings.TrimSpace(schedule.Hours) != "" {
+		start, end, err := parseHourRange(schedule.Hours)
+		if err != nil {
+			return nil, fmt.Errorf("runtime schedule %w", err)
+		}
+
+		window.StartHour, window.EndHour = start, end
+	}
+
+	if len(schedule.Weekdays) > 0 {
+		window.Weekdays = make(map[time.Weekday]bool, len(schedule.Weekdays))
+		for _, name := range schedule.Weekdays {
+			weekday, ok := weekdayNames[strings.ToLower(strings.TrimSpace(name))]
+			if !ok {
+				return nil, fmt.Errorf("unsupported runtime schedule weekday %q", name)
+			}
+
+			window.Weekdays[weekday] = true
+		}
+	}
+
+	return &window, nil
+}
+
 // ProfileHTTPClient builds an HTTP client for remote profile collection.
 func ProfileHTTPClient(cfg File) (*http.Client, error) {
 	timeout, err := parseDurationOrDefault(cfg.Profile.Timeout, defaultProfileTimeout, "profile timeout")
@@ -140,50 +997,745 @@ func ProfileHTTPClient(cfg File) (*http.Client, error) {
 		return nil, err
 	}
 
-	return &http.Client{
-		Timeout: timeout,
-	}, nil
-}
+	tlsConfig, err := ProfileTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-// GitHubHTTPClient builds an HTTP client for GitHub API operations.
-func GitHubHTTPClient(cfg File) (*http.Client, error) {
-	timeout, err := parseDurationOrDefault(cfg.GitHub.Timeout, defaultGitHubTimeout, "github timeout")
+	proxy, err := ProfileProxyURL(cfg)
 	if err != nil {
 		return nil, err
 	}
 
+	transport := httpTransport(proxy, tlsConfig)
+	if guard := newProfileHostGuard(cfg); guard.isEnabled() {
+		transport = guard.transport(transport)
+	}
+
 	return &http.Client{
-		Timeout: timeout,
+		Timeout:   timeout,
+		Transport: transport,
 	}, nil
 }
 
-// ReadAppKey loads the GitHub App private key from disk.
-func ReadAppKey(cfg File) ([]byte, error) {
-	privateKeyPath := strings.TrimSpace(cfg.GitHub.PrivateKeyPath)
-	if privateKeyPath == "" {
-		return nil, fmt.Errorf("github private key path is required")
+// ProxyURL parses the top-level proxy_url used by the forge API clients;
+// nil keeps the proxy from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment.
+func ProxyURL(cfg File) (*url.URL, error) {
+	proxy, err := parseProxyURL(cfg.ProxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("proxy url: %w", err)
 	}
 
-	privateKey, err := os.ReadFile(privateKeyPath)
-	if err != nil {
-		return nil, fmt.Errorf("read github private key: %w", err)
+	return proxy, nil
+}
+
+// ProfileProxyURL resolves the proxy for profile collection, preferring profile.proxy_url over proxy_url.
+func ProfileProxyURL(cfg File) (*url.URL, error) {
+	if strings.TrimSpace(cfg.Profile.ProxyURL) == "" {
+		return ProxyURL(cfg)
 	}
 
-	if len(privateKey) == 0 {
-		return nil, fmt.Errorf("github private key is empty")
+	proxy, err := parseProxyURL(cfg.Profile.ProxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("profile proxy url: %w", err)
 	}
 
-	return privateKey, nil
+	return proxy, nil
 }
 
-func parseDurationOrDefault(raw string, defaultValue time.Duration, fieldName string) (time.Duration, error) {
+// parseProxyURL parses an http, https or socks5 proxy url, returning nil when raw is empty.
+func parseProxyURL(raw string) (*url.URL, error) {
 	if strings.TrimSpace(raw) == "" {
-		return defaultValue, nil
+		return nil, nil
 	}
 
-	parsed, err := time.ParseDuration(strings.TrimSpace(raw))
+	proxy, err := url.Parse(strings.TrimSpace(raw))
 	if err != nil {
-		return 0, fmt.Errorf("parse %s: %w", fieldName, err)
+		return nil, err
+	}
+
+	switch proxy.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, want http, https or socks5", proxy.Scheme)
+	}
+
+	if proxy.Host == "" {
+		return nil, fmt.Errorf("proxy url must include a host")
+	}
+
+	return proxy, nil
+}
+
+// httpTransport clones the default transport with the given proxy and TLS settings.
+// It returns nil when neither is set so clients keep http.DefaultTransport.
+func httpTransport(proxy *url.URL, tlsConfig *tls.Config) http.RoundTripper {
+	if proxy == nil && tlsConfig == nil {
+		return nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return transport
+}
+
+// ProfileTLSConfig builds the TLS settings for profile endpoints, or nil when none are configured.
+func ProfileTLSConfig(cfg File) (*tls.Config, error) {
+	settings := cfg.Profile.TLS
+	certFile := strings.TrimSpace(settings.CertFile)
+	keyFile := strings.TrimSpace(settings.KeyFile)
+	caFile := strings.TrimSpace(settings.CAFile)
+	if certFile == "" && keyFile == "" && caFile == "" && !cfg.Profile.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("profile tls cert_file and key_file must be set together")
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.Profile.InsecureSkipVerify,
+	}
+
+	if certFile != "" {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load profile tls client certificate: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	if caFile != "" {
+		bundle, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read profile tls ca bundle: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("profile tls ca bundle %s contains no PEM certificates", caFile)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// ProfileSource resolves the configured profile source kind, inferring it from the url scheme when unset.
+func ProfileSource(cfg File) (string, error) {
+	source := strings.ToLower(strings.TrimSpace(cfg.Profile.Source))
+	switch source {
+	case "":
+		return profileSourceFromURL(cfg.Profile.URL), nil
+	case profileSourceHTTP, profileSourceS3, profileSourceParca, profileSourceFile:
+		return source, nil
+	default:
+		return "", fmt.Errorf("unsupported profile source %q", cfg.Profile.Source)
+	}
+}
+
+// profileSourceFromURL maps s3:// and file:// profile urls to their sources and everything else to http.
+func profileSourceFromURL(rawURL string) string {
+	scheme, _, ok := strings.Cut(strings.TrimSpace(rawURL), "://")
+	if !ok {
+		return profileSourceHTTP
+	}
+
+	switch strings.ToLower(scheme) {
+	case profileSourceS3:
+		return profileSourceS3
+	case profileSourceFile:
+		return profileSourceFile
+	default:
+		return profileSourceHTTP
+	}
+}
+
+// ProfileType returns the normalized profile.type; empty selects cpu.
+func ProfileType(cfg File) cpgo.ProfileType {
+	return cpgo.ProfileType(strings.ToLower(strings.TrimSpace(cfg.Profile.Type)))
+}
+
+// ProfileTransform resolves the converter fetched payloads pass through before validation.
+// An empty transform selects the identity transform; command runs profile.command_transform.
+func ProfileTransform(cfg File) (cpgo.ProfileTransform, error) {
+	name := strings.ToLower(strings.TrimSpace(cfg.Profile.Transform))
+	if name == "" {
+		name = profileTransformIdentity
+	}
+
+	if name == profileTransformCommand {
+		timeout, err := parseDurationOrDefault(cfg.Profile.CommandTransform.Timeout, 0, "profile command transform timeout")
+		if err != nil {
+			return nil, err
+		}
+
+		return pprofio.NewCommandTransform(pprofio.CommandTransformOptions{
+			Command: cfg.Profile.CommandTransform.Command,
+			Timeout: timeout,
+		})
+	}
+
+	if strings.TrimSpace(cfg.Profile.CommandTransform.Command) != "" {
+		return nil, fmt.Errorf("profile.command_transform requires profile.transform: command")
+	}
+
+	transform, ok := profileTransforms[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported profile transform %q", cfg.Profile.Transform)
+	}
+
+	return transform, nil
+}
+
+// ProfileCollection resolves how HTTP profiles are collected.
+func ProfileCollection(cfg File) (string, error) {
+	collection := strings.ToLower(strings.TrimSpace(cfg.Profile.Collection))
+	switch collection {
+	case "", collectionSingle:
+		return collectionSingle, nil
+	case collectionTwoStep:
+		return collection, nil
+	default:
+		return "", fmt.Errorf("unsupported profile collection %q", cfg.Profile.Collection)
+	}
+}
+
+// TwoStepOptions maps two-step capture settings into fetcher options.
+func TwoStepOptions(cfg File) (pprofio.TwoStepOptions, error) {
+	fetchURL, err := parseOptionalURL(cfg.Profile.TwoStep.FetchURL, "two-step fetch url")
+	if err != nil {
+		return pprofio.TwoStepOptions{}, err
+	}
+
+	cancelURL, err := parseOptionalURL(cfg.Profile.TwoStep.CancelURL, "two-step cancel url")
+	if err != nil {
+		return pprofio.TwoStepOptions{}, err
+	}
+
+	pollInterval, err := parseDurationOrDefault(cfg.Profile.TwoStep.PollInterval, 0, "two-step poll interval")
+	if err != nil {
+		return pprofio.TwoStepOptions{}, err
+	}
+
+	return pprofio.TwoStepOptions{
+		StartMethod:  cfg.Profile.TwoStep.StartMethod,
+		FetchURL:     fetchURL,
+		FetchMethod:  cfg.Profile.TwoStep.FetchMethod,
+		PollInterval: pollInterval,
+		CancelURL:    cancelURL,
+		CancelMethod: cfg.Profile.TwoStep.CancelMethod,
+		MaxBytes:     cfg.Profile.MaxBytes,
+	}, nil
+}
+
+// FetchRetry maps profile retry settings into fetcher options.
+func FetchRetry(cfg File) (pprofio.RetrySettings, error) {
+	baseDelay, err := parseDurationOrDefault(cfg.Profile.Retry.BaseDelay, 0, "profile retry base delay")
+	if err != nil {
+		return pprofio.RetrySettings{}, err
+	}
+
+	maxDelay, err := parseDurationOrDefault(cfg.Profile.Retry.MaxDelay, 0, "profile retry max delay")
+	if err != nil {
+		return pprofio.RetrySettings{}, err
+	}
+
+	return pprofio.RetrySettings{
+		MaxAttempts: cfg.Profile.Retry.MaxAttempts,
+		BaseDelay:   baseDelay,
+		MaxDelay:    maxDelay,
+	}, nil
+}
+
+// FetcherOptions maps retry and size limit settings into single-collection fetcher options.
+func FetcherOptions(cfg File) (pprofio.FetcherOptions, error) {
+	retry, err := FetchRetry(cfg)
+	if err != nil {
+		return pprofio.FetcherOptions{}, err
+	}
+
+	if cfg.Profile.MaxBytes < 0 {
+		return pprofio.FetcherOptions{}, fmt.Errorf("profile max bytes must not be negative")
+	}
+
+	return pprofio.FetcherOptions{
+		Retry:    retry,
+		MaxBytes: cfg.Profile.MaxBytes,
+	}, nil
+}
+
+// MergeOptions maps multi-window collection settings into fetcher options.
+// A zero samples value collects a single window.
+func MergeOptions(cfg File) pprofio.MergeOptions {
+	samples := cfg.Profile.Samples
+	if samples == 0 {
+		samples = 1
+	}
+
+	return pprofio.MergeOptions{
+		Samples:      samples,
+		MinSamples:   cfg.Profile.MinSamples,
+		DropOutliers: cfg.Profile.DropOutliers,
+	}
+}
+
+// S3Options maps S3 settings and environment credentials into fetcher options.
+func S3Options(cfg File, httpClient *http.Client) s3io.Options {
+	region := strings.TrimSpace(cfg.Profile.S3.Region)
+	if region == "" {
+		region = s3io.RegionFromEnv()
+	}
+
+	return s3io.Options{
+		Region:      region,
+		Endpoint:    strings.TrimSpace(cfg.Profile.S3.Endpoint),
+		Credentials: s3io.CredentialsFromEnv(),
+		HTTPClient:  httpClient,
+		MaxBytes:    cfg.Profile.MaxBytes,
+	}
+}
+
+// ParcaOptions maps Parca query settings into fetcher options.
+func ParcaOptions(cfg File, httpClient *http.Client) (parcaio.Options, error) {
+	timeRange, err := parseDurationOrDefault(cfg.Profile.Parca.Range, 0, "parca range")
+	if err != nil {
+		return parcaio.Options{}, err
+	}
+
+	return parcaio.Options{
+		Query:       strings.TrimSpace(cfg.Profile.Parca.Query),
+		Range:       timeRange,
+		BearerToken: strings.TrimSpace(cfg.Profile.Parca.Token),
+		HTTPClient:  httpClient,
+		MaxBytes:    cfg.Profile.MaxBytes,
+	}, nil
+}
+
+// ValidatorOptions maps profile validation settings into validator options.
+// Options present in the config, even as zero or false, replace the strictness preset.
+func ValidatorOptions(cfg File) (pprofio.ValidatorOptions, error) {
+	validation := cfg.Profile.Validation
+	options := pprofio.ValidatorOptions{
+		Strictness:          strings.TrimSpace(cfg.Profile.Strictness),
+		DefaultSampleType:   strings.TrimSpace(validation.DefaultSampleType),
+		CPUSampleTypes:      validation.CPUSampleTypes,
+		MinDurationFraction: validation.MinDurationFraction,
+	}
+
+	if validation.RequireCPUSampleType != nil {
+		options.RequireCPUSampleType = *validation.RequireCPUSampleType
+		options.Explicit = append(options.Explicit, pprofio.PresetRequireCPUSampleType)
+	}
+
+	if validation.MinSamples != nil {
+		if *validation.MinSamples < 0 {
+			return pprofio.ValidatorOptions{}, fmt.Errorf("profile min samples must not be negative")
+		}
+
+		options.MinSamples = *validation.MinSamples
+		options.Explicit = append(options.Explicit, pprofio.PresetMinSamples)
+	}
+
+	if maxAge := strings.TrimSpace(validation.MaxAge); maxAge != "" {
+		parsed, err := time.ParseDuration(maxAge)
+		if err != nil {
+			return pprofio.ValidatorOptions{}, fmt.Errorf("parse profile max age: %w", err)
+		}
+
+		if parsed < 0 {
+			return pprofio.ValidatorOptions{}, fmt.Errorf("profile max age must not be negative")
+		}
+
+		options.MaxAge = parsed
+		options.Explicit = append(options.Explicit, pprofio.PresetMaxAge)
+	}
+
+	if validation.MinSymbolizedFraction != nil {
+		if *validation.MinSymbolizedFraction < 0 || *validation.MinSymbolizedFraction > 1 {
+			return pprofio.ValidatorOptions{}, fmt.Errorf("profile min symbolized fraction must be between 0 and 1")
+		}
+
+		options.MinSymbolizedFraction = *validation.MinSymbolizedFraction
+		options.Explicit = append(options.Explicit, pprofio.PresetMinSymbolizedFraction)
+	}
+
+	if validation.MaxSingleFunctionRatio != nil {
+		if *validation.MaxSingleFunctionRatio < 0 || *validation.MaxSingleFunctionRatio > 1 {
+			return pprofio.ValidatorOptions{}, fmt.Errorf("profile max single function ratio must be between 0 and 1")
+		}
+
+		options.MaxSingleFunctionRatio = *validation.MaxSingleFunctionRatio
+		options.Explicit = append(options.Explicit, pprofio.PresetMaxSingleFunctionRatio)
+	}
+
+	return options, nil
+}
+
+// ProfileMerger builds the base profile merger; an unset decay merges without scaling.
+func ProfileMerger(cfg File) (*pprofio.BaseMerger, error) {
+	decay := cfg.Profile.Decay
+	if decay == 0 {
+		return pprofio.NewBaseMerger(1)
+	}
+
+	if !cfg.Profile.MergeWithBase {
+		return nil, fmt.Errorf("profile decay requires merge_with_base")
+	}
+
+	return pprofio.NewBaseMerger(decay)
+}
+
+// ProfileValidator builds the built-in validator, chained with the optional validation command.
+func ProfileValidator(cfg File) (cpgo.ProfileValidator, error) {
+	validatorOptions, err := ValidatorOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	validator, err := pprofio.NewValidatorWithOptions(validatorOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(cfg.Profile.Validation.Command) == "" {
+		return validator, nil
+	}
+
+	commandTimeout, err := parseDurationOrDefault(cfg.Profile.Validation.CommandTimeout, 0, "profile validation command timeout")
+	if err != nil {
+		return nil, err
+	}
+
+	commandValidator, err := pprofio.NewCommandValidator(pprofio.CommandValidatorOptions{
+		Command: cfg.Profile.Validation.Command,
+		Timeout: commandTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pprofio.ChainValidators(validator, commandValidator), nil
+}
+
+// ProfileComparer builds the optional profile comparer, needed by the pull request text diff
+// and by every change threshold.
+func ProfileComparer(cfg File) cpgo.ProfileComparer {
+	isThresholdSet := cfg.Profile.MinChange > 0 || cfg.Repository.MinChangeRatio > 0 ||
+		cfg.Repository.MinChangeSamples > 0 || cfg.PullRequest.DraftBelowChangeRatio > 0
+	if !cfg.PullRequest.TextDiff.Enabled && !isThresholdSet {
+		return nil
+	}
+
+	return profilediff.NewComparer(profilediff.Options{
+		TopFunctions: cfg.PullRequest.TextDiff.TopFunctions,
+		MaxDiffBytes: cfg.PullRequest.TextDiff.MaxBytes,
+	})
+}
+
+// ProfileSummarizer builds the optional pull request function summary.
+func ProfileSummarizer(cfg File) cpgo.ProfileSummarizer {
+	if !cfg.PullRequest.DiffSummary.Enabled {
+		return nil
+	}
+
+	return profilediff.NewSummarizer(cfg.PullRequest.DiffSummary.TopFunctions)
+}
+
+// RunCache builds the optional in-process run cache.
+func RunCache(cfg File) (*cpgo.RunCache, error) {
+	if !cfg.Runtime.Cache.Enabled {
+		return nil, nil
+	}
+
+	if cfg.Runtime.Cache.Size < 0 {
+		return nil, fmt.Errorf("runtime cache size must not be negative")
+	}
+
+	ttl, err := parseDurationOrDefault(cfg.Runtime.Cache.TTL, 0, "runtime cache ttl")
+	if err != nil {
+		return nil, err
+	}
+
+	return cpgo.NewRunCache(cfg.Runtime.Cache.Size, ttl), nil
+}
+
+// ProfileLabeler builds the optional hot package pull request labeler.
+func ProfileLabeler(cfg File) cpgo.ProfileLabeler {
+	if !cfg.PullRequest.HotFunctionLabels {
+		return nil
+	}
+
+	return profilediff.NewLabeler()
+}
+
+// DiffPolicy builds the optional policy enforcing diff rules.
+func DiffPolicy(cfg File) (cpgo.DiffPolicy, error) {
+	if len(cfg.DiffRules) == 0 {
+		return nil, nil
+	}
+
+	rules := make([]profilediff.Rule, 0, len(cfg.DiffRules))
+	for _, rule := range cfg.DiffRules {
+		rules = append(rules, profilediff.Rule{
+			Function:      strings.TrimSpace(rule.Function),
+			Present:       rule.Present,
+			MinCumPercent: rule.MinCumPercent,
+		})
+	}
+
+	policy, err := profilediff.NewPolicy(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// ProfileVerifier builds the optional post-commit toolchain verifier.
+func ProfileVerifier(cfg File) (cpgo.ProfileVerifier, error) {
+	if !cfg.Verify.Build.Enabled {
+		return nil, nil
+	}
+
+	verifier, err := gobuild.NewVerifier(gobuild.Options{
+		Dir:      cfg.Verify.Build.Dir,
+		Command:  cfg.Verify.Build.Command,
+		Packages: cfg.Verify.Build.Packages,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return verifier, nil
+}
+
+// Provider resolves the repository host, defaulting to GitHub; forgejo is an alias of gitea.
+func Provider(cfg File) (string, error) {
+	switch provider := strings.ToLower(strings.TrimSpace(cfg.Provider)); provider {
+	case "", providerGitHub:
+		return providerGitHub, nil
+	case providerGitea, "forgejo":
+		return providerGitea, nil
+	case providerGitLab:
+		return provider, nil
+	default:
+		return "", fmt.Errorf("unsupported provider %q", cfg.Provider)
+	}
+}
+
+// GitLabHTTPClient builds an HTTP client for GitLab API operations.
+func GitLabHTTPClient(cfg File) (*http.Client, error) {
+	timeout, err := parseDurationOrDefault(cfg.GitLab.Timeout, defaultGitLabTimeout, "gitlab timeout")
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := ProxyURL(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: httpTransport(proxy, nil),
+	}, nil
+}
+
+// GiteaHTTPClient builds an HTTP client for Gitea API operations.
+func GiteaHTTPClient(cfg File) (*http.Client, error) {
+	timeout, err := parseDurationOrDefault(cfg.Gitea.Timeout, defaultGiteaTimeout, "gitea timeout")
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := ProxyURL(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: httpTransport(proxy, nil),
+	}, nil
+}
+
+// GitHubHTTPClient builds an HTTP client for GitHub API operations.
+func GitHubHTTPClient(cfg File) (*http.Client, error) {
+	timeout, err := parseDurationOrDefault(cfg.GitHub.Timeout, defaultGitHubTimeout, "github timeout")
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := ProxyURL(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: httpTransport(proxy, nil),
+	}, nil
+}
+
+// SecretProvider builds the configured secret provider, defaulting to environment variables.
+func SecretProvider(cfg File) (secretio.SecretProvider, error) {
+	switch provider := strings.TrimSpace(cfg.Secrets.Provider); provider {
+	case "", secretProviderEnv:
+		return secretio.EnvProvider{}, nil
+	case secretProviderFile:
+		return secretio.FileProvider{}, nil
+	case secretProviderVault:
+		address := strings.TrimSpace(cfg.Secrets.Vault.Address)
+		if address == "" {
+			address = os.Getenv("VAULT_ADDR")
+		}
+
+		token := strings.TrimSpace(cfg.Secrets.Vault.Token)
+		if token == "" {
+			token = os.Getenv("VAULT_TOKEN")
+		}
+
+		return secretio.NewVaultProvider(secretio.VaultOptions{
+			Address: address,
+			Token:   token,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported secrets provider %q", provider)
+	}
+}
+
+// CommitSigner builds the optional commit signer, resolving the key passphrase through secrets.
+func CommitSigner(cfg File, secrets secretio.SecretProvider) (*githubapi.CommitSigner, error) {
+	signing := cfg.Commit.Signing
+	if !signing.Enabled {
+		return nil, nil
+	}
+
+	var passphrase string
+	if passphraseRef := strings.TrimSpace(signing.PassphraseRef); passphraseRef != "" {
+		resolved, err := secrets.Get(passphraseRef)
+		if err != nil {
+			return nil, fmt.Errorf("resolve commit signing passphrase: %w", err)
+		}
+
+		passphrase = strings.TrimRight(string(resolved), "\r\n")
+	}
+
+	signer, err := githubapi.NewCommitSigner(githubapi.SigningOptions{
+		Format:     signing.Format,
+		KeyPath:    signing.KeyPath,
+		KeyID:      signing.KeyID,
+		Passphrase: passphrase,
+		Name:       cfg.Commit.CommitterName,
+		Email:      cfg.Commit.CommitterEmail,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("commit signing: %w", err)
+	}
+
+	return signer, nil
+}
+
+// GitHubToken resolves the GitHub token from whichever of token, token_ref, token_path
+// or token_env is set, returning an empty token when none is configured.
+func GitHubToken(cfg File, secrets secretio.SecretProvider) (string, error) {
+	sources := map[string]string{
+		"token":      strings.TrimSpace(cfg.GitHub.Token),
+		"token_ref":  strings.TrimSpace(cfg.GitHub.TokenRef),
+		"token_path": strings.TrimSpace(cfg.GitHub.TokenPath),
+		"token_env":  strings.TrimSpace(cfg.GitHub.TokenEnv),
+	}
+
+	var configured []string
+	for _, name := range []string{"token", "token_ref", "token_path", "token_env"} {
+		if sources[name] != "" {
+			configured = append(configured, name)
+		}
+	}
+
+	if len(configured) == 0 {
+		return "", nil
+	}
+
+	if len(configured) > 1 {
+		return "", fmt.Errorf("github %s are mutually exclusive", strings.Join(configured, " and "))
+	}
+
+	var token string
+	switch source := sources[configured[0]]; configured[0] {
+	case "token":
+		return source, nil
+	case "token_ref":
+		resolved, err := secrets.Get(source)
+		if err != nil {
+			return "", fmt.Errorf("resolve github token: %w", err)
+		}
+
+		token = string(resolved)
+	case "token_path":
+		content, err := os.ReadFile(source)
+		if err != nil {
+			return "", fmt.Errorf("read github token: %w", err)
+		}
+
+		token = string(content)
+	case "token_env":
+		token = os.Getenv(source)
+	}
+
+	if token = strings.TrimSpace(token); token == "" {
+		return "", fmt.Errorf("github %s resolved to an empty token", configured[0])
+	}
+
+	return token, nil
+}
+
+// ReadAppKey loads the GitHub App private key from its secret reference or disk.
+func ReadAppKey(cfg File, secrets secretio.SecretProvider) ([]byte, error) {
+	if privateKeyRef := strings.TrimSpace(cfg.GitHub.PrivateKeyRef); privateKeyRef != "" {
+		privateKey, err := secrets.Get(privateKeyRef)
+		if err != nil {
+			return nil, fmt.Errorf("resolve github private key: %w", err)
+		}
+
+		return privateKey, nil
+	}
+
+	privateKeyPath := strings.TrimSpace(cfg.GitHub.PrivateKeyPath)
+	if privateKeyPath == "" {
+		return nil, fmt.Errorf("github private key path is required")
+	}
+
+	privateKey, err := os.ReadFile(privateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("read github private key: %w", err)
+	}
+
+	if len(privateKey) == 0 {
+		return nil, fmt.Errorf("github private key is empty")
+	}
+
+	return privateKey, nil
+}
+
+func parseDurationOrDefault(raw string, defaultValue time.Duration, fieldName string) (time.Duration, error) {
+	if strings.TrimSpace(raw) == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := time.ParseDuration(strings.TrimSpace(raw))
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", fieldName, err)
 	}
 
 	if parsed <= 0 {
@@ -193,6 +1745,113 @@ func parseDurationOrDefault(raw string, defaultValue time.Duration, fieldName st
 	return parsed, nil
 }
 
+func parseOptionalURL(raw string, fieldName string) (*url.URL, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", fieldName, err)
+	}
+
+	if parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("%s must include scheme and host", fieldName)
+	}
+
+	return parsed, nil
+}
+
+// configMap is a koanf provider serving an already decoded configuration.
+type configMap map[string]any
+
+func (m configMap) ReadBytes() ([]byte, error) {
+	return nil, fmt.Errorf("config map does not provide raw bytes")
+}
+
+func (m configMap) Read() (map[string]any, error) {
+	return m, nil
+}
+
+// shellCommandKey names the config values run through sh -c and therefore not expanded.
+const shellCommandKey = "command"
+
+// envReferencePattern matches a braced `${VAR}` reference, or `$${` escaping a literal `${`.
+var envReferencePattern = regexp.MustCompile(`\$(\$?)\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv expands braced environment references in every string of a decoded configuration value;
+// a bare `$`, as in "costs $5 less", stays literal. Map keys are left alone so header names and other
+// keys stay literal, and command values are left to the shell that runs them, which expands variables itself.
+func expandEnv(value any, fieldPath string, lookup func(string) (string, bool)) (any, error) {
+	switch typed := value.(type) {
+	case string:
+		var missing []string
+		expanded := envReferencePattern.ReplaceAllStringFunc(typed, func(reference string) string {
+			match := envReferencePattern.FindStringSubmatch(reference)
+			if match[1] != "" {
+				return reference[1:]
+			}
+
+			resolved, ok := lookup(match[2])
+			if !ok {
+				missing = append(missing, match[2])
+			}
+
+			return resolved
+		})
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("%s references unset environment variable %s", fieldPath, strings.Join(missing, ", "))
+		}
+
+		return expanded, nil
+	case map[string]any:
+		keys := make([]string, 0, len(typed))
+		for key := range typed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		expanded := make(map[string]any, len(typed))
+		for _, key := range keys {
+			if key == shellCommandKey {
+				expanded[key] = typed[key]
+				continue
+			}
+
+			child, err := expandEnv(typed[key], joinFieldPath(fieldPath, key), lookup)
+			if err != nil {
+				return nil, err
+			}
+
+			expanded[key] = child
+		}
+
+		return expanded, nil
+	case []any:
+		expanded := make([]any, len(typed))
+		for index, item := range typed {
+			child, err := expandEnv(item, fmt.Sprintf("%s[%d]", fieldPath, index), lookup)
+			if err != nil {
+				return nil, err
+			}
+
+			expanded[index] = child
+		}
+
+		return expanded, nil
+	default:
+		return value, nil
+	}
+}
+
+func joinFieldPath(parent string, key string) string {
+	if parent == "" {
+		return key
+	}
+
+	return parent + "." + key
+}
+
 func cloneHeaders(headers map[string]string) map[string]string {
 	if len(headers) == 0 {
 		return nil
@@ -205,3 +1864,15 @@ func cloneHeaders(headers map[string]string) map[string]string {
 
 	return cloned
 }
+
+// trimmedStrings trims each value and drops empty ones.
+func trimmedStrings(values []string) []string {
+	var trimmed []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			trimmed = append(trimmed, value)
+		}
+	}
+
+	return trimmed
+}
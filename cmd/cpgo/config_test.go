@@ -1,9 +1,17 @@
 package main
 
 import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
+
+	"cpgo/pprofio"
 )
 
 func TestLoad(t *testing.T) {
@@ -25,6 +33,10 @@ repository:
 github:
   app_id: 123
   private_key_path: /tmp/key.pem
+pull_request:
+  title: Refresh PGO profile
+  text_diff:
+    enabled: true
 `)
 		if err != nil {
 			t.Fatalf("write temp config: %v", err)
@@ -35,13 +47,255 @@ github:
 			t.Fatalf("load config: %v", err)
 		}
 
-		if cfg.Profile.Seconds != 60 {
-			t.Fatalf("expected seconds to be 60, got %d", cfg.Profile.Seconds)
+		if cfg.Profile.Seconds == nil || *cfg.Profile.Seconds != 60 {
+			t.Fatalf("expected seconds to be 60, got %v", cfg.Profile.Seconds)
 		}
 
 		if cfg.Repository.Owner != "acme" {
 			t.Fatalf("expected owner acme, got %s", cfg.Repository.Owner)
 		}
+
+		if cfg.PullRequest.Title != "Refresh PGO profile" || !cfg.PullRequest.TextDiff.Enabled {
+			t.Fatalf("expected pull_request section to be decoded, got %+v", cfg.PullRequest)
+		}
+	})
+
+	t.Run("expands environment references in string values", func(t *testing.T) {
+		t.Setenv("CPGO_TEST_TOKEN", "secret-token")
+		t.Setenv("CPGO_TEST_OWNER", "acme")
+
+		path := filepath.Join(t.TempDir(), "cpgo.yaml")
+		if err := os.WriteFile(path, []byte(`
+profile:
+  url: https://example.com/debug/pprof/profile
+  headers:
+    Authorization: "Bearer ${CPGO_TEST_TOKEN}"
+repository:
+  owner: ${CPGO_TEST_OWNER}
+  name: payments
+github:
+  token: ${CPGO_TEST_TOKEN}
+  tokens: ["cost-$${CPGO_TEST_OWNER}", "${CPGO_TEST_OWNER}-pgo"]
+pull_request:
+  body: costs $5 less, see $CPGO_TEST_OWNER
+`), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("load config: %v", err)
+		}
+
+		if cfg.GitHub.Token != "secret-token" || cfg.Profile.Headers["Authorization"] != "Bearer secret-token" {
+			t.Fatalf("expected expanded credentials, got %q and %v", cfg.GitHub.Token, cfg.Profile.Headers)
+		}
+
+		if cfg.Repository.Owner != "acme" || len(cfg.GitHub.Tokens) != 2 || cfg.GitHub.Tokens[0] != "cost-${CPGO_TEST_OWNER}" || cfg.GitHub.Tokens[1] != "acme-pgo" {
+			t.Fatalf("expected expanded owner and list values, got %q and %v", cfg.Repository.Owner, cfg.GitHub.Tokens)
+		}
+
+		if cfg.PullRequest.Body != "costs $5 less, see $CPGO_TEST_OWNER" {
+			t.Fatalf("expected a bare $ to stay literal, got %q", cfg.PullRequest.Body)
+		}
+	})
+
+	t.Run("leaves shell commands to the shell", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cpgo.yaml")
+		if err := os.WriteFile(path, []byte(`
+profile:
+  validation:
+    command: 'go tool pprof -top "$CPGO_PROFILE" | awk ''{print $1}'''
+extra_files:
+  - path: VERSION
+    command: for f in *.go; do echo $f; done
+`), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("load config: %v", err)
+		}
+
+		if !strings.Contains(cfg.Profile.Validation.Command, `"$CPGO_PROFILE" | awk '{print $1}'`) || cfg.ExtraFiles[0].Command != "for f in *.go; do echo $f; done" {
+			t.Fatalf("expected commands to stay literal, got %q and %q", cfg.Profile.Validation.Command, cfg.ExtraFiles[0].Command)
+		}
+	})
+
+	t.Run("decodes the pull_request section", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cpgo.yaml")
+		if err := os.WriteFile(path, []byte("pull_request:\n  title: refresh\n  close_superseded: true\n"), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("load config: %v", err)
+		}
+
+		if cfg.PullRequest.Title != "refresh" || !cfg.PullRequest.CloseSuperseded {
+			t.Fatalf("expected pull_request settings, got %+v", cfg.PullRequest)
+		}
+	})
+
+	t.Run("decodes a repositories list", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cpgo.yaml")
+		if err := os.WriteFile(path, []byte(`
+profile:
+  url: https://example.com/debug/pprof/profile
+repositories:
+  - owner: acme
+    name: payments
+    pgo_path: cmd/api/default.pgo
+  - owner: acme
+    name: ledger
+    pgo_path: default.pgo
+    profile_url: https://ledger.example.com/debug/pprof/profile
+runtime:
+  concurrency: 2
+`), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("load config: %v", err)
+		}
+
+		if len(cfg.Repositories) != 2 || cfg.Repositories[0].PGOPath != "cmd/api/default.pgo" || cfg.Repositories[1].ProfileURL != "https://ledger.example.com/debug/pprof/profile" {
+			t.Fatalf("expected two repository entries, got %+v", cfg.Repositories)
+		}
+
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("validate: %v", err)
+		}
+	})
+
+	t.Run("reads the configuration from stdin for -", func(t *testing.T) {
+		t.Setenv("CPGO_TEST_OWNER", "acme")
+
+		stdin, err := os.CreateTemp(t.TempDir(), "cpgo-stdin-*.yaml")
+		if err != nil {
+			t.Fatalf("create stdin file: %v", err)
+		}
+		defer func() { _ = stdin.Close() }()
+
+		if _, err := stdin.WriteString("repository:\n  owner: ${CPGO_TEST_OWNER}\n  name: payments\n"); err != nil {
+			t.Fatalf("write stdin file: %v", err)
+		}
+
+		if _, err := stdin.Seek(0, 0); err != nil {
+			t.Fatalf("rewind stdin file: %v", err)
+		}
+
+		originalStdin := os.Stdin
+		os.Stdin = stdin
+		defer func() { os.Stdin = originalStdin }()
+
+		cfg, err := Load("-")
+		if err != nil {
+			t.Fatalf("load config: %v", err)
+		}
+
+		if cfg.Repository.Owner != "acme" || cfg.Repository.Name != "payments" {
+			t.Fatalf("expected the piped repository, got %+v", cfg.Repository)
+		}
+	})
+
+	t.Run("rejects malformed piped configuration", func(t *testing.T) {
+		if _, err := LoadReader(strings.NewReader("repository: [")); err == nil || !strings.Contains(err.Error(), "decode config file") {
+			t.Fatalf("expected decode error, got %v", err)
+		}
+	})
+
+	t.Run("decodes the same configuration from yaml, toml and json", func(t *testing.T) {
+		t.Setenv("CPGO_TEST_TOKEN", "secret-token")
+
+		for name, content := range map[string]string{
+			"cpgo.yml": `
+profile:
+  url: https://example.com/debug/pprof/profile
+  seconds: 45
+  headers:
+    Authorization: "Bearer ${CPGO_TEST_TOKEN}"
+repositories:
+  - owner: acme
+    name: payments
+    pgo_path: cmd/api/default.pgo
+repository:
+  max_shrink_ratio: 0.5
+pull_request:
+  labels: [pgo, performance]
+  draft: true
+`,
+			"cpgo.toml": `
+[profile]
+url = "https://example.com/debug/pprof/profile"
+seconds = 45
+
+[profile.headers]
+Authorization = "Bearer ${CPGO_TEST_TOKEN}"
+
+[[repositories]]
+owner = "acme"
+name = "payments"
+pgo_path = "cmd/api/default.pgo"
+
+[repository]
+max_shrink_ratio = 0.5
+
+[pull_request]
+labels = ["pgo", "performance"]
+draft = true
+`,
+			"cpgo.json": `{
+  "profile": {
+    "url": "https://example.com/debug/pprof/profile",
+    "seconds": 45,
+    "headers": {"Authorization": "Bearer ${CPGO_TEST_TOKEN}"}
+  },
+  "repositories": [{"owner": "acme", "name": "payments", "pgo_path": "cmd/api/default.pgo"}],
+  "repository": {"max_shrink_ratio": 0.5},
+  "pull_request": {"labels": ["pgo", "performance"], "draft": true}
+}`,
+		} {
+			t.Run(name, func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), name)
+				if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+					t.Fatalf("write config: %v", err)
+				}
+
+				cfg, err := Load(path)
+				if err != nil {
+					t.Fatalf("load config: %v", err)
+				}
+
+				if cfg.Profile.Seconds == nil || *cfg.Profile.Seconds != 45 || cfg.Profile.Headers["Authorization"] != "Bearer secret-token" {
+					t.Fatalf("unexpected profile settings %+v", cfg.Profile)
+				}
+
+				if len(cfg.Repositories) != 1 || cfg.Repositories[0].Owner != "acme" || cfg.Repositories[0].PGOPath != "cmd/api/default.pgo" {
+					t.Fatalf("unexpected repositories %+v", cfg.Repositories)
+				}
+
+				if cfg.Repository.MaxShrinkRatio != 0.5 || len(cfg.PullRequest.Labels) != 2 || !cfg.PullRequest.Draft {
+					t.Fatalf("unexpected repository or pull request settings %+v %+v", cfg.Repository, cfg.PullRequest)
+				}
+			})
+		}
+	})
+
+	t.Run("rejects unset environment references", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cpgo.yaml")
+		if err := os.WriteFile(path, []byte("github:\n  token: ${CPGO_TEST_UNSET_TOKEN}\n"), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+
+		_, err := Load(path)
+		if err == nil || !strings.Contains(err.Error(), "github.token") || !strings.Contains(err.Error(), "CPGO_TEST_UNSET_TOKEN") {
+			t.Fatalf("expected unset variable error naming the field, got %v", err)
+		}
 	})
 }
 
@@ -50,7 +304,7 @@ func TestBuildRunRequest(t *testing.T) {
 		req, err := BuildRunRequest(File{
 			Profile: Profile{
 				URL:     "https://example.com/debug/pprof/profile",
-				Seconds: 30,
+				Seconds: new(30),
 				Headers: map[string]string{
 					"Authorization": "Bearer x",
 				},
@@ -74,6 +328,27 @@ func TestBuildRunRequest(t *testing.T) {
 		}
 	})
 
+	t.Run("maps explicit zero seconds to an instantaneous profile", func(t *testing.T) {
+		req, err := BuildRunRequest(File{
+			Profile: Profile{
+				URL:     "https://example.com/debug/pprof/heap",
+				Seconds: new(0),
+			},
+			Repository: Repository{
+				Owner:   "acme",
+				Name:    "payments",
+				PGOPath: "default.pgo",
+			},
+		})
+		if err != nil {
+			t.Fatalf("build run request: %v", err)
+		}
+
+		if !req.Profile.OmitSeconds || req.Profile.Seconds != 0 {
+			t.Fatalf("expected seconds to be omitted, got %+v", req.Profile)
+		}
+	})
+
 	t.Run("returns error for invalid profile url", func(t *testing.T) {
 		_, err := BuildRunRequest(File{
 			Profile: Profile{
@@ -109,3 +384,590 @@ func TestOperationTimeout(t *testing.T) {
 		}
 	})
 }
+
+func TestProfileSource(t *testing.T) {
+	t.Run("defaults to http", func(t *testing.T) {
+		source, err := ProfileSource(File{})
+		if err != nil {
+			t.Fatalf("profile source: %v", err)
+		}
+
+		if source != profileSourceHTTP {
+			t.Fatalf("expected http source, got %s", source)
+		}
+	})
+
+	t.Run("infers the source from the url scheme", func(t *testing.T) {
+		for rawURL, expected := range map[string]string{
+			"file:///var/lib/profiles/cpu.pprof":              profileSourceFile,
+			"s3://profiles/payments/cpu.pgo":                  profileSourceS3,
+			"https://service.example.com/debug/pprof/profile": profileSourceHTTP,
+		} {
+			source, err := ProfileSource(File{
+				Profile: Profile{
+					URL: rawURL,
+				},
+			})
+			if err != nil {
+				t.Fatalf("profile source: %v", err)
+			}
+
+			if source != expected {
+				t.Fatalf("expected %s source for %s, got %s", expected, rawURL, source)
+			}
+		}
+	})
+
+	t.Run("returns error for unknown source", func(t *testing.T) {
+		_, err := ProfileSource(File{
+			Profile: Profile{
+				Source: "ftp",
+			},
+		})
+		if err == nil {
+			t.Fatalf("expected unsupported source error")
+		}
+	})
+}
+
+func TestProfileTransform(t *testing.T) {
+	for _, name := range []string{"", "identity", " Identity "} {
+		transform, err := ProfileTransform(File{Profile: Profile{Transform: name}})
+		if err != nil {
+			t.Fatalf("profile transform %q: %v", name, err)
+		}
+
+		if _, ok := transform.(pprofio.IdentityTransform); !ok {
+			t.Fatalf("expected identity transform for %q, got %T", name, transform)
+		}
+	}
+
+	if _, err := ProfileTransform(File{Profile: Profile{Transform: "flamegraph"}}); err == nil {
+		t.Fatalf("expected unsupported transform error")
+	}
+
+	transform, err := ProfileTransform(File{Profile: Profile{Transform: "command", CommandTransform: CommandTransform{Command: "folded2pprof", Timeout: "10s"}}})
+	if err != nil {
+		t.Fatalf("profile transform command: %v", err)
+	}
+
+	if _, ok := transform.(*pprofio.CommandTransform); !ok {
+		t.Fatalf("expected command transform, got %T", transform)
+	}
+
+	for name, profile := range map[string]Profile{
+		"command without command_transform.command": {Transform: "command"},
+		"command_transform without command":         {CommandTransform: CommandTransform{Command: "folded2pprof"}},
+	} {
+		if _, err := ProfileTransform(File{Profile: profile}); err == nil {
+			t.Fatalf("expected %s to be rejected", name)
+		}
+	}
+}
+
+func TestProfileComparer(t *testing.T) {
+	t.Run("builds a comparer for change thresholds without the text diff", func(t *testing.T) {
+		if ProfileComparer(File{}) != nil {
+			t.Fatalf("expected no comparer without a text diff or threshold")
+		}
+
+		cfg := File{Profile: Profile{MinChange: 0.1}}
+		if ProfileComparer(cfg) == nil {
+			t.Fatalf("expected a comparer for profile.min_change")
+		}
+
+		req, err := BuildRunRequest(File{
+			Profile:    Profile{URL: "https://example.com/debug/pprof/profile", MinChange: 0.1},
+			Repository: Repository{Owner: "acme", Name: "payments", PGOPath: "default.pgo"},
+		})
+		if err != nil {
+			t.Fatalf("build run request: %v", err)
+		}
+
+		if !req.PullRequest.HideComparison {
+			t.Fatalf("expected the comparison to stay out of the body without text_diff")
+		}
+	})
+}
+
+func TestValidatorOptions(t *testing.T) {
+	t.Run("marks configured zero values as explicit", func(t *testing.T) {
+		requireCPUSampleType, maxSingleFunctionRatio := false, 0.0
+		options, err := ValidatorOptions(File{Profile: Profile{
+			Strictness: "strict",
+			Validation: ProfileValidation{
+				RequireCPUSampleType:   &requireCPUSampleType,
+				MaxAge:                 "0s",
+				MaxSingleFunctionRatio: &maxSingleFunctionRatio,
+			},
+		}})
+		if err != nil {
+			t.Fatalf("validator options: %v", err)
+		}
+
+		expected := []pprofio.PresetOption{pprofio.PresetRequireCPUSampleType, pprofio.PresetMaxAge, pprofio.PresetMaxSingleFunctionRatio}
+		if !slices.Equal(options.Explicit, expected) {
+			t.Fatalf("expected explicit options %v, got %v", expected, options.Explicit)
+		}
+	})
+
+	t.Run("leaves unset options to the preset", func(t *testing.T) {
+		options, err := ValidatorOptions(File{Profile: Profile{Strictness: "standard"}})
+		if err != nil {
+			t.Fatalf("validator options: %v", err)
+		}
+
+		if len(options.Explicit) != 0 {
+			t.Fatalf("expected no explicit options, got %v", options.Explicit)
+		}
+	})
+
+	t.Run("rejects out of range ratios", func(t *testing.T) {
+		ratio := 1.5
+		if _, err := ValidatorOptions(File{Profile: Profile{Validation: ProfileValidation{MaxSingleFunctionRatio: &ratio}}}); err == nil {
+			t.Fatalf("expected ratio error")
+		}
+	})
+}
+
+func TestProfileHTTPClient(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		_, _ = response.Write([]byte("profile"))
+	}))
+	t.Cleanup(server.Close)
+
+	t.Run("trusts the configured ca bundle", func(t *testing.T) {
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		bundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		if err := os.WriteFile(caFile, bundle, 0o600); err != nil {
+			t.Fatalf("write ca bundle: %v", err)
+		}
+
+		client, err := ProfileHTTPClient(File{Profile: Profile{TLS: ProfileTLS{CAFile: caFile}}})
+		if err != nil {
+			t.Fatalf("profile http client: %v", err)
+		}
+
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("get with private ca: %v", err)
+		}
+		_ = resp.Body.Close()
+	})
+
+	t.Run("skips verification when asked", func(t *testing.T) {
+		client, err := ProfileHTTPClient(File{Profile: Profile{InsecureSkipVerify: true}})
+		if err != nil {
+			t.Fatalf("profile http client: %v", err)
+		}
+
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("get without verification: %v", err)
+		}
+		_ = resp.Body.Close()
+	})
+
+	t.Run("verifies against system roots by default", func(t *testing.T) {
+		client, err := ProfileHTTPClient(File{})
+		if err != nil {
+			t.Fatalf("profile http client: %v", err)
+		}
+
+		if client.Transport != nil {
+			t.Fatalf("expected the default transport")
+		}
+
+		if _, err := client.Get(server.URL); err == nil {
+			t.Fatalf("expected an unknown authority error")
+		}
+	})
+
+	t.Run("rejects invalid tls settings", func(t *testing.T) {
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		if err := os.WriteFile(caFile, []byte("not a certificate"), 0o600); err != nil {
+			t.Fatalf("write ca bundle: %v", err)
+		}
+
+		for name, settings := range map[string]ProfileTLS{
+			"cert without key": {CertFile: "client.pem"},
+			"missing key pair": {CertFile: "missing.pem", KeyFile: "missing-key.pem"},
+			"empty ca bundle":  {CAFile: caFile},
+		} {
+			if _, err := ProfileHTTPClient(File{Profile: Profile{TLS: settings}}); err == nil {
+				t.Fatalf("expected error for %s", name)
+			}
+		}
+	})
+}
+
+func TestProxyURL(t *testing.T) {
+	proxyFor := func(t *testing.T, client *http.Client) string {
+		t.Helper()
+
+		transport, ok := client.Transport.(*http.Transport)
+		if !ok {
+			t.Fatalf("expected a configured transport, got %T", client.Transport)
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "https://api.github.com/repos", nil)
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+
+		proxy, err := transport.Proxy(req)
+		if err != nil {
+			t.Fatalf("resolve proxy: %v", err)
+		}
+
+		return proxy.String()
+	}
+
+	t.Run("keeps the environment proxy by default", func(t *testing.T) {
+		client, err := GitHubHTTPClient(File{})
+		if err != nil {
+			t.Fatalf("github http client: %v", err)
+		}
+
+		if client.Transport != nil {
+			t.Fatalf("expected the default transport, got %T", client.Transport)
+		}
+	})
+
+	t.Run("routes forge and profile clients through proxy_url", func(t *testing.T) {
+		cfg := File{ProxyURL: "socks5://proxy.internal:1080"}
+
+		githubClient, err := GitHubHTTPClient(cfg)
+		if err != nil {
+			t.Fatalf("github http client: %v", err)
+		}
+
+		profileClient, err := ProfileHTTPClient(cfg)
+		if err != nil {
+			t.Fatalf("profile http client: %v", err)
+		}
+
+		if proxy := proxyFor(t, githubClient); proxy != cfg.ProxyURL {
+			t.Fatalf("expected github proxy %s, got %s", cfg.ProxyURL, proxy)
+		}
+
+		if proxy := proxyFor(t, profileClient); proxy != cfg.ProxyURL {
+			t.Fatalf("expected profile proxy %s, got %s", cfg.ProxyURL, proxy)
+		}
+	})
+
+	t.Run("prefers profile.proxy_url for profile collection", func(t *testing.T) {
+		client, err := ProfileHTTPClient(File{
+			ProxyURL: "http://egress.internal:3128",
+			Profile:  Profile{ProxyURL: "https://profiles-proxy.internal:8443"},
+		})
+		if err != nil {
+			t.Fatalf("profile http client: %v", err)
+		}
+
+		if proxy := proxyFor(t, client); proxy != "https://profiles-proxy.internal:8443" {
+			t.Fatalf("expected the profile proxy, got %s", proxy)
+		}
+	})
+
+	t.Run("rejects unsupported proxy urls", func(t *testing.T) {
+		for _, raw := range []string{"ftp://proxy.internal", "http://", "proxy.internal:3128"} {
+			if _, err := ProxyURL(File{ProxyURL: raw}); err == nil {
+				t.Fatalf("expected error for %q", raw)
+			}
+		}
+	})
+}
+
+func TestReadAppKey(t *testing.T) {
+	t.Run("resolves private key reference through the secret provider", func(t *testing.T) {
+		t.Setenv("CPGO_TEST_APP_KEY", "pem-bytes")
+
+		cfg := File{GitHub: GitHub{PrivateKeyRef: "CPGO_TEST_APP_KEY"}}
+		secrets, err := SecretProvider(cfg)
+		if err != nil {
+			t.Fatalf("secret provider: %v", err)
+		}
+
+		privateKey, err := ReadAppKey(cfg, secrets)
+		if err != nil {
+			t.Fatalf("read app key: %v", err)
+		}
+
+		if string(privateKey) != "pem-bytes" {
+			t.Fatalf("unexpected private key %q", privateKey)
+		}
+	})
+
+	t.Run("rejects unknown secret providers", func(t *testing.T) {
+		if _, err := SecretProvider(File{Secrets: Secrets{Provider: "keychain"}}); err == nil {
+			t.Fatalf("expected unsupported provider error")
+		}
+	})
+}
+
+func TestFileValidate(t *testing.T) {
+	t.Run("accepts a complete configuration", func(t *testing.T) {
+		cfg := File{
+			Profile:    Profile{URL: "https://example.com/debug/pprof/profile", Timeout: "30s"},
+			Repository: Repository{Owner: "acme", Name: "payments", PGOPath: "default.pgo"},
+		}
+
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("validate: %v", err)
+		}
+	})
+
+	t.Run("reports every problem with its field path", func(t *testing.T) {
+		err := File{
+			Provider: "bitbucket",
+			Profile: Profile{
+				URL:     "example.com/debug/pprof/profile",
+				Timeout: "soon",
+				Retry:   ProfileRetry{BaseDelay: "-1s"},
+			},
+			Repository: Repository{Name: "payments"},
+			Runtime:    Runtime{Timeout: "2 minutes"},
+		}.Validate()
+		if err == nil {
+			t.Fatalf("expected validation error")
+		}
+
+		for _, fieldPath := range []string{"provider:", "profile.url:", "profile.timeout:", "profile.retry:", "repository.owner:", "runtime.timeout:"} {
+			if !strings.Contains(err.Error(), fieldPath) {
+				t.Fatalf("expected %s problem in %v", fieldPath, err)
+			}
+		}
+
+		if strings.Contains(err.Error(), "repository.name:") {
+			t.Fatalf("expected no repository.name problem in %v", err)
+		}
+	})
+
+	t.Run("reports every run request problem with its field path", func(t *testing.T) {
+		err := File{
+			Profile: Profile{URL: "https://example.com/debug/pprof/profile", MinChange: 4},
+			Repository: Repository{
+				Owner:          "acme",
+				Name:           "payments",
+				MaxShrinkRatio: 7,
+				MinChangeRatio: 3,
+			},
+			PullRequest: PullRequest{TextDiff: TextDiff{Enabled: true}},
+			Runtime:     Runtime{Timeout: "bogus"},
+		}.Validate()
+		if err == nil {
+			t.Fatalf("expected validation error")
+		}
+
+		for _, fieldPath := range []string{"runtime.timeout:", "repository.pgo_path:", "repository.max_shrink_ratio:", "repository.min_change_ratio:", "profile.min_change:"} {
+			if !strings.Contains(err.Error(), fieldPath) {
+				t.Fatalf("expected %s problem in %v", fieldPath, err)
+			}
+		}
+	})
+
+	t.Run("checks the profile method and body", func(t *testing.T) {
+		for name, profile := range map[string]Profile{
+			"unsupported method": {Method: "PUT"},
+			"body without post":  {Body: `{"pod":"api-0"}`},
+			"two-step post":      {Method: "POST", Collection: collectionTwoStep},
+		} {
+			profile.URL = "https://example.com/captures"
+			err := File{Profile: profile, Repository: Repository{Owner: "acme", Name: "payments", PGOPath: "default.pgo"}}.Validate()
+			if err == nil || !strings.Contains(err.Error(), "profile.method:") {
+				t.Fatalf("expected profile.method problem for %s, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("rejects retry and non-cpu types with two-step collection", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			profile   Profile
+			fieldPath string
+		}{
+			"retry":     {profile: Profile{Retry: ProfileRetry{MaxAttempts: 3}}, fieldPath: "profile.retry:"},
+			"heap type": {profile: Profile{Type: "heap"}, fieldPath: "profile.type:"},
+		} {
+			tc.profile.URL = "https://example.com/captures"
+			tc.profile.Collection = collectionTwoStep
+			err := File{Profile: tc.profile, Repository: Repository{Owner: "acme", Name: "payments", PGOPath: "default.pgo"}}.Validate()
+			if err == nil || !strings.Contains(err.Error(), tc.fieldPath) {
+				t.Fatalf("expected %s problem for %s, got %v", tc.fieldPath, name, err)
+			}
+		}
+	})
+
+	t.Run("rejects block_private behind a proxy", func(t *testing.T) {
+		for name, cfg := range map[string]File{
+			"proxy_url":         {ProxyURL: "http://proxy.internal:3128"},
+			"profile.proxy_url": {Profile: Profile{ProxyURL: "socks5://proxy.internal:1080"}},
+		} {
+			cfg.Profile.URL = "https://example.com/debug/pprof/profile"
+			cfg.Profile.BlockPrivate = true
+			cfg.Repository = Repository{Owner: "acme", Name: "payments", PGOPath: "default.pgo"}
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "profile.block_private:") {
+				t.Fatalf("expected profile.block_private problem with %s, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("checks the profile decay", func(t *testing.T) {
+		for name, profile := range map[string]Profile{
+			"without merge_with_base": {Decay: 0.5},
+			"above one":               {Decay: 1.5, MergeWithBase: true},
+			"negative":                {Decay: -0.5, MergeWithBase: true},
+		} {
+			profile.URL = "https://example.com/debug/pprof/profile"
+			err := File{Profile: profile, Repository: Repository{Owner: "acme", Name: "payments", PGOPath: "default.pgo"}}.Validate()
+			if err == nil || !strings.Contains(err.Error(), "profile.decay:") {
+				t.Fatalf("expected profile.decay problem for %s, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("requires fast-forward updates and no symlinks with gitea", func(t *testing.T) {
+		forcePush := false
+		for name, tc := range map[string]struct {
+			repository Repository
+			verify     Verify
+			fieldPath  string
+		}{
+			"default force push": {repository: Repository{Owner: "acme", Name: "payments", PGOPath: "default.pgo"}, fieldPath: "repository.force_push:"},
+			"revert":             {repository: Repository{Owner: "acme", Name: "payments", PGOPath: "default.pgo", ForcePush: &forcePush}, verify: Verify{Build: VerifyBuild{Enabled: true, Revert: true}}, fieldPath: "verify.build.revert:"},
+			"hash in filename":   {repository: Repository{Owner: "acme", Name: "payments", PGOPath: "default.pgo", ForcePush: &forcePush, HashInFilename: true}, fieldPath: "repository.hash_in_filename:"},
+		} {
+			err := File{
+				Provider:   providerGitea,
+				Profile:    Profile{URL: "https://example.com/debug/pprof/profile"},
+				Repository: tc.repository,
+				Verify:     tc.verify,
+			}.Validate()
+			if err == nil || !strings.Contains(err.Error(), tc.fieldPath) {
+				t.Fatalf("expected %s problem for %s, got %v", tc.fieldPath, name, err)
+			}
+		}
+
+		err := File{
+			Provider:   providerGitea,
+			Profile:    Profile{URL: "https://example.com/debug/pprof/profile"},
+			Repository: Repository{Owner: "acme", Name: "payments", PGOPath: "default.pgo", ForcePush: &forcePush},
+		}.Validate()
+		if err != nil {
+			t.Fatalf("validate: %v", err)
+		}
+	})
+
+	t.Run("rejects committer and symlink settings with gitlab", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			commit         Commit
+			hashInFilename bool
+			fieldPath      string
+		}{
+			"committer":        {commit: Commit{CommitterName: "cpgo", CommitterEmail: "cpgo@example.com"}, fieldPath: "commit.committer_name:"},
+			"dco":              {commit: Commit{DCO: true}, fieldPath: "commit.dco:"},
+			"hash in filename": {hashInFilename: true, fieldPath: "repository.hash_in_filename:"},
+		} {
+			err := File{
+				Provider:   providerGitLab,
+				Profile:    Profile{URL: "https://example.com/debug/pprof/profile"},
+				Repository: Repository{Owner: "acme", Name: "payments", PGOPath: "default.pgo", HashInFilename: tc.hashInFilename},
+				Commit:     tc.commit,
+			}.Validate()
+			if err == nil || !strings.Contains(err.Error(), tc.fieldPath) {
+				t.Fatalf("expected %s problem for %s, got %v", tc.fieldPath, name, err)
+			}
+		}
+	})
+
+	t.Run("checks every repository entry", func(t *testing.T) {
+		err := File{
+			Profile: Profile{URL: "https://example.com/debug/pprof/profile"},
+			Repositories: []RepositoryEntry{
+				{Repository: Repository{Owner: "acme", Name: "payments", PGOPath: "default.pgo"}},
+				{Repository: Repository{Owner: "acme", PGOPath: "default.pgo"}, ProfileURL: "ledger.example.com"},
+			},
+			Runtime: Runtime{Concurrency: -1},
+		}.Validate()
+		if err == nil {
+			t.Fatalf("expected validation error")
+		}
+
+		for _, fieldPath := range []string{"repositories[1].name:", "repositories[1].profile_url:", "runtime.concurrency:"} {
+			if !strings.Contains(err.Error(), fieldPath) {
+				t.Fatalf("expected %s problem in %v", fieldPath, err)
+			}
+		}
+
+		if strings.Contains(err.Error(), "repositories[0]") {
+			t.Fatalf("expected no problem with the first repository in %v", err)
+		}
+	})
+}
+
+func TestGitHubToken(t *testing.T) {
+	t.Setenv("CPGO_TEST_GITHUB_TOKEN", "env-token")
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("file-token\n"), 0o600); err != nil {
+		t.Fatalf("write token file: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		github   GitHub
+		expected string
+	}{
+		{name: "inline", github: GitHub{Token: "inline-token"}, expected: "inline-token"},
+		{name: "secret reference", github: GitHub{TokenRef: "CPGO_TEST_GITHUB_TOKEN"}, expected: "env-token"},
+		{name: "file", github: GitHub{TokenPath: tokenPath}, expected: "file-token"},
+		{name: "environment variable", github: GitHub{TokenEnv: "CPGO_TEST_GITHUB_TOKEN"}, expected: "env-token"},
+		{name: "unset", expected: ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := File{GitHub: tc.github}
+			secrets, err := SecretProvider(cfg)
+			if err != nil {
+				t.Fatalf("secret provider: %v", err)
+			}
+
+			token, err := GitHubToken(cfg, secrets)
+			if err != nil {
+				t.Fatalf("github token: %v", err)
+			}
+
+			if token != tc.expected {
+				t.Fatalf("expected token %q, got %q", tc.expected, token)
+			}
+		})
+	}
+
+	t.Run("rejects several sources", func(t *testing.T) {
+		cfg := File{GitHub: GitHub{TokenPath: tokenPath, TokenEnv: "CPGO_TEST_GITHUB_TOKEN"}}
+		if _, err := GitHubToken(cfg, nil); err == nil || !strings.Contains(err.Error(), "token_path and token_env") {
+			t.Fatalf("expected mutually exclusive sources error, got %v", err)
+		}
+	})
+
+	t.Run("rejects an empty environment variable", func(t *testing.T) {
+		if _, err := GitHubToken(File{GitHub: GitHub{TokenEnv: "CPGO_TEST_UNSET_GITHUB_TOKEN"}}, nil); err == nil {
+			t.Fatalf("expected empty token error")
+		}
+	})
+}
+
+func TestProvider(t *testing.T) {
+	for raw, expected := range map[string]string{"": providerGitHub, "github": providerGitHub, "GitLab": providerGitLab, "forgejo": providerGitea} {
+		provider, err := Provider(File{Provider: raw})
+		if err != nil {
+			t.Fatalf("provider %q: %v", raw, err)
+		}
+
+		if provider != expected {
+			t.Fatalf("expected %s provider for %q, got %s", expected, raw, provider)
+		}
+	}
+
+	if _, err := Provider(File{Provider: "bitbucket"}); err == nil {
+		t.Fatalf("expected unsupported provider error")
+	}
+}
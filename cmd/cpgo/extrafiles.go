@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"cpgo"
+)
+
+// ResolveExtraFiles renders configured extra files, running commands and capturing their stdout.
+func ResolveExtraFiles(ctx context.Context, extraFiles []ExtraFile) ([]cpgo.FileChange, error) {
+	changes := make([]cpgo.FileChange, 0, len(extraFiles))
+	for _, extraFile := range extraFiles {
+		path := strings.TrimSpace(extraFile.Path)
+		if path == "" {
+			return nil, fmt.Errorf("extra file path is required")
+		}
+
+		hasContent := extraFile.Content != ""
+		hasCommand := strings.TrimSpace(extraFile.Command) != ""
+		if hasContent == hasCommand {
+			return nil, fmt.Errorf("extra file %s must set exactly one of content or command", path)
+		}
+
+		content := []byte(extraFile.Content)
+		if hasCommand {
+			output, err := runExtraFileCommand(ctx, extraFile.Command)
+			if err != nil {
+				return nil, fmt.Errorf("extra file %s: %w", path, err)
+			}
+
+			content = output
+		}
+
+		changes = append(changes, cpgo.FileChange{
+			Path:    path,
+			Content: content,
+		})
+	}
+
+	return changes, nil
+}
+
+// runExtraFileCommand runs a shell command and returns its stdout.
+func runExtraFileCommand(ctx context.Context, command string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("run command: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestResolveExtraFiles(t *testing.T) {
+	t.Run("renders static content and command output", func(t *testing.T) {
+		changes, err := ResolveExtraFiles(context.Background(), []ExtraFile{
+			{Path: "perf/README.md", Content: "static"},
+			{Path: "perf/benchstat.txt", Command: "printf 'name old new'"},
+		})
+		if err != nil {
+			t.Fatalf("resolve extra files: %v", err)
+		}
+
+		if len(changes) != 2 {
+			t.Fatalf("expected 2 changes, got %d", len(changes))
+		}
+
+		if string(changes[0].Content) != "static" {
+			t.Fatalf("unexpected static content: %q", changes[0].Content)
+		}
+
+		if string(changes[1].Content) != "name old new" {
+			t.Fatalf("unexpected command output: %q", changes[1].Content)
+		}
+	})
+
+	t.Run("rejects entries with both content and command", func(t *testing.T) {
+		_, err := ResolveExtraFiles(context.Background(), []ExtraFile{
+			{Path: "perf/out.txt", Content: "static", Command: "true"},
+		})
+		if err == nil {
+			t.Fatalf("expected extra file error")
+		}
+	})
+
+	t.Run("returns command failures", func(t *testing.T) {
+		_, err := ResolveExtraFiles(context.Background(), []ExtraFile{
+			{Path: "perf/out.txt", Command: "exit 3"},
+		})
+		if err == nil {
+			t.Fatalf("expected command error")
+		}
+	})
+}
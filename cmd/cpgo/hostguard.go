@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	guardedDialTimeout   = 30 * time.Second
+	guardedDialKeepAlive = 30 * time.Second
+)
+
+// profileHostGuard restricts which hosts profile requests may reach, so a mistyped or
+// malicious config cannot point cpgo at internal endpoints such as cloud metadata services.
+// Both restrictions are opt-in.
+type profileHostGuard struct {
+	allowedHosts []string
+	blockPrivate bool
+}
+
+// newProfileHostGuard reads profile.allowed_hosts and profile.block_private.
+func newProfileHostGuard(cfg File) profileHostGuard {
+	allowedHosts := make([]string, 0, len(cfg.Profile.AllowedHosts))
+	for _, host := range cfg.Profile.AllowedHosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			allowedHosts = append(allowedHosts, host)
+		}
+	}
+
+	return profileHostGuard{
+		allowedHosts: allowedHosts,
+		blockPrivate: cfg.Profile.BlockPrivate,
+	}
+}
+
+// isEnabled reports whether the guard restricts anything.
+func (guard profileHostGuard) isEnabled() bool {
+	return len(guard.allowedHosts) > 0 || guard.blockPrivate
+}
+
+// checkURL rejects http(s) urls whose host is not allowed or, with block_private, is a private address literal.
+// Other schemes, such as file and s3 urls, do not reach a host named in the url and are not checked.
+func (guard profileHostGuard) checkURL(target *url.URL) error {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil
+	}
+
+	host := strings.ToLower(target.Hostname())
+	if len(guard.allowedHosts) > 0 && !slices.Contains(guard.allowedHosts, host) {
+		return fmt.Errorf("profile url host %q is not in profile.allowed_hosts", host)
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil && guard.blockPrivate && isPrivateAddr(addr) {
+		return fmt.Errorf("profile url host %s is a private address and profile.block_private is set", host)
+	}
+
+	return nil
+}
+
+// checkDial rejects connections to private addresses after name resolution,
+// so hostnames resolving to loopback, link-local or private networks are blocked as well.
+func (guard profileHostGuard) checkDial(_ string, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("parse dial address %s: %w", address, err)
+	}
+
+	if isPrivateAddr(addrPort.Addr()) {
+		return fmt.Errorf("profile request to private address %s blocked by profile.block_private", addrPort.Addr())
+	}
+
+	return nil
+}
+
+// transport wraps next so every request, including redirects and two-step collection urls,
+// passes checkURL; nil next starts from the default transport.
+// With block_private, profile requests bypass HTTP_PROXY and HTTPS_PROXY, since checkDial
+// would otherwise see the proxy's address instead of the profile host's.
+func (guard profileHostGuard) transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport.(*http.Transport).Clone()
+	}
+
+	if base, ok := next.(*http.Transport); ok && guard.blockPrivate {
+		dialer := &net.Dialer{
+			Timeout:   guardedDialTimeout,
+			KeepAlive: guardedDialKeepAlive,
+			Control:   guard.checkDial,
+		}
+		base.DialContext = dialer.DialContext
+		base.Proxy = nil
+	}
+
+	return guardedTransport{guard: guard, next: next}
+}
+
+// guardedTransport checks each request url before sending it.
+type guardedTransport struct {
+	guard profileHostGuard
+	next  http.RoundTripper
+}
+
+// RoundTrip rejects requests to disallowed hosts and sends the rest.
+func (transport guardedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := transport.guard.checkURL(req.URL); err != nil {
+		return nil, err
+	}
+
+	return transport.next.RoundTrip(req)
+}
+
+// isPrivateAddr reports loopback, private, link-local and unspecified addresses.
+func isPrivateAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsUnspecified()
+}
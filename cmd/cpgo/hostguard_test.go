@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestProfileHostGuardCheckURL(t *testing.T) {
+	guard := newProfileHostGuard(File{Profile: Profile{AllowedHosts: []string{" Profiler.Internal "}, BlockPrivate: true}})
+
+	for rawURL, isAllowed := range map[string]bool{
+		"https://profiler.internal:8443/debug/pprof/profile": true,
+		"https://PROFILER.internal/debug/pprof/profile":      true,
+		"http://169.254.169.254/latest/meta-data":            false,
+		"https://other.internal/debug/pprof/profile":         false,
+		"file:///var/lib/profiles/cpu.pprof":                 true,
+	} {
+		target, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatalf("parse %s: %v", rawURL, err)
+		}
+
+		if err := guard.checkURL(target); (err == nil) != isAllowed {
+			t.Fatalf("expected allowed=%t for %s, got %v", isAllowed, rawURL, err)
+		}
+	}
+
+	privateOnly := newProfileHostGuard(File{Profile: Profile{BlockPrivate: true}})
+	for rawURL, isAllowed := range map[string]bool{
+		"http://127.0.0.1:6060/debug/pprof/profile":        false,
+		"http://[::1]/debug/pprof/profile":                 false,
+		"http://10.0.0.8/debug/pprof/profile":              false,
+		"https://profiler.example.com/debug/pprof/profile": true,
+	} {
+		target, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatalf("parse %s: %v", rawURL, err)
+		}
+
+		if err := privateOnly.checkURL(target); (err == nil) != isAllowed {
+			t.Fatalf("expected allowed=%t for %s, got %v", isAllowed, rawURL, err)
+		}
+	}
+}
+
+func TestProfileHTTPClientHostGuard(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/redirect" {
+			http.Redirect(response, req, "http://metadata.internal/latest", http.StatusFound)
+			return
+		}
+
+		_, _ = response.Write([]byte("profile"))
+	}))
+	t.Cleanup(server.Close)
+
+	t.Run("blocks hostnames resolving to private addresses", func(t *testing.T) {
+		client, err := ProfileHTTPClient(File{Profile: Profile{BlockPrivate: true}})
+		if err != nil {
+			t.Fatalf("profile http client: %v", err)
+		}
+
+		localURL := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+		if _, err := client.Get(localURL); err == nil || !strings.Contains(err.Error(), "profile.block_private") {
+			t.Fatalf("expected private dial to be blocked, got %v", err)
+		}
+	})
+
+	t.Run("dials the profile host directly instead of an environment proxy", func(t *testing.T) {
+		client, err := ProfileHTTPClient(File{Profile: Profile{BlockPrivate: true}})
+		if err != nil {
+			t.Fatalf("profile http client: %v", err)
+		}
+
+		guarded, ok := client.Transport.(guardedTransport)
+		if !ok {
+			t.Fatalf("expected guarded transport, got %T", client.Transport)
+		}
+
+		if base, ok := guarded.next.(*http.Transport); !ok || base.Proxy != nil {
+			t.Fatalf("expected a transport without proxy, got %T", guarded.next)
+		}
+	})
+
+	t.Run("checks redirect targets against the allowlist", func(t *testing.T) {
+		client, err := ProfileHTTPClient(File{Profile: Profile{AllowedHosts: []string{"127.0.0.1"}}})
+		if err != nil {
+			t.Fatalf("profile http client: %v", err)
+		}
+
+		response, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("expected allowed host to be fetched, got %v", err)
+		}
+		_ = response.Body.Close()
+
+		if _, err := client.Get(server.URL + "/redirect"); err == nil || !strings.Contains(err.Error(), "profile.allowed_hosts") {
+			t.Fatalf("expected redirect to a disallowed host to fail, got %v", err)
+		}
+	})
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -13,8 +15,27 @@ import (
 	"github.com/rs/zerolog"
 
 	"cpgo"
+	"cpgo/fileio"
+	"cpgo/giteaapi"
 	"cpgo/githubapi"
+	"cpgo/gitlabapi"
+	"cpgo/parcaio"
 	"cpgo/pprofio"
+	"cpgo/profilediff"
+	"cpgo/s3io"
+	"cpgo/secretio"
+)
+
+const (
+	commandRun      = "run"
+	commandPlan     = "plan"
+	commandServe    = "serve"
+	commandValidate = "validate"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
 )
 
 func main() {
@@ -26,115 +47,550 @@ func main() {
 }
 
 func run(ctx context.Context, args []string, stdout io.Writer, logger zerolog.Logger) error {
-	flagSet := flag.NewFlagSet("cpgo", flag.ContinueOnError)
+	command, commandArgs := splitCommand(args)
+	switch command {
+	case commandRun:
+		return runRefresh(ctx, commandArgs, stdout, logger)
+	case commandPlan:
+		return runPlan(ctx, commandArgs, stdout, logger)
+	case commandServe:
+		return runServe(ctx, commandArgs, logger)
+	case commandValidate:
+		return runValidate(ctx, commandArgs, stdout, logger)
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// splitCommand separates an optional leading subcommand; flags alone imply run.
+func splitCommand(args []string) (string, []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return commandRun, args
+	}
+
+	return args[0], args[1:]
+}
+
+// newFlagSet creates a subcommand flag set with the shared -config flag.
+func newFlagSet(command string, configPath *string) *flag.FlagSet {
+	flagSet := flag.NewFlagSet("cpgo "+command, flag.ContinueOnError)
 	flagSet.SetOutput(os.Stderr)
+	flagSet.StringVar(configPath, "config", "", "Path to cpgo YAML, TOML or JSON configuration file, or - to read YAML from stdin.")
+
+	return flagSet
+}
 
+// loadConfig reads and validates a single-repository configuration file and maps it into a run request.
+func loadConfig(configPath string) (File, cpgo.RunRequest, error) {
+	runs, err := loadRepositoryRuns(configPath)
+	if err != nil {
+		return File{}, cpgo.RunRequest{}, err
+	}
+
+	if len(runs) > 1 {
+		return File{}, cpgo.RunRequest{}, fmt.Errorf("repositories are only supported by the run command")
+	}
+
+	return runs[0].Config, runs[0].Request, nil
+}
+
+// loadRepositoryRuns reads and validates the configuration file and maps every configured
+// repository into its own config and run request.
+func loadRepositoryRuns(configPath string) (RepositoryRuns, error) {
+	if strings.TrimSpace(configPath) == "" {
+		return nil, fmt.Errorf("config path is required")
+	}
+
+	config, err := Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
+
+	configs := RepositoryConfigs(config)
+	runs := make(RepositoryRuns, 0, len(configs))
+	for index, repositoryConfig := range configs {
+		req, err := BuildRunRequest(repositoryConfig)
+		if err != nil {
+			if len(configs) > 1 {
+				return nil, fmt.Errorf("repositories[%d]: %w", index, err)
+			}
+
+			return nil, err
+		}
+
+		runs = append(runs, RepositoryRun{Config: repositoryConfig, Request: req})
+	}
+
+	return runs, nil
+}
+
+func runRefresh(ctx context.Context, args []string, stdout io.Writer, logger zerolog.Logger) error {
 	var configPath string
-	flagSet.StringVar(&configPath, "config", "", "Path to cpgo YAML configuration file.")
+	var resultFile string
+	flagSet := newFlagSet(commandRun, &configPath)
+	var dryRun bool
+	var dryRunDiff bool
+	var output string
+	var metricsFile string
+	var printVersion bool
+	flagSet.BoolVar(&printVersion, "version", false, "Print the cpgo version, commit and Go version, then exit.")
+	flagSet.StringVar(&resultFile, "result-file", "", "Path to atomically write the run result as JSON.")
+	flagSet.StringVar(&metricsFile, "metrics-file", "", "Path to atomically write run metrics for the Prometheus textfile collector.")
+	flagSet.BoolVar(&dryRun, "dry-run", false, "Report whether the profile would change without writing branches or pull requests.")
+	flagSet.BoolVar(&dryRunDiff, "diff", false, "With -dry-run, print how the flat values of the top functions would change.")
+	flagSet.StringVar(&output, "output", outputText, "Stdout format of the run result: text or json.")
 
 	if err := flagSet.Parse(args); err != nil {
 		return err
 	}
 
-	if strings.TrimSpace(configPath) == "" {
-		return fmt.Errorf("config path is required")
+	if printVersion {
+		writeVersion(stdout, readBuildVersion())
+		return nil
 	}
 
-	config, err := Load(configPath)
+	if output != outputText && output != outputJSON {
+		return fmt.Errorf("unsupported output format %q", output)
+	}
+
+	if dryRunDiff && !dryRun {
+		return fmt.Errorf("-diff requires -dry-run")
+	}
+
+	runs, err := loadRepositoryRuns(configPath)
 	if err != nil {
 		return err
 	}
 
-	req, err := BuildRunRequest(config)
+	concurrency, err := Concurrency(runs[0].Config)
 	if err != nil {
 		return err
 	}
 
-	timeout, err := OperationTimeout(config)
+	// One cache serves every repository; its keys include the repository and path.
+	runCache, err := RunCache(runs[0].Config)
 	if err != nil {
 		return err
 	}
 
-	runContext, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
+	for index := range runs {
+		runs[index].Request.DryRun = dryRun
+		runs[index].Request.DryRunDiff = dryRunDiff
+	}
 
-	logger.Info().Str("config_path", configPath).Msg("starting cpgo run")
+	logger.Info().Str("config_path", configPath).Bool("dry_run", dryRun).Int("repositories", len(runs)).Msg("starting cpgo run")
 
-	svc, err := newService(runContext, config, req.Repository)
-	if err != nil {
-		return err
+	runRepositories(ctx, runs, concurrency, func(runCtx context.Context, run RepositoryRun) (cpgo.RunResult, error) {
+		runLogger := logger
+		if len(runs) > 1 {
+			runLogger = logger.With().Str("repository", run.Repository()).Logger()
+		}
+
+		result, err := executeRun(runCtx, run.Config, run.Request, runCache, runLogger)
+		if err != nil {
+			if len(runs) > 1 {
+				runLogger.Error().Err(err).Msg("cpgo repository run failed")
+			}
+
+			return result, err
+		}
+
+		logRunResult(runLogger, result)
+
+		return result, nil
+	})
+
+	err = runs.Err()
+	if strings.TrimSpace(resultFile) != "" {
+		var document any = resultDocuments(configPath, runs)
+		if len(runs) == 1 {
+			document = NewResultDocument(configPath, runs[0].Request, runs[0].Result, runs[0].Err)
+		}
+
+		if writeErr := WriteResultFile(resultFile, document); writeErr != nil {
+			return errors.Join(err, writeErr)
+		}
 	}
 
-	result, err := svc.Run(runContext, req)
-	if err != nil {
-		return err
+	if strings.TrimSpace(metricsFile) != "" {
+		if writeErr := WriteMetricsFile(metricsFile, FormatRunMetrics(runs)); writeErr != nil {
+			return errors.Join(err, writeErr)
+		}
 	}
 
+	if writeErr := writeRepositoryRuns(stdout, output, configPath, runs); writeErr != nil {
+		return errors.Join(err, writeErr)
+	}
+
+	return err
+}
+
+// logRunResult logs the outcome and warnings of one successful run.
+func logRunResult(logger zerolog.Logger, result cpgo.RunResult) {
 	logger.Info().
 		Str("base_branch", result.BaseBranch).
 		Str("head_branch", result.HeadBranch).
 		Int("pr_number", result.PullRequestNumber).
+		Str("pr_url", result.PullRequestURL).
 		Str("commit_sha", result.CommitSHA).
+		Str("profile_url", result.ProfileSourceURL).
+		Int("previous_profile_bytes", result.PreviousProfileBytes).
+		Int("new_profile_bytes", result.NewProfileBytes).
 		Bool("changed", result.IsProfileChanged).
 		Bool("pr_created", result.IsPullRequestCreated).
 		Bool("noop", result.IsNoop).
+		Bool("dry_run", result.IsDryRun).
+		Dict("timings", zerolog.Dict().
+			Dur("fetch", result.Timings.Fetch).
+			Dur("validate", result.Timings.Validate).
+			Dur("read", result.Timings.Read).
+			Dur("upsert", result.Timings.Upsert).
+			Dur("pull_request", result.Timings.PullRequest)).
 		Msg("completed cpgo run")
 
+	for _, warning := range result.Warnings {
+		logger.Warn().Str("warning", warning).Msg("cpgo run completed with a warning")
+	}
+}
+
+// writeRunResult prints the run result as one key=value line or, for json, as a JSON object.
+func writeRunResult(stdout io.Writer, output string, result cpgo.RunResult) error {
+	if output == outputJSON {
+		if err := json.NewEncoder(stdout).Encode(result); err != nil {
+			return fmt.Errorf("encode run result: %w", err)
+		}
+
+		return nil
+	}
+
 	_, _ = fmt.Fprintf(
 		stdout,
-		"base_branch=%s head_branch=%s pr_number=%d commit_sha=%s changed=%t pr_created=%t noop=%t\n",
+		"base_branch=%s head_branch=%s pr_number=%d pr_url=%s commit_sha=%s profile_url=%s previous_bytes=%d new_bytes=%d changed=%t pr_created=%t noop=%t\n",
 		result.BaseBranch,
 		result.HeadBranch,
 		result.PullRequestNumber,
+		result.PullRequestURL,
 		result.CommitSHA,
+		result.ProfileSourceURL,
+		result.PreviousProfileBytes,
+		result.NewProfileBytes,
 		result.IsProfileChanged,
 		result.IsPullRequestCreated,
 		result.IsNoop,
 	)
 
+	if result.ProfileDiff != "" {
+		_, _ = io.WriteString(stdout, result.ProfileDiff)
+	}
+
 	return nil
 }
 
-func newService(ctx context.Context, config File, repository cpgo.RepositorySettings) (*cpgo.Service, error) {
-	profileClient, err := ProfileHTTPClient(config)
+// executeRun resolves extra files and runs one refresh within the configured operation timeout,
+// skipping it as a noop when the run starts outside the schedule window. runCache is shared
+// across runs of the process and may be nil.
+func executeRun(ctx context.Context, config File, req cpgo.RunRequest, runCache *cpgo.RunCache, logger zerolog.Logger) (cpgo.RunResult, error) {
+	timeout, err := OperationTimeout(config)
+	if err != nil {
+		return cpgo.RunResult{}, err
+	}
+
+	schedule, err := RunSchedule(config)
+	if err != nil {
+		return cpgo.RunResult{}, err
+	}
+
+	if now := time.Now(); schedule != nil && !schedule.Allows(now) {
+		logger.Info().Stringer("schedule", schedule).Time("now", now).Msg("skipping cpgo run outside the schedule window")
+		return cpgo.RunResult{SkipReason: cpgo.SkipReasonOutsideSchedule, IsNoop: true}, nil
+	}
+
+	runContext, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req.ExtraFiles, err = ResolveExtraFiles(runContext, config.ExtraFiles)
+	if err != nil {
+		return cpgo.RunResult{}, err
+	}
+
+	svc, err := newService(runContext, config, req.Repository, runCache, logger)
+	if err != nil {
+		return cpgo.RunResult{}, err
+	}
+
+	return svc.Run(runContext, req)
+}
+
+// runPlan prints the branches and pull request a run would use without writing.
+func runPlan(ctx context.Context, args []string, stdout io.Writer, logger zerolog.Logger) error {
+	var configPath string
+	flagSet := newFlagSet(commandPlan, &configPath)
+
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	config, req, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	timeout, err := OperationTimeout(config)
+	if err != nil {
+		return err
+	}
+
+	planContext, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	logger.Info().Str("config_path", configPath).Msg("planning cpgo run")
+
+	svc, err := newService(planContext, config, req.Repository, nil, logger)
+	if err != nil {
+		return err
+	}
+
+	plan, err := svc.Plan(planContext, req)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(
+		stdout,
+		"base_branch=%s head_branch=%s pr_exists=%t pr_managed=%t pr_number=%d pr_url=%s\n",
+		plan.BaseBranch,
+		plan.HeadBranch,
+		plan.HasPullRequest,
+		plan.IsManaged,
+		plan.PullRequestNumber,
+		plan.PullRequestURL,
+	)
+
+	return nil
+}
+
+func newService(
+	ctx context.Context,
+	config File,
+	repository cpgo.RepositorySettings,
+	runCache *cpgo.RunCache,
+	logger zerolog.Logger,
+) (*cpgo.Service, error) {
+	profileClient, err := newProfileHTTPClient(config, logger)
 	if err != nil {
 		return nil, err
 	}
 
-	ghClient, err := GitHubHTTPClient(config)
+	profileFetcher, err := newProfileFetcher(config, profileClient)
+	if err != nil {
+		return nil, err
+	}
+
+	transform, err := ProfileTransform(config)
 	if err != nil {
 		return nil, err
 	}
 
-	ghAdapter, err := newGitHubAdapter(ctx, config, repository, ghClient)
+	// Payloads are converted before multi-window merging, which needs pprof input.
+	profileFetcher, err = pprofio.NewTransformingFetcher(profileFetcher, transform)
+	if err != nil {
+		return nil, err
+	}
+
+	if mergeOptions := MergeOptions(config); mergeOptions.Samples != 1 || mergeOptions.MinSamples != 0 || mergeOptions.DropOutliers != 0 {
+		profileFetcher, err = pprofio.NewMergingFetcher(profileFetcher, mergeOptions)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	validator, err := ProfileValidator(config)
+	if err != nil {
+		return nil, err
+	}
+
+	profileVerifier, err := ProfileVerifier(config)
+	if err != nil {
+		return nil, err
+	}
+
+	diffPolicy, err := DiffPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+
+	profileMerger, err := ProfileMerger(config)
+	if err != nil {
+		return nil, err
+	}
+
+	adapter, err := newRepositoryAdapter(ctx, config, repository, logger)
 	if err != nil {
 		return nil, err
 	}
 
 	return cpgo.NewService(cpgo.Dependencies{
-		ProfileFetcher:   pprofio.NewFetcher(profileClient),
-		ProfileValidator: pprofio.NewValidator(),
-		BranchWriter:     ghAdapter,
-		PullRequests:     ghAdapter,
+		ProfileFetcher:    profileFetcher,
+		ProfileValidator:  validator,
+		BranchWriter:      adapter,
+		PullRequests:      adapter,
+		ProfileComparer:   ProfileComparer(config),
+		ProfileNormalizer: pprofio.NewNormalizer(),
+		ProfileMerger:     profileMerger,
+		TagWriter:         adapter,
+		ProfileVerifier:   profileVerifier,
+		ProfileLabeler:    ProfileLabeler(config),
+		ProfileSummarizer: ProfileSummarizer(config),
+		ProfileDiffer:     profilediff.NewFlatDiffer(config.PullRequest.TextDiff.TopFunctions),
+		DiffPolicy:        diffPolicy,
+		StatusReporter:    adapter,
+		RunCache:          runCache,
 	})
 }
 
+// repositoryAdapter is implemented by every repository host client.
+type repositoryAdapter interface {
+	cpgo.BranchWriter
+	cpgo.PullRequestService
+	cpgo.TagWriter
+	cpgo.StatusReporter
+}
+
+// newRepositoryAdapter builds the client for the configured repository host.
+func newRepositoryAdapter(
+	ctx context.Context,
+	config File,
+	repository cpgo.RepositorySettings,
+	logger zerolog.Logger,
+) (repositoryAdapter, error) {
+	provider, err := Provider(config)
+	if err != nil {
+		return nil, err
+	}
+
+	switch provider {
+	case providerGitLab:
+		return newGitLabAdapter(config)
+	case providerGitea:
+		return newGiteaAdapter(config)
+	}
+
+	ghClient, err := GitHubHTTPClient(config)
+	if err != nil {
+		return nil, err
+	}
+	ghClient.Transport = githubapi.ObserveRateLimits(ghClient.Transport, func(event githubapi.RateLimitEvent) {
+		logger.Warn().
+			Str("endpoint", event.Endpoint).
+			Int("status", event.StatusCode).
+			Dur("retry_after", event.Wait).
+			Time("reset_at", event.ResetAt).
+			Int("remaining", event.Remaining).
+			Msg("github rate limit hit")
+	})
+
+	return newGitHubAdapter(ctx, config, repository, ghClient)
+}
+
+// newProfileHTTPClient builds the profile HTTP client, warning when certificate verification is off.
+func newProfileHTTPClient(config File, logger zerolog.Logger) (*http.Client, error) {
+	httpClient, err := ProfileHTTPClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Profile.InsecureSkipVerify {
+		logger.Warn().Msg("profile.insecure_skip_verify is set: TLS certificates of profile endpoints are NOT verified; never use this outside development")
+	}
+
+	return httpClient, nil
+}
+
+// newProfileFetcher selects the profile fetcher for the configured source.
+func newProfileFetcher(config File, httpClient *http.Client) (cpgo.ProfileFetcher, error) {
+	source, err := ProfileSource(config)
+	if err != nil {
+		return nil, err
+	}
+
+	switch source {
+	case profileSourceS3:
+		return s3io.NewFetcher(S3Options(config, httpClient))
+	case profileSourceFile:
+		return fileio.NewFetcher(), nil
+	case profileSourceParca:
+		options, err := ParcaOptions(config, httpClient)
+		if err != nil {
+			return nil, err
+		}
+
+		return parcaio.NewFetcher(options)
+	}
+
+	collection, err := ProfileCollection(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if collection == collectionTwoStep {
+		options, err := TwoStepOptions(config)
+		if err != nil {
+			return nil, err
+		}
+
+		return pprofio.NewTwoStepFetcher(httpClient, options)
+	}
+
+	options, err := FetcherOptions(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return pprofio.NewFetcherWithOptions(httpClient, options)
+}
+
 func newGitHubAdapter(
 	ctx context.Context,
 	config File,
 	repository cpgo.RepositorySettings,
 	httpClient *http.Client,
 ) (*githubapi.Client, error) {
-	token := strings.TrimSpace(config.GitHub.Token)
+	secrets, err := SecretProvider(config)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := GitHubToken(config, secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	if token != "" && len(config.GitHub.Tokens) > 0 {
+		return nil, fmt.Errorf("github token and tokens are mutually exclusive")
+	}
+
+	signer, err := CommitSigner(config, secrets)
+	if err != nil {
+		return nil, err
+	}
+
 	if token != "" {
-		return githubapi.NewClientFromToken(httpClient, token)
+		return githubapi.NewClientFromToken(httpClient, token, GitHubEnterpriseURLs(config), signer)
+	}
+
+	if len(config.GitHub.Tokens) > 0 {
+		return githubapi.NewClientFromTokens(httpClient, config.GitHub.Tokens, GitHubEnterpriseURLs(config), signer)
 	}
 
 	if config.GitHub.AppID <= 0 {
 		return nil, fmt.Errorf("github app id must be positive when token is not configured")
 	}
 
-	appKeyPEM, err := ReadAppKey(config)
+	appKeyPEM, err := ReadAppKey(config, secrets)
 	if err != nil {
 		return nil, err
 	}
@@ -146,10 +602,86 @@ func newGitHubAdapter(
 			Owner: repository.Owner,
 			Name:  repository.Name,
 		},
+		HTTPClient:     httpClient,
+		EnterpriseURLs: GitHubEnterpriseURLs(config),
+		Signer:         signer,
+	})
+}
+
+func newGitLabAdapter(config File) (*gitlabapi.Client, error) {
+	if config.Commit.Signing.Enabled {
+		return nil, fmt.Errorf("commit signing is not supported with the gitlab provider")
+	}
+
+	secrets, err := SecretProvider(config)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := resolveToken(secrets, providerGitLab, config.GitLab.Token, config.GitLab.TokenRef)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := GitLabHTTPClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return gitlabapi.NewClient(gitlabapi.Options{
+		BaseURL:    strings.TrimSpace(config.GitLab.BaseURL),
+		Token:      token,
+		HTTPClient: httpClient,
+	})
+}
+
+func newGiteaAdapter(config File) (*giteaapi.Client, error) {
+	if config.Commit.Signing.Enabled {
+		return nil, fmt.Errorf("commit signing is not supported with the gitea provider")
+	}
+
+	secrets, err := SecretProvider(config)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := resolveToken(secrets, providerGitea, config.Gitea.Token, config.Gitea.TokenRef)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := GiteaHTTPClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return giteaapi.NewClient(giteaapi.Options{
+		BaseURL:    strings.TrimSpace(config.Gitea.BaseURL),
+		Token:      token,
 		HTTPClient: httpClient,
 	})
 }
 
+// resolveToken returns the inline token or resolves its secret reference; setting both is an error.
+func resolveToken(secrets secretio.SecretProvider, provider string, token string, tokenRef string) (string, error) {
+	token = strings.TrimSpace(token)
+	tokenRef = strings.TrimSpace(tokenRef)
+	if tokenRef == "" {
+		return token, nil
+	}
+
+	if token != "" {
+		return "", fmt.Errorf("%s token and token_ref are mutually exclusive", provider)
+	}
+
+	resolved, err := secrets.Get(tokenRef)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s token: %w", provider, err)
+	}
+
+	return strings.TrimSpace(string(resolved)), nil
+}
+
 func newLogger(output io.Writer) zerolog.Logger {
 	return zerolog.New(zerolog.ConsoleWriter{
 		Out:        output,
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"cpgo"
+)
+
+func TestSplitCommand(t *testing.T) {
+	t.Run("treats leading flags as the run command", func(t *testing.T) {
+		command, args := splitCommand([]string{"-config", "cpgo.yaml"})
+		if command != commandRun {
+			t.Fatalf("expected run command, got %s", command)
+		}
+
+		if len(args) != 2 {
+			t.Fatalf("expected flags to be preserved, got %v", args)
+		}
+	})
+
+	t.Run("extracts explicit subcommand", func(t *testing.T) {
+		command, args := splitCommand([]string{"plan", "-config", "cpgo.yaml"})
+		if command != commandPlan {
+			t.Fatalf("expected plan command, got %s", command)
+		}
+
+		if len(args) != 2 || args[0] != "-config" {
+			t.Fatalf("expected subcommand flags, got %v", args)
+		}
+	})
+}
+
+func TestRunUnknownCommand(t *testing.T) {
+	err := run(context.Background(), []string{"deploy"}, io.Discard, zerolog.Nop())
+	if err == nil {
+		t.Fatalf("expected unknown command error")
+	}
+}
+
+func TestWriteRunResult(t *testing.T) {
+	result := cpgo.RunResult{
+		BaseBranch:        "main",
+		HeadBranch:        "cpgo",
+		PullRequestNumber: 42,
+		PullRequestURL:    "https://github.com/complex64/cpgo/pull/42",
+		NewProfileBytes:   128,
+		IsProfileChanged:  true,
+	}
+
+	t.Run("prints key value pairs by default", func(t *testing.T) {
+		var stdout bytes.Buffer
+		if err := writeRunResult(&stdout, outputText, result); err != nil {
+			t.Fatalf("write run result: %v", err)
+		}
+
+		if !strings.HasPrefix(stdout.String(), "base_branch=main head_branch=cpgo pr_number=42 pr_url=https://github.com/complex64/cpgo/pull/42 ") {
+			t.Fatalf("unexpected text output %q", stdout.String())
+		}
+	})
+
+	t.Run("prints the dry run diff after the result line", func(t *testing.T) {
+		diffResult := result
+		diffResult.ProfileDiff = "no existing profile\n"
+
+		var stdout bytes.Buffer
+		if err := writeRunResult(&stdout, outputText, diffResult); err != nil {
+			t.Fatalf("write run result: %v", err)
+		}
+
+		if !strings.HasSuffix(stdout.String(), " noop=false\nno existing profile\n") {
+			t.Fatalf("expected diff after the result line, got %q", stdout.String())
+		}
+	})
+
+	t.Run("prints typed json fields", func(t *testing.T) {
+		var stdout bytes.Buffer
+		if err := writeRunResult(&stdout, outputJSON, result); err != nil {
+			t.Fatalf("write run result: %v", err)
+		}
+
+		var decoded map[string]any
+		if err := json.Unmarshal(stdout.Bytes(), &decoded); err != nil {
+			t.Fatalf("decode json output: %v", err)
+		}
+
+		if decoded["pr_number"] != float64(42) || decoded["pr_url"] != "https://github.com/complex64/cpgo/pull/42" || decoded["changed"] != true || decoded["noop"] != false || decoded["head_branch"] != "cpgo" {
+			t.Fatalf("unexpected json output %s", stdout.String())
+		}
+	})
+}
+
+func TestRunPrintsVersion(t *testing.T) {
+	var stdout bytes.Buffer
+	if err := run(context.Background(), []string{"-version"}, &stdout, zerolog.Nop()); err != nil {
+		t.Fatalf("run -version: %v", err)
+	}
+
+	if !strings.HasPrefix(stdout.String(), "version=") || !strings.Contains(stdout.String(), " go=go") {
+		t.Fatalf("unexpected version output %q", stdout.String())
+	}
+}
+
+func TestRunRejectsUnknownOutput(t *testing.T) {
+	err := run(context.Background(), []string{"-output", "yaml"}, io.Discard, zerolog.Nop())
+	if err == nil || !strings.Contains(err.Error(), "unsupported output format") {
+		t.Fatalf("expected unsupported output error, got %v", err)
+	}
+}
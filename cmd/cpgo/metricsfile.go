@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// runMetricValue reads one gauge value from a repository run.
+type runMetricValue func(run RepositoryRun) float64
+
+// runMetric is one gauge of the metrics file.
+type runMetric struct {
+	name  string
+	help  string
+	value runMetricValue
+}
+
+// runMetrics lists the gauges written for every repository run.
+var runMetrics = []runMetric{
+	{name: "cpgo_last_run_timestamp_seconds", help: "Unix time the last cpgo run finished.", value: func(run RepositoryRun) float64 { return float64(run.FinishedAt.Unix()) }},
+	{name: "cpgo_run_success", help: "Whether the last cpgo run succeeded.", value: func(run RepositoryRun) float64 { return boolValue(run.Err == nil) }},
+	{name: "cpgo_run_duration_seconds", help: "Duration of the last cpgo run.", value: func(run RepositoryRun) float64 { return run.Duration.Seconds() }},
+	{name: "cpgo_profile_fetch_duration_seconds", help: "Duration of the profile fetch in the last cpgo run.", value: func(run RepositoryRun) float64 { return run.Result.Timings.Fetch.Seconds() }},
+	{name: "cpgo_profile_changed", help: "Whether the last cpgo run found a changed profile.", value: func(run RepositoryRun) float64 { return boolValue(run.Result.IsProfileChanged) }},
+	{name: "cpgo_pull_request_created", help: "Whether the last cpgo run opened a pull request.", value: func(run RepositoryRun) float64 { return boolValue(run.Result.IsPullRequestCreated) }},
+	{name: "cpgo_profile_bytes", help: "Size of the profile fetched by the last cpgo run.", value: func(run RepositoryRun) float64 { return float64(run.Result.NewProfileBytes) }},
+	{name: "cpgo_previous_profile_bytes", help: "Size of the base branch profile in the last cpgo run.", value: func(run RepositoryRun) float64 { return float64(run.Result.PreviousProfileBytes) }},
+}
+
+// FormatRunMetrics renders run outcomes as gauges in the Prometheus text exposition format,
+// one sample per repository, for the node exporter textfile collector.
+func FormatRunMetrics(runs RepositoryRuns) []byte {
+	var builder strings.Builder
+	for _, metric := range runMetrics {
+		_, _ = fmt.Fprintf(&builder, "# HELP %s %s\n", metric.name, metric.help)
+		_, _ = fmt.Fprintf(&builder, "# TYPE %s gauge\n", metric.name)
+		for _, run := range runs {
+			_, _ = fmt.Fprintf(&builder, "%s{repository=%q} %s\n", metric.name, run.Repository(), strconv.FormatFloat(metric.value(run), 'f', -1, 64))
+		}
+	}
+
+	return []byte(builder.String())
+}
+
+// WriteMetricsFile atomically replaces path with the run metrics, as the textfile collector requires.
+func WriteMetricsFile(path string, metrics []byte) error {
+	return writeFileAtomically(path, metrics, "metrics file")
+}
+
+func boolValue(value bool) float64 {
+	if value {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cpgo"
+)
+
+func TestWriteMetricsFile(t *testing.T) {
+	t.Run("writes gauges for a successful run", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cpgo.prom")
+		metrics := FormatRunMetrics(RepositoryRuns{{
+			Request: newMetricsRequest("acme", "payments"),
+			Result: cpgo.RunResult{
+				NewProfileBytes:      2048,
+				IsProfileChanged:     true,
+				IsPullRequestCreated: true,
+				Timings:              cpgo.RunTimings{Fetch: 1500 * time.Millisecond},
+			},
+			Duration:   2 * time.Second,
+			FinishedAt: time.Unix(1714564800, 0),
+		}})
+
+		if err := WriteMetricsFile(path, metrics); err != nil {
+			t.Fatalf("write metrics file: %v", err)
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read metrics file: %v", err)
+		}
+
+		for _, line := range []string{
+			"# TYPE cpgo_run_success gauge",
+			`cpgo_run_success{repository="acme/payments"} 1`,
+			`cpgo_last_run_timestamp_seconds{repository="acme/payments"} 1714564800`,
+			`cpgo_profile_fetch_duration_seconds{repository="acme/payments"} 1.5`,
+			`cpgo_pull_request_created{repository="acme/payments"} 1`,
+			`cpgo_profile_bytes{repository="acme/payments"} 2048`,
+		} {
+			if !strings.Contains(string(content), line+"\n") {
+				t.Fatalf("expected metrics to contain %q, got:\n%s", line, content)
+			}
+		}
+	})
+
+	t.Run("reports failed runs", func(t *testing.T) {
+		metrics := FormatRunMetrics(RepositoryRuns{{
+			Request:    newMetricsRequest("acme", "payments"),
+			Err:        errors.New("fetch cpu profile: timeout"),
+			Duration:   time.Second,
+			FinishedAt: time.Unix(0, 0),
+		}})
+		if !strings.Contains(string(metrics), `cpgo_run_success{repository="acme/payments"} 0`) {
+			t.Fatalf("expected failed run gauge, got:\n%s", metrics)
+		}
+	})
+
+	t.Run("writes one sample per repository under a single header", func(t *testing.T) {
+		metrics := string(FormatRunMetrics(RepositoryRuns{
+			{Request: newMetricsRequest("acme", "payments")},
+			{Request: newMetricsRequest("acme", "ledger"), Err: errors.New("boom")},
+		}))
+
+		if strings.Count(metrics, "# TYPE cpgo_run_success gauge\n") != 1 {
+			t.Fatalf("expected one header per gauge, got:\n%s", metrics)
+		}
+
+		if !strings.Contains(metrics, `cpgo_run_success{repository="acme/payments"} 1`) || !strings.Contains(metrics, `cpgo_run_success{repository="acme/ledger"} 0`) {
+			t.Fatalf("expected a sample per repository, got:\n%s", metrics)
+		}
+	})
+}
+
+func newMetricsRequest(owner string, name string) cpgo.RunRequest {
+	return cpgo.RunRequest{Repository: cpgo.RepositorySettings{Owner: owner, Name: name}}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"cpgo"
+)
+
+// RepositoryRun is one repository refreshed by the run command and its outcome.
+type RepositoryRun struct {
+	Config     File
+	Request    cpgo.RunRequest
+	Result     cpgo.RunResult
+	Err        error
+	Duration   time.Duration
+	FinishedAt time.Time
+}
+
+// Repository returns the owner/name of the refreshed repository.
+func (run RepositoryRun) Repository() string {
+	return run.Request.Repository.Owner + "/" + run.Request.Repository.Name
+}
+
+// RepositoryRuns aggregates the outcomes of one run command across its repositories.
+type RepositoryRuns []RepositoryRun
+
+// Err joins the failures of every repository, each prefixed with its repository;
+// a single repository's failure is returned unchanged.
+func (runs RepositoryRuns) Err() error {
+	if len(runs) == 1 {
+		return runs[0].Err
+	}
+
+	var failures []error
+	for _, run := range runs {
+		if run.Err != nil {
+			failures = append(failures, fmt.Errorf("%s: %w", run.Repository(), run.Err))
+		}
+	}
+
+	return errors.Join(failures...)
+}
+
+// repositoryRunFunc refreshes one repository.
+type repositoryRunFunc func(ctx context.Context, run RepositoryRun) (cpgo.RunResult, error)
+
+// runRepositories refreshes every repository with at most concurrency runs in flight,
+// recording each outcome in place; one repository failing does not stop the others.
+func runRepositories(ctx context.Context, runs RepositoryRuns, concurrency int, execute repositoryRunFunc) {
+	slots := make(chan struct{}, max(concurrency, 1))
+
+	var wg sync.WaitGroup
+	for index := range runs {
+		wg.Go(func() {
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			startedAt := time.Now()
+			runs[index].Result, runs[index].Err = execute(ctx, runs[index])
+			runs[index].FinishedAt = time.Now()
+			runs[index].Duration = runs[index].FinishedAt.Sub(startedAt)
+		})
+	}
+
+	wg.Wait()
+}
+
+// writeRepositoryRuns prints the run results: a single repository as before, several as one
+// repository-prefixed line per successful run or, for json, as an array of result documents.
+func writeRepositoryRuns(stdout io.Writer, output string, configPath string, runs RepositoryRuns) error {
+	if len(runs) == 1 {
+		if runs[0].Err != nil {
+			return nil
+		}
+
+		return writeRunResult(stdout, output, runs[0].Result)
+	}
+
+	if output == outputJSON {
+		if err := json.NewEncoder(stdout).Encode(resultDocuments(configPath, runs)); err != nil {
+			return fmt.Errorf("encode run results: %w", err)
+		}
+
+		return nil
+	}
+
+	for _, run := range runs {
+		if run.Err != nil {
+			continue
+		}
+
+		_, _ = fmt.Fprintf(stdout, "repository=%s ", run.Repository())
+		if err := writeRunResult(stdout, output, run.Result); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resultDocuments builds one result document per repository run.
+func resultDocuments(configPath string, runs RepositoryRuns) []ResultDocument {
+	documents := make([]ResultDocument, 0, len(runs))
+	for _, run := range runs {
+		documents = append(documents, NewResultDocument(configPath, run.Request, run.Result, run.Err))
+	}
+
+	return documents
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"cpgo"
+)
+
+func TestRunRepositories(t *testing.T) {
+	runs := RepositoryRuns{
+		{Request: newMetricsRequest("acme", "payments")},
+		{Request: newMetricsRequest("acme", "ledger")},
+		{Request: newMetricsRequest("acme", "search")},
+	}
+
+	var inFlight, maxInFlight atomic.Int32
+	runRepositories(context.Background(), runs, 2, func(ctx context.Context, run RepositoryRun) (cpgo.RunResult, error) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			observed := maxInFlight.Load()
+			if current <= observed || maxInFlight.CompareAndSwap(observed, current) {
+				break
+			}
+		}
+
+		if run.Request.Repository.Name == "ledger" {
+			return cpgo.RunResult{}, errors.New("fetch cpu profile: timeout")
+		}
+
+		return cpgo.RunResult{HeadBranch: "cpgo/" + run.Request.Repository.Name}, nil
+	})
+
+	if maxInFlight.Load() > 2 {
+		t.Fatalf("expected at most 2 concurrent runs, got %d", maxInFlight.Load())
+	}
+
+	if runs[0].Result.HeadBranch != "cpgo/payments" || runs[2].Result.HeadBranch != "cpgo/search" || runs[2].FinishedAt.IsZero() {
+		t.Fatalf("expected every repository to run despite a failure, got %+v", runs)
+	}
+
+	err := runs.Err()
+	if err == nil || !strings.Contains(err.Error(), "acme/ledger: fetch cpu profile: timeout") || strings.Contains(err.Error(), "acme/payments") {
+		t.Fatalf("expected only the ledger failure, got %v", err)
+	}
+}
+
+func TestRepositoryConfigs(t *testing.T) {
+	configs := RepositoryConfigs(File{
+		Profile: Profile{URL: "https://example.com/debug/pprof/profile", Timeout: "30s"},
+		Repositories: []RepositoryEntry{
+			{Repository: Repository{Owner: "acme", Name: "payments"}},
+			{Repository: Repository{Owner: "acme", Name: "ledger"}, ProfileURL: "https://ledger.example.com/debug/pprof/profile"},
+		},
+	})
+
+	if len(configs) != 2 || configs[0].Repository.Name != "payments" || configs[1].Repository.Name != "ledger" {
+		t.Fatalf("expected one config per repository, got %+v", configs)
+	}
+
+	if configs[0].Profile.URL != "https://example.com/debug/pprof/profile" || configs[1].Profile.URL != "https://ledger.example.com/debug/pprof/profile" {
+		t.Fatalf("expected shared and overridden profile urls, got %s and %s", configs[0].Profile.URL, configs[1].Profile.URL)
+	}
+
+	if configs[1].Profile.Timeout != "30s" || len(configs[1].Repositories) != 0 {
+		t.Fatalf("expected shared settings without nested repositories, got %+v", configs[1])
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"cpgo"
+)
+
+// ResultDocument is the JSON payload written by -result-file for later pipeline steps.
+type ResultDocument struct {
+	ConfigPath string         `json:"config_path"`
+	Repository string         `json:"repository"`
+	PGOPath    string         `json:"pgo_path"`
+	PGOPaths   []string       `json:"pgo_paths,omitempty"`
+	Seconds    int            `json:"profile_seconds"`
+	Succeeded  bool           `json:"succeeded"`
+	Error      string         `json:"error,omitempty"`
+	Result     cpgo.RunResult `json:"result"`
+}
+
+// NewResultDocument combines a run outcome with the configuration that produced it.
+func NewResultDocument(configPath string, req cpgo.RunRequest, result cpgo.RunResult, runErr error) ResultDocument {
+	document := ResultDocument{
+		ConfigPath: configPath,
+		Repository: req.Repository.Owner + "/" + req.Repository.Name,
+		PGOPath:    req.Repository.PGOPath,
+		PGOPaths:   req.Repository.PGOPaths,
+		Seconds:    req.Profile.Seconds,
+		Succeeded:  runErr == nil,
+		Result:     result,
+	}
+
+	if runErr != nil {
+		document.Error = runErr.Error()
+	}
+
+	return document
+}
+
+// WriteResultFile atomically replaces path with the JSON encoded document,
+// or the array of documents of a multi-repository run.
+func WriteResultFile(path string, document any) error {
+	encoded, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode result file: %w", err)
+	}
+
+	return writeFileAtomically(path, append(encoded, '\n'), "result file")
+}
+
+// writeFileAtomically replaces path through a synced temporary sibling so readers never see a partial file.
+func writeFileAtomically(path string, content []byte, name string) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temporary %s: %w", name, err)
+	}
+	tempPath := tempFile.Name()
+	defer func() { _ = os.Remove(tempPath) }()
+
+	if _, err := tempFile.Write(content); err != nil {
+		_ = tempFile.Close()
+		return fmt.Errorf("write temporary %s: %w", name, err)
+	}
+
+	if err := tempFile.Sync(); err != nil {
+		_ = tempFile.Close()
+		return fmt.Errorf("sync temporary %s: %w", name, err)
+	}
+
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("close temporary %s: %w", name, err)
+	}
+
+	if err := os.Chmod(tempPath, 0o644); err != nil {
+		return fmt.Errorf("chmod temporary %s: %w", name, err)
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		return fmt.Errorf("rename %s: %w", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cpgo"
+)
+
+func TestWriteResultFile(t *testing.T) {
+	t.Run("writes result document as json", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "result.json")
+
+		document := NewResultDocument("cpgo.yaml", cpgo.RunRequest{
+			Repository: cpgo.RepositorySettings{
+				Owner:   "acme",
+				Name:    "payments",
+				PGOPath: "default.pgo",
+			},
+		}, cpgo.RunResult{
+			CommitSHA:         "abc123",
+			PullRequestNumber: 7,
+			IsProfileChanged:  true,
+		}, nil)
+
+		if err := WriteResultFile(path, document); err != nil {
+			t.Fatalf("write result file: %v", err)
+		}
+
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read result file: %v", err)
+		}
+
+		var decoded map[string]any
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			t.Fatalf("decode result file: %v", err)
+		}
+
+		if decoded["repository"] != "acme/payments" || decoded["succeeded"] != true {
+			t.Fatalf("unexpected result document: %s", raw)
+		}
+
+		result, ok := decoded["result"].(map[string]any)
+		if !ok || result["commit_sha"] != "abc123" || result["pr_number"] != float64(7) || result["changed"] != true {
+			t.Fatalf("unexpected run result: %s", raw)
+		}
+
+		entries, err := os.ReadDir(filepath.Dir(path))
+		if err != nil {
+			t.Fatalf("read result dir: %v", err)
+		}
+
+		if len(entries) != 1 {
+			t.Fatalf("expected temporary files to be cleaned up, got %d entries", len(entries))
+		}
+	})
+
+	t.Run("records run errors", func(t *testing.T) {
+		document := NewResultDocument("cpgo.yaml", cpgo.RunRequest{}, cpgo.RunResult{}, errors.New("boom"))
+		if document.Succeeded || document.Error != "boom" {
+			t.Fatalf("expected failed document, got %+v", document)
+		}
+	})
+}
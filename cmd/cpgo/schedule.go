@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Embedded so schedule time zones resolve in the scratch container image.
+	_ "time/tzdata"
+)
+
+// weekdayNames maps accepted weekday spellings to weekdays.
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// ScheduleWindow is the weekly window in which runs may proceed.
+// The hour range starts at StartHour and ends before EndHour, wrapping past midnight
+// when EndHour is not after StartHour.
+type ScheduleWindow struct {
+	Location  *time.Location
+	Weekdays  map[time.Weekday]bool
+	StartHour int
+	EndHour   int
+}
+
+// Allows reports whether now falls inside the window, evaluated in the window's time zone.
+func (window ScheduleWindow) Allows(now time.Time) bool {
+	local := now.In(window.Location)
+	if len(window.Weekdays) > 0 && !window.Weekdays[local.Weekday()] {
+		return false
+	}
+
+	hour := local.Hour()
+	if window.StartHour < window.EndHour {
+		return hour >= window.StartHour && hour < window.EndHour
+	}
+
+	return hour >= window.StartHour || hour < window.EndHour
+}
+
+// String describes the window for logs, e.g. "mon,tue 09-17 Europe/Berlin".
+func (window ScheduleWindow) String() string {
+	var weekdays []string
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if window.Weekdays[weekday] {
+			weekdays = append(weekdays, strings.ToLower(weekday.String()[:3]))
+		}
+	}
+
+	days := "daily"
+	if len(weekdays) > 0 {
+		days = strings.Join(weekdays, ",")
+	}
+
+	return fmt.Sprintf("%s %02d-%02d %s", days, window.StartHour, window.EndHour, window.Location)
+}
+
+// parseHourRange parses a "start-end" range of hours such as 9-17, where end is exclusive.
+func parseHourRange(raw string) (int, int, error) {
+	rawStart, rawEnd, ok := strings.Cut(strings.TrimSpace(raw), "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("hours %q must be a start-end range such as 9-17", raw)
+	}
+
+	start, err := strconv.Atoi(strings.TrimSpace(rawStart))
+	if err != nil || start < 0 || start > 23 {
+		return 0, 0, fmt.Errorf("hours %q must start at an hour from 0 to 23", raw)
+	}
+
+	end, err := strconv.Atoi(strings.TrimSpace(rawEnd))
+	if err != nil || end < 0 || end > 24 {
+		return 0, 0, fmt.Errorf("hours %q must end at an hour from 0 to 24", raw)
+	}
+
+	if start == end {
+		return 0, 0, fmt.Errorf("hours %q must not be empty", raw)
+	}
+
+	return start, end, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunSchedule(t *testing.T) {
+	t.Run("allows runs inside the window only", func(t *testing.T) {
+		window, err := RunSchedule(File{Runtime: Runtime{Schedule: Schedule{
+			Timezone: "Europe/Berlin",
+			Weekdays: []string{"mon", "Tuesday"},
+			Hours:    "9-17",
+		}}})
+		if err != nil {
+			t.Fatalf("run schedule: %v", err)
+		}
+
+		for _, tc := range []struct {
+			now      time.Time
+			expected bool
+		}{
+			{now: time.Date(2024, time.June, 3, 7, 0, 0, 0, time.UTC), expected: true},
+			{now: time.Date(2024, time.June, 3, 6, 59, 0, 0, time.UTC), expected: false},
+			{now: time.Date(2024, time.June, 4, 15, 0, 0, 0, time.UTC), expected: false},
+			{now: time.Date(2024, time.June, 5, 10, 0, 0, 0, time.UTC), expected: false},
+		} {
+			if window.Allows(tc.now) != tc.expected {
+				t.Fatalf("expected %s allowed=%t in %s", tc.now, tc.expected, window)
+			}
+		}
+	})
+
+	t.Run("wraps hours past midnight", func(t *testing.T) {
+		window, err := RunSchedule(File{Runtime: Runtime{Schedule: Schedule{Hours: "22-6"}}})
+		if err != nil {
+			t.Fatalf("run schedule: %v", err)
+		}
+
+		if !window.Allows(time.Date(2024, time.June, 3, 23, 0, 0, 0, time.UTC)) || window.Allows(time.Date(2024, time.June, 3, 12, 0, 0, 0, time.UTC)) {
+			t.Fatalf("expected an overnight window, got %s", window)
+		}
+	})
+
+	t.Run("is disabled without hours or weekdays", func(t *testing.T) {
+		window, err := RunSchedule(File{})
+		if err != nil || window != nil {
+			t.Fatalf("expected no schedule, got %v %v", window, err)
+		}
+	})
+
+	t.Run("rejects invalid windows", func(t *testing.T) {
+		for _, schedule := range []Schedule{
+			{Hours: "9"},
+			{Hours: "9-9"},
+			{Hours: "9-25"},
+			{Weekdays: []string{"someday"}},
+			{Timezone: "Mars/Olympus", Hours: "9-17"},
+		} {
+			if _, err := RunSchedule(File{Runtime: Runtime{Schedule: schedule}}); err == nil {
+				t.Fatalf("expected %+v to be rejected", schedule)
+			}
+		}
+	})
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"cpgo"
+)
+
+const (
+	defaultServeListen     = ":8080"
+	refreshPath            = "/refresh"
+	serveReadHeaderTimeout = 10 * time.Second
+)
+
+// refreshFunc runs one refresh and reports its outcome.
+type refreshFunc func(ctx context.Context) (cpgo.RunResult, error)
+
+// refreshHandler triggers refreshes over HTTP, allowing one run at a time
+// because concurrent runs would race on the same head branch.
+type refreshHandler struct {
+	configPath string
+	request    cpgo.RunRequest
+	refresh    refreshFunc
+	runContext context.Context
+	webhook    WebhookOptions
+	slots      chan struct{}
+	logger     zerolog.Logger
+}
+
+// runServe serves POST /refresh until the context ends or the process is signaled.
+func runServe(ctx context.Context, args []string, logger zerolog.Logger) error {
+	var configPath string
+	var listen string
+	flagSet := newFlagSet(commandServe, &configPath)
+	flagSet.StringVar(&listen, "listen", "", "Address to listen on; overrides serve.listen.")
+
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	config, req, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	if listen == "" {
+		listen = ServeListen(config)
+	}
+
+	// In-flight runs outlive the shutdown signal for up to one operation timeout.
+	shutdownTimeout, err := OperationTimeout(config)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	runContext, cancelRuns := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelRuns()
+
+	webhook, err := ServeWebhookOptions(config)
+	if err != nil {
+		return err
+	}
+
+	// The cache outlives single refreshes so repeated triggers skip already committed profiles.
+	runCache, err := RunCache(config)
+	if err != nil {
+		return err
+	}
+
+	handler := newRefreshHandler(runContext, configPath, req, func(runCtx context.Context) (cpgo.RunResult, error) {
+		return executeRun(runCtx, config, req, runCache, logger)
+	}, webhook, logger)
+
+	mux := http.NewServeMux()
+	mux.Handle(refreshPath, handler)
+
+	server := &http.Server{
+		Addr:              listen,
+		Handler:           mux,
+		ReadHeaderTimeout: serveReadHeaderTimeout,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	logger.Info().Str("config_path", configPath).Str("listen", listen).Msg("serving cpgo refreshes")
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	logger.Info().Msg("shutting down cpgo server")
+
+	shutdownContext, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownContext); err != nil {
+		return fmt.Errorf("shutdown server: %w", err)
+	}
+
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serve: %w", err)
+	}
+
+	return nil
+}
+
+// newRefreshHandler returns a handler whose runs are bound to runContext rather than the
+// triggering request, so webhook senders with short timeouts do not cancel a refresh.
+func newRefreshHandler(
+	runContext context.Context,
+	configPath string,
+	request cpgo.RunRequest,
+	refresh refreshFunc,
+	webhook WebhookOptions,
+	logger zerolog.Logger,
+) *refreshHandler {
+	return &refreshHandler{
+		configPath: configPath,
+		request:    request,
+		refresh:    refresh,
+		runContext: runContext,
+		webhook:    webhook,
+		slots:      make(chan struct{}, 1),
+		logger:     logger,
+	}
+}
+
+// ServeHTTP runs a refresh for POST requests and responds with the result document.
+func (handler *refreshHandler) ServeHTTP(response http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		response.Header().Set("Allow", http.MethodPost)
+		http.Error(response, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	event, err := readWebhookEvent(req, handler.webhook)
+	if errors.Is(err, errInvalidWebhookSignature) {
+		http.Error(response, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if errors.Is(err, errWebhookPayloadTooLarge) {
+		http.Error(response, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(response, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if event.Name == webhookPingEvent {
+		_, _ = io.WriteString(response, "pong\n")
+		return
+	}
+
+	if !matchesWebhookEvents(event, handler.webhook.Events) {
+		handler.logger.Info().Str("event", event.Name).Str("action", event.Action).Msg("ignoring webhook event")
+		response.WriteHeader(http.StatusAccepted)
+		_, _ = io.WriteString(response, "event ignored\n")
+		return
+	}
+
+	select {
+	case handler.slots <- struct{}{}:
+		defer func() { <-handler.slots }()
+	default:
+		http.Error(response, "refresh already running", http.StatusConflict)
+		return
+	}
+
+	handler.logger.Info().
+		Str("remote_addr", req.RemoteAddr).
+		Str("event", event.Name).
+		Str("event_repository", event.Repository.FullName).
+		Msg("starting triggered cpgo run")
+
+	result, err := handler.refresh(handler.runContext)
+	status := http.StatusOK
+	if err != nil {
+		handler.logger.Error().Err(err).Msg("triggered cpgo run failed")
+		status = http.StatusInternalServerError
+	}
+
+	response.Header().Set("Content-Type", "application/json")
+	response.WriteHeader(status)
+	_ = json.NewEncoder(response).Encode(NewResultDocument(handler.configPath, handler.request, result, err))
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"cpgo"
+)
+
+func TestRefreshHandler(t *testing.T) {
+	t.Run("returns the run result as json", func(t *testing.T) {
+		handler := newRefreshHandler(context.Background(), "cpgo.yaml", cpgo.RunRequest{}, func(context.Context) (cpgo.RunResult, error) {
+			return cpgo.RunResult{PullRequestNumber: 7, IsProfileChanged: true}, nil
+		}, WebhookOptions{}, zerolog.Nop())
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, refreshPath, nil))
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", recorder.Code)
+		}
+
+		var document ResultDocument
+		if err := json.Unmarshal(recorder.Body.Bytes(), &document); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+
+		if !document.Succeeded || document.Result.PullRequestNumber != 7 {
+			t.Fatalf("unexpected result document %+v", document)
+		}
+	})
+
+	t.Run("reports failed runs", func(t *testing.T) {
+		handler := newRefreshHandler(context.Background(), "cpgo.yaml", cpgo.RunRequest{}, func(context.Context) (cpgo.RunResult, error) {
+			return cpgo.RunResult{}, errors.New("fetch cpu profile: connection refused")
+		}, WebhookOptions{}, zerolog.Nop())
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, refreshPath, nil))
+
+		if recorder.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status 500, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("rejects concurrent refreshes", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		handler := newRefreshHandler(context.Background(), "cpgo.yaml", cpgo.RunRequest{}, func(context.Context) (cpgo.RunResult, error) {
+			close(started)
+			<-release
+			return cpgo.RunResult{}, nil
+		}, WebhookOptions{}, zerolog.Nop())
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, refreshPath, nil))
+		}()
+		<-started
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, refreshPath, nil))
+		close(release)
+		<-done
+
+		if recorder.Code != http.StatusConflict {
+			t.Fatalf("expected status 409, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("rejects non-post requests", func(t *testing.T) {
+		handler := newRefreshHandler(context.Background(), "cpgo.yaml", cpgo.RunRequest{}, nil, WebhookOptions{}, zerolog.Nop())
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, refreshPath, nil))
+
+		if recorder.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("expected status 405, got %d", recorder.Code)
+		}
+	})
+}
+
+func TestRefreshHandlerWebhook(t *testing.T) {
+	secret := []byte("webhook-secret")
+	payload := `{"action":"created","repository":{"full_name":"acme/api"},"deployment_status":{"state":"success"}}`
+
+	newWebhookRequest := func(event string, body string, signature string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, refreshPath, strings.NewReader(body))
+		req.Header.Set(webhookEventHeader, event)
+		if signature != "" {
+			req.Header.Set(webhookSignatureHeader, signature)
+		}
+		return req
+	}
+
+	sign := func(body string) string {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(body))
+		return webhookSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	newHandler := func(runs *int, events []string) *refreshHandler {
+		return newRefreshHandler(context.Background(), "cpgo.yaml", cpgo.RunRequest{}, func(context.Context) (cpgo.RunResult, error) {
+			*runs++
+			return cpgo.RunResult{}, nil
+		}, WebhookOptions{Secret: secret, Events: events}, zerolog.Nop())
+	}
+
+	t.Run("runs on a validly signed event", func(t *testing.T) {
+		var runs int
+		recorder := httptest.NewRecorder()
+		newHandler(&runs, []string{"deployment_status:success"}).ServeHTTP(recorder, newWebhookRequest("deployment_status", payload, sign(payload)))
+
+		if recorder.Code != http.StatusOK || runs != 1 {
+			t.Fatalf("expected one run with status 200, got %d runs with status %d", runs, recorder.Code)
+		}
+	})
+
+	t.Run("rejects missing and mismatched signatures", func(t *testing.T) {
+		for name, signature := range map[string]string{
+			"missing":    "",
+			"mismatched": sign(payload + " "),
+			"malformed":  "sha256=not-hex",
+		} {
+			var runs int
+			recorder := httptest.NewRecorder()
+			newHandler(&runs, nil).ServeHTTP(recorder, newWebhookRequest("deployment_status", payload, signature))
+
+			if recorder.Code != http.StatusUnauthorized || runs != 0 {
+				t.Fatalf("%s signature: expected status 401 without a run, got %d with %d runs", name, recorder.Code, runs)
+			}
+		}
+	})
+
+	t.Run("rejects payloads over the size limit", func(t *testing.T) {
+		oversized := payload + strings.Repeat(" ", maxWebhookPayloadBytes)
+		var runs int
+		recorder := httptest.NewRecorder()
+		newHandler(&runs, nil).ServeHTTP(recorder, newWebhookRequest("deployment_status", oversized, sign(oversized)))
+
+		if recorder.Code != http.StatusRequestEntityTooLarge || runs != 0 {
+			t.Fatalf("expected status 413 without a run, got %d with %d runs", recorder.Code, runs)
+		}
+	})
+
+	t.Run("ignores events outside the filter", func(t *testing.T) {
+		failed := strings.Replace(payload, `"success"`, `"failure"`, 1)
+		for event, body := range map[string]string{
+			"push":              payload,
+			"deployment_status": failed,
+			"ping":              payload,
+		} {
+			var runs int
+			recorder := httptest.NewRecorder()
+			newHandler(&runs, []string{"deployment_status:success"}).ServeHTTP(recorder, newWebhookRequest(event, body, sign(body)))
+
+			if runs != 0 {
+				t.Fatalf("%s event: expected no run, got %d", event, runs)
+			}
+
+			if event != "ping" && recorder.Code != http.StatusAccepted {
+				t.Fatalf("%s event: expected status 202, got %d", event, recorder.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"cpgo"
+	"cpgo/fileio"
+	"cpgo/pprofio"
+)
+
+// runValidate fetches and validates one profile, printing its metadata, without GitHub access.
+// An optional -config applies the configured validation rules and fetch timeout.
+func runValidate(ctx context.Context, args []string, stdout io.Writer, logger zerolog.Logger) error {
+	var configPath string
+	var profileLocation string
+	var seconds int
+	flagSet := newFlagSet(commandValidate, &configPath)
+	flagSet.StringVar(&profileLocation, "profile", "", "Profile file path or http(s)/file URL to validate.")
+	flagSet.IntVar(&seconds, "seconds", 0, "Seconds query parameter for http(s) profile URLs; 0 omits it.")
+
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(profileLocation) == "" {
+		return fmt.Errorf("profile is required")
+	}
+
+	var config File
+	validator := cpgo.ProfileValidator(pprofio.NewValidator())
+	transform := cpgo.ProfileTransform(pprofio.IdentityTransform{})
+	if strings.TrimSpace(configPath) != "" {
+		var err error
+		config, err = Load(configPath)
+		if err != nil {
+			return err
+		}
+
+		validator, err = ProfileValidator(config)
+		if err != nil {
+			return err
+		}
+
+		transform, err = ProfileTransform(config)
+		if err != nil {
+			return err
+		}
+	}
+
+	httpClient, err := newProfileHTTPClient(config, logger)
+	if err != nil {
+		return err
+	}
+
+	logger.Info().Str("profile", profileLocation).Msg("validating profile")
+
+	raw, err := readValidateProfile(ctx, httpClient, profileLocation, seconds)
+	if err != nil {
+		return err
+	}
+
+	raw, err = transform.TransformProfile(ctx, raw)
+	if err != nil {
+		return fmt.Errorf("transform profile: %w", err)
+	}
+
+	// The lenient inspection only rejects undecodable, empty or mistyped profiles; the configured rules follow.
+	expectations := cpgo.ProfileExpectations{ProfileType: ProfileType(config)}
+	info, err := pprofio.NewValidator().InspectProfile(raw, expectations)
+	if err != nil {
+		return fmt.Errorf("invalid profile: %w", err)
+	}
+
+	if err := validateProfile(ctx, validator, raw, expectations); err != nil {
+		return fmt.Errorf("invalid profile: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(
+		stdout,
+		"samples=%d duration=%s period=%d period_type=%s sample_types=%s locations=%d\n",
+		info.Samples,
+		info.Duration,
+		info.Period,
+		info.PeriodType,
+		strings.Join(info.SampleTypes, ","),
+		info.Locations,
+	)
+
+	return nil
+}
+
+// validateProfile checks the profile against the configured profile type, stopping with ctx,
+// when the validator supports it.
+func validateProfile(ctx context.Context, validator cpgo.ProfileValidator, raw []byte, expectations cpgo.ProfileExpectations) error {
+	switch typed := validator.(type) {
+	case cpgo.ContextValidator:
+		return typed.ValidateCPUProfileContext(ctx, raw, expectations)
+	case cpgo.ExpectationValidator:
+		return typed.ValidateCPUProfileWithExpectations(raw, expectations)
+	default:
+		return validator.ValidateCPUProfile(raw)
+	}
+}
+
+// readValidateProfile fetches http(s) and file URLs through their fetchers and reads anything else as a local path.
+func readValidateProfile(ctx context.Context, httpClient *http.Client, location string, seconds int) ([]byte, error) {
+	profileURL, err := url.Parse(location)
+	if err != nil || profileURL.Scheme == "" {
+		raw, err := os.ReadFile(location)
+		if err != nil {
+			return nil, fmt.Errorf("read profile: %w", err)
+		}
+
+		return raw, nil
+	}
+
+	var fetcher cpgo.ProfileFetcher
+	switch profileURL.Scheme {
+	case "http", "https":
+		fetcher = pprofio.NewFetcher(httpClient)
+	case profileSourceFile:
+		fetcher = fileio.NewFetcher()
+	default:
+		return nil, fmt.Errorf("unsupported profile url scheme %q", profileURL.Scheme)
+	}
+
+	result, err := fetcher.FetchCPUProfile(ctx, cpgo.FetchProfileRequest{
+		URL:     profileURL,
+		Seconds: seconds,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Content, nil
+}
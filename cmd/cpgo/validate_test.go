@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+	"github.com/rs/zerolog"
+)
+
+func TestRunValidate(t *testing.T) {
+	t.Run("prints metadata for a valid profile file", func(t *testing.T) {
+		profilePath := filepath.Join(t.TempDir(), "default.pgo")
+		if err := os.WriteFile(profilePath, writeProfile(t), 0o600); err != nil {
+			t.Fatalf("write profile: %v", err)
+		}
+
+		var stdout bytes.Buffer
+		if err := run(context.Background(), []string{"validate", "-profile", profilePath}, &stdout, zerolog.Nop()); err != nil {
+			t.Fatalf("validate: %v", err)
+		}
+
+		if stdout.String() != "samples=3 duration=30s period=10000000 period_type=cpu/nanoseconds sample_types=samples/count,cpu/nanoseconds locations=1\n" {
+			t.Fatalf("unexpected output %q", stdout.String())
+		}
+	})
+
+	t.Run("fails for an invalid profile", func(t *testing.T) {
+		profilePath := filepath.Join(t.TempDir(), "default.pgo")
+		if err := os.WriteFile(profilePath, []byte("not-a-profile"), 0o600); err != nil {
+			t.Fatalf("write profile: %v", err)
+		}
+
+		err := run(context.Background(), []string{"validate", "-profile", "file://" + profilePath}, &bytes.Buffer{}, zerolog.Nop())
+		if err == nil || !strings.Contains(err.Error(), "parse cpu profile") {
+			t.Fatalf("expected parse error, got %v", err)
+		}
+	})
+
+	t.Run("validates against the configured profile type", func(t *testing.T) {
+		location := &profile.Location{ID: 1}
+		heapProfile := &profile.Profile{
+			SampleType: []*profile.ValueType{
+				{Type: "inuse_objects", Unit: "count"},
+				{Type: "inuse_space", Unit: "bytes"},
+			},
+			Location: []*profile.Location{location},
+			Sample: []*profile.Sample{
+				{Value: []int64{1, 512}, Location: []*profile.Location{location}},
+			},
+		}
+
+		var raw bytes.Buffer
+		if err := heapProfile.Write(&raw); err != nil {
+			t.Fatalf("write profile: %v", err)
+		}
+
+		dir := t.TempDir()
+		profilePath := filepath.Join(dir, "heap.pgo")
+		configPath := filepath.Join(dir, "cpgo.yaml")
+		if err := os.WriteFile(profilePath, raw.Bytes(), 0o600); err != nil {
+			t.Fatalf("write profile: %v", err)
+		}
+		if err := os.WriteFile(configPath, []byte("profile:\n  type: heap\n"), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		cpuConfigPath := filepath.Join(dir, "cpu.yaml")
+		if err := os.WriteFile(cpuConfigPath, []byte("profile:\n  validation:\n    require_cpu_sample_type: true\n"), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+
+		if err := run(context.Background(), []string{"validate", "-config", cpuConfigPath, "-profile", profilePath}, &bytes.Buffer{}, zerolog.Nop()); err == nil {
+			t.Fatalf("expected a heap profile to fail cpu validation")
+		}
+
+		if err := run(context.Background(), []string{"validate", "-config", configPath, "-profile", profilePath}, &bytes.Buffer{}, zerolog.Nop()); err != nil {
+			t.Fatalf("validate heap profile: %v", err)
+		}
+	})
+
+	t.Run("requires a profile", func(t *testing.T) {
+		if err := run(context.Background(), []string{"validate"}, &bytes.Buffer{}, zerolog.Nop()); err == nil {
+			t.Fatalf("expected missing profile error")
+		}
+	})
+}
+
+// writeProfile encodes a 30 second CPU profile with three samples.
+func writeProfile(t *testing.T) []byte {
+	t.Helper()
+
+	location := &profile.Location{ID: 1}
+	cpuProfile := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		PeriodType:    &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Period:        10_000_000,
+		DurationNanos: 30_000_000_000,
+		Location:      []*profile.Location{location},
+		Sample: []*profile.Sample{
+			{Value: []int64{3, 30_000_000}, Location: []*profile.Location{location}},
+		},
+	}
+
+	var raw bytes.Buffer
+	if err := cpuProfile.Write(&raw); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+
+	return raw.Bytes()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+)
+
+// version is the release version, set at build time with -ldflags "-X main.version=v1.2.3".
+var version string
+
+// buildVersion identifies the running cpgo binary.
+type buildVersion struct {
+	Version   string
+	Commit    string
+	GoVersion string
+}
+
+// readBuildVersion combines the ldflags version with the module version and VCS revision
+// the Go toolchain embeds, falling back to "unknown" for anything not recorded.
+func readBuildVersion() buildVersion {
+	current := buildVersion{
+		Version:   version,
+		Commit:    "unknown",
+		GoVersion: runtime.Version(),
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if ok {
+		if current.Version == "" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			current.Version = info.Main.Version
+		}
+
+		var revision string
+		var isModified bool
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				revision = setting.Value
+			case "vcs.modified":
+				isModified = setting.Value == "true"
+			}
+		}
+
+		if revision != "" {
+			current.Commit = revision
+			if isModified {
+				current.Commit += "-dirty"
+			}
+		}
+	}
+
+	if current.Version == "" {
+		current.Version = "unknown"
+	}
+
+	return current
+}
+
+// writeVersion prints the build version as one key=value line.
+func writeVersion(stdout io.Writer, current buildVersion) {
+	_, _ = fmt.Fprintf(stdout, "version=%s commit=%s go=%s\n", current.Version, current.Commit, current.GoVersion)
+}
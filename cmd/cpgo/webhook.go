@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	webhookSignatureHeader = "X-Hub-Signature-256"
+	webhookEventHeader     = "X-GitHub-Event"
+	webhookSignaturePrefix = "sha256="
+	webhookPingEvent       = "ping"
+	// maxWebhookPayloadBytes matches the largest payload GitHub delivers.
+	maxWebhookPayloadBytes = 25 << 20
+)
+
+var errInvalidWebhookSignature = errors.New("invalid webhook signature")
+
+var errWebhookPayloadTooLarge = errors.New("webhook payload too large")
+
+// WebhookOptions configures verification and filtering of webhook triggers.
+// Events lists `<event>` or `<event>:<qualifier>` entries, where the qualifier
+// matches the payload action or, for deployment_status, the deployment state.
+type WebhookOptions struct {
+	Secret []byte
+	Events []string
+}
+
+// webhookEvent is the subset of a GitHub webhook payload cpgo inspects.
+type webhookEvent struct {
+	Name       string
+	Action     string `json:"action"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	DeploymentStatus struct {
+		State string `json:"state"`
+	} `json:"deployment_status"`
+}
+
+// readWebhookEvent verifies the payload signature when a secret is configured and decodes the event.
+func readWebhookEvent(req *http.Request, options WebhookOptions) (webhookEvent, error) {
+	// Reading one byte past the limit rejects an oversized payload instead of checking a cut one.
+	payload, err := io.ReadAll(io.LimitReader(req.Body, maxWebhookPayloadBytes+1))
+	if err != nil {
+		return webhookEvent{}, fmt.Errorf("read webhook payload: %w", err)
+	}
+
+	if len(payload) > maxWebhookPayloadBytes {
+		return webhookEvent{}, fmt.Errorf("%w: limit is %d bytes", errWebhookPayloadTooLarge, maxWebhookPayloadBytes)
+	}
+
+	if len(options.Secret) > 0 && !isValidWebhookSignature(options.Secret, payload, req.Header.Get(webhookSignatureHeader)) {
+		return webhookEvent{}, errInvalidWebhookSignature
+	}
+
+	event := webhookEvent{Name: strings.TrimSpace(req.Header.Get(webhookEventHeader))}
+	if len(strings.TrimSpace(string(payload))) > 0 {
+		// Plain triggers may send any body; only JSON webhook payloads carry event details.
+		_ = json.Unmarshal(payload, &event)
+	}
+
+	return event, nil
+}
+
+// isValidWebhookSignature checks a GitHub sha256 HMAC signature in constant time.
+func isValidWebhookSignature(secret []byte, payload []byte, signature string) bool {
+	encoded, ok := strings.CutPrefix(strings.TrimSpace(signature), webhookSignaturePrefix)
+	if !ok {
+		return false
+	}
+
+	expected, err := hex.DecodeString(encoded)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// matchesWebhookEvents reports whether the event triggers a run; no configured events accepts every event.
+func matchesWebhookEvents(event webhookEvent, events []string) bool {
+	if len(events) == 0 {
+		return true
+	}
+
+	for _, entry := range events {
+		name, qualifier, hasQualifier := strings.Cut(strings.TrimSpace(entry), ":")
+		if name != event.Name {
+			continue
+		}
+
+		if !hasQualifier || qualifier == event.Action || qualifier == event.DeploymentStatus.State {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,9 +1,12 @@
 package cpgo
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
+	"slices"
 	"strings"
+	"time"
 )
 
 const (
@@ -13,91 +16,508 @@ const (
 	defaultPRTitle         = "perf(pgo): refresh pgo profile"
 	defaultPRBody          = "Automated PGO profile refresh."
 	defaultCommitMessage   = "perf(pgo): refresh pgo profile"
+	fileURLScheme          = "file"
+	profileMethodGet       = "GET"
+	profileMethodPost      = "POST"
+)
+
+// Change threshold modes combine repository change thresholds.
+const (
+	ChangeThresholdAll = "all"
+	ChangeThresholdAny = "any"
 )
 
 // RunRequest captures one complete cpgo refresh operation.
+// DryRun fetches, validates and compares the profile without writing to the repository.
+// DryRunDiff additionally renders how a changed profile differs from the base profile.
 type RunRequest struct {
 	Profile     ProfileSettings
 	Repository  RepositorySettings
 	PullRequest PullRequestSettings
 	Commit      CommitSettings
+	ExtraFiles  []FileChange
+	Verify      VerifySettings
+	Status      StatusSettings
+	DryRun      bool
+	DryRunDiff  bool
 }
 
-// ProfileSettings describes where and how to collect the CPU profile.
+// ProfileSettings describes where and how to collect the profile.
+// OmitSeconds fetches an instantaneous profile without a seconds parameter.
+// Type defaults to cpu; heap, mutex and block profiles are always instantaneous.
+// Method is GET (the default) or POST; only POST requests carry a Body.
+// MergeWithBase commits the fetched profile merged with the base branch
+// profile instead of replacing it, so samples accumulate across refreshes.
 type ProfileSettings struct {
-	URL     *url.URL
-	Seconds int
-	Headers map[string]string
+	URL           *url.URL
+	Seconds       int
+	OmitSeconds   bool
+	Headers       map[string]string
+	Type          ProfileType
+	Method        string
+	Body          []byte
+	MergeWithBase bool
 }
 
 // RepositorySettings identifies the target repository and branch strategy.
 type RepositorySettings struct {
-	Owner      string
-	Name       string
-	PGOPath    string
-	BaseBranch string
-	HeadBranch string
+	Owner   string
+	Name    string
+	PGOPath string
+	// PGOPaths writes the same profile to several paths in one commit; after
+	// normalization it lists every target, with PGOPath first.
+	PGOPaths            []string
+	BaseBranch          string
+	HeadBranch          string
+	RequireExistingBase bool
+	TagProfiles         bool
+	MaxShrinkRatio      float64
+	AllowShrink         bool
+	BranchPerRun        bool
+	// InstanceID scopes the default head branch and managed marker, so several
+	// configurations can target one repository without sharing a pull request.
+	InstanceID string
+	// MinChangeRatio skips commits that move no more than this share of samples.
+	MinChangeRatio float64
+	// MinChangeSamples skips commits whose per-function sample counts move no more than this in total.
+	MinChangeSamples int64
+	// MinChange skips commits whose per-function cosine dissimilarity does not exceed it.
+	MinChange float64
+	// ChangeThresholdMode requires all configured thresholds (the default) or any one to be exceeded.
+	ChangeThresholdMode string
+	// HashInFilename commits a content-hashed file and points PGOPath at it through a symlink.
+	HashInFilename bool
+	// FastForwardOnly adds a commit to an existing head branch instead of rebuilding and force-pushing it.
+	FastForwardOnly bool
+	// CleanupStaleBranch deletes the head branch on a noop run without an open managed pull request.
+	CleanupStaleBranch bool
+	// CompareRef is the branch or tag whose profile thresholds compare against instead of the base.
+	CompareRef string
+	// CommitToBase fast-forwards the base branch, skipping head branches and pull requests.
+	CommitToBase bool
+	// RebaseOnConflict rebuilds the commit when the base branch moves while it is written.
+	RebaseOnConflict bool
+	// PreflightChecks reads the protection of the target branch before writing to it.
+	PreflightChecks bool
 }
 
 // PullRequestSettings controls the automation PR identity and metadata.
+// ManagedByMarkerAliases are earlier markers that still identify cpgo's own
+// pull requests; new pull requests only carry ManagedByMarker.
+// Draft opens every new pull request as a draft; updates keep the existing state.
+// Labels are applied to new pull requests and must already exist in the repository.
+// Reviewers, TeamReviewers and Assignees are requested on new pull requests on a
+// best-effort basis; failures are reported as run warnings.
 type PullRequestSettings struct {
-	Title           string
-	Body            string
-	ManagedByMarker string
+	Title                  string
+	Body                   string
+	ManagedByMarker        string
+	ManagedByMarkerAliases []string
+	Labels                 []string
+	Reviewers              []string
+	TeamReviewers          []string
+	Assignees              []string
+	MinUpdateInterval      time.Duration
+	Draft                  bool
+	DraftBelowChangeRatio  float64
+	CloseSuperseded        bool
+	// HideComparison leaves the profile comparison out of the body while it still feeds
+	// change thresholds and DraftBelowChangeRatio.
+	HideComparison bool
+}
+
+// isManaged reports whether a pull request body carries the managed marker or one of its aliases.
+func (settings PullRequestSettings) isManaged(body string) bool {
+	for _, marker := range settings.managedMarkers() {
+		if strings.Contains(body, marker) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// managedMarkers returns the managed marker followed by its non-empty aliases.
+func (settings PullRequestSettings) managedMarkers() []string {
+	markers := []string{settings.ManagedByMarker}
+	for _, alias := range settings.ManagedByMarkerAliases {
+		if alias != "" {
+			markers = append(markers, alias)
+		}
+	}
+
+	return markers
+}
+
+// VerifySettings controls post-commit toolchain verification.
+type VerifySettings struct {
+	RevertOnFailure bool
+}
+
+// StatusSettings controls the commit status reported on the base branch head.
+// An empty Context disables status reporting.
+type StatusSettings struct {
+	Context string
 }
 
 // CommitSettings defines commit metadata for profile updates.
+// Each identity's name and email are set together; unset identities keep the
+// author and committer the credentials default to.
+// ProvenanceTrailers adds the redacted profile source, sampling window and
+// collection time to the commit message as git trailers.
 type CommitSettings struct {
-	Message string
+	Message            string
+	AuthorName         string
+	AuthorEmail        string
+	CommitterName      string
+	CommitterEmail     string
+	DCO                bool
+	ProvenanceTrailers bool
 }
 
-// normalized validates required fields and applies cpgo defaults.
+// author returns the configured commit author identity.
+func (settings CommitSettings) author() CommitIdentity {
+	return CommitIdentity{Name: settings.AuthorName, Email: settings.AuthorEmail}
+}
+
+// committer returns the configured commit committer identity.
+func (settings CommitSettings) committer() CommitIdentity {
+	return CommitIdentity{Name: settings.CommitterName, Email: settings.CommitterEmail}
+}
+
+// FieldError is a run request problem, named by the config field it belongs to.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+// Error returns the field path followed by the problem.
+func (fieldErr *FieldError) Error() string {
+	return fieldErr.Field + ": " + fieldErr.Err.Error()
+}
+
+// Unwrap returns the underlying problem.
+func (fieldErr *FieldError) Unwrap() error {
+	return fieldErr.Err
+}
+
+// Validate reports every invalid or conflicting field of the request, each as a *FieldError,
+// joined with errors.Join. Unset fields that cpgo defaults are not problems.
+func (req RunRequest) Validate() error {
+	var problems []error
+	check := func(field string, err error) {
+		problems = append(problems, &FieldError{Field: field, Err: err})
+	}
+
+	if req.DryRunDiff && !req.DryRun {
+		check("dry_run_diff", fmt.Errorf("requires a dry run"))
+	}
+
+	profileURL := req.Profile.URL
+	switch {
+	case profileURL == nil:
+		check("profile.url", fmt.Errorf("is required"))
+	case profileURL.Scheme == fileURLScheme:
+		if profileURL.Path == "" {
+			check("profile.url", fmt.Errorf("file url must include a path"))
+		}
+	case profileURL.Scheme == "" || profileURL.Host == "":
+		check("profile.url", fmt.Errorf("must include scheme and host"))
+	}
+
+	method := strings.ToUpper(strings.TrimSpace(req.Profile.Method))
+	switch method {
+	case "":
+		method = profileMethodGet
+	case profileMethodGet, profileMethodPost:
+	default:
+		check("profile.method", fmt.Errorf("unsupported method %q, want GET or POST", req.Profile.Method))
+	}
+
+	if len(req.Profile.Body) > 0 && method != profileMethodPost {
+		check("profile.method", fmt.Errorf("body requires the POST method"))
+	}
+
+	profileType := req.Profile.Type
+	omitSeconds := req.Profile.OmitSeconds
+	switch profileType {
+	case "", ProfileTypeCPU:
+		profileType = ProfileTypeCPU
+	case ProfileTypeHeap, ProfileTypeMutex, ProfileTypeBlock:
+		if req.Profile.Seconds != 0 {
+			check("profile.seconds", fmt.Errorf("only apply to cpu profiles, not %s", profileType))
+		}
+
+		omitSeconds = true
+	default:
+		check("profile.type", fmt.Errorf("unsupported profile type %q", profileType))
+	}
+
+	if omitSeconds && profileType == ProfileTypeCPU {
+		// Go's cpu endpoint needs a window to sample over, whatever path it is served under.
+		if req.Profile.Seconds != 0 {
+			check("profile.seconds", fmt.Errorf("must be zero when omitted"))
+		} else if profileURL != nil && (profileURL.Scheme == "http" || profileURL.Scheme == "https") {
+			check("profile.seconds", fmt.Errorf("cpu profiles fetched over http require positive seconds; set profile.type for heap, mutex or block profiles"))
+		}
+	}
+
+	if strings.TrimSpace(req.Repository.Owner) == "" {
+		check("repository.owner", fmt.Errorf("is required"))
+	}
+
+	if strings.TrimSpace(req.Repository.Name) == "" {
+		check("repository.name", fmt.Errorf("is required"))
+	}
+
+	pgoPaths := normalizedPGOPaths(req.Repository.PGOPath, req.Repository.PGOPaths)
+	if len(pgoPaths) == 0 {
+		check("repository.pgo_path", fmt.Errorf("is required"))
+	}
+
+	if req.Repository.MaxShrinkRatio < 0 || req.Repository.MaxShrinkRatio >= 1 {
+		check("repository.max_shrink_ratio", fmt.Errorf("must be at least 0 and below 1"))
+	}
+
+	if req.Repository.MinChangeRatio < 0 || req.Repository.MinChangeRatio > 1 {
+		check("repository.min_change_ratio", fmt.Errorf("must be between 0 and 1"))
+	}
+
+	if req.Repository.MinChangeSamples < 0 {
+		check("repository.min_change_samples", fmt.Errorf("must not be negative"))
+	}
+
+	if req.Repository.CommitToBase {
+		if err := req.validateCommitToBase(); err != nil {
+			check("repository.commit_to_base", err)
+		}
+	}
+
+	if req.Repository.CleanupStaleBranch && req.Repository.BranchPerRun {
+		check("repository.cleanup_stale_branch", fmt.Errorf("does not apply to branch per run"))
+	}
+
+	if req.Repository.MinChange < 0 || req.Repository.MinChange > 1 {
+		check("profile.min_change", fmt.Errorf("must be between 0 and 1"))
+	}
+
+	switch mode := strings.TrimSpace(req.Repository.ChangeThresholdMode); mode {
+	case "", ChangeThresholdAll, ChangeThresholdAny:
+	default:
+		check("repository.change_threshold_mode", fmt.Errorf("unsupported change threshold mode %q", mode))
+	}
+
+	for index, extraFile := range req.ExtraFiles {
+		field := fmt.Sprintf("extra_files[%d].path", index)
+		if strings.TrimSpace(extraFile.Path) == "" {
+			check(field, fmt.Errorf("is required"))
+		} else if slices.Contains(pgoPaths, extraFile.Path) {
+			check(field, fmt.Errorf("%s overlaps the pgo path", extraFile.Path))
+		}
+	}
+
+	instanceID := strings.TrimSpace(req.Repository.InstanceID)
+	if instanceID != "" && !isValidInstanceID(instanceID) {
+		check("repository.instance_id", fmt.Errorf("%q may only contain letters, digits, '-', '_' and '.'", instanceID))
+	}
+
+	if isTemplatedHeadBranch(req.Repository.HeadBranch) {
+		if _, err := parseHeadBranchTemplate(req.Repository.HeadBranch); err != nil {
+			check("repository.head_branch", err)
+		}
+
+		if req.Repository.BranchPerRun {
+			check("repository.branch_per_run", fmt.Errorf("does not apply to a templated head branch"))
+		}
+
+		if req.Repository.CleanupStaleBranch {
+			check("repository.cleanup_stale_branch", fmt.Errorf("does not apply to a templated head branch"))
+		}
+	}
+
+	if req.PullRequest.DraftBelowChangeRatio < 0 || req.PullRequest.DraftBelowChangeRatio > 1 {
+		check("pull_request.draft_below_change_ratio", fmt.Errorf("must be between 0 and 1"))
+	}
+
+	if req.PullRequest.MinUpdateInterval < 0 {
+		check("pull_request.min_update_interval", fmt.Errorf("must not be negative"))
+	}
+
+	for _, runTemplate := range req.runTemplates() {
+		if isTemplatedText(runTemplate.text) {
+			if _, err := parseRunTemplate(runTemplate.name, runTemplate.text); err != nil {
+				check(runTemplate.field, err)
+			}
+		}
+	}
+
+	committerName := strings.TrimSpace(req.Commit.CommitterName)
+	if (strings.TrimSpace(req.Commit.AuthorName) == "") != (strings.TrimSpace(req.Commit.AuthorEmail) == "") {
+		check("commit.author_name", fmt.Errorf("author name and email must be set together"))
+	}
+
+	if (committerName == "") != (strings.TrimSpace(req.Commit.CommitterEmail) == "") {
+		check("commit.committer_name", fmt.Errorf("committer name and email must be set together"))
+	}
+
+	if req.Commit.DCO && committerName == "" {
+		check("commit.dco", fmt.Errorf("requires the committer name and email"))
+	}
+
+	return errors.Join(problems...)
+}
+
+// normalized validates the request and applies cpgo defaults.
 func (req RunRequest) normalized() (RunRequest, error) {
+	if err := req.Validate(); err != nil {
+		return RunRequest{}, err
+	}
+
 	normalized := req
 
-	if normalized.Profile.URL == nil {
-		return RunRequest{}, fmt.Errorf("profile url is required")
+	normalized.Profile.Method = strings.ToUpper(strings.TrimSpace(normalized.Profile.Method))
+	if normalized.Profile.Method == "" {
+		normalized.Profile.Method = profileMethodGet
 	}
 
-	if normalized.Profile.URL.Scheme == "" || normalized.Profile.URL.Host == "" {
-		return RunRequest{}, fmt.Errorf("profile url must include scheme and host")
+	switch normalized.Profile.Type {
+	case "":
+		normalized.Profile.Type = ProfileTypeCPU
+	case ProfileTypeHeap, ProfileTypeMutex, ProfileTypeBlock:
+		normalized.Profile.OmitSeconds = true
 	}
 
-	if normalized.Profile.Seconds <= 0 {
+	if !normalized.Profile.OmitSeconds && normalized.Profile.Seconds <= 0 {
 		normalized.Profile.Seconds = defaultProfileSeconds
 	}
 
-	if strings.TrimSpace(normalized.Repository.Owner) == "" {
-		return RunRequest{}, fmt.Errorf("repository owner is required")
-	}
+	pgoPaths := normalizedPGOPaths(normalized.Repository.PGOPath, normalized.Repository.PGOPaths)
+	normalized.Repository.PGOPath = pgoPaths[0]
+	normalized.Repository.PGOPaths = pgoPaths
 
-	if strings.TrimSpace(normalized.Repository.Name) == "" {
-		return RunRequest{}, fmt.Errorf("repository name is required")
-	}
+	normalized.Repository.CompareRef = strings.TrimSpace(normalized.Repository.CompareRef)
 
-	if strings.TrimSpace(normalized.Repository.PGOPath) == "" {
-		return RunRequest{}, fmt.Errorf("repository pgo path is required")
+	if strings.TrimSpace(normalized.Repository.ChangeThresholdMode) == ChangeThresholdAny {
+		normalized.Repository.ChangeThresholdMode = ChangeThresholdAny
+	} else {
+		normalized.Repository.ChangeThresholdMode = ChangeThresholdAll
 	}
 
+	instanceID := strings.TrimSpace(normalized.Repository.InstanceID)
+	normalized.Repository.InstanceID = instanceID
+
 	if strings.TrimSpace(normalized.Repository.HeadBranch) == "" {
-		normalized.Repository.HeadBranch = defaultHeadBranch
+		normalized.Repository.HeadBranch = instanceHeadBranch(instanceID)
 	}
 
 	if strings.TrimSpace(normalized.PullRequest.ManagedByMarker) == "" {
-		normalized.PullRequest.ManagedByMarker = defaultManagedByMarker
+		normalized.PullRequest.ManagedByMarker = instanceManagedByMarker(instanceID)
 	}
 
 	if strings.TrimSpace(normalized.PullRequest.Title) == "" {
-		normalized.PullRequest.Title = defaultPRTitle
+		normalized.PullRequest.Title = withProfileType(defaultPRTitle, normalized.Profile.Type)
 	}
 
 	if strings.TrimSpace(normalized.PullRequest.Body) == "" {
-		normalized.PullRequest.Body = defaultPRBody
+		normalized.PullRequest.Body = withProfileType(defaultPRBody, normalized.Profile.Type)
 	}
 
 	if strings.TrimSpace(normalized.Commit.Message) == "" {
-		normalized.Commit.Message = defaultCommitMessage
+		normalized.Commit.Message = withProfileType(defaultCommitMessage, normalized.Profile.Type)
+	}
+
+	normalized.Commit.AuthorName = strings.TrimSpace(normalized.Commit.AuthorName)
+	normalized.Commit.AuthorEmail = strings.TrimSpace(normalized.Commit.AuthorEmail)
+	normalized.Commit.CommitterName = strings.TrimSpace(normalized.Commit.CommitterName)
+	normalized.Commit.CommitterEmail = strings.TrimSpace(normalized.Commit.CommitterEmail)
+
+	if normalized.Commit.DCO {
+		normalized.Commit.Message = appendSignOff(normalized.Commit.Message, normalized.Commit.CommitterName, normalized.Commit.CommitterEmail)
 	}
 
 	return normalized, nil
 }
+
+// appendSignOff adds a Developer Certificate of Origin trailer unless it is already present.
+func appendSignOff(message string, name string, email string) string {
+	trailer := fmt.Sprintf("Signed-off-by: %s <%s>", strings.TrimSpace(name), strings.TrimSpace(email))
+	if strings.Contains(message, trailer) {
+		return message
+	}
+
+	return strings.TrimRight(message, "\n") + "\n\n" + trailer
+}
+
+// validateCommitToBase rejects settings that need a head branch, or that would
+// rewrite the base branch, alongside committing to the base branch.
+func (req RunRequest) validateCommitToBase() error {
+	switch {
+	case req.Repository.BranchPerRun:
+		return fmt.Errorf("does not apply to branch per run")
+	case isTemplatedHeadBranch(req.Repository.HeadBranch):
+		return fmt.Errorf("does not apply to a templated head branch")
+	case req.Repository.CleanupStaleBranch:
+		return fmt.Errorf("does not apply to cleanup stale branch")
+	case req.Verify.RevertOnFailure:
+		return fmt.Errorf("cannot revert a failed verification on the base branch")
+	}
+
+	return nil
+}
+
+// instanceHeadBranch returns the default head branch for an instance.
+func instanceHeadBranch(instanceID string) string {
+	if instanceID == "" {
+		return defaultHeadBranch
+	}
+
+	return defaultHeadBranch + "-" + instanceID
+}
+
+// instanceManagedByMarker returns the default managed marker for an instance.
+func instanceManagedByMarker(instanceID string) string {
+	if instanceID == "" {
+		return defaultManagedByMarker
+	}
+
+	return "<!-- managed-by:cpgo:" + instanceID + " -->"
+}
+
+// isValidInstanceID reports whether an instance id is safe in branch names and HTML comments.
+func isValidInstanceID(instanceID string) bool {
+	for _, r := range instanceID {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+
+	return !strings.HasPrefix(instanceID, ".") && !strings.Contains(instanceID, "..")
+}
+
+// normalizedPGOPaths merges the primary pgo path with additional targets, primary first.
+func normalizedPGOPaths(pgoPath string, pgoPaths []string) []string {
+	var normalized []string
+	for _, path := range append([]string{pgoPath}, pgoPaths...) {
+		path = strings.TrimSpace(path)
+		if path == "" || slices.Contains(normalized, path) {
+			continue
+		}
+
+		normalized = append(normalized, path)
+	}
+
+	return normalized
+}
+
+// withProfileType names non-cpu profile types in a default message,
+// e.g. "refresh pgo profile" becomes "refresh pgo heap profile".
+func withProfileType(message string, profileType ProfileType) string {
+	if profileType == ProfileTypeCPU {
+		return message
+	}
+
+	return strings.Replace(message, " profile", " "+string(profileType)+" profile", 1)
+}
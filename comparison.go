@@ -0,0 +1,151 @@
+package cpgo
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// comparisonUnavailable replaces the profile diff in pull request bodies when the comparison failed.
+const comparisonUnavailable = "Profile comparison unavailable; see the cpgo run warnings."
+
+// compareWithBase compares the base branch profile with the fetched profile when both are available.
+func (svc *Service) compareWithBase(previous ReadFileResult, current []byte) (*ProfileComparison, error) {
+	if svc.profileComparer == nil || !previous.HasFile {
+		return nil, nil
+	}
+
+	comparison, err := svc.profileComparer.CompareProfiles(previous.Content, current)
+	if err != nil {
+		return nil, err
+	}
+
+	return &comparison, nil
+}
+
+// checkDiffPolicy rejects profiles whose change from the base profile violates the diff policy.
+func (svc *Service) checkDiffPolicy(previous ReadFileResult, current []byte) error {
+	if svc.diffPolicy == nil {
+		return nil
+	}
+
+	var previousContent []byte
+	if previous.HasFile {
+		previousContent = previous.Content
+	}
+
+	if err := svc.diffPolicy.CheckProfileDiff(previousContent, current); err != nil {
+		return fmt.Errorf("%w: %w", ErrDiffRuleViolation, err)
+	}
+
+	return nil
+}
+
+// comparisonSection renders the profile comparison section for the pull request body.
+// Comparison errors are reported as run warnings; the body only notes that the diff is missing,
+// so comparer output such as file paths never reaches the pull request.
+func comparisonSection(comparison *ProfileComparison, err error) string {
+	if err != nil {
+		// A corrupt base profile is a reason to refresh it, so the comparison never blocks the run.
+		return comparisonUnavailable
+	}
+
+	if comparison == nil {
+		return ""
+	}
+
+	if strings.TrimSpace(comparison.TextDiff) == "" {
+		return ""
+	}
+
+	return "<details>\n<summary>Profile diff (pprof -top)</summary>\n\n```diff\n" +
+		strings.TrimRight(comparison.TextDiff, "\n") +
+		"\n```\n\n</details>"
+}
+
+// summarySection renders the function summary table, omitting it without a base profile or on failure.
+func (svc *Service) summarySection(previous ReadFileResult, current []byte) string {
+	if svc.profileSummarizer == nil || !previous.HasFile {
+		return ""
+	}
+
+	summary, err := svc.profileSummarizer.SummarizeProfileChange(previous.Content, current)
+	if err != nil {
+		// Like labels, the summary only aids review; a corrupt base profile is still worth replacing.
+		return ""
+	}
+
+	if strings.TrimSpace(summary) == "" {
+		return ""
+	}
+
+	return "### Hottest functions\n\n" + strings.TrimRight(summary, "\n")
+}
+
+// isMinorChange reports whether a comparison moved less than the draft threshold.
+func isMinorChange(comparison *ProfileComparison, draftBelowChangeRatio float64) bool {
+	if comparison == nil || draftBelowChangeRatio <= 0 {
+		return false
+	}
+
+	return comparison.ChangeRatio < draftBelowChangeRatio
+}
+
+// isBelowChangeThreshold reports whether a comparison falls short of the configured change thresholds.
+// Profiles that cannot be compared are never held back.
+func isBelowChangeThreshold(settings RepositorySettings, comparison *ProfileComparison) bool {
+	if comparison == nil {
+		return false
+	}
+
+	var exceeded []bool
+	if settings.MinChangeRatio > 0 {
+		exceeded = append(exceeded, comparison.ChangeRatio > settings.MinChangeRatio)
+	}
+
+	if settings.MinChangeSamples > 0 {
+		exceeded = append(exceeded, comparison.SampleDelta > settings.MinChangeSamples)
+	}
+
+	if settings.MinChange > 0 {
+		exceeded = append(exceeded, 1-comparison.Similarity > settings.MinChange)
+	}
+
+	if len(exceeded) == 0 {
+		return false
+	}
+
+	if settings.ChangeThresholdMode == ChangeThresholdAny {
+		return !slices.Contains(exceeded, true)
+	}
+
+	return slices.Contains(exceeded, false)
+}
+
+// appendSection appends a markdown section to a pull request body.
+func appendSection(body string, section string) string {
+	if strings.TrimSpace(section) == "" {
+		return body
+	}
+
+	if strings.TrimSpace(body) == "" {
+		return section
+	}
+
+	return strings.TrimRight(body, "\n") + "\n\n" + section
+}
+
+// profileLabels derives triage labels, omitting them when the profile cannot be labeled.
+func (svc *Service) profileLabels(profile []byte) []string {
+	if svc.profileLabeler == nil {
+		return nil
+	}
+
+	labels, err := svc.profileLabeler.ProfileLabels(profile)
+	if err != nil {
+		// Labels only aid triage; the validated profile is still worth proposing without them.
+		return nil
+	}
+
+	return labels
+}
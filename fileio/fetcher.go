@@ -0,0 +1,59 @@
+package fileio
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"cpgo"
+)
+
+const schemeFile = "file"
+
+// Fetcher reads pre-collected profiles from `file:///path` URLs on the local disk.
+type Fetcher struct{}
+
+var _ cpgo.ProfileFetcher = (*Fetcher)(nil)
+
+// NewFetcher returns a local file profile fetcher.
+func NewFetcher() *Fetcher {
+	return &Fetcher{}
+}
+
+// FetchCPUProfile reads the profile file; the sampling window does not apply.
+func (fetcher *Fetcher) FetchCPUProfile(ctx context.Context, req cpgo.FetchProfileRequest) (cpgo.FetchProfileResult, error) {
+	if req.URL == nil {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("profile url is required")
+	}
+
+	if req.URL.Scheme != schemeFile {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("profile url scheme must be %s, got %q", schemeFile, req.URL.Scheme)
+	}
+
+	if req.URL.Host != "" && req.URL.Host != "localhost" {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("profile url must reference a local file, got host %q", req.URL.Host)
+	}
+
+	if req.URL.Path == "" {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("profile url must have the form file:///path/to/profile")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("read profile file: %w", err)
+	}
+
+	profile, err := os.ReadFile(req.URL.Path)
+	if err != nil {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("read profile file: %w", err)
+	}
+
+	if len(profile) == 0 {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("profile file %s is empty", req.URL.Path)
+	}
+
+	sourceURL := *req.URL
+	return cpgo.FetchProfileResult{
+		Content:   profile,
+		SourceURL: &sourceURL,
+	}, nil
+}
@@ -0,0 +1,58 @@
+package fileio
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cpgo"
+)
+
+func TestFetcherFetchCPUProfile(t *testing.T) {
+	t.Run("reads the profile from disk", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cpu.pprof")
+		if err := os.WriteFile(path, []byte("profile-bytes"), 0o600); err != nil {
+			t.Fatalf("write profile: %v", err)
+		}
+
+		profileURL := &url.URL{Scheme: "file", Path: path}
+		result, err := NewFetcher().FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{
+			URL:     profileURL,
+			Seconds: 30,
+		})
+		if err != nil {
+			t.Fatalf("fetch profile: %v", err)
+		}
+
+		if string(result.Content) != "profile-bytes" || result.SourceURL.String() != profileURL.String() {
+			t.Fatalf("unexpected result %q from %s", result.Content, result.SourceURL)
+		}
+	})
+
+	t.Run("rejects missing and empty files", func(t *testing.T) {
+		empty := filepath.Join(t.TempDir(), "empty.pprof")
+		if err := os.WriteFile(empty, nil, 0o600); err != nil {
+			t.Fatalf("write profile: %v", err)
+		}
+
+		for _, path := range []string{empty, filepath.Join(t.TempDir(), "missing.pprof")} {
+			_, err := NewFetcher().FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{
+				URL: &url.URL{Scheme: "file", Path: path},
+			})
+			if err == nil {
+				t.Fatalf("expected error for %s", path)
+			}
+		}
+	})
+
+	t.Run("rejects remote hosts", func(t *testing.T) {
+		_, err := NewFetcher().FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{
+			URL: &url.URL{Scheme: "file", Host: "fileserver", Path: "/cpu.pprof"},
+		})
+		if err == nil {
+			t.Fatalf("expected remote host error")
+		}
+	})
+}
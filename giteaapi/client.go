@@ -0,0 +1,885 @@
+package giteaapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"cpgo"
+)
+
+const (
+	apiPath                  = "/api/v1"
+	defaultHTTPClientTimeout = 30 * time.Second
+	listPageSize             = 50
+	errorPreviewBytes        = 4 * 1024
+	draftTitlePrefix         = "WIP: "
+	fileTypeFile             = "file"
+)
+
+// Options configures access to a Gitea or Forgejo instance.
+// BaseURL is the instance root, e.g. https://forgejo.example.com; the /api/v1 suffix is added.
+type Options struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// Client implements repository and pull request ports via the Gitea REST API, which Forgejo shares.
+// Commits go through the file contents API, which updates files by their blob sha and cannot
+// force-push, so an existing head branch always gains a commit on top instead of being rebuilt
+// from the base branch. Symlinks cannot be written through the API.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+}
+
+var _ cpgo.BranchWriter = (*Client)(nil)
+var _ cpgo.PullRequestService = (*Client)(nil)
+var _ cpgo.TagWriter = (*Client)(nil)
+var _ cpgo.StatusReporter = (*Client)(nil)
+
+// apiError is a non-2xx Gitea API response.
+type apiError struct {
+	StatusCode int
+	Message    string
+}
+
+func (err *apiError) Error() string {
+	if err.Message == "" {
+		return fmt.Sprintf("unexpected status %d", err.StatusCode)
+	}
+
+	return fmt.Sprintf("unexpected status %d: %s", err.StatusCode, err.Message)
+}
+
+type repository struct {
+	DefaultBranch string `json:"default_branch"`
+}
+
+type branch struct {
+	Commit branchCommit `json:"commit"`
+}
+
+type branchCommit struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type tag struct {
+	Commit struct {
+		SHA string `json:"sha"`
+	} `json:"commit"`
+}
+
+type contentsEntry struct {
+	Type string `json:"type"`
+	SHA  string `json:"sha"`
+}
+
+type identity struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// fileOperation is one file change of a commit created through the contents API.
+type fileOperation struct {
+	Operation string `json:"operation"`
+	Path      string `json:"path"`
+	Content   string `json:"content,omitempty"`
+	SHA       string `json:"sha,omitempty"`
+}
+
+type changeFilesRequest struct {
+	Branch    string          `json:"branch"`
+	NewBranch string          `json:"new_branch,omitempty"`
+	Message   string          `json:"message"`
+	Author    *identity       `json:"author,omitempty"`
+	Committer *identity       `json:"committer,omitempty"`
+	Files     []fileOperation `json:"files"`
+}
+
+type changeFilesResponse struct {
+	Commit struct {
+		SHA string `json:"sha"`
+	} `json:"commit"`
+}
+
+type pullRequest struct {
+	Number  int            `json:"number"`
+	Title   string         `json:"title"`
+	Body    string         `json:"body"`
+	HTMLURL string         `json:"html_url"`
+	Head    pullRequestRef `json:"head"`
+	Base    pullRequestRef `json:"base"`
+}
+
+type pullRequestRef struct {
+	Ref  string `json:"ref"`
+	Repo struct {
+		FullName string `json:"full_name"`
+	} `json:"repo"`
+}
+
+type createPullRequestRequest struct {
+	Head   string  `json:"head"`
+	Base   string  `json:"base"`
+	Title  string  `json:"title"`
+	Body   string  `json:"body"`
+	Labels []int64 `json:"labels,omitempty"`
+}
+
+type label struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// NewClient validates options and returns a Gitea API client.
+func NewClient(options Options) (*Client, error) {
+	token := strings.TrimSpace(options.Token)
+	if token == "" {
+		return nil, fmt.Errorf("gitea token is required")
+	}
+
+	baseURL := strings.TrimRight(strings.TrimSpace(options.BaseURL), "/")
+	parsed, err := url.Parse(baseURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("gitea base url must include scheme and host")
+	}
+
+	return &Client{
+		httpClient: withDefaultTimeout(options.HTTPClient),
+		baseURL:    strings.TrimSuffix(baseURL, apiPath) + apiPath,
+		token:      token,
+	}, nil
+}
+
+// DefaultBranch returns the configured repository default branch.
+func (client *Client) DefaultBranch(ctx context.Context, repositoryRef cpgo.RepositoryRef) (string, error) {
+	if err := validateRepositoryRef(repositoryRef); err != nil {
+		return "", err
+	}
+
+	var decoded repository
+	if _, err := client.doJSON(ctx, http.MethodGet, repositoryPath(repositoryRef), nil, nil, &decoded); err != nil {
+		return "", fmt.Errorf("get repository: %w", err)
+	}
+
+	defaultBranch := strings.TrimSpace(decoded.DefaultBranch)
+	if defaultBranch == "" {
+		return "", fmt.Errorf("repository default branch is empty")
+	}
+
+	return defaultBranch, nil
+}
+
+// ReadFile returns raw file bytes from a branch.
+func (client *Client) ReadFile(ctx context.Context, req cpgo.ReadFileRequest) (cpgo.ReadFileResult, error) {
+	if err := validateRepositoryRef(req.Repository); err != nil {
+		return cpgo.ReadFileResult{}, err
+	}
+
+	if strings.TrimSpace(req.Branch) == "" {
+		return cpgo.ReadFileResult{}, fmt.Errorf("branch is required")
+	}
+
+	if strings.TrimSpace(req.Path) == "" {
+		return cpgo.ReadFileResult{}, fmt.Errorf("path is required")
+	}
+
+	resp, err := client.send(ctx, http.MethodGet, repositoryPath(req.Repository)+"/raw/"+escapeFilePath(req.Path), url.Values{"ref": {req.Branch}}, nil)
+	if err != nil {
+		if isNotFound(err) {
+			return cpgo.ReadFileResult{HasFile: false}, nil
+		}
+
+		return cpgo.ReadFileResult{}, fmt.Errorf("get file %s: %w", req.Path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return cpgo.ReadFileResult{}, fmt.Errorf("read file %s: %w", req.Path, err)
+	}
+
+	return cpgo.ReadFileResult{
+		Content: content,
+		HasFile: true,
+	}, nil
+}
+
+// DeleteBranch deletes the branch, reporting false when the branch was already missing.
+func (client *Client) DeleteBranch(ctx context.Context, repositoryRef cpgo.RepositoryRef, branchName string) (bool, error) {
+	if err := validateRepositoryRef(repositoryRef); err != nil {
+		return false, err
+	}
+
+	if strings.TrimSpace(branchName) == "" {
+		return false, fmt.Errorf("branch is required")
+	}
+
+	_, err := client.doJSON(ctx, http.MethodDelete, branchPath(repositoryRef, branchName), nil, nil, nil)
+	if isNotFound(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("delete branch: %w", err)
+	}
+
+	return true, nil
+}
+
+// LastCommitTime returns the time of the branch head commit.
+func (client *Client) LastCommitTime(ctx context.Context, repositoryRef cpgo.RepositoryRef, branchName string) (time.Time, bool, error) {
+	if err := validateRepositoryRef(repositoryRef); err != nil {
+		return time.Time{}, false, err
+	}
+
+	if strings.TrimSpace(branchName) == "" {
+		return time.Time{}, false, fmt.Errorf("branch is required")
+	}
+
+	head, hasBranch, err := client.getBranch(ctx, repositoryRef, branchName)
+	if err != nil || !hasBranch {
+		return time.Time{}, false, err
+	}
+
+	return head.Commit.Timestamp, true, nil
+}
+
+// BranchHead returns the commit SHA at the head of the branch.
+func (client *Client) BranchHead(ctx context.Context, repositoryRef cpgo.RepositoryRef, branchName string) (string, bool, error) {
+	if err := validateRepositoryRef(repositoryRef); err != nil {
+		return "", false, err
+	}
+
+	if strings.TrimSpace(branchName) == "" {
+		return "", false, fmt.Errorf("branch is required")
+	}
+
+	head, hasBranch, err := client.getBranch(ctx, repositoryRef, branchName)
+	if err != nil || !hasBranch {
+		return "", false, err
+	}
+
+	return head.Commit.ID, true, nil
+}
+
+// UpsertFileAndForceBranch writes every file operation in one commit through the contents API.
+// A missing head branch is created from the base branch and an existing one gains the commit
+// on top. The API cannot force-push, so ForceUpdate is rejected for an existing head branch.
+func (client *Client) UpsertFileAndForceBranch(ctx context.Context, req cpgo.UpsertFileRequest) (cpgo.UpsertFileResult, error) {
+	if err := validateRepositoryRef(req.Repository); err != nil {
+		return cpgo.UpsertFileResult{}, err
+	}
+
+	if strings.TrimSpace(req.BaseBranch) == "" {
+		return cpgo.UpsertFileResult{}, fmt.Errorf("base branch is required")
+	}
+
+	if strings.TrimSpace(req.HeadBranch) == "" {
+		return cpgo.UpsertFileResult{}, fmt.Errorf("head branch is required")
+	}
+
+	if strings.TrimSpace(req.Path) == "" {
+		return cpgo.UpsertFileResult{}, fmt.Errorf("path is required")
+	}
+
+	if strings.TrimSpace(req.CommitMessage) == "" {
+		return cpgo.UpsertFileResult{}, fmt.Errorf("commit message is required")
+	}
+
+	changes := append([]cpgo.FileChange{{Path: req.Path, Content: req.Content}}, req.AdditionalFiles...)
+	for _, change := range changes {
+		if strings.TrimSpace(change.Path) == "" {
+			return cpgo.UpsertFileResult{}, fmt.Errorf("additional file path is required")
+		}
+
+		if change.Symlink {
+			return cpgo.UpsertFileResult{}, fmt.Errorf("gitea cannot write symlink %s through the api", change.Path)
+		}
+	}
+
+	_, hasHead, err := client.getBranch(ctx, req.Repository, req.HeadBranch)
+	if err != nil {
+		return cpgo.UpsertFileResult{}, err
+	}
+
+	if hasHead && req.ForceUpdate {
+		return cpgo.UpsertFileResult{}, fmt.Errorf("gitea cannot force-push branch %s; set repository.force_push: false", req.HeadBranch)
+	}
+
+	payload := changeFilesRequest{
+		Branch:    req.HeadBranch,
+		Message:   req.CommitMessage,
+		Author:    toIdentity(req.Author),
+		Committer: toIdentity(req.Committer),
+	}
+	if !hasHead {
+		payload.Branch = req.BaseBranch
+		payload.NewBranch = req.HeadBranch
+	}
+
+	payload.Files, err = client.fileOperations(ctx, req.Repository, payload.Branch, changes)
+	if err != nil {
+		return cpgo.UpsertFileResult{}, err
+	}
+
+	var created changeFilesResponse
+	if _, err := client.doJSON(ctx, http.MethodPost, repositoryPath(req.Repository)+"/contents", nil, payload, &created); err != nil {
+		return cpgo.UpsertFileResult{}, fmt.Errorf("change files: %w", err)
+	}
+
+	if strings.TrimSpace(created.Commit.SHA) == "" {
+		return cpgo.UpsertFileResult{}, fmt.Errorf("created commit has empty sha")
+	}
+
+	return cpgo.UpsertFileResult{
+		CommitSHA:       created.Commit.SHA,
+		IsBranchCreated: !hasHead,
+	}, nil
+}
+
+// fileOperations maps file changes to create, update and delete operations against the parent
+// branch, carrying the current blob sha that Gitea requires to update or delete a file.
+func (client *Client) fileOperations(ctx context.Context, repositoryRef cpgo.RepositoryRef, parentBranch string, changes []cpgo.FileChange) ([]fileOperation, error) {
+	operations := make([]fileOperation, 0, len(changes))
+	for _, change := range changes {
+		blobSHA, err := client.fileSHA(ctx, repositoryRef, parentBranch, change.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case change.Delete && blobSHA == "":
+			continue
+		case change.Delete:
+			operations = append(operations, fileOperation{Operation: "delete", Path: change.Path, SHA: blobSHA})
+			continue
+		}
+
+		operation := fileOperation{
+			Operation: "create",
+			Path:      change.Path,
+			Content:   base64.StdEncoding.EncodeToString(change.Content),
+		}
+		if blobSHA != "" {
+			operation.Operation = "update"
+			operation.SHA = blobSHA
+		}
+
+		operations = append(operations, operation)
+	}
+
+	return operations, nil
+}
+
+// fileSHA returns the blob sha of a file on a branch, empty when the file is absent.
+func (client *Client) fileSHA(ctx context.Context, repositoryRef cpgo.RepositoryRef, branchName string, path string) (string, error) {
+	var entry contentsEntry
+	_, err := client.doJSON(ctx, http.MethodGet, repositoryPath(repositoryRef)+"/contents/"+escapeFilePath(path), url.Values{"ref": {branchName}}, nil, &entry)
+	if err != nil {
+		if isNotFound(err) {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("get file %s: %w", path, err)
+	}
+
+	if entry.Type != fileTypeFile {
+		return "", fmt.Errorf("path %q is not a file", path)
+	}
+
+	return entry.SHA, nil
+}
+
+// CreateTag creates a lightweight tag, tolerating a tag that already points at the commit.
+func (client *Client) CreateTag(ctx context.Context, req cpgo.CreateTagRequest) error {
+	if err := validateRepositoryRef(req.Repository); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(req.Name) == "" {
+		return fmt.Errorf("tag name is required")
+	}
+
+	if strings.TrimSpace(req.CommitSHA) == "" {
+		return fmt.Errorf("commit sha is required")
+	}
+
+	tagsPath := repositoryPath(req.Repository) + "/tags"
+	_, err := client.doJSON(ctx, http.MethodPost, tagsPath, nil, map[string]string{
+		"tag_name": req.Name,
+		"target":   req.CommitSHA,
+	}, nil)
+	if err == nil {
+		return nil
+	}
+
+	var existing tag
+	if _, getErr := client.doJSON(ctx, http.MethodGet, tagsPath+"/"+url.PathEscape(req.Name), nil, nil, &existing); getErr == nil && existing.Commit.SHA == req.CommitSHA {
+		return nil
+	}
+
+	return fmt.Errorf("create tag: %w", err)
+}
+
+// ReportStatus creates a commit status on the current head commit of a branch.
+func (client *Client) ReportStatus(ctx context.Context, req cpgo.ReportStatusRequest) error {
+	if err := validateRepositoryRef(req.Repository); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(req.Branch) == "" {
+		return fmt.Errorf("branch is required")
+	}
+
+	if strings.TrimSpace(req.Context) == "" {
+		return fmt.Errorf("status context is required")
+	}
+
+	head, hasBranch, err := client.getBranch(ctx, req.Repository, req.Branch)
+	if err != nil {
+		return err
+	}
+
+	if !hasBranch || strings.TrimSpace(head.Commit.ID) == "" {
+		return fmt.Errorf("branch %s has no head commit", req.Branch)
+	}
+
+	status := map[string]string{
+		"state":       req.State,
+		"context":     req.Context,
+		"description": req.Description,
+	}
+	if req.TargetURL != "" {
+		status["target_url"] = req.TargetURL
+	}
+
+	if _, err := client.doJSON(ctx, http.MethodPost, repositoryPath(req.Repository)+"/statuses/"+head.Commit.ID, nil, status, nil); err != nil {
+		if isForbidden(err) {
+			return fmt.Errorf("create commit status: %w: %v", cpgo.ErrStatusNotPermitted, err)
+		}
+
+		return fmt.Errorf("create commit status: %w", err)
+	}
+
+	return nil
+}
+
+// FindOpenByHead pages through open PRs for one base/head pair in the same repository,
+// preferring the first whose body carries a managed marker over the first match.
+func (client *Client) FindOpenByHead(ctx context.Context, req cpgo.FindPullRequestRequest) (*cpgo.PullRequest, error) {
+	if err := validateRepositoryRef(req.Repository); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(req.BaseBranch) == "" {
+		return nil, fmt.Errorf("base branch is required")
+	}
+
+	if strings.TrimSpace(req.HeadBranch) == "" {
+		return nil, fmt.Errorf("head branch is required")
+	}
+
+	pullRequests, err := client.listOpenPullRequests(ctx, req.Repository)
+	if err != nil {
+		return nil, err
+	}
+
+	var first *cpgo.PullRequest
+	for _, pullRequest := range pullRequests {
+		if pullRequest.Base.Ref != req.BaseBranch || pullRequest.Head.Ref != req.HeadBranch || !isSameRepository(pullRequest, req.Repository) {
+			continue
+		}
+
+		match := toPullRequest(pullRequest)
+		if containsAny(match.Body, req.ManagedMarkers) {
+			return &match, nil
+		}
+
+		if first == nil {
+			first = &match
+		}
+	}
+
+	return first, nil
+}
+
+func containsAny(body string, markers []string) bool {
+	for _, marker := range markers {
+		if marker != "" && strings.Contains(body, marker) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ListOpenByHeadPrefix pages through open PRs against the base branch and keeps same-repository heads with the prefix.
+func (client *Client) ListOpenByHeadPrefix(ctx context.Context, req cpgo.ListPullRequestsRequest) ([]cpgo.PullRequest, error) {
+	if err := validateRepositoryRef(req.Repository); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(req.BaseBranch) == "" {
+		return nil, fmt.Errorf("base branch is required")
+	}
+
+	if strings.TrimSpace(req.HeadBranchPrefix) == "" {
+		return nil, fmt.Errorf("head branch prefix is required")
+	}
+
+	pullRequests, err := client.listOpenPullRequests(ctx, req.Repository)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []cpgo.PullRequest
+	for _, pullRequest := range pullRequests {
+		if pullRequest.Base.Ref != req.BaseBranch || !isSameRepository(pullRequest, req.Repository) {
+			continue
+		}
+
+		if strings.HasPrefix(pullRequest.Head.Ref, req.HeadBranchPrefix) {
+			matches = append(matches, toPullRequest(pullRequest))
+		}
+	}
+
+	return matches, nil
+}
+
+// listOpenPullRequests pages through every open PR; the API has no branch filters.
+func (client *Client) listOpenPullRequests(ctx context.Context, repositoryRef cpgo.RepositoryRef) ([]pullRequest, error) {
+	var pullRequests []pullRequest
+	for page := 1; ; page++ {
+		var batch []pullRequest
+		_, err := client.doJSON(ctx, http.MethodGet, repositoryPath(repositoryRef)+"/pulls", url.Values{
+			"state": {"open"},
+			"limit": {strconv.Itoa(listPageSize)},
+			"page":  {strconv.Itoa(page)},
+		}, nil, &batch)
+		if err != nil {
+			return nil, fmt.Errorf("list pull requests: %w", err)
+		}
+
+		pullRequests = append(pullRequests, batch...)
+		if len(batch) < listPageSize {
+			return pullRequests, nil
+		}
+	}
+}
+
+// Close comments on a pull request and then closes it.
+func (client *Client) Close(ctx context.Context, req cpgo.ClosePullRequestRequest) error {
+	if err := validateRepositoryRef(req.Repository); err != nil {
+		return err
+	}
+
+	if req.Number <= 0 {
+		return fmt.Errorf("pull request number must be positive")
+	}
+
+	if strings.TrimSpace(req.Comment) != "" {
+		commentsPath := fmt.Sprintf("%s/issues/%d/comments", repositoryPath(req.Repository), req.Number)
+		if _, err := client.doJSON(ctx, http.MethodPost, commentsPath, nil, map[string]string{"body": req.Comment}, nil); err != nil {
+			return fmt.Errorf("comment on pull request #%d: %w", req.Number, err)
+		}
+	}
+
+	pullPath := fmt.Sprintf("%s/pulls/%d", repositoryPath(req.Repository), req.Number)
+	if _, err := client.doJSON(ctx, http.MethodPatch, pullPath, nil, map[string]string{"state": "closed"}, nil); err != nil {
+		return fmt.Errorf("close pull request #%d: %w", req.Number, err)
+	}
+
+	return nil
+}
+
+// Create opens a pull request; a draft gets Gitea's WIP: title prefix.
+// Labels are resolved to ids up front; missing optional labels, reviewers and
+// assignees become warnings because the pull request is useful without them.
+func (client *Client) Create(ctx context.Context, req cpgo.CreatePullRequestRequest) (cpgo.PullRequest, error) {
+	if err := validateRepositoryRef(req.Repository); err != nil {
+		return cpgo.PullRequest{}, err
+	}
+
+	if strings.TrimSpace(req.BaseBranch) == "" {
+		return cpgo.PullRequest{}, fmt.Errorf("base branch is required")
+	}
+
+	if strings.TrimSpace(req.HeadBranch) == "" {
+		return cpgo.PullRequest{}, fmt.Errorf("head branch is required")
+	}
+
+	if strings.TrimSpace(req.Title) == "" {
+		return cpgo.PullRequest{}, fmt.Errorf("pull request title is required")
+	}
+
+	if strings.TrimSpace(req.Body) == "" {
+		return cpgo.PullRequest{}, fmt.Errorf("pull request body is required")
+	}
+
+	labelIDs, warnings, err := client.labelIDs(ctx, req.Repository, req.RequiredLabels, req.Labels)
+	if err != nil {
+		return cpgo.PullRequest{}, err
+	}
+
+	title := req.Title
+	if req.Draft {
+		title = draftTitlePrefix + title
+	}
+
+	var created pullRequest
+	_, err = client.doJSON(ctx, http.MethodPost, repositoryPath(req.Repository)+"/pulls", nil, createPullRequestRequest{
+		Head:   req.HeadBranch,
+		Base:   req.BaseBranch,
+		Title:  title,
+		Body:   req.Body,
+		Labels: labelIDs,
+	}, &created)
+	if err != nil {
+		return cpgo.PullRequest{}, fmt.Errorf("create pull request: %w", err)
+	}
+
+	pullRequest := toPullRequest(created)
+	pullRequest.Warnings = append(warnings, client.requestReview(ctx, req, created.Number)...)
+
+	return pullRequest, nil
+}
+
+// requestReview requests reviewers and assignees, returning failures as warnings.
+func (client *Client) requestReview(ctx context.Context, req cpgo.CreatePullRequestRequest, number int) []string {
+	var warnings []string
+	if len(req.Reviewers) > 0 || len(req.TeamReviewers) > 0 {
+		reviewersPath := fmt.Sprintf("%s/pulls/%d/requested_reviewers", repositoryPath(req.Repository), number)
+		_, err := client.doJSON(ctx, http.MethodPost, reviewersPath, nil, map[string][]string{
+			"reviewers":      req.Reviewers,
+			"team_reviewers": req.TeamReviewers,
+		}, nil)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("request reviewers on pull request #%d: %v", number, err))
+		}
+	}
+
+	if len(req.Assignees) > 0 {
+		issuePath := fmt.Sprintf("%s/issues/%d", repositoryPath(req.Repository), number)
+		if _, err := client.doJSON(ctx, http.MethodPatch, issuePath, nil, map[string][]string{"assignees": req.Assignees}, nil); err != nil {
+			warnings = append(warnings, fmt.Sprintf("add assignees to pull request #%d: %v", number, err))
+		}
+	}
+
+	return warnings
+}
+
+// labelIDs resolves label names to ids; a missing required label fails and a missing optional label is a warning.
+func (client *Client) labelIDs(ctx context.Context, repositoryRef cpgo.RepositoryRef, required []string, optional []string) ([]int64, []string, error) {
+	if len(required) == 0 && len(optional) == 0 {
+		return nil, nil, nil
+	}
+
+	existing := map[string]int64{}
+	for page := 1; ; page++ {
+		var batch []label
+		_, err := client.doJSON(ctx, http.MethodGet, repositoryPath(repositoryRef)+"/labels", url.Values{
+			"limit": {strconv.Itoa(listPageSize)},
+			"page":  {strconv.Itoa(page)},
+		}, nil, &batch)
+		if err != nil {
+			return nil, nil, fmt.Errorf("list labels: %w", err)
+		}
+
+		for _, item := range batch {
+			existing[item.Name] = item.ID
+		}
+
+		if len(batch) < listPageSize {
+			break
+		}
+	}
+
+	var ids []int64
+	var warnings []string
+	for _, name := range append(slices.Clone(required), optional...) {
+		id, ok := existing[name]
+		switch {
+		case ok:
+			ids = append(ids, id)
+		case slices.Contains(required, name):
+			return nil, nil, fmt.Errorf("pull request label %q does not exist in %s/%s", name, repositoryRef.Owner, repositoryRef.Name)
+		default:
+			warnings = append(warnings, fmt.Sprintf("pull request label %q does not exist", name))
+		}
+	}
+
+	return ids, warnings, nil
+}
+
+// getBranch reads a branch, reporting false when it does not exist.
+func (client *Client) getBranch(ctx context.Context, repositoryRef cpgo.RepositoryRef, branchName string) (branch, bool, error) {
+	var decoded branch
+	if _, err := client.doJSON(ctx, http.MethodGet, branchPath(repositoryRef, branchName), nil, nil, &decoded); err != nil {
+		if isNotFound(err) {
+			return branch{}, false, nil
+		}
+
+		return branch{}, false, fmt.Errorf("get branch %s: %w", branchName, err)
+	}
+
+	return decoded, true, nil
+}
+
+// doJSON sends a request with an optional JSON body and decodes a JSON response into out when set.
+func (client *Client) doJSON(ctx context.Context, method string, path string, query url.Values, body any, out any) (*http.Response, error) {
+	resp, err := client.send(ctx, method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return resp, nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	return resp, nil
+}
+
+// send performs an authenticated API request, turning non-2xx responses into apiError.
+func (client *Client) send(ctx context.Context, method string, path string, query url.Values, body any) (*http.Response, error) {
+	requestURL, err := url.Parse(client.baseURL + "/" + path)
+	if err != nil {
+		return nil, fmt.Errorf("build gitea url: %w", err)
+	}
+	requestURL.RawQuery = query.Encode()
+
+	var payload io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("encode gitea request: %w", err)
+		}
+
+		payload = bytes.NewReader(encoded)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, requestURL.String(), payload)
+	if err != nil {
+		return nil, fmt.Errorf("build gitea request: %w", err)
+	}
+
+	httpReq.Header.Set("Authorization", "token "+client.token)
+	httpReq.Header.Set("Accept", "application/json")
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer func() { _ = resp.Body.Close() }()
+
+		preview, _ := io.ReadAll(io.LimitReader(resp.Body, errorPreviewBytes))
+		return nil, &apiError{
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(preview)),
+		}
+	}
+
+	return resp, nil
+}
+
+func repositoryPath(repositoryRef cpgo.RepositoryRef) string {
+	return "repos/" + url.PathEscape(repositoryRef.Owner) + "/" + url.PathEscape(repositoryRef.Name)
+}
+
+func branchPath(repositoryRef cpgo.RepositoryRef, branchName string) string {
+	return repositoryPath(repositoryRef) + "/branches/" + url.PathEscape(branchName)
+}
+
+// escapeFilePath escapes each segment of a repository file path, keeping its slashes.
+func escapeFilePath(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for index, segment := range segments {
+		segments[index] = url.PathEscape(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func toIdentity(commitIdentity cpgo.CommitIdentity) *identity {
+	if commitIdentity.IsZero() {
+		return nil
+	}
+
+	return &identity{Name: commitIdentity.Name, Email: commitIdentity.Email}
+}
+
+func isSameRepository(pullRequest pullRequest, repositoryRef cpgo.RepositoryRef) bool {
+	return strings.EqualFold(pullRequest.Head.Repo.FullName, repositoryRef.Owner+"/"+repositoryRef.Name)
+}
+
+func toPullRequest(pullRequest pullRequest) cpgo.PullRequest {
+	return cpgo.PullRequest{
+		Number:     pullRequest.Number,
+		Title:      pullRequest.Title,
+		Body:       pullRequest.Body,
+		URL:        pullRequest.HTMLURL,
+		HeadBranch: pullRequest.Head.Ref,
+	}
+}
+
+func isNotFound(err error) bool {
+	var giteaError *apiError
+	return errors.As(err, &giteaError) && giteaError.StatusCode == http.StatusNotFound
+}
+
+func isForbidden(err error) bool {
+	var giteaError *apiError
+	return errors.As(err, &giteaError) && giteaError.StatusCode == http.StatusForbidden
+}
+
+func validateRepositoryRef(repositoryRef cpgo.RepositoryRef) error {
+	if strings.TrimSpace(repositoryRef.Owner) == "" {
+		return fmt.Errorf("repository owner is required")
+	}
+
+	if strings.TrimSpace(repositoryRef.Name) == "" {
+		return fmt.Errorf("repository name is required")
+	}
+
+	return nil
+}
+
+func withDefaultTimeout(httpClient *http.Client) *http.Client {
+	if httpClient == nil {
+		return &http.Client{
+			Timeout: defaultHTTPClientTimeout,
+		}
+	}
+
+	httpClientCopy := *httpClient
+	if httpClientCopy.Timeout <= 0 {
+		httpClientCopy.Timeout = defaultHTTPClientTimeout
+	}
+
+	return &httpClientCopy
+}
@@ -0,0 +1,283 @@
+package giteaapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cpgo"
+)
+
+func TestClientReadFile(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "token token" {
+			t.Fatalf("expected token authorization header")
+		}
+
+		switch req.URL.Path {
+		case "/api/v1/repos/acme/payments/raw/cmd/default.pgo":
+			if req.URL.Query().Get("ref") != "main" {
+				t.Fatalf("expected ref main, got %s", req.URL.Query().Get("ref"))
+			}
+
+			_, _ = response.Write([]byte("profile-bytes"))
+		case "/api/v1/repos/acme/payments/raw/missing.pgo":
+			response.WriteHeader(http.StatusNotFound)
+		default:
+			t.Fatalf("unexpected path: %s", req.URL.Path)
+		}
+	}))
+
+	repository := cpgo.RepositoryRef{Owner: "acme", Name: "payments"}
+	result, err := client.ReadFile(context.Background(), cpgo.ReadFileRequest{Repository: repository, Branch: "main", Path: "cmd/default.pgo"})
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+
+	if !result.HasFile || string(result.Content) != "profile-bytes" {
+		t.Fatalf("expected profile bytes, got %+v", result)
+	}
+
+	result, err = client.ReadFile(context.Background(), cpgo.ReadFileRequest{Repository: repository, Branch: "main", Path: "missing.pgo"})
+	if err != nil {
+		t.Fatalf("read missing file: %v", err)
+	}
+
+	if result.HasFile {
+		t.Fatalf("expected missing file result")
+	}
+}
+
+func TestClientUpsertFileAndForceBranch(t *testing.T) {
+	t.Run("creates the head branch from the base branch", func(t *testing.T) {
+		var payload changeFilesRequest
+		client := newTestClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+			switch req.Method + " " + req.URL.Path {
+			case "GET /api/v1/repos/acme/payments/branches/cpgo":
+				response.WriteHeader(http.StatusNotFound)
+			case "GET /api/v1/repos/acme/payments/contents/default.pgo":
+				if req.URL.Query().Get("ref") != "main" {
+					t.Fatalf("expected sha lookups against the base branch, got %s", req.URL.Query().Get("ref"))
+				}
+
+				_, _ = response.Write([]byte(`{"type":"file","sha":"base-blob"}`))
+			case "GET /api/v1/repos/acme/payments/contents/old.pgo":
+				response.WriteHeader(http.StatusNotFound)
+			case "POST /api/v1/repos/acme/payments/contents":
+				if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+					t.Fatalf("decode change files request: %v", err)
+				}
+
+				_, _ = response.Write([]byte(`{"commit":{"sha":"new-commit"}}`))
+			default:
+				t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+			}
+		}))
+
+		result, err := client.UpsertFileAndForceBranch(context.Background(), cpgo.UpsertFileRequest{
+			Repository:      cpgo.RepositoryRef{Owner: "acme", Name: "payments"},
+			BaseBranch:      "main",
+			HeadBranch:      "cpgo",
+			Path:            "default.pgo",
+			Content:         []byte("profile"),
+			AdditionalFiles: []cpgo.FileChange{{Path: "old.pgo", Delete: true}},
+			CommitMessage:   "perf(pgo): refresh pgo profile",
+			Committer:       cpgo.CommitIdentity{Name: "cpgo-bot", Email: "bot@example.com"},
+			ForceUpdate:     true,
+		})
+		if err != nil {
+			t.Fatalf("upsert file: %v", err)
+		}
+
+		if result.CommitSHA != "new-commit" || !result.IsBranchCreated {
+			t.Fatalf("unexpected result %+v", result)
+		}
+
+		if payload.Branch != "main" || payload.NewBranch != "cpgo" || payload.Author != nil || payload.Committer == nil || payload.Committer.Email != "bot@example.com" {
+			t.Fatalf("expected a new cpgo branch from main with the committer identity, got %+v", payload)
+		}
+
+		if len(payload.Files) != 1 || payload.Files[0].Operation != "update" || payload.Files[0].SHA != "base-blob" || payload.Files[0].Content != "cHJvZmlsZQ==" {
+			t.Fatalf("expected one sha-based update without deleting a missing file, got %+v", payload.Files)
+		}
+	})
+
+	t.Run("rejects force updates of an existing head branch", func(t *testing.T) {
+		client := newTestClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+			if req.Method+" "+req.URL.Path != "GET /api/v1/repos/acme/payments/branches/cpgo" {
+				t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+			}
+
+			_, _ = response.Write([]byte(`{"name":"cpgo","commit":{"id":"head-commit"}}`))
+		}))
+
+		_, err := client.UpsertFileAndForceBranch(context.Background(), cpgo.UpsertFileRequest{
+			Repository:    cpgo.RepositoryRef{Owner: "acme", Name: "payments"},
+			BaseBranch:    "main",
+			HeadBranch:    "cpgo",
+			Path:          "default.pgo",
+			Content:       []byte("profile"),
+			CommitMessage: "perf(pgo): refresh pgo profile",
+			ForceUpdate:   true,
+		})
+		if err == nil || !strings.Contains(err.Error(), "force_push: false") {
+			t.Fatalf("expected force update error, got %v", err)
+		}
+	})
+
+	t.Run("adds a commit on top of an existing head branch", func(t *testing.T) {
+		var payload changeFilesRequest
+		client := newTestClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+			switch req.Method + " " + req.URL.Path {
+			case "GET /api/v1/repos/acme/payments/branches/cpgo":
+				_, _ = response.Write([]byte(`{"name":"cpgo","commit":{"id":"head-commit"}}`))
+			case "GET /api/v1/repos/acme/payments/contents/default.pgo":
+				if req.URL.Query().Get("ref") != "cpgo" {
+					t.Fatalf("expected sha lookups against the head branch, got %s", req.URL.Query().Get("ref"))
+				}
+
+				response.WriteHeader(http.StatusNotFound)
+			case "POST /api/v1/repos/acme/payments/contents":
+				if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+					t.Fatalf("decode change files request: %v", err)
+				}
+
+				_, _ = response.Write([]byte(`{"commit":{"sha":"new-commit"}}`))
+			default:
+				t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+			}
+		}))
+
+		result, err := client.UpsertFileAndForceBranch(context.Background(), cpgo.UpsertFileRequest{
+			Repository:    cpgo.RepositoryRef{Owner: "acme", Name: "payments"},
+			BaseBranch:    "main",
+			HeadBranch:    "cpgo",
+			Path:          "default.pgo",
+			Content:       []byte("profile"),
+			CommitMessage: "perf(pgo): refresh pgo profile",
+		})
+		if err != nil {
+			t.Fatalf("upsert file: %v", err)
+		}
+
+		if result.IsBranchCreated || payload.Branch != "cpgo" || payload.NewBranch != "" {
+			t.Fatalf("expected a commit on the existing cpgo branch, got %+v and %+v", result, payload)
+		}
+
+		if len(payload.Files) != 1 || payload.Files[0].Operation != "create" || payload.Files[0].SHA != "" {
+			t.Fatalf("expected a create operation, got %+v", payload.Files)
+		}
+	})
+}
+
+func TestClientFindOpenByHead(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("state") != "open" {
+			t.Fatalf("expected open state filter, got %s", req.URL.RawQuery)
+		}
+
+		if req.URL.Query().Get("page") == "2" {
+			_, _ = response.Write([]byte(`[{"number":8,"body":"<!-- managed-by:cpgo -->","head":{"ref":"cpgo","repo":{"full_name":"acme/payments"}},"base":{"ref":"main"}}]`))
+			return
+		}
+
+		pullRequests := make([]string, 0, listPageSize)
+		pullRequests = append(pullRequests,
+			`{"number":5,"body":"<!-- managed-by:cpgo -->","head":{"ref":"cpgo","repo":{"full_name":"fork/payments"}},"base":{"ref":"main"}}`,
+			`{"number":6,"body":"<!-- managed-by:cpgo -->","head":{"ref":"cpgo","repo":{"full_name":"acme/payments"}},"base":{"ref":"release"}}`,
+			`{"number":7,"body":"Hand-written change.","head":{"ref":"cpgo","repo":{"full_name":"acme/payments"}},"base":{"ref":"main"}}`,
+		)
+		for len(pullRequests) < listPageSize {
+			pullRequests = append(pullRequests, `{"number":1,"head":{"ref":"feature","repo":{"full_name":"acme/payments"}},"base":{"ref":"main"}}`)
+		}
+
+		_, _ = response.Write([]byte("[" + strings.Join(pullRequests, ",") + "]"))
+	}))
+
+	pullRequest, err := client.FindOpenByHead(context.Background(), cpgo.FindPullRequestRequest{
+		Repository:     cpgo.RepositoryRef{Owner: "acme", Name: "payments"},
+		BaseBranch:     "main",
+		HeadBranch:     "cpgo",
+		ManagedMarkers: []string{"<!-- managed-by:cpgo -->"},
+	})
+	if err != nil {
+		t.Fatalf("find pull request: %v", err)
+	}
+
+	if pullRequest == nil || pullRequest.Number != 8 {
+		t.Fatalf("expected the managed same-repository pull request from the second page, got %+v", pullRequest)
+	}
+}
+
+func TestClientCreate(t *testing.T) {
+	var payload createPullRequestRequest
+	client := newTestClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/v1/repos/acme/payments/labels":
+			_, _ = response.Write([]byte(`[{"id":3,"name":"pgo"}]`))
+		case "/api/v1/repos/acme/payments/pulls":
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode pull request: %v", err)
+			}
+
+			_, _ = response.Write([]byte(`{"number":9,"html_url":"https://forgejo.example.com/acme/payments/pulls/9","head":{"ref":"cpgo"}}`))
+		default:
+			t.Fatalf("unexpected path: %s", req.URL.Path)
+		}
+	}))
+
+	created, err := client.Create(context.Background(), cpgo.CreatePullRequestRequest{
+		Repository:     cpgo.RepositoryRef{Owner: "acme", Name: "payments"},
+		BaseBranch:     "main",
+		HeadBranch:     "cpgo",
+		Title:          "refresh",
+		Body:           "Automated PGO profile refresh.",
+		RequiredLabels: []string{"pgo"},
+		Labels:         []string{"hot:encoding/json"},
+		Draft:          true,
+	})
+	if err != nil {
+		t.Fatalf("create pull request: %v", err)
+	}
+
+	if payload.Title != "WIP: refresh" || len(payload.Labels) != 1 || payload.Labels[0] != 3 {
+		t.Fatalf("unexpected pull request payload %+v", payload)
+	}
+
+	if created.Number != 9 || len(created.Warnings) != 1 || !strings.Contains(created.Warnings[0], "hot:encoding/json") {
+		t.Fatalf("expected the pull request with a missing label warning, got %+v", created)
+	}
+
+	_, err = client.Create(context.Background(), cpgo.CreatePullRequestRequest{
+		Repository:     cpgo.RepositoryRef{Owner: "acme", Name: "payments"},
+		BaseBranch:     "main",
+		HeadBranch:     "cpgo",
+		Title:          "refresh",
+		Body:           "Automated PGO profile refresh.",
+		RequiredLabels: []string{"performance"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "performance") {
+		t.Fatalf("expected missing required label error, got %v", err)
+	}
+}
+
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(Options{
+		BaseURL:    server.URL,
+		Token:      "token",
+		HTTPClient: server.Client(),
+	})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+
+	return client
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -17,19 +18,109 @@ const defaultGitHubHTTPTimeout = 30 * time.Second
 
 // AppClientRequest holds GitHub App credentials and repository target data.
 type AppClientRequest struct {
-	AppID         int64
-	PrivateKeyPEM []byte
-	Repository    cpgo.RepositoryRef
-	HTTPClient    *http.Client
+	AppID          int64
+	PrivateKeyPEM  []byte
+	Repository     cpgo.RepositoryRef
+	HTTPClient     *http.Client
+	EnterpriseURLs EnterpriseURLs
+	Signer         *CommitSigner
 }
 
-func NewClientFromToken(httpClient *http.Client, token string) (*Client, error) {
+// EnterpriseURLs points clients at a GitHub Enterprise Server instance.
+// An empty BaseURL targets github.com; an empty UploadURL reuses BaseURL.
+type EnterpriseURLs struct {
+	BaseURL   string
+	UploadURL string
+}
+
+// validate requires absolute http(s) URLs when enterprise endpoints are configured.
+func (urls EnterpriseURLs) validate() error {
+	if strings.TrimSpace(urls.BaseURL) == "" {
+		if strings.TrimSpace(urls.UploadURL) != "" {
+			return fmt.Errorf("github upload url requires a base url")
+		}
+
+		return nil
+	}
+
+	for name, rawURL := range map[string]string{"base": urls.BaseURL, "upload": urls.UploadURL} {
+		if strings.TrimSpace(rawURL) == "" {
+			continue
+		}
+
+		parsed, err := url.Parse(strings.TrimSpace(rawURL))
+		if err != nil {
+			return fmt.Errorf("parse github %s url: %w", name, err)
+		}
+
+		if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("github %s url must be an absolute http(s) url", name)
+		}
+	}
+
+	return nil
+}
+
+// newEnterpriseAwareClient builds a go-github client, targeting enterprise endpoints when configured.
+func newEnterpriseAwareClient(httpClient *http.Client, urls EnterpriseURLs) (*github.Client, error) {
+	if err := urls.validate(); err != nil {
+		return nil, err
+	}
+
+	githubClient := github.NewClient(httpClient)
+	baseURL := strings.TrimSpace(urls.BaseURL)
+	if baseURL == "" {
+		return githubClient, nil
+	}
+
+	uploadURL := strings.TrimSpace(urls.UploadURL)
+	if uploadURL == "" {
+		uploadURL = baseURL
+	}
+
+	enterpriseClient, err := githubClient.WithEnterpriseURLs(baseURL, uploadURL)
+	if err != nil {
+		return nil, fmt.Errorf("configure github enterprise urls: %w", err)
+	}
+
+	return enterpriseClient, nil
+}
+
+func NewClientFromToken(httpClient *http.Client, token string, urls EnterpriseURLs, signer *CommitSigner) (*Client, error) {
 	if strings.TrimSpace(token) == "" {
 		return nil, fmt.Errorf("token is required")
 	}
 
-	githubClient := github.NewClient(withTimeout(httpClient)).WithAuthToken(token)
-	return NewClient(githubClient)
+	githubClient, err := newEnterpriseAwareClient(withTimeout(httpClient), urls)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(githubClient.WithAuthToken(token), signer)
+}
+
+// NewClientFromTokens authenticates requests round-robin across tokens with equivalent access.
+func NewClientFromTokens(httpClient *http.Client, tokens []string, urls EnterpriseURLs, signer *CommitSigner) (*Client, error) {
+	baseTransport := http.DefaultTransport
+	if httpClient != nil && httpClient.Transport != nil {
+		baseTransport = httpClient.Transport
+	}
+
+	poolTransport, err := NewTokenPoolTransport(baseTransport, tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	githubClient, err := newEnterpriseAwareClient(withTransport(httpClient, poolTransport), urls)
+	if err != nil {
+		return nil, err
+	}
+
+	// go-github would answer every call locally once one token's quota is spent, without
+	// reaching the pool; the pool transport tracks the limits of each token itself.
+	githubClient.DisableRateLimitCheck = true
+
+	return NewClient(githubClient, signer)
 }
 
 func NewClientFromApp(ctx context.Context, req AppClientRequest) (*Client, error) {
@@ -55,8 +146,13 @@ func NewClientFromApp(ctx context.Context, req AppClientRequest) (*Client, error
 		return nil, fmt.Errorf("create github app transport: %w", err)
 	}
 
-	appHTTPClient := withTransport(req.HTTPClient, appTransport)
-	appClient := github.NewClient(appHTTPClient)
+	appClient, err := newEnterpriseAwareClient(withTransport(req.HTTPClient, appTransport), req.EnterpriseURLs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Installation tokens are minted against the same API host the client talks to.
+	appTransport.BaseURL = strings.TrimSuffix(appClient.BaseURL.String(), "/")
 
 	installation, _, err := appClient.Apps.FindRepositoryInstallation(ctx, req.Repository.Owner, req.Repository.Name)
 	if err != nil {
@@ -64,10 +160,12 @@ func NewClientFromApp(ctx context.Context, req AppClientRequest) (*Client, error
 	}
 
 	installationTransport := ghinstallation.NewFromAppsTransport(appTransport, installation.GetID())
-	installationHTTPClient := withTransport(req.HTTPClient, installationTransport)
-	installationClient := github.NewClient(installationHTTPClient)
+	installationClient, err := newEnterpriseAwareClient(withTransport(req.HTTPClient, installationTransport), req.EnterpriseURLs)
+	if err != nil {
+		return nil, err
+	}
 
-	return NewClient(installationClient)
+	return NewClient(installationClient, req.Signer)
 }
 
 func withTimeout(httpClient *http.Client) *http.Client {
@@ -3,14 +3,18 @@ package githubapi
 import (
 	"context"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"testing"
+	"time"
 
 	"cpgo"
 )
 
 func TestNewClientFromToken(t *testing.T) {
 	t.Run("returns client when token is set", func(t *testing.T) {
-		client, err := NewClientFromToken(&http.Client{}, "token")
+		client, err := NewClientFromToken(&http.Client{}, "token", EnterpriseURLs{}, nil)
 		if err != nil {
 			t.Fatalf("new client from token: %v", err)
 		}
@@ -20,14 +24,83 @@ func TestNewClientFromToken(t *testing.T) {
 		}
 	})
 
+	t.Run("targets enterprise endpoints when configured", func(t *testing.T) {
+		client, err := NewClientFromToken(&http.Client{}, "token", EnterpriseURLs{BaseURL: "https://github.example.com"}, nil)
+		if err != nil {
+			t.Fatalf("new client from token: %v", err)
+		}
+
+		if client.githubClient.BaseURL.String() != "https://github.example.com/api/v3/" {
+			t.Fatalf("unexpected base url %s", client.githubClient.BaseURL)
+		}
+
+		if client.githubClient.UploadURL.String() != "https://github.example.com/api/uploads/" {
+			t.Fatalf("unexpected upload url %s", client.githubClient.UploadURL)
+		}
+	})
+
+	t.Run("rejects malformed enterprise urls", func(t *testing.T) {
+		for _, urls := range []EnterpriseURLs{
+			{BaseURL: "github.example.com"},
+			{BaseURL: "https://github.example.com", UploadURL: "/api/uploads"},
+			{UploadURL: "https://github.example.com/api/uploads"},
+		} {
+			if _, err := NewClientFromToken(&http.Client{}, "token", urls, nil); err == nil {
+				t.Fatalf("expected %+v to be rejected", urls)
+			}
+		}
+	})
+
 	t.Run("returns error when token is empty", func(t *testing.T) {
-		_, err := NewClientFromToken(&http.Client{}, "")
+		_, err := NewClientFromToken(&http.Client{}, "", EnterpriseURLs{}, nil)
 		if err == nil {
 			t.Fatalf("expected error")
 		}
 	})
 }
 
+func TestNewClientFromTokens(t *testing.T) {
+	t.Run("moves on to the next token once one is spent", func(t *testing.T) {
+		reset := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+
+		var seen []string
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			seen = append(seen, req.Header.Get("Authorization"))
+			remaining := "100"
+			if req.Header.Get("Authorization") == "Bearer a" {
+				remaining = "0"
+			}
+
+			resp.Header().Set("X-RateLimit-Remaining", remaining)
+			resp.Header().Set("X-RateLimit-Reset", reset)
+			_, _ = resp.Write([]byte(`{"default_branch":"main"}`))
+		}))
+		t.Cleanup(server.Close)
+
+		client, err := NewClientFromTokens(server.Client(), []string{"a", "b"}, EnterpriseURLs{}, nil)
+		if err != nil {
+			t.Fatalf("new client from tokens: %v", err)
+		}
+
+		baseURL, err := url.Parse(server.URL + "/")
+		if err != nil {
+			t.Fatalf("parse server url: %v", err)
+		}
+		client.githubClient.BaseURL = baseURL
+
+		repository := cpgo.RepositoryRef{Owner: "acme", Name: "payments"}
+		for range 2 {
+			if _, err := client.DefaultBranch(context.Background(), repository); err != nil {
+				t.Fatalf("default branch: %v", err)
+			}
+		}
+
+		if len(seen) != 2 || seen[0] != "Bearer a" || seen[1] != "Bearer b" {
+			t.Fatalf("expected the second call to use token b, got %v", seen)
+		}
+	})
+}
+
 func TestNewClientFromAppValidation(t *testing.T) {
 	t.Run("returns error for invalid app id", func(t *testing.T) {
 		_, err := NewClientFromApp(context.Background(), AppClientRequest{
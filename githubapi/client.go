@@ -6,7 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/google/go-github/v77/github"
 
@@ -15,24 +18,48 @@ import (
 
 const (
 	fileModeRegular = "100644"
+	fileModeSymlink = "120000"
 	treeEntryBlob   = "blob"
+	treeEntryTree   = "tree"
+	listPageSize    = 100
 )
 
+// errRefConflict marks a ref update rejected because the ref moved concurrently.
+var errRefConflict = errors.New("branch ref changed concurrently")
+
+// errBaseMoved marks a commit built on a base branch commit that is no longer the base head.
+var errBaseMoved = errors.New("base branch moved during commit")
+
+// maxBaseRebuilds bounds how often a commit is rebuilt after the base branch moved under it.
+const maxBaseRebuilds = 3
+
 // Client implements repository and pull request ports via GitHub REST APIs.
+// A nil signer leaves commits unsigned; GitHub App installations still get
+// verified commits then, because GitHub signs API commits without a custom author.
+// The git object calls behind UpsertFileAndForceBranch are retried on rate limits.
 type Client struct {
-	githubClient *github.Client
+	githubClient     *github.Client
+	signer           *CommitSigner
+	rateLimitRetries int
+	sleep            func(context.Context, time.Duration) error
 }
 
 var _ cpgo.BranchWriter = (*Client)(nil)
 var _ cpgo.PullRequestService = (*Client)(nil)
+var _ cpgo.TagWriter = (*Client)(nil)
+var _ cpgo.StatusReporter = (*Client)(nil)
+var _ cpgo.BranchProtectionReader = (*Client)(nil)
 
-func NewClient(githubClient *github.Client) (*Client, error) {
+func NewClient(githubClient *github.Client, signer *CommitSigner) (*Client, error) {
 	if githubClient == nil {
 		return nil, fmt.Errorf("github client is required")
 	}
 
 	return &Client{
-		githubClient: githubClient,
+		githubClient:     githubClient,
+		signer:           signer,
+		rateLimitRetries: defaultRateLimitRetries,
+		sleep:            sleepContext,
 	}, nil
 }
 
@@ -55,7 +82,7 @@ func (client *Client) DefaultBranch(ctx context.Context, repository cpgo.Reposit
 	return defaultBranch, nil
 }
 
-// ReadFile returns raw file bytes from a branch using git object lookups.
+// ReadFile returns raw file bytes from a branch, or a tag of that name, using git object lookups.
 func (client *Client) ReadFile(ctx context.Context, req cpgo.ReadFileRequest) (cpgo.ReadFileResult, error) {
 	if err := validateRepositoryRef(req.Repository); err != nil {
 		return cpgo.ReadFileResult{}, err
@@ -69,7 +96,7 @@ func (client *Client) ReadFile(ctx context.Context, req cpgo.ReadFileRequest) (c
 		return cpgo.ReadFileResult{}, fmt.Errorf("path is required")
 	}
 
-	baseCommitSHA, baseTreeSHA, err := client.baseCommitTree(ctx, req.Repository, req.Branch)
+	baseCommitSHA, baseTreeSHA, err := client.refCommitTree(ctx, req.Repository, req.Branch)
 	if err != nil {
 		return cpgo.ReadFileResult{}, err
 	}
@@ -93,11 +120,15 @@ func (client *Client) ReadFile(ctx context.Context, req cpgo.ReadFileRequest) (c
 		break
 	}
 
-	if blobSHA == "" {
-		if tree.GetTruncated() {
-			return cpgo.ReadFileResult{}, fmt.Errorf("tree response was truncated while resolving path %q", req.Path)
+	if blobSHA == "" && tree.GetTruncated() {
+		// Very large repositories exceed the recursive tree limit; resolve the path one directory at a time.
+		blobSHA, err = client.walkTreePath(ctx, req.Repository, baseTreeSHA, req.Path)
+		if err != nil {
+			return cpgo.ReadFileResult{}, err
 		}
+	}
 
+	if blobSHA == "" {
 		return cpgo.ReadFileResult{HasFile: false}, nil
 	}
 
@@ -116,6 +147,159 @@ func (client *Client) ReadFile(ctx context.Context, req cpgo.ReadFileRequest) (c
 	}, nil
 }
 
+// walkTreePath resolves a blob by reading only the trees along its path, returning an empty sha when it is absent.
+func (client *Client) walkTreePath(ctx context.Context, repository cpgo.RepositoryRef, rootTreeSHA string, filePath string) (string, error) {
+	treeSHA := rootTreeSHA
+	segments := strings.Split(strings.Trim(filePath, "/"), "/")
+	for index, segment := range segments {
+		tree, _, err := client.githubClient.Git.GetTree(ctx, repository.Owner, repository.Name, treeSHA, false)
+		if err != nil {
+			return "", fmt.Errorf("get tree %s: %w", treeSHA, err)
+		}
+
+		entry := findTreeEntry(tree.Entries, segment)
+		if entry == nil {
+			if tree.GetTruncated() {
+				return "", fmt.Errorf("tree response was truncated while resolving path %q", filePath)
+			}
+
+			return "", nil
+		}
+
+		isLast := index == len(segments)-1
+		switch {
+		case isLast && entry.GetType() != treeEntryBlob:
+			return "", fmt.Errorf("path %q is not a blob entry", filePath)
+		case isLast:
+			return strings.TrimSpace(entry.GetSHA()), nil
+		case entry.GetType() != treeEntryTree:
+			return "", nil
+		}
+
+		treeSHA = entry.GetSHA()
+	}
+
+	return "", nil
+}
+
+func findTreeEntry(entries []*github.TreeEntry, name string) *github.TreeEntry {
+	for _, entry := range entries {
+		if entry.GetPath() == name {
+			return entry
+		}
+	}
+
+	return nil
+}
+
+// DeleteBranch deletes the branch ref, reporting false when the branch was already missing.
+func (client *Client) DeleteBranch(ctx context.Context, repository cpgo.RepositoryRef, branch string) (bool, error) {
+	if err := validateRepositoryRef(repository); err != nil {
+		return false, err
+	}
+
+	if strings.TrimSpace(branch) == "" {
+		return false, fmt.Errorf("branch is required")
+	}
+
+	_, err := client.githubClient.Git.DeleteRef(ctx, repository.Owner, repository.Name, "heads/"+branch)
+	if isNotFound(err) || isReferenceMissing(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("delete branch ref: %w", err)
+	}
+
+	return true, nil
+}
+
+// LastCommitTime returns the committer time of the branch head commit.
+func (client *Client) LastCommitTime(ctx context.Context, repository cpgo.RepositoryRef, branch string) (time.Time, bool, error) {
+	if err := validateRepositoryRef(repository); err != nil {
+		return time.Time{}, false, err
+	}
+
+	if strings.TrimSpace(branch) == "" {
+		return time.Time{}, false, fmt.Errorf("branch is required")
+	}
+
+	ref, _, err := client.githubClient.Git.GetRef(ctx, repository.Owner, repository.Name, "heads/"+branch)
+	if err != nil {
+		if isNotFound(err) {
+			return time.Time{}, false, nil
+		}
+
+		return time.Time{}, false, fmt.Errorf("get branch ref: %w", err)
+	}
+
+	commit, _, err := client.githubClient.Git.GetCommit(ctx, repository.Owner, repository.Name, ref.GetObject().GetSHA())
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("get branch head commit: %w", err)
+	}
+
+	return commit.GetCommitter().GetDate().Time, true, nil
+}
+
+// BranchHead returns the commit SHA the branch ref points at.
+func (client *Client) BranchHead(ctx context.Context, repository cpgo.RepositoryRef, branch string) (string, bool, error) {
+	if err := validateRepositoryRef(repository); err != nil {
+		return "", false, err
+	}
+
+	if strings.TrimSpace(branch) == "" {
+		return "", false, fmt.Errorf("branch is required")
+	}
+
+	ref, _, err := client.githubClient.Git.GetRef(ctx, repository.Owner, repository.Name, "heads/"+branch)
+	if err != nil {
+		if isNotFound(err) {
+			return "", false, nil
+		}
+
+		return "", false, fmt.Errorf("get branch ref: %w", err)
+	}
+
+	return ref.GetObject().GetSHA(), true, nil
+}
+
+// BranchProtection reads the protection rules of a branch.
+// GitHub answers 404 for unprotected branches and for tokens without administration
+// read access, so both report an unprotected branch, as does a 403.
+func (client *Client) BranchProtection(ctx context.Context, repository cpgo.RepositoryRef, branch string) (cpgo.BranchProtection, bool, error) {
+	if err := validateRepositoryRef(repository); err != nil {
+		return cpgo.BranchProtection{}, false, err
+	}
+
+	if strings.TrimSpace(branch) == "" {
+		return cpgo.BranchProtection{}, false, fmt.Errorf("branch is required")
+	}
+
+	protection, _, err := retryRateLimited(ctx, client, func() (*github.Protection, *github.Response, error) {
+		return client.githubClient.Repositories.GetBranchProtection(ctx, repository.Owner, repository.Name, branch)
+	})
+	if err != nil {
+		if errors.Is(err, github.ErrBranchNotProtected) || isNotFound(err) || isForbidden(err) {
+			return cpgo.BranchProtection{}, false, nil
+		}
+
+		return cpgo.BranchProtection{}, false, fmt.Errorf("get branch protection: %w", err)
+	}
+
+	allowForcePushes := protection.GetAllowForcePushes()
+	reviews := protection.GetRequiredPullRequestReviews()
+	return cpgo.BranchProtection{
+		AllowsForcePushes:    allowForcePushes != nil && allowForcePushes.Enabled,
+		RequiresPullRequest:  reviews != nil,
+		HasPullRequestBypass: hasBypassActors(reviews.GetBypassPullRequestAllowances()),
+	}, true, nil
+}
+
+// hasBypassActors reports whether any user, team or app may bypass required pull requests.
+func hasBypassActors(allowances *github.BypassPullRequestAllowances) bool {
+	return allowances != nil && len(allowances.Users)+len(allowances.Teams)+len(allowances.Apps) > 0
+}
+
 // UpsertFileAndForceBranch writes a commit and force-updates the head ref.
 func (client *Client) UpsertFileAndForceBranch(ctx context.Context, req cpgo.UpsertFileRequest) (cpgo.UpsertFileResult, error) {
 	if err := validateRepositoryRef(req.Repository); err != nil {
@@ -138,27 +322,81 @@ func (client *Client) UpsertFileAndForceBranch(ctx context.Context, req cpgo.Ups
 		return cpgo.UpsertFileResult{}, fmt.Errorf("commit message is required")
 	}
 
-	baseCommitSHA, baseTreeSHA, err := client.baseCommitTree(ctx, req.Repository, req.BaseBranch)
+	for _, change := range req.AdditionalFiles {
+		if strings.TrimSpace(change.Path) == "" {
+			return cpgo.UpsertFileResult{}, fmt.Errorf("additional file path is required")
+		}
+	}
+
+	entries, err := client.treeEntries(ctx, req)
+	if err != nil {
+		return cpgo.UpsertFileResult{}, err
+	}
+
+	// Another writer may move the head ref between our reads and the update; rebuild once on
+	// fresh state, or up to maxBaseRebuilds times when rebasing on base branch conflicts.
+	rebuilds := 1
+	if req.RebaseOnConflict {
+		rebuilds = maxBaseRebuilds
+	}
+
+	result, err := client.commitAndUpdateHead(ctx, req, entries)
+	for rebuild := 0; rebuild < rebuilds && (errors.Is(err, errRefConflict) || errors.Is(err, errBaseMoved)); rebuild++ {
+		result, err = client.commitAndUpdateHead(ctx, req, entries)
+	}
 	if err != nil {
 		return cpgo.UpsertFileResult{}, err
 	}
 
-	blobSHA, err := client.createBlob(ctx, req.Repository, req.Content)
+	return result, nil
+}
+
+// commitAndUpdateHead builds a commit and points the head ref at it.
+// A force update builds on the current base; otherwise an existing head branch is
+// extended with a fast-forward commit. All file operations land in exactly one tree and one commit.
+func (client *Client) commitAndUpdateHead(ctx context.Context, req cpgo.UpsertFileRequest, entries []*github.TreeEntry) (cpgo.UpsertFileResult, error) {
+	parentCommitSHA, parentTreeSHA, err := client.baseCommitTree(ctx, req.Repository, req.BaseBranch)
 	if err != nil {
 		return cpgo.UpsertFileResult{}, err
 	}
 
-	treeSHA, err := client.createTree(ctx, req, baseTreeSHA, blobSHA)
+	isOnBase := true
+	if !req.ForceUpdate {
+		headCommitSHA, headTreeSHA, hasHead, err := client.headCommitTree(ctx, req.Repository, req.HeadBranch)
+		if err != nil {
+			return cpgo.UpsertFileResult{}, err
+		}
+
+		if hasHead {
+			parentCommitSHA, parentTreeSHA = headCommitSHA, headTreeSHA
+			isOnBase = false
+		}
+	}
+
+	treeSHA, err := client.createTree(ctx, req.Repository, parentTreeSHA, entries)
 	if err != nil {
 		return cpgo.UpsertFileResult{}, err
 	}
 
-	commitSHA, err := client.createCommit(ctx, req, treeSHA, baseCommitSHA)
+	commitSHA, err := client.createCommit(ctx, req, treeSHA, parentCommitSHA)
 	if err != nil {
 		return cpgo.UpsertFileResult{}, err
 	}
 
-	isBranchCreated, err := client.updateHeadRef(ctx, req.Repository, req.HeadBranch, commitSHA)
+	// A head branch updated from the base is checked against the base head right before the
+	// ref moves; committing straight to the base relies on the fast-forward update instead.
+	if req.RebaseOnConflict && isOnBase && req.HeadBranch != req.BaseBranch {
+		baseCommitSHA, _, err := client.BranchHead(ctx, req.Repository, req.BaseBranch)
+		if err != nil {
+			return cpgo.UpsertFileResult{}, err
+		}
+
+		if baseCommitSHA != parentCommitSHA {
+			return cpgo.UpsertFileResult{}, fmt.Errorf("%w: %s moved from %s to %s", errBaseMoved, req.BaseBranch, parentCommitSHA, baseCommitSHA)
+		}
+	}
+
+	isBranchCreated, err := client.updateHeadRef(ctx, req.Repository, req.HeadBranch, commitSHA, req.ForceUpdate)
 	if err != nil {
 		return cpgo.UpsertFileResult{}, err
 	}
@@ -169,7 +407,84 @@ func (client *Client) UpsertFileAndForceBranch(ctx context.Context, req cpgo.Ups
 	}, nil
 }
 
-// FindOpenByHead resolves an open PR by base/head branch filters.
+// CreateTag creates a lightweight tag ref, tolerating a tag that already points at the commit.
+func (client *Client) CreateTag(ctx context.Context, req cpgo.CreateTagRequest) error {
+	if err := validateRepositoryRef(req.Repository); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(req.Name) == "" {
+		return fmt.Errorf("tag name is required")
+	}
+
+	if strings.TrimSpace(req.CommitSHA) == "" {
+		return fmt.Errorf("commit sha is required")
+	}
+
+	_, _, err := client.githubClient.Git.CreateRef(ctx, req.Repository.Owner, req.Repository.Name, github.CreateRef{
+		Ref: "refs/tags/" + req.Name,
+		SHA: req.CommitSHA,
+	})
+	if err == nil {
+		return nil
+	}
+
+	existing, _, getErr := client.githubClient.Git.GetRef(ctx, req.Repository.Owner, req.Repository.Name, "tags/"+req.Name)
+	if getErr == nil && existing.GetObject().GetSHA() == req.CommitSHA {
+		return nil
+	}
+
+	return fmt.Errorf("create tag ref: %w", err)
+}
+
+// ReportStatus creates a commit status on the current head commit of a branch.
+func (client *Client) ReportStatus(ctx context.Context, req cpgo.ReportStatusRequest) error {
+	if err := validateRepositoryRef(req.Repository); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(req.Branch) == "" {
+		return fmt.Errorf("branch is required")
+	}
+
+	if strings.TrimSpace(req.Context) == "" {
+		return fmt.Errorf("status context is required")
+	}
+
+	ref, _, err := client.githubClient.Git.GetRef(ctx, req.Repository.Owner, req.Repository.Name, "heads/"+req.Branch)
+	if err != nil {
+		return fmt.Errorf("get branch ref: %w", err)
+	}
+
+	headSHA := strings.TrimSpace(ref.GetObject().GetSHA())
+	if headSHA == "" {
+		return fmt.Errorf("branch ref has empty commit sha")
+	}
+
+	status := github.RepoStatus{
+		State:       new(req.State),
+		Context:     new(req.Context),
+		Description: new(req.Description),
+	}
+	if req.TargetURL != "" {
+		status.TargetURL = new(req.TargetURL)
+	}
+
+	if _, _, err := client.githubClient.Repositories.CreateStatus(ctx, req.Repository.Owner, req.Repository.Name, headSHA, status); err != nil {
+		// GitHub hides the statuses endpoint from classic tokens without the scope
+		// and rejects fine-grained tokens without commit status write access.
+		if isNotFound(err) || isForbidden(err) {
+			return fmt.Errorf("create commit status: %w: %v", cpgo.ErrStatusNotPermitted, err)
+		}
+
+		return fmt.Errorf("create commit status: %w", err)
+	}
+
+	return nil
+}
+
+// FindOpenByHead resolves an open PR by base/head branch filters, paging through every match
+// and preferring the first whose body carries a managed marker over the first match.
 func (client *Client) FindOpenByHead(ctx context.Context, req cpgo.FindPullRequestRequest) (*cpgo.PullRequest, error) {
 	if err := validateRepositoryRef(req.Repository); err != nil {
 		return nil, err
@@ -183,29 +498,126 @@ func (client *Client) FindOpenByHead(ctx context.Context, req cpgo.FindPullReque
 		return nil, fmt.Errorf("head branch is required")
 	}
 
-	pullRequests, _, err := client.githubClient.PullRequests.List(ctx, req.Repository.Owner, req.Repository.Name, &github.PullRequestListOptions{
+	options := &github.PullRequestListOptions{
 		State: "open",
 		Base:  req.BaseBranch,
 		Head:  req.Repository.Owner + ":" + req.HeadBranch,
 		ListOptions: github.ListOptions{
-			PerPage: 1,
+			PerPage: listPageSize,
 		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("list pull requests: %w", err)
 	}
 
-	if len(pullRequests) == 0 {
-		return nil, nil
+	var first *cpgo.PullRequest
+	for {
+		pullRequests, resp, err := client.githubClient.PullRequests.List(ctx, req.Repository.Owner, req.Repository.Name, options)
+		if err != nil {
+			return nil, fmt.Errorf("list pull requests: %w", err)
+		}
+
+		for _, pullRequest := range pullRequests {
+			match := toPullRequest(pullRequest)
+			if containsAny(match.Body, req.ManagedMarkers) {
+				return &match, nil
+			}
+
+			if first == nil {
+				first = &match
+			}
+		}
+
+		if resp == nil || resp.NextPage == 0 {
+			return first, nil
+		}
+
+		options.Page = resp.NextPage
 	}
+}
 
-	pullRequest := pullRequests[0]
-	return &cpgo.PullRequest{
-		Number: pullRequest.GetNumber(),
-		Title:  pullRequest.GetTitle(),
-		Body:   pullRequest.GetBody(),
-		URL:    pullRequest.GetHTMLURL(),
-	}, nil
+func containsAny(body string, markers []string) bool {
+	for _, marker := range markers {
+		if marker != "" && strings.Contains(body, marker) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ListOpenByHeadPrefix pages through open PRs against the base branch and keeps same-repository heads with the prefix.
+func (client *Client) ListOpenByHeadPrefix(ctx context.Context, req cpgo.ListPullRequestsRequest) ([]cpgo.PullRequest, error) {
+	if err := validateRepositoryRef(req.Repository); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(req.BaseBranch) == "" {
+		return nil, fmt.Errorf("base branch is required")
+	}
+
+	if strings.TrimSpace(req.HeadBranchPrefix) == "" {
+		return nil, fmt.Errorf("head branch prefix is required")
+	}
+
+	options := &github.PullRequestListOptions{
+		State: "open",
+		Base:  req.BaseBranch,
+		ListOptions: github.ListOptions{
+			PerPage: listPageSize,
+		},
+	}
+
+	var matches []cpgo.PullRequest
+	for {
+		pullRequests, resp, err := client.githubClient.PullRequests.List(ctx, req.Repository.Owner, req.Repository.Name, options)
+		if err != nil {
+			return nil, fmt.Errorf("list pull requests: %w", err)
+		}
+
+		for _, pullRequest := range pullRequests {
+			head := pullRequest.GetHead()
+			if head.GetRepo().GetFullName() != req.Repository.Owner+"/"+req.Repository.Name {
+				continue
+			}
+
+			if strings.HasPrefix(head.GetRef(), req.HeadBranchPrefix) {
+				matches = append(matches, toPullRequest(pullRequest))
+			}
+		}
+
+		if resp == nil || resp.NextPage == 0 {
+			return matches, nil
+		}
+
+		options.Page = resp.NextPage
+	}
+}
+
+// Close comments on a pull request and then closes it.
+func (client *Client) Close(ctx context.Context, req cpgo.ClosePullRequestRequest) error {
+	if err := validateRepositoryRef(req.Repository); err != nil {
+		return err
+	}
+
+	if req.Number <= 0 {
+		return fmt.Errorf("pull request number must be positive")
+	}
+
+	if strings.TrimSpace(req.Comment) != "" {
+		_, _, err := client.githubClient.Issues.CreateComment(ctx, req.Repository.Owner, req.Repository.Name, req.Number, &github.IssueComment{
+			Body: new(req.Comment),
+		})
+		if err != nil {
+			return fmt.Errorf("comment on pull request #%d: %w", req.Number, err)
+		}
+	}
+
+	_, _, err := client.githubClient.PullRequests.Edit(ctx, req.Repository.Owner, req.Repository.Name, req.Number, &github.PullRequest{
+		State: new("closed"),
+	})
+	if err != nil {
+		return fmt.Errorf("close pull request #%d: %w", req.Number, err)
+	}
+
+	return nil
 }
 
 // Create opens a new pull request from head branch to base branch.
@@ -230,27 +642,93 @@ func (client *Client) Create(ctx context.Context, req cpgo.CreatePullRequestRequ
 		return cpgo.PullRequest{}, fmt.Errorf("pull request body is required")
 	}
 
+	if err := client.checkLabelsExist(ctx, req.Repository, req.RequiredLabels); err != nil {
+		return cpgo.PullRequest{}, err
+	}
+
 	pullRequest, _, err := client.githubClient.PullRequests.Create(ctx, req.Repository.Owner, req.Repository.Name, &github.NewPullRequest{
 		Title: new(req.Title),
 		Head:  new(req.HeadBranch),
 		Base:  new(req.BaseBranch),
 		Body:  new(req.Body),
+		Draft: new(req.Draft),
 	})
 	if err != nil {
 		return cpgo.PullRequest{}, fmt.Errorf("create pull request: %w", err)
 	}
 
-	return cpgo.PullRequest{
-		Number: pullRequest.GetNumber(),
-		Title:  pullRequest.GetTitle(),
-		Body:   pullRequest.GetBody(),
-		URL:    pullRequest.GetHTMLURL(),
-	}, nil
+	created := toPullRequest(pullRequest)
+
+	// The pull request is already open, so a label failure warns like a failed review request.
+	labels := append(slices.Clone(req.RequiredLabels), req.Labels...)
+	if err := client.addLabels(ctx, req.Repository, pullRequest.GetNumber(), labels); err != nil {
+		created.Warnings = append(created.Warnings, err.Error())
+	}
+
+	created.Warnings = append(created.Warnings, client.requestReview(ctx, req, pullRequest.GetNumber())...)
+
+	return created, nil
+}
+
+// requestReview requests reviewers and assignees, returning failures as warnings
+// because the pull request is already open and useful without them.
+func (client *Client) requestReview(ctx context.Context, req cpgo.CreatePullRequestRequest, number int) []string {
+	var warnings []string
+	if len(req.Reviewers) > 0 || len(req.TeamReviewers) > 0 {
+		_, _, err := client.githubClient.PullRequests.RequestReviewers(ctx, req.Repository.Owner, req.Repository.Name, number, github.ReviewersRequest{
+			Reviewers:     req.Reviewers,
+			TeamReviewers: req.TeamReviewers,
+		})
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("request reviewers on pull request #%d: %v", number, err))
+		}
+	}
+
+	if len(req.Assignees) > 0 {
+		_, _, err := client.githubClient.Issues.AddAssignees(ctx, req.Repository.Owner, req.Repository.Name, number, req.Assignees)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("add assignees to pull request #%d: %v", number, err))
+		}
+	}
+
+	return warnings
+}
+
+// checkLabelsExist fails for labels missing from the repository, which GitHub would otherwise create silently.
+func (client *Client) checkLabelsExist(ctx context.Context, repository cpgo.RepositoryRef, labels []string) error {
+	for _, label := range labels {
+		_, _, err := client.githubClient.Issues.GetLabel(ctx, repository.Owner, repository.Name, url.PathEscape(label))
+		if isNotFound(err) {
+			return fmt.Errorf("pull request label %q does not exist in %s/%s", label, repository.Owner, repository.Name)
+		}
+
+		if err != nil {
+			return fmt.Errorf("get label %q: %w", label, err)
+		}
+	}
+
+	return nil
+}
+
+// addLabels applies labels to a pull request through its issue.
+func (client *Client) addLabels(ctx context.Context, repository cpgo.RepositoryRef, number int, labels []string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+
+	_, _, err := client.githubClient.Issues.AddLabelsToIssue(ctx, repository.Owner, repository.Name, number, labels)
+	if err != nil {
+		return fmt.Errorf("add labels to pull request #%d: %w", number, err)
+	}
+
+	return nil
 }
 
 // baseCommitTree fetches the base branch commit and tree SHAs.
 func (client *Client) baseCommitTree(ctx context.Context, repository cpgo.RepositoryRef, baseBranch string) (string, string, error) {
-	baseRef, _, err := client.githubClient.Git.GetRef(ctx, repository.Owner, repository.Name, "heads/"+baseBranch)
+	baseRef, _, err := retryRateLimited(ctx, client, func() (*github.Reference, *github.Response, error) {
+		return client.githubClient.Git.GetRef(ctx, repository.Owner, repository.Name, "heads/"+baseBranch)
+	})
 	if err != nil {
 		return "", "", fmt.Errorf("get base branch ref: %w", err)
 	}
@@ -260,26 +738,115 @@ func (client *Client) baseCommitTree(ctx context.Context, repository cpgo.Reposi
 		return "", "", fmt.Errorf("base branch ref has empty commit sha")
 	}
 
-	baseCommit, _, err := client.githubClient.Git.GetCommit(ctx, repository.Owner, repository.Name, baseCommitSHA)
+	baseTreeSHA, err := client.commitTree(ctx, repository, baseCommitSHA)
 	if err != nil {
-		return "", "", fmt.Errorf("get base commit: %w", err)
+		return "", "", err
 	}
 
-	baseTreeSHA := strings.TrimSpace(baseCommit.GetTree().GetSHA())
-	if baseTreeSHA == "" {
-		return "", "", fmt.Errorf("base commit has empty tree sha")
+	return baseCommitSHA, baseTreeSHA, nil
+}
+
+// refCommitTree resolves a branch or, when no branch has that name, a tag to its commit and tree.
+// Annotated tags are peeled to the commit they point at.
+func (client *Client) refCommitTree(ctx context.Context, repository cpgo.RepositoryRef, ref string) (string, string, error) {
+	commitSHA, treeSHA, err := client.baseCommitTree(ctx, repository, ref)
+	if err == nil || !isNotFound(err) {
+		return commitSHA, treeSHA, err
 	}
 
-	return baseCommitSHA, baseTreeSHA, nil
+	tagRef, _, tagErr := retryRateLimited(ctx, client, func() (*github.Reference, *github.Response, error) {
+		return client.githubClient.Git.GetRef(ctx, repository.Owner, repository.Name, "tags/"+ref)
+	})
+	if tagErr != nil {
+		if isNotFound(tagErr) {
+			return "", "", err
+		}
+
+		return "", "", fmt.Errorf("get tag ref: %w", tagErr)
+	}
+
+	commitSHA = strings.TrimSpace(tagRef.GetObject().GetSHA())
+	if tagRef.GetObject().GetType() == "tag" {
+		tag, _, err := retryRateLimited(ctx, client, func() (*github.Tag, *github.Response, error) {
+			return client.githubClient.Git.GetTag(ctx, repository.Owner, repository.Name, commitSHA)
+		})
+		if err != nil {
+			return "", "", fmt.Errorf("get annotated tag %s: %w", ref, err)
+		}
+
+		commitSHA = strings.TrimSpace(tag.GetObject().GetSHA())
+	}
+
+	if commitSHA == "" {
+		return "", "", fmt.Errorf("tag ref has empty commit sha")
+	}
+
+	treeSHA, err = client.commitTree(ctx, repository, commitSHA)
+	if err != nil {
+		return "", "", err
+	}
+
+	return commitSHA, treeSHA, nil
+}
+
+// commitTree returns the tree of a commit.
+func (client *Client) commitTree(ctx context.Context, repository cpgo.RepositoryRef, commitSHA string) (string, error) {
+	commit, _, err := retryRateLimited(ctx, client, func() (*github.Commit, *github.Response, error) {
+		return client.githubClient.Git.GetCommit(ctx, repository.Owner, repository.Name, commitSHA)
+	})
+	if err != nil {
+		return "", fmt.Errorf("get base commit: %w", err)
+	}
+
+	treeSHA := strings.TrimSpace(commit.GetTree().GetSHA())
+	if treeSHA == "" {
+		return "", fmt.Errorf("base commit has empty tree sha")
+	}
+
+	return treeSHA, nil
+}
+
+// headCommitTree resolves the head branch commit and tree, reporting false when the branch does not exist.
+func (client *Client) headCommitTree(ctx context.Context, repository cpgo.RepositoryRef, headBranch string) (string, string, bool, error) {
+	headRef, _, err := retryRateLimited(ctx, client, func() (*github.Reference, *github.Response, error) {
+		return client.githubClient.Git.GetRef(ctx, repository.Owner, repository.Name, "heads/"+headBranch)
+	})
+	if isNotFound(err) {
+		return "", "", false, nil
+	}
+	if err != nil {
+		return "", "", false, fmt.Errorf("get head branch ref: %w", err)
+	}
+
+	headCommitSHA := strings.TrimSpace(headRef.GetObject().GetSHA())
+	if headCommitSHA == "" {
+		return "", "", false, fmt.Errorf("head branch ref has empty commit sha")
+	}
+
+	headCommit, _, err := retryRateLimited(ctx, client, func() (*github.Commit, *github.Response, error) {
+		return client.githubClient.Git.GetCommit(ctx, repository.Owner, repository.Name, headCommitSHA)
+	})
+	if err != nil {
+		return "", "", false, fmt.Errorf("get head commit: %w", err)
+	}
+
+	headTreeSHA := strings.TrimSpace(headCommit.GetTree().GetSHA())
+	if headTreeSHA == "" {
+		return "", "", false, fmt.Errorf("head commit has empty tree sha")
+	}
+
+	return headCommitSHA, headTreeSHA, true, nil
 }
 
 // createBlob stores profile bytes as a git blob.
 func (client *Client) createBlob(ctx context.Context, repository cpgo.RepositoryRef, content []byte) (string, error) {
 	encodedContent := base64.StdEncoding.EncodeToString(content)
 
-	blob, _, err := client.githubClient.Git.CreateBlob(ctx, repository.Owner, repository.Name, github.Blob{
-		Content:  new(encodedContent),
-		Encoding: new("base64"),
+	blob, _, err := retryRateLimited(ctx, client, func() (*github.Blob, *github.Response, error) {
+		return client.githubClient.Git.CreateBlob(ctx, repository.Owner, repository.Name, github.Blob{
+			Content:  new(encodedContent),
+			Encoding: new("base64"),
+		})
 	})
 	if err != nil {
 		return "", fmt.Errorf("create blob: %w", err)
@@ -293,15 +860,42 @@ func (client *Client) createBlob(ctx context.Context, repository cpgo.Repository
 	return blobSHA, nil
 }
 
-// createTree builds a tree that updates the configured profile path.
-func (client *Client) createTree(ctx context.Context, req cpgo.UpsertFileRequest, baseTreeSHA string, blobSHA string) (string, error) {
-	tree, _, err := client.githubClient.Git.CreateTree(ctx, req.Repository.Owner, req.Repository.Name, baseTreeSHA, []*github.TreeEntry{
-		{
-			Path: new(req.Path),
+// treeEntries uploads blobs for every written file and returns the tree entries for one commit.
+func (client *Client) treeEntries(ctx context.Context, req cpgo.UpsertFileRequest) ([]*github.TreeEntry, error) {
+	changes := append([]cpgo.FileChange{{Path: req.Path, Content: req.Content}}, req.AdditionalFiles...)
+
+	entries := make([]*github.TreeEntry, 0, len(changes))
+	for _, change := range changes {
+		entry := &github.TreeEntry{
+			Path: new(change.Path),
 			Mode: new(fileModeRegular),
 			Type: new(treeEntryBlob),
-			SHA:  new(blobSHA),
-		},
+		}
+
+		if change.Symlink {
+			entry.Mode = new(fileModeSymlink)
+		}
+
+		// A nil SHA removes the path from the base tree.
+		if !change.Delete {
+			blobSHA, err := client.createBlob(ctx, req.Repository, change.Content)
+			if err != nil {
+				return nil, err
+			}
+
+			entry.SHA = new(blobSHA)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// createTree builds a tree that applies every file operation on top of the base tree.
+func (client *Client) createTree(ctx context.Context, repository cpgo.RepositoryRef, baseTreeSHA string, entries []*github.TreeEntry) (string, error) {
+	tree, _, err := retryRateLimited(ctx, client, func() (*github.Tree, *github.Response, error) {
+		return client.githubClient.Git.CreateTree(ctx, repository.Owner, repository.Name, baseTreeSHA, entries)
 	})
 	if err != nil {
 		return "", fmt.Errorf("create tree: %w", err)
@@ -315,9 +909,9 @@ func (client *Client) createTree(ctx context.Context, req cpgo.UpsertFileRequest
 	return treeSHA, nil
 }
 
-// createCommit creates a commit with the updated tree and base parent.
+// createCommit creates a commit with the updated tree and base parent, signing it when a signer is configured.
 func (client *Client) createCommit(ctx context.Context, req cpgo.UpsertFileRequest, treeSHA string, parentCommitSHA string) (string, error) {
-	commit, _, err := client.githubClient.Git.CreateCommit(ctx, req.Repository.Owner, req.Repository.Name, github.Commit{
+	commit := github.Commit{
 		Message: new(req.CommitMessage),
 		Tree: &github.Tree{
 			SHA: new(treeSHA),
@@ -327,12 +921,34 @@ func (client *Client) createCommit(ctx context.Context, req cpgo.UpsertFileReque
 				SHA: new(parentCommitSHA),
 			},
 		},
-	}, nil)
+	}
+
+	now := time.Now()
+	commit.Author = commitAuthor(req.Author, now)
+	commit.Committer = commitAuthor(req.Committer, now)
+
+	var options *github.CreateCommitOptions
+	if client.signer != nil {
+		// The signed payload must match what GitHub stores, so the identity and date are pinned here.
+		if commit.Author == nil {
+			commit.Author = client.signer.author(now)
+		}
+
+		if commit.Committer == nil {
+			commit.Committer = client.signer.author(now)
+		}
+
+		options = &github.CreateCommitOptions{Signer: client.signer.signer}
+	}
+
+	created, _, err := retryRateLimited(ctx, client, func() (*github.Commit, *github.Response, error) {
+		return client.githubClient.Git.CreateCommit(ctx, req.Repository.Owner, req.Repository.Name, commit, options)
+	})
 	if err != nil {
 		return "", fmt.Errorf("create commit: %w", err)
 	}
 
-	commitSHA := strings.TrimSpace(commit.GetSHA())
+	commitSHA := strings.TrimSpace(created.GetSHA())
 	if commitSHA == "" {
 		return "", fmt.Errorf("created commit has empty sha")
 	}
@@ -340,33 +956,57 @@ func (client *Client) createCommit(ctx context.Context, req cpgo.UpsertFileReque
 	return commitSHA, nil
 }
 
-// updateHeadRef force-updates the branch ref, creating it when absent.
-func (client *Client) updateHeadRef(ctx context.Context, repository cpgo.RepositoryRef, headBranch string, commitSHA string) (bool, error) {
-	_, _, err := client.githubClient.Git.UpdateRef(ctx, repository.Owner, repository.Name, "heads/"+headBranch, github.UpdateRef{
-		SHA:   commitSHA,
-		Force: new(true),
-	})
+// commitAuthor converts a configured identity, returning nil so GitHub applies its default when unset.
+func commitAuthor(identity cpgo.CommitIdentity, date time.Time) *github.CommitAuthor {
+	if identity.IsZero() {
+		return nil
+	}
+
+	return &github.CommitAuthor{
+		Name:  new(identity.Name),
+		Email: new(identity.Email),
+		Date:  &github.Timestamp{Time: date},
+	}
+}
+
+// updateHeadRef points the branch ref at the commit, creating it when absent.
+// Without force, a head branch that no longer fast-forwards to the commit is reported rather than overwritten.
+func (client *Client) updateHeadRef(ctx context.Context, repository cpgo.RepositoryRef, headBranch string, commitSHA string, force bool) (bool, error) {
+	operation := "force update branch ref"
+	if !force {
+		operation = "fast-forward branch ref"
+	}
+
+	_, _, err := client.updateRef(ctx, repository, headBranch, commitSHA, force)
 	if err == nil {
 		return false, nil
 	}
 
+	if isRefConflict(err) && !force {
+		return false, fmt.Errorf("%s: %w: %w: head branch %s diverged from commit %s and is not overwritten without force: %w",
+			operation, errRefConflict, cpgo.ErrBranchDiverged, headBranch, commitSHA, err)
+	}
+
+	if isRefConflict(err) {
+		return false, fmt.Errorf("%s: %w: %w", operation, errRefConflict, err)
+	}
+
 	if !isNotFound(err) && !isReferenceMissing(err) {
-		return false, fmt.Errorf("force update branch ref: %w", err)
+		return false, fmt.Errorf("%s: %w", operation, err)
 	}
 
-	_, _, err = client.githubClient.Git.CreateRef(ctx, repository.Owner, repository.Name, github.CreateRef{
-		Ref: "refs/heads/" + headBranch,
-		SHA: commitSHA,
+	_, _, err = retryRateLimited(ctx, client, func() (*github.Reference, *github.Response, error) {
+		return client.githubClient.Git.CreateRef(ctx, repository.Owner, repository.Name, github.CreateRef{
+			Ref: "refs/heads/" + headBranch,
+			SHA: commitSHA,
+		})
 	})
 	if err == nil {
 		return true, nil
 	}
 
 	// The branch may have been created concurrently after the initial update attempt.
-	_, _, updateErr := client.githubClient.Git.UpdateRef(ctx, repository.Owner, repository.Name, "heads/"+headBranch, github.UpdateRef{
-		SHA:   commitSHA,
-		Force: new(true),
-	})
+	_, _, updateErr := client.updateRef(ctx, repository, headBranch, commitSHA, force)
 	if updateErr == nil {
 		return false, nil
 	}
@@ -374,6 +1014,26 @@ func (client *Client) updateHeadRef(ctx context.Context, repository cpgo.Reposit
 	return false, fmt.Errorf("create branch ref: %w (retry update failed: %v)", err, updateErr)
 }
 
+// updateRef moves the head branch ref to the commit, retrying rate limit rejections.
+func (client *Client) updateRef(ctx context.Context, repository cpgo.RepositoryRef, headBranch string, commitSHA string, force bool) (*github.Reference, *github.Response, error) {
+	return retryRateLimited(ctx, client, func() (*github.Reference, *github.Response, error) {
+		return client.githubClient.Git.UpdateRef(ctx, repository.Owner, repository.Name, "heads/"+headBranch, github.UpdateRef{
+			SHA:   commitSHA,
+			Force: new(force),
+		})
+	})
+}
+
+func toPullRequest(pullRequest *github.PullRequest) cpgo.PullRequest {
+	return cpgo.PullRequest{
+		Number:     pullRequest.GetNumber(),
+		Title:      pullRequest.GetTitle(),
+		Body:       pullRequest.GetBody(),
+		URL:        pullRequest.GetHTMLURL(),
+		HeadBranch: pullRequest.GetHead().GetRef(),
+	}
+}
+
 func isNotFound(err error) bool {
 	var githubError *github.ErrorResponse
 	if !errors.As(err, &githubError) {
@@ -387,6 +1047,15 @@ func isNotFound(err error) bool {
 	return githubError.Response.StatusCode == http.StatusNotFound
 }
 
+func isForbidden(err error) bool {
+	var githubError *github.ErrorResponse
+	if !errors.As(err, &githubError) || githubError.Response == nil {
+		return false
+	}
+
+	return githubError.Response.StatusCode == http.StatusForbidden
+}
+
 func isReferenceMissing(err error) bool {
 	var githubError *github.ErrorResponse
 	if !errors.As(err, &githubError) {
@@ -410,6 +1079,42 @@ func isReferenceMissing(err error) bool {
 	return false
 }
 
+// isRefConflict detects optimistic-concurrency rejections of a ref update.
+func isRefConflict(err error) bool {
+	var githubError *github.ErrorResponse
+	if !errors.As(err, &githubError) {
+		return false
+	}
+
+	if githubError.Response == nil {
+		return false
+	}
+
+	switch githubError.Response.StatusCode {
+	case http.StatusConflict:
+		return true
+	case http.StatusUnprocessableEntity:
+	default:
+		return false
+	}
+
+	messages := []string{githubError.Message}
+	for _, item := range githubError.Errors {
+		messages = append(messages, item.Message)
+	}
+
+	for _, message := range messages {
+		normalizedMessage := strings.ToLower(message)
+		if strings.Contains(normalizedMessage, "not a fast forward") ||
+			strings.Contains(normalizedMessage, "not a fast-forward") ||
+			strings.Contains(normalizedMessage, " is at ") {
+			return true
+		}
+	}
+
+	return false
+}
+
 func validateRepositoryRef(repository cpgo.RepositoryRef) error {
 	if strings.TrimSpace(repository.Owner) == "" {
 		return fmt.Errorf("repository owner is required")
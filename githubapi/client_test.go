@@ -4,10 +4,15 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-github/v77/github"
 
@@ -47,6 +52,41 @@ func TestClientReadFile(t *testing.T) {
 		}
 	})
 
+	t.Run("reads file bytes from an annotated tag", func(t *testing.T) {
+		githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/repos/acme/payments/git/ref/heads/v1.4.0":
+				response.WriteHeader(http.StatusNotFound)
+				_, _ = response.Write([]byte(`{"message":"Not Found"}`))
+			case "/repos/acme/payments/git/ref/tags/v1.4.0":
+				_, _ = response.Write([]byte(`{"ref":"refs/tags/v1.4.0","object":{"type":"tag","sha":"tag-object"}}`))
+			case "/repos/acme/payments/git/tags/tag-object":
+				_, _ = response.Write([]byte(`{"sha":"tag-object","object":{"type":"commit","sha":"release-commit"}}`))
+			case "/repos/acme/payments/git/commits/release-commit":
+				_, _ = response.Write([]byte(`{"sha":"release-commit","tree":{"sha":"release-tree"}}`))
+			case "/repos/acme/payments/git/trees/release-tree":
+				_, _ = response.Write([]byte(`{"sha":"release-tree","truncated":false,"tree":[{"path":"default.pgo","type":"blob","sha":"pgo-sha"}]}`))
+			case "/repos/acme/payments/git/blobs/pgo-sha":
+				_, _ = response.Write([]byte("release-profile"))
+			default:
+				t.Fatalf("unexpected path: %s", req.URL.Path)
+			}
+		}))
+
+		result, err := mustNewClient(t, githubClient).ReadFile(context.Background(), cpgo.ReadFileRequest{
+			Repository: cpgo.RepositoryRef{Owner: "acme", Name: "payments"},
+			Branch:     "v1.4.0",
+			Path:       "default.pgo",
+		})
+		if err != nil {
+			t.Fatalf("read file: %v", err)
+		}
+
+		if !result.HasFile || string(result.Content) != "release-profile" {
+			t.Fatalf("expected the tagged profile, got %+v", result)
+		}
+	})
+
 	t.Run("reads file bytes when target path exists", func(t *testing.T) {
 		githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
 			switch req.URL.Path {
@@ -84,6 +124,47 @@ func TestClientReadFile(t *testing.T) {
 			t.Fatalf("expected profile bytes, got %q", string(result.Content))
 		}
 	})
+
+	t.Run("walks the path when the recursive tree is truncated", func(t *testing.T) {
+		githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/repos/acme/payments/git/ref/heads/main":
+				_, _ = response.Write([]byte(`{"ref":"refs/heads/main","object":{"type":"commit","sha":"base-commit"}}`))
+			case "/repos/acme/payments/git/commits/base-commit":
+				_, _ = response.Write([]byte(`{"sha":"base-commit","tree":{"sha":"base-tree"}}`))
+			case "/repos/acme/payments/git/trees/base-tree":
+				if req.URL.Query().Get("recursive") != "" {
+					_, _ = response.Write([]byte(`{"sha":"base-tree","truncated":true,"tree":[{"path":"README.md","type":"blob","sha":"readme-sha"}]}`))
+					return
+				}
+
+				_, _ = response.Write([]byte(`{"sha":"base-tree","truncated":false,"tree":[{"path":"cmd","type":"tree","sha":"cmd-tree"}]}`))
+			case "/repos/acme/payments/git/trees/cmd-tree":
+				_, _ = response.Write([]byte(`{"sha":"cmd-tree","truncated":false,"tree":[{"path":"default.pgo","type":"blob","sha":"pgo-sha"}]}`))
+			case "/repos/acme/payments/git/blobs/pgo-sha":
+				_, _ = response.Write([]byte("profile-bytes"))
+			default:
+				t.Fatalf("unexpected path: %s", req.URL.Path)
+			}
+		}))
+
+		client := mustNewClient(t, githubClient)
+		result, err := client.ReadFile(context.Background(), cpgo.ReadFileRequest{
+			Repository: cpgo.RepositoryRef{
+				Owner: "acme",
+				Name:  "payments",
+			},
+			Branch: "main",
+			Path:   "cmd/default.pgo",
+		})
+		if err != nil {
+			t.Fatalf("read file: %v", err)
+		}
+
+		if !result.HasFile || string(result.Content) != "profile-bytes" {
+			t.Fatalf("expected profile bytes through the tree walk, got %+v", result)
+		}
+	})
 }
 
 func TestClientFindOpenByHead(t *testing.T) {
@@ -130,119 +211,1080 @@ func TestClientFindOpenByHead(t *testing.T) {
 	}
 }
 
-func TestClientUpsertFileAndForceBranch(t *testing.T) {
-	encodedProfile := base64.StdEncoding.EncodeToString([]byte("new-profile"))
-	createRefCalled := false
+func TestClientFindOpenByHeadPrefersManaged(t *testing.T) {
+	var serverURL string
+	githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("page") == "2" {
+			_, _ = response.Write([]byte(`[{"number":43,"body":"Refresh.\n\n<!-- managed-by:cpgo -->"}]`))
+			return
+		}
+
+		response.Header().Set("Link", fmt.Sprintf(`<%s/repos/acme/payments/pulls?page=2>; rel="next"`, serverURL))
+		_, _ = response.Write([]byte(`[{"number":42,"body":"Hand-written change."}]`))
+	}))
+	serverURL = strings.TrimSuffix(githubClient.BaseURL.String(), "/")
+
+	client := mustNewClient(t, githubClient)
+	request := cpgo.FindPullRequestRequest{
+		Repository:     cpgo.RepositoryRef{Owner: "acme", Name: "payments"},
+		BaseBranch:     "main",
+		HeadBranch:     "cpgo",
+		ManagedMarkers: []string{"<!-- managed-by:cpgo -->"},
+	}
+
+	pullRequest, err := client.FindOpenByHead(context.Background(), request)
+	if err != nil {
+		t.Fatalf("find pull request: %v", err)
+	}
+
+	if pullRequest == nil || pullRequest.Number != 43 {
+		t.Fatalf("expected the managed pull request from the second page, got %+v", pullRequest)
+	}
+
+	request.ManagedMarkers = []string{"<!-- managed-by:other -->"}
+	pullRequest, err = client.FindOpenByHead(context.Background(), request)
+	if err != nil {
+		t.Fatalf("find pull request: %v", err)
+	}
+
+	if pullRequest == nil || pullRequest.Number != 42 {
+		t.Fatalf("expected the first match without a managed pull request, got %+v", pullRequest)
+	}
+}
 
+func TestClientCreateAppliesLabels(t *testing.T) {
+	var labels []string
 	githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
 		switch req.URL.Path {
-		case "/repos/acme/payments/git/ref/heads/main":
-			_, _ = response.Write([]byte(`{"ref":"refs/heads/main","object":{"type":"commit","sha":"base-commit"}}`))
-		case "/repos/acme/payments/git/commits/base-commit":
-			_, _ = response.Write([]byte(`{"sha":"base-commit","tree":{"sha":"base-tree"}}`))
-		case "/repos/acme/payments/git/blobs":
-			var payload struct {
-				Content  string `json:"content"`
-				Encoding string `json:"encoding"`
-			}
-			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
-				t.Fatalf("decode blob request: %v", err)
+		case "/repos/acme/payments/pulls":
+			_, _ = response.Write([]byte(`{"number":42,"html_url":"https://github.com/acme/payments/pull/42"}`))
+		case "/repos/acme/payments/issues/42/labels":
+			if err := json.NewDecoder(req.Body).Decode(&labels); err != nil {
+				t.Fatalf("decode labels request: %v", err)
 			}
 
-			if payload.Encoding != "base64" {
-				t.Fatalf("expected base64 encoding, got %s", payload.Encoding)
-			}
+			_, _ = response.Write([]byte(`[{"name":"hot:encoding/json"}]`))
+		default:
+			t.Fatalf("unexpected request path: %s", req.URL.Path)
+		}
+	}))
 
-			if payload.Content != encodedProfile {
-				t.Fatalf("expected encoded profile content")
-			}
+	client := mustNewClient(t, githubClient)
+	_, err := client.Create(context.Background(), cpgo.CreatePullRequestRequest{
+		Repository: cpgo.RepositoryRef{
+			Owner: "acme",
+			Name:  "payments",
+		},
+		BaseBranch: "main",
+		HeadBranch: "cpgo",
+		Title:      "perf(pgo): refresh pgo profile",
+		Body:       "Automated PGO profile refresh.",
+		Labels:     []string{"hot:encoding/json"},
+	})
+	if err != nil {
+		t.Fatalf("create pull request: %v", err)
+	}
 
-			_, _ = response.Write([]byte(`{"sha":"blob-sha"}`))
-		case "/repos/acme/payments/git/trees":
-			_, _ = response.Write([]byte(`{"sha":"tree-sha"}`))
-		case "/repos/acme/payments/git/commits":
-			_, _ = response.Write([]byte(`{"sha":"commit-sha"}`))
-		case "/repos/acme/payments/git/refs/heads/cpgo":
-			response.WriteHeader(http.StatusUnprocessableEntity)
-			_, _ = response.Write([]byte(`{"message":"Reference does not exist","errors":[]}`))
-		case "/repos/acme/payments/git/refs":
-			createRefCalled = true
-			var payload struct {
-				Ref string `json:"ref"`
-				SHA string `json:"sha"`
-			}
-			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
-				t.Fatalf("decode create ref request: %v", err)
-			}
+	if len(labels) != 1 || labels[0] != "hot:encoding/json" {
+		t.Fatalf("expected label request, got %v", labels)
+	}
+}
 
-			if payload.Ref != "refs/heads/cpgo" {
-				t.Fatalf("expected refs/heads/cpgo, got %s", payload.Ref)
+func TestClientCreateWarnsWhenLabelsFail(t *testing.T) {
+	githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/repos/acme/payments/pulls":
+			_, _ = response.Write([]byte(`{"number":42}`))
+		case "/repos/acme/payments/issues/42/labels":
+			response.WriteHeader(http.StatusForbidden)
+			_, _ = response.Write([]byte(`{"message":"Resource not accessible by integration"}`))
+		default:
+			t.Fatalf("unexpected request path: %s", req.URL.Path)
+		}
+	}))
+
+	client := mustNewClient(t, githubClient)
+	created, err := client.Create(context.Background(), cpgo.CreatePullRequestRequest{
+		Repository: cpgo.RepositoryRef{
+			Owner: "acme",
+			Name:  "payments",
+		},
+		BaseBranch: "main",
+		HeadBranch: "cpgo",
+		Title:      "perf(pgo): refresh pgo profile",
+		Body:       "Automated PGO profile refresh.",
+		Labels:     []string{"hot:encoding/json"},
+	})
+	if err != nil {
+		t.Fatalf("create pull request: %v", err)
+	}
+
+	if created.Number != 42 || len(created.Warnings) != 1 || !strings.Contains(created.Warnings[0], "add labels to pull request #42") {
+		t.Fatalf("expected the pull request with a label warning, got %+v", created)
+	}
+}
+
+func TestClientCreateRequiresExistingLabels(t *testing.T) {
+	var labels []string
+	githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/repos/acme/payments/labels/team/perf":
+			_, _ = response.Write([]byte(`{"name":"team/perf"}`))
+		case "/repos/acme/payments/labels/typo":
+			response.WriteHeader(http.StatusNotFound)
+			_, _ = response.Write([]byte(`{"message":"Not Found"}`))
+		case "/repos/acme/payments/pulls":
+			_, _ = response.Write([]byte(`{"number":42}`))
+		case "/repos/acme/payments/issues/42/labels":
+			if err := json.NewDecoder(req.Body).Decode(&labels); err != nil {
+				t.Fatalf("decode labels request: %v", err)
 			}
 
-			if payload.SHA != "commit-sha" {
-				t.Fatalf("expected commit-sha, got %s", payload.SHA)
+			_, _ = response.Write([]byte(`[]`))
+		default:
+			t.Fatalf("unexpected request path: %s", req.URL.Path)
+		}
+	}))
+
+	client := mustNewClient(t, githubClient)
+	req := cpgo.CreatePullRequestRequest{
+		Repository: cpgo.RepositoryRef{
+			Owner: "acme",
+			Name:  "payments",
+		},
+		BaseBranch:     "main",
+		HeadBranch:     "cpgo",
+		Title:          "perf(pgo): refresh pgo profile",
+		Body:           "Automated PGO profile refresh.",
+		RequiredLabels: []string{"team/perf"},
+		Labels:         []string{"hot:encoding/json"},
+	}
+	if _, err := client.Create(context.Background(), req); err != nil {
+		t.Fatalf("create pull request: %v", err)
+	}
+
+	if len(labels) != 2 || labels[0] != "team/perf" || labels[1] != "hot:encoding/json" {
+		t.Fatalf("expected required and derived labels, got %v", labels)
+	}
+
+	req.RequiredLabels = []string{"typo"}
+	_, err := client.Create(context.Background(), req)
+	if err == nil || !strings.Contains(err.Error(), `label "typo" does not exist`) {
+		t.Fatalf("expected missing label error, got %v", err)
+	}
+}
+
+func TestClientCreateRequestsReview(t *testing.T) {
+	var reviewers struct {
+		Reviewers     []string `json:"reviewers"`
+		TeamReviewers []string `json:"team_reviewers"`
+	}
+	githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/repos/acme/payments/pulls":
+			_, _ = response.Write([]byte(`{"number":42}`))
+		case "/repos/acme/payments/pulls/42/requested_reviewers":
+			if err := json.NewDecoder(req.Body).Decode(&reviewers); err != nil {
+				t.Fatalf("decode reviewers request: %v", err)
 			}
 
-			_, _ = response.Write([]byte(`{"ref":"refs/heads/cpgo","object":{"type":"commit","sha":"commit-sha"}}`))
+			_, _ = response.Write([]byte(`{"number":42}`))
+		case "/repos/acme/payments/issues/42/assignees":
+			response.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = response.Write([]byte(`{"message":"Validation Failed"}`))
 		default:
 			t.Fatalf("unexpected request path: %s", req.URL.Path)
 		}
 	}))
 
 	client := mustNewClient(t, githubClient)
-	result, err := client.UpsertFileAndForceBranch(context.Background(), cpgo.UpsertFileRequest{
+	created, err := client.Create(context.Background(), cpgo.CreatePullRequestRequest{
 		Repository: cpgo.RepositoryRef{
 			Owner: "acme",
 			Name:  "payments",
 		},
 		BaseBranch:    "main",
 		HeadBranch:    "cpgo",
-		Path:          "default.pgo",
-		Content:       []byte("new-profile"),
-		CommitMessage: "perf(pgo): refresh pgo profile",
+		Title:         "perf(pgo): refresh pgo profile",
+		Body:          "Automated PGO profile refresh.",
+		Reviewers:     []string{"octocat"},
+		TeamReviewers: []string{"perf-team"},
+		Assignees:     []string{"ghost"},
 	})
 	if err != nil {
-		t.Fatalf("upsert file: %v", err)
+		t.Fatalf("create pull request: %v", err)
 	}
 
-	if result.CommitSHA != "commit-sha" {
-		t.Fatalf("expected commit-sha, got %s", result.CommitSHA)
+	if len(reviewers.Reviewers) != 1 || len(reviewers.TeamReviewers) != 1 || reviewers.TeamReviewers[0] != "perf-team" {
+		t.Fatalf("unexpected reviewers request %+v", reviewers)
 	}
 
-	if !result.IsBranchCreated {
-		t.Fatalf("expected branch creation")
+	if created.Number != 42 || len(created.Warnings) != 1 || !strings.Contains(created.Warnings[0], "add assignees") {
+		t.Fatalf("expected the pull request with an assignee warning, got %+v", created)
 	}
+}
 
-	if !createRefCalled {
-		t.Fatalf("expected create ref call")
+func TestClientListOpenByHeadPrefix(t *testing.T) {
+	var serverURL string
+	githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/repos/acme/payments/pulls" {
+			t.Fatalf("unexpected path: %s", req.URL.Path)
+		}
+
+		if req.URL.Query().Get("page") == "2" {
+			_, _ = response.Write([]byte(`[{"number":3,"head":{"ref":"cpgo/20240501-120000","repo":{"full_name":"acme/payments"}}}]`))
+			return
+		}
+
+		response.Header().Set("Link", fmt.Sprintf(`<%s/repos/acme/payments/pulls?page=2>; rel="next"`, serverURL))
+		_, _ = response.Write([]byte(`[
+			{"number":1,"head":{"ref":"cpgo/20240430-120000","repo":{"full_name":"acme/payments"}}},
+			{"number":2,"head":{"ref":"cpgo/20240430-130000","repo":{"full_name":"fork/payments"}}},
+			{"number":4,"head":{"ref":"feature","repo":{"full_name":"acme/payments"}}}
+		]`))
+	}))
+	serverURL = strings.TrimSuffix(githubClient.BaseURL.String(), "/")
+
+	client := mustNewClient(t, githubClient)
+	pullRequests, err := client.ListOpenByHeadPrefix(context.Background(), cpgo.ListPullRequestsRequest{
+		Repository: cpgo.RepositoryRef{
+			Owner: "acme",
+			Name:  "payments",
+		},
+		BaseBranch:       "main",
+		HeadBranchPrefix: "cpgo/",
+	})
+	if err != nil {
+		t.Fatalf("list pull requests: %v", err)
+	}
+
+	if len(pullRequests) != 2 || pullRequests[0].Number != 1 || pullRequests[1].Number != 3 {
+		t.Fatalf("expected same-repository prefix matches across pages, got %+v", pullRequests)
 	}
 }
 
-func mustNewClient(t *testing.T, githubClient *github.Client) *Client {
-	t.Helper()
+func TestClientBranchHead(t *testing.T) {
+	githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/repos/acme/payments/git/ref/heads/main":
+			_, _ = response.Write([]byte(`{"ref":"refs/heads/main","object":{"type":"commit","sha":"base-commit"}}`))
+		case "/repos/acme/payments/git/ref/heads/missing":
+			response.WriteHeader(http.StatusNotFound)
+			_, _ = response.Write([]byte(`{"message":"Not Found"}`))
+		default:
+			t.Fatalf("unexpected request path: %s", req.URL.Path)
+		}
+	}))
 
-	client, err := NewClient(githubClient)
-	if err != nil {
-		t.Fatalf("new client: %v", err)
+	client := mustNewClient(t, githubClient)
+	repository := cpgo.RepositoryRef{Owner: "acme", Name: "payments"}
+
+	commitSHA, hasBranch, err := client.BranchHead(context.Background(), repository, "main")
+	if err != nil || !hasBranch || commitSHA != "base-commit" {
+		t.Fatalf("expected base-commit head, got %q %t %v", commitSHA, hasBranch, err)
 	}
 
-	return client
+	_, hasBranch, err = client.BranchHead(context.Background(), repository, "missing")
+	if err != nil || hasBranch {
+		t.Fatalf("expected missing branch without error, got %t %v", hasBranch, err)
+	}
 }
 
-func newGitHubClient(t *testing.T, handler http.Handler) *github.Client {
-	t.Helper()
+func TestClientDeleteBranch(t *testing.T) {
+	githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/repos/acme/payments/git/refs/heads/cpgo":
+			response.WriteHeader(http.StatusNoContent)
+		case "/repos/acme/payments/git/refs/heads/gone":
+			response.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = response.Write([]byte(`{"message":"Reference does not exist"}`))
+		default:
+			t.Fatalf("unexpected request path: %s", req.URL.Path)
+		}
+	}))
 
-	server := httptest.NewServer(handler)
-	t.Cleanup(server.Close)
+	client := mustNewClient(t, githubClient)
+	repository := cpgo.RepositoryRef{Owner: "acme", Name: "payments"}
 
-	baseURL, err := url.Parse(server.URL + "/")
-	if err != nil {
-		t.Fatalf("parse base url: %v", err)
+	if isDeleted, err := client.DeleteBranch(context.Background(), repository, "cpgo"); err != nil || !isDeleted {
+		t.Fatalf("expected the branch to be deleted, got %t %v", isDeleted, err)
 	}
 
-	client := github.NewClient(server.Client())
-	client.BaseURL = baseURL
-	client.UploadURL = baseURL
+	if isDeleted, err := client.DeleteBranch(context.Background(), repository, "gone"); err != nil || isDeleted {
+		t.Fatalf("expected a missing branch to report no deletion, got %t %v", isDeleted, err)
+	}
+}
 
-	return client
+func TestClientBranchProtection(t *testing.T) {
+	githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/repos/acme/payments/branches/cpgo/protection":
+			_, _ = response.Write([]byte(`{"allow_force_pushes":{"enabled":false},"required_pull_request_reviews":{"required_approving_review_count":1}}`))
+		case "/repos/acme/payments/branches/main/protection":
+			_, _ = response.Write([]byte(`{"required_pull_request_reviews":{"bypass_pull_request_allowances":{"apps":[{"slug":"cpgo"}]}}}`))
+		case "/repos/acme/payments/branches/open/protection":
+			response.WriteHeader(http.StatusNotFound)
+			_, _ = response.Write([]byte(`{"message":"Branch not protected"}`))
+		case "/repos/acme/payments/branches/hidden/protection":
+			response.WriteHeader(http.StatusForbidden)
+			_, _ = response.Write([]byte(`{"message":"Resource not accessible by integration"}`))
+		default:
+			t.Fatalf("unexpected request path: %s", req.URL.Path)
+		}
+	}))
+
+	client := mustNewClient(t, githubClient)
+	repository := cpgo.RepositoryRef{Owner: "acme", Name: "payments"}
+
+	protection, isProtected, err := client.BranchProtection(context.Background(), repository, "cpgo")
+	if err != nil || !isProtected || protection.AllowsForcePushes || !protection.RequiresPullRequest {
+		t.Fatalf("expected protected branch requiring pull requests, got %+v %t %v", protection, isProtected, err)
+	}
+
+	protection, _, err = client.BranchProtection(context.Background(), repository, "main")
+	if err != nil || !protection.RequiresPullRequest || !protection.HasPullRequestBypass {
+		t.Fatalf("expected pull request bypass actors, got %+v %v", protection, err)
+	}
+
+	for _, branch := range []string{"open", "hidden"} {
+		if _, isProtected, err := client.BranchProtection(context.Background(), repository, branch); err != nil || isProtected {
+			t.Fatalf("expected %s to read as unprotected, got %t %v", branch, isProtected, err)
+		}
+	}
+}
+
+func TestClientLastCommitTime(t *testing.T) {
+	githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/repos/acme/payments/git/ref/heads/cpgo":
+			_, _ = response.Write([]byte(`{"ref":"refs/heads/cpgo","object":{"type":"commit","sha":"head-commit"}}`))
+		case "/repos/acme/payments/git/commits/head-commit":
+			_, _ = response.Write([]byte(`{"sha":"head-commit","committer":{"date":"2024-05-01T12:00:00Z"}}`))
+		case "/repos/acme/payments/git/ref/heads/missing":
+			response.WriteHeader(http.StatusNotFound)
+			_, _ = response.Write([]byte(`{"message":"Not Found"}`))
+		default:
+			t.Fatalf("unexpected request path: %s", req.URL.Path)
+		}
+	}))
+
+	client := mustNewClient(t, githubClient)
+	repository := cpgo.RepositoryRef{
+		Owner: "acme",
+		Name:  "payments",
+	}
+
+	lastCommitTime, hasBranch, err := client.LastCommitTime(context.Background(), repository, "cpgo")
+	if err != nil {
+		t.Fatalf("last commit time: %v", err)
+	}
+
+	if !hasBranch || !lastCommitTime.Equal(time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected head commit time %s (branch %t)", lastCommitTime, hasBranch)
+	}
+
+	_, hasBranch, err = client.LastCommitTime(context.Background(), repository, "missing")
+	if err != nil || hasBranch {
+		t.Fatalf("expected missing branch without error, got %t %v", hasBranch, err)
+	}
+}
+
+func TestClientCreateTag(t *testing.T) {
+	githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/repos/acme/payments/git/refs":
+			var payload struct {
+				Ref string `json:"ref"`
+				SHA string `json:"sha"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode create ref request: %v", err)
+			}
+
+			if payload.Ref != "refs/tags/pgo/2024-05-01-commit-" || payload.SHA != "commit-sha" {
+				t.Fatalf("unexpected tag ref payload: %+v", payload)
+			}
+
+			response.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = response.Write([]byte(`{"message":"Reference already exists","errors":[]}`))
+		case "/repos/acme/payments/git/ref/tags/pgo/2024-05-01-commit-":
+			_, _ = response.Write([]byte(`{"ref":"refs/tags/pgo/2024-05-01-commit-","object":{"type":"commit","sha":"commit-sha"}}`))
+		default:
+			t.Fatalf("unexpected request path: %s", req.URL.Path)
+		}
+	}))
+
+	client := mustNewClient(t, githubClient)
+	err := client.CreateTag(context.Background(), cpgo.CreateTagRequest{
+		Repository: cpgo.RepositoryRef{
+			Owner: "acme",
+			Name:  "payments",
+		},
+		Name:      "pgo/2024-05-01-commit-",
+		CommitSHA: "commit-sha",
+	})
+	if err != nil {
+		t.Fatalf("expected existing tag at the same commit to be accepted, got %v", err)
+	}
+}
+
+func TestClientUpsertFileAndForceBranch(t *testing.T) {
+	encodedProfile := base64.StdEncoding.EncodeToString([]byte("new-profile"))
+	createRefCalled := false
+
+	githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/repos/acme/payments/git/ref/heads/main":
+			_, _ = response.Write([]byte(`{"ref":"refs/heads/main","object":{"type":"commit","sha":"base-commit"}}`))
+		case "/repos/acme/payments/git/commits/base-commit":
+			_, _ = response.Write([]byte(`{"sha":"base-commit","tree":{"sha":"base-tree"}}`))
+		case "/repos/acme/payments/git/blobs":
+			var payload struct {
+				Content  string `json:"content"`
+				Encoding string `json:"encoding"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode blob request: %v", err)
+			}
+
+			if payload.Encoding != "base64" {
+				t.Fatalf("expected base64 encoding, got %s", payload.Encoding)
+			}
+
+			if payload.Content != encodedProfile {
+				t.Fatalf("expected encoded profile content")
+			}
+
+			_, _ = response.Write([]byte(`{"sha":"blob-sha"}`))
+		case "/repos/acme/payments/git/trees":
+			_, _ = response.Write([]byte(`{"sha":"tree-sha"}`))
+		case "/repos/acme/payments/git/commits":
+			_, _ = response.Write([]byte(`{"sha":"commit-sha"}`))
+		case "/repos/acme/payments/git/refs/heads/cpgo":
+			response.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = response.Write([]byte(`{"message":"Reference does not exist","errors":[]}`))
+		case "/repos/acme/payments/git/refs":
+			createRefCalled = true
+			var payload struct {
+				Ref string `json:"ref"`
+				SHA string `json:"sha"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode create ref request: %v", err)
+			}
+
+			if payload.Ref != "refs/heads/cpgo" {
+				t.Fatalf("expected refs/heads/cpgo, got %s", payload.Ref)
+			}
+
+			if payload.SHA != "commit-sha" {
+				t.Fatalf("expected commit-sha, got %s", payload.SHA)
+			}
+
+			_, _ = response.Write([]byte(`{"ref":"refs/heads/cpgo","object":{"type":"commit","sha":"commit-sha"}}`))
+		default:
+			t.Fatalf("unexpected request path: %s", req.URL.Path)
+		}
+	}))
+
+	client := mustNewClient(t, githubClient)
+	result, err := client.UpsertFileAndForceBranch(context.Background(), cpgo.UpsertFileRequest{
+		Repository: cpgo.RepositoryRef{
+			Owner: "acme",
+			Name:  "payments",
+		},
+		BaseBranch:    "main",
+		HeadBranch:    "cpgo",
+		Path:          "default.pgo",
+		Content:       []byte("new-profile"),
+		CommitMessage: "perf(pgo): refresh pgo profile",
+		ForceUpdate:   true,
+	})
+	if err != nil {
+		t.Fatalf("upsert file: %v", err)
+	}
+
+	if result.CommitSHA != "commit-sha" {
+		t.Fatalf("expected commit-sha, got %s", result.CommitSHA)
+	}
+
+	if !result.IsBranchCreated {
+		t.Fatalf("expected branch creation")
+	}
+
+	if !createRefCalled {
+		t.Fatalf("expected create ref call")
+	}
+}
+
+func TestClientUpsertFileAndForceBranchRetriesRateLimits(t *testing.T) {
+	const secondaryRateLimit = `{"message":"You have exceeded a secondary rate limit.","documentation_url":"https://docs.github.com/rest/overview/rate-limits-for-the-rest-api#about-secondary-rate-limits"}`
+
+	newRateLimitedClient := func(t *testing.T, limitedBlobCalls int, retryAfter string) (*Client, *int) {
+		t.Helper()
+
+		var blobCalls int
+		githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/repos/acme/payments/git/ref/heads/main":
+				_, _ = response.Write([]byte(`{"ref":"refs/heads/main","object":{"type":"commit","sha":"base-commit"}}`))
+			case "/repos/acme/payments/git/commits/base-commit":
+				_, _ = response.Write([]byte(`{"sha":"base-commit","tree":{"sha":"base-tree"}}`))
+			case "/repos/acme/payments/git/blobs":
+				blobCalls++
+				if blobCalls <= limitedBlobCalls {
+					response.Header().Set("Retry-After", retryAfter)
+					response.WriteHeader(http.StatusForbidden)
+					_, _ = response.Write([]byte(secondaryRateLimit))
+					return
+				}
+
+				_, _ = response.Write([]byte(`{"sha":"blob-sha"}`))
+			case "/repos/acme/payments/git/trees":
+				_, _ = response.Write([]byte(`{"sha":"tree-sha"}`))
+			case "/repos/acme/payments/git/commits":
+				_, _ = response.Write([]byte(`{"sha":"commit-sha"}`))
+			case "/repos/acme/payments/git/refs/heads/cpgo":
+				_, _ = response.Write([]byte(`{"ref":"refs/heads/cpgo","object":{"type":"commit","sha":"commit-sha"}}`))
+			default:
+				t.Fatalf("unexpected request path: %s", req.URL.Path)
+			}
+		}))
+
+		return mustNewClient(t, githubClient), &blobCalls
+	}
+
+	upsert := func(ctx context.Context, client *Client) error {
+		_, err := client.UpsertFileAndForceBranch(ctx, cpgo.UpsertFileRequest{
+			Repository:    cpgo.RepositoryRef{Owner: "acme", Name: "payments"},
+			BaseBranch:    "main",
+			HeadBranch:    "cpgo",
+			Path:          "default.pgo",
+			Content:       []byte("new-profile"),
+			CommitMessage: "perf(pgo): refresh pgo profile",
+			ForceUpdate:   true,
+		})
+
+		return err
+	}
+
+	t.Run("retries a secondary rate limit after the requested wait", func(t *testing.T) {
+		client, blobCalls := newRateLimitedClient(t, 2, "0")
+
+		var waits []time.Duration
+		client.sleep = func(_ context.Context, wait time.Duration) error {
+			waits = append(waits, wait)
+			return nil
+		}
+
+		if err := upsert(context.Background(), client); err != nil {
+			t.Fatalf("upsert file: %v", err)
+		}
+
+		if *blobCalls != 3 || len(waits) != 2 || waits[0] != 0 {
+			t.Fatalf("expected two waits of the Retry-After delay before the blob succeeded, got %d calls and waits %v", *blobCalls, waits)
+		}
+	})
+
+	t.Run("gives up after the bounded number of retries", func(t *testing.T) {
+		client, blobCalls := newRateLimitedClient(t, 10, "0")
+		client.sleep = func(context.Context, time.Duration) error { return nil }
+
+		err := upsert(context.Background(), client)
+
+		var abuseErr *github.AbuseRateLimitError
+		if !errors.As(err, &abuseErr) {
+			t.Fatalf("expected the secondary rate limit error, got %v", err)
+		}
+
+		if *blobCalls != defaultRateLimitRetries+1 {
+			t.Fatalf("expected %d blob calls, got %d", defaultRateLimitRetries+1, *blobCalls)
+		}
+	})
+
+	t.Run("does not wait past the context deadline", func(t *testing.T) {
+		client, blobCalls := newRateLimitedClient(t, 10, "60")
+		client.sleep = func(context.Context, time.Duration) error {
+			t.Fatalf("expected no wait beyond the context deadline")
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		t.Cleanup(cancel)
+
+		var abuseErr *github.AbuseRateLimitError
+		if err := upsert(ctx, client); !errors.As(err, &abuseErr) {
+			t.Fatalf("expected the secondary rate limit error, got %v", err)
+		}
+
+		if *blobCalls != 1 {
+			t.Fatalf("expected a single blob call, got %d", *blobCalls)
+		}
+	})
+}
+
+func TestClientUpsertFileAndForceBranchBatchesFiles(t *testing.T) {
+	var blobCalls, treeCalls, commitCalls int
+	var treeEntries []map[string]any
+
+	githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/repos/acme/payments/git/ref/heads/main":
+			_, _ = response.Write([]byte(`{"ref":"refs/heads/main","object":{"type":"commit","sha":"base-commit"}}`))
+		case "/repos/acme/payments/git/commits/base-commit":
+			_, _ = response.Write([]byte(`{"sha":"base-commit","tree":{"sha":"base-tree"}}`))
+		case "/repos/acme/payments/git/blobs":
+			blobCalls++
+			_, _ = fmt.Fprintf(response, `{"sha":"blob-%d"}`, blobCalls)
+		case "/repos/acme/payments/git/trees":
+			treeCalls++
+			var payload struct {
+				Tree []map[string]any `json:"tree"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode tree request: %v", err)
+			}
+
+			treeEntries = payload.Tree
+			_, _ = response.Write([]byte(`{"sha":"tree-sha"}`))
+		case "/repos/acme/payments/git/commits":
+			commitCalls++
+			_, _ = response.Write([]byte(`{"sha":"commit-sha"}`))
+		case "/repos/acme/payments/git/refs/heads/cpgo":
+			_, _ = response.Write([]byte(`{"ref":"refs/heads/cpgo","object":{"type":"commit","sha":"commit-sha"}}`))
+		default:
+			t.Fatalf("unexpected request path: %s", req.URL.Path)
+		}
+	}))
+
+	var additionalFiles []cpgo.FileChange
+	for index := range 8 {
+		additionalFiles = append(additionalFiles, cpgo.FileChange{
+			Path:    fmt.Sprintf("services/%d/default.pgo", index),
+			Content: []byte("profile"),
+		})
+	}
+	additionalFiles[0].Content = []byte("default-0123456789ab.pgo")
+	additionalFiles[0].Symlink = true
+	additionalFiles = append(additionalFiles, cpgo.FileChange{
+		Path:   "stale.pgo",
+		Delete: true,
+	})
+
+	client := mustNewClient(t, githubClient)
+	_, err := client.UpsertFileAndForceBranch(context.Background(), cpgo.UpsertFileRequest{
+		Repository: cpgo.RepositoryRef{
+			Owner: "acme",
+			Name:  "payments",
+		},
+		BaseBranch:      "main",
+		HeadBranch:      "cpgo",
+		Path:            "default.pgo",
+		Content:         []byte("new-profile"),
+		AdditionalFiles: additionalFiles,
+		CommitMessage:   "perf(pgo): refresh pgo profile",
+		ForceUpdate:     true,
+	})
+	if err != nil {
+		t.Fatalf("upsert files: %v", err)
+	}
+
+	if treeCalls != 1 || commitCalls != 1 {
+		t.Fatalf("expected one tree and one commit for 10 files, got %d trees and %d commits", treeCalls, commitCalls)
+	}
+
+	if blobCalls != 9 {
+		t.Fatalf("expected 9 blobs, got %d", blobCalls)
+	}
+
+	if len(treeEntries) != 10 {
+		t.Fatalf("expected 10 tree entries, got %d", len(treeEntries))
+	}
+
+	if mode := treeEntries[1]["mode"]; mode != fileModeSymlink {
+		t.Fatalf("expected symlink mode for symlink entry, got %v", mode)
+	}
+
+	deleted := treeEntries[len(treeEntries)-1]
+	if sha, hasSHA := deleted["sha"]; !hasSHA || sha != nil {
+		t.Fatalf("expected null sha for deleted entry, got %v", deleted)
+	}
+}
+
+func TestClientUpsertFileAndForceBranchRetriesRefConflict(t *testing.T) {
+	baseRefReads := 0
+	commitsCreated := 0
+	refUpdates := 0
+
+	githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/repos/acme/payments/git/ref/heads/main":
+			baseRefReads++
+			if baseRefReads == 1 {
+				_, _ = response.Write([]byte(`{"ref":"refs/heads/main","object":{"type":"commit","sha":"base-commit"}}`))
+				return
+			}
+
+			_, _ = response.Write([]byte(`{"ref":"refs/heads/main","object":{"type":"commit","sha":"moved-commit"}}`))
+		case "/repos/acme/payments/git/commits/base-commit":
+			_, _ = response.Write([]byte(`{"sha":"base-commit","tree":{"sha":"base-tree"}}`))
+		case "/repos/acme/payments/git/commits/moved-commit":
+			_, _ = response.Write([]byte(`{"sha":"moved-commit","tree":{"sha":"moved-tree"}}`))
+		case "/repos/acme/payments/git/blobs":
+			_, _ = response.Write([]byte(`{"sha":"blob-sha"}`))
+		case "/repos/acme/payments/git/trees":
+			_, _ = response.Write([]byte(`{"sha":"tree-sha"}`))
+		case "/repos/acme/payments/git/commits":
+			commitsCreated++
+			_, _ = fmt.Fprintf(response, `{"sha":"commit-sha-%d"}`, commitsCreated)
+		case "/repos/acme/payments/git/refs/heads/cpgo":
+			refUpdates++
+			if refUpdates == 1 {
+				response.WriteHeader(http.StatusConflict)
+				_, _ = response.Write([]byte(`{"message":"Reference cannot be updated: refs/heads/cpgo is at other-sha but expected base-commit"}`))
+				return
+			}
+
+			_, _ = response.Write([]byte(`{"ref":"refs/heads/cpgo","object":{"type":"commit","sha":"commit-sha-2"}}`))
+		default:
+			t.Fatalf("unexpected request path: %s", req.URL.Path)
+		}
+	}))
+
+	client := mustNewClient(t, githubClient)
+	result, err := client.UpsertFileAndForceBranch(context.Background(), cpgo.UpsertFileRequest{
+		Repository: cpgo.RepositoryRef{
+			Owner: "acme",
+			Name:  "payments",
+		},
+		BaseBranch:    "main",
+		HeadBranch:    "cpgo",
+		Path:          "default.pgo",
+		Content:       []byte("new-profile"),
+		CommitMessage: "perf(pgo): refresh pgo profile",
+		ForceUpdate:   true,
+	})
+	if err != nil {
+		t.Fatalf("upsert file: %v", err)
+	}
+
+	if result.CommitSHA != "commit-sha-2" {
+		t.Fatalf("expected retried commit-sha-2, got %s", result.CommitSHA)
+	}
+
+	if baseRefReads != 2 {
+		t.Fatalf("expected base ref to be re-resolved once, got %d reads", baseRefReads)
+	}
+
+	if refUpdates != 2 {
+		t.Fatalf("expected two ref updates, got %d", refUpdates)
+	}
+}
+
+func TestClientUpsertFileRebasesOnMovedBase(t *testing.T) {
+	baseRefReads := 0
+	commitsCreated := 0
+	refUpdates := 0
+	var treeBases []string
+
+	githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/repos/acme/payments/git/ref/heads/main":
+			// The base moves after the first commit was built on it and then stays put.
+			baseRefReads++
+			if baseRefReads == 1 {
+				_, _ = response.Write([]byte(`{"ref":"refs/heads/main","object":{"type":"commit","sha":"base-commit"}}`))
+				return
+			}
+
+			_, _ = response.Write([]byte(`{"ref":"refs/heads/main","object":{"type":"commit","sha":"moved-commit"}}`))
+		case "/repos/acme/payments/git/commits/base-commit":
+			_, _ = response.Write([]byte(`{"sha":"base-commit","tree":{"sha":"base-tree"}}`))
+		case "/repos/acme/payments/git/commits/moved-commit":
+			_, _ = response.Write([]byte(`{"sha":"moved-commit","tree":{"sha":"moved-tree"}}`))
+		case "/repos/acme/payments/git/blobs":
+			_, _ = response.Write([]byte(`{"sha":"blob-sha"}`))
+		case "/repos/acme/payments/git/trees":
+			var payload struct {
+				BaseTree string `json:"base_tree"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode tree request: %v", err)
+			}
+
+			treeBases = append(treeBases, payload.BaseTree)
+			_, _ = response.Write([]byte(`{"sha":"tree-sha"}`))
+		case "/repos/acme/payments/git/commits":
+			commitsCreated++
+			_, _ = fmt.Fprintf(response, `{"sha":"commit-sha-%d"}`, commitsCreated)
+		case "/repos/acme/payments/git/refs/heads/cpgo":
+			refUpdates++
+			_, _ = response.Write([]byte(`{"ref":"refs/heads/cpgo","object":{"type":"commit","sha":"commit-sha-2"}}`))
+		default:
+			t.Fatalf("unexpected request path: %s", req.URL.Path)
+		}
+	}))
+
+	client := mustNewClient(t, githubClient)
+	result, err := client.UpsertFileAndForceBranch(context.Background(), cpgo.UpsertFileRequest{
+		Repository: cpgo.RepositoryRef{
+			Owner: "acme",
+			Name:  "payments",
+		},
+		BaseBranch:       "main",
+		HeadBranch:       "cpgo",
+		Path:             "default.pgo",
+		Content:          []byte("new-profile"),
+		CommitMessage:    "perf(pgo): refresh pgo profile",
+		ForceUpdate:      true,
+		RebaseOnConflict: true,
+	})
+	if err != nil {
+		t.Fatalf("upsert file: %v", err)
+	}
+
+	if result.CommitSHA != "commit-sha-2" || refUpdates != 1 {
+		t.Fatalf("expected only the rebuilt commit-sha-2 to be pushed, got %s after %d ref updates", result.CommitSHA, refUpdates)
+	}
+
+	if !reflect.DeepEqual(treeBases, []string{"base-tree", "moved-tree"}) {
+		t.Fatalf("expected the rebuilt tree to use the moved base, got %v", treeBases)
+	}
+}
+
+func TestClientUpsertFileFastForwardsExistingHead(t *testing.T) {
+	var treePayload struct {
+		BaseTree string `json:"base_tree"`
+	}
+	var commitPayload struct {
+		Parents []string `json:"parents"`
+	}
+	var refPayload struct {
+		SHA   string `json:"sha"`
+		Force bool   `json:"force"`
+	}
+	isDiverged := false
+
+	githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/repos/acme/payments/git/ref/heads/main":
+			_, _ = response.Write([]byte(`{"ref":"refs/heads/main","object":{"type":"commit","sha":"base-commit"}}`))
+		case "/repos/acme/payments/git/ref/heads/cpgo":
+			_, _ = response.Write([]byte(`{"ref":"refs/heads/cpgo","object":{"type":"commit","sha":"head-commit"}}`))
+		case "/repos/acme/payments/git/commits/base-commit":
+			_, _ = response.Write([]byte(`{"sha":"base-commit","tree":{"sha":"base-tree"}}`))
+		case "/repos/acme/payments/git/commits/head-commit":
+			_, _ = response.Write([]byte(`{"sha":"head-commit","tree":{"sha":"head-tree"}}`))
+		case "/repos/acme/payments/git/blobs":
+			_, _ = response.Write([]byte(`{"sha":"blob-sha"}`))
+		case "/repos/acme/payments/git/trees":
+			if err := json.NewDecoder(req.Body).Decode(&treePayload); err != nil {
+				t.Fatalf("decode tree request: %v", err)
+			}
+			_, _ = response.Write([]byte(`{"sha":"tree-sha"}`))
+		case "/repos/acme/payments/git/commits":
+			if err := json.NewDecoder(req.Body).Decode(&commitPayload); err != nil {
+				t.Fatalf("decode commit request: %v", err)
+			}
+			_, _ = response.Write([]byte(`{"sha":"commit-sha"}`))
+		case "/repos/acme/payments/git/refs/heads/cpgo":
+			if err := json.NewDecoder(req.Body).Decode(&refPayload); err != nil {
+				t.Fatalf("decode update ref request: %v", err)
+			}
+
+			if isDiverged {
+				response.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = response.Write([]byte(`{"message":"Update is not a fast forward"}`))
+				return
+			}
+
+			_, _ = response.Write([]byte(`{"ref":"refs/heads/cpgo","object":{"type":"commit","sha":"commit-sha"}}`))
+		default:
+			t.Fatalf("unexpected request path: %s", req.URL.Path)
+		}
+	}))
+
+	client := mustNewClient(t, githubClient)
+	upsert := func() (cpgo.UpsertFileResult, error) {
+		return client.UpsertFileAndForceBranch(context.Background(), cpgo.UpsertFileRequest{
+			Repository:    cpgo.RepositoryRef{Owner: "acme", Name: "payments"},
+			BaseBranch:    "main",
+			HeadBranch:    "cpgo",
+			Path:          "default.pgo",
+			Content:       []byte("new-profile"),
+			CommitMessage: "perf(pgo): refresh pgo profile",
+		})
+	}
+
+	t.Run("commits on top of the head branch without force", func(t *testing.T) {
+		result, err := upsert()
+		if err != nil {
+			t.Fatalf("upsert file: %v", err)
+		}
+
+		if result.CommitSHA != "commit-sha" || result.IsBranchCreated {
+			t.Fatalf("expected commit-sha on the existing branch, got %+v", result)
+		}
+
+		if treePayload.BaseTree != "head-tree" || len(commitPayload.Parents) != 1 || commitPayload.Parents[0] != "head-commit" {
+			t.Fatalf("expected commit on head-commit/head-tree, got tree %+v commit %+v", treePayload, commitPayload)
+		}
+
+		if refPayload.SHA != "commit-sha" || refPayload.Force {
+			t.Fatalf("expected non-force ref update, got %+v", refPayload)
+		}
+	})
+
+	t.Run("reports divergence instead of overwriting", func(t *testing.T) {
+		isDiverged = true
+
+		_, err := upsert()
+		if !errors.Is(err, cpgo.ErrBranchDiverged) || !strings.Contains(err.Error(), "head branch cpgo diverged") {
+			t.Fatalf("expected divergence error, got %v", err)
+		}
+	})
+}
+
+func TestClientCreateCommitIdentity(t *testing.T) {
+	var payload map[string]any
+	githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		payload = nil
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			t.Fatalf("decode create commit request: %v", err)
+		}
+
+		_, _ = response.Write([]byte(`{"sha":"commit-sha"}`))
+	}))
+
+	client := mustNewClient(t, githubClient)
+	req := cpgo.UpsertFileRequest{
+		Repository:    cpgo.RepositoryRef{Owner: "acme", Name: "payments"},
+		CommitMessage: "perf(pgo): refresh pgo profile",
+	}
+
+	t.Run("omits identities by default", func(t *testing.T) {
+		if _, err := client.createCommit(context.Background(), req, "tree-sha", "base-commit"); err != nil {
+			t.Fatalf("create commit: %v", err)
+		}
+
+		if _, ok := payload["author"]; ok {
+			t.Fatalf("expected no author, got %v", payload["author"])
+		}
+
+		if _, ok := payload["committer"]; ok {
+			t.Fatalf("expected no committer, got %v", payload["committer"])
+		}
+	})
+
+	t.Run("sets configured identities", func(t *testing.T) {
+		req := req
+		req.Author = cpgo.CommitIdentity{Name: "perf-team", Email: "perf@example.com"}
+		req.Committer = cpgo.CommitIdentity{Name: "cpgo-bot", Email: "cpgo-bot@example.com"}
+
+		if _, err := client.createCommit(context.Background(), req, "tree-sha", "base-commit"); err != nil {
+			t.Fatalf("create commit: %v", err)
+		}
+
+		author, _ := payload["author"].(map[string]any)
+		committer, _ := payload["committer"].(map[string]any)
+		if author["email"] != "perf@example.com" || committer["email"] != "cpgo-bot@example.com" {
+			t.Fatalf("expected configured identities, got author %v committer %v", author, committer)
+		}
+	})
+}
+
+func mustNewClient(t *testing.T, githubClient *github.Client) *Client {
+	t.Helper()
+
+	client, err := NewClient(githubClient, nil)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+
+	return client
+}
+
+func newGitHubClient(t *testing.T, handler http.Handler) *github.Client {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	baseURL, err := url.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatalf("parse base url: %v", err)
+	}
+
+	client := github.NewClient(server.Client())
+	client.BaseURL = baseURL
+	client.UploadURL = baseURL
+
+	return client
+}
+
+func TestClientReportStatus(t *testing.T) {
+	var payload struct {
+		State       string `json:"state"`
+		Context     string `json:"context"`
+		Description string `json:"description"`
+		TargetURL   string `json:"target_url"`
+	}
+
+	githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/repos/acme/payments/git/ref/heads/main":
+			_, _ = response.Write([]byte(`{"ref":"refs/heads/main","object":{"type":"commit","sha":"base-commit"}}`))
+		case "/repos/acme/payments/statuses/base-commit":
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode create status request: %v", err)
+			}
+			_, _ = response.Write([]byte(`{"state":"failure"}`))
+		default:
+			t.Fatalf("unexpected request path: %s", req.URL.Path)
+		}
+	}))
+
+	client := mustNewClient(t, githubClient)
+	err := client.ReportStatus(context.Background(), cpgo.ReportStatusRequest{
+		Repository: cpgo.RepositoryRef{
+			Owner: "acme",
+			Name:  "payments",
+		},
+		Branch:      "main",
+		State:       cpgo.StatusStateFailure,
+		Context:     "cpgo/pgo-profile",
+		Description: "PGO profile is stale; refresh pending in #12",
+		TargetURL:   "https://github.com/acme/payments/pull/12",
+	})
+	if err != nil {
+		t.Fatalf("report status: %v", err)
+	}
+
+	if payload.State != "failure" || payload.Context != "cpgo/pgo-profile" || payload.TargetURL != "https://github.com/acme/payments/pull/12" {
+		t.Fatalf("unexpected status payload %+v", payload)
+	}
+
+	t.Run("reports tokens without status access", func(t *testing.T) {
+		githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/repos/acme/payments/git/ref/heads/main":
+				_, _ = response.Write([]byte(`{"ref":"refs/heads/main","object":{"type":"commit","sha":"base-commit"}}`))
+			case "/repos/acme/payments/statuses/base-commit":
+				response.WriteHeader(http.StatusForbidden)
+				_, _ = response.Write([]byte(`{"message":"Resource not accessible by integration"}`))
+			default:
+				t.Fatalf("unexpected request path: %s", req.URL.Path)
+			}
+		}))
+
+		err := mustNewClient(t, githubClient).ReportStatus(context.Background(), cpgo.ReportStatusRequest{
+			Repository: cpgo.RepositoryRef{Owner: "acme", Name: "payments"},
+			Branch:     "main",
+			State:      cpgo.StatusStateSuccess,
+			Context:    "cpgo/pgo-profile",
+		})
+		if !errors.Is(err, cpgo.ErrStatusNotPermitted) {
+			t.Fatalf("expected ErrStatusNotPermitted, got %v", err)
+		}
+	})
 }
@@ -0,0 +1,92 @@
+package githubapi
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimitEvent describes a GitHub response that asked the client to back off.
+// Wait is the Retry-After delay when GitHub sends one and otherwise the time
+// remaining until ResetAt. Remaining is -1 when the response carried no quota.
+type RateLimitEvent struct {
+	Endpoint   string
+	StatusCode int
+	Wait       time.Duration
+	ResetAt    time.Time
+	Remaining  int
+}
+
+// rateLimitTransport reports rate-limited responses without altering them.
+type rateLimitTransport struct {
+	base    http.RoundTripper
+	now     func() time.Time
+	observe func(RateLimitEvent)
+}
+
+// ObserveRateLimits wraps base so observe is called for every rate-limited GitHub response.
+func ObserveRateLimits(base http.RoundTripper, observe func(RateLimitEvent)) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if observe == nil {
+		return base
+	}
+
+	return &rateLimitTransport{
+		base:    base,
+		now:     time.Now,
+		observe: observe,
+	}
+}
+
+// RoundTrip forwards the request and reports the response when it signals a rate limit.
+func (transport *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := transport.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if event, isLimited := rateLimitEvent(req, resp, transport.now()); isLimited {
+		transport.observe(event)
+	}
+
+	return resp, nil
+}
+
+// rateLimitEvent extracts primary or secondary rate limit details from a response.
+func rateLimitEvent(req *http.Request, resp *http.Response, now time.Time) (RateLimitEvent, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return RateLimitEvent{}, false
+	}
+
+	remaining := -1
+	if parsed, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		remaining = parsed
+	}
+
+	retryAfter, hasRetryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+	if remaining != 0 && !hasRetryAfter {
+		return RateLimitEvent{}, false
+	}
+
+	resetAt := now
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		resetAt = time.Unix(reset, 0)
+	}
+
+	wait := resetAt.Sub(now)
+	if hasRetryAfter {
+		wait = retryAfter
+		resetAt = now.Add(retryAfter)
+	}
+
+	return RateLimitEvent{
+		Endpoint:   req.Method + " " + req.URL.Path,
+		StatusCode: resp.StatusCode,
+		Wait:       max(wait, 0),
+		ResetAt:    resetAt,
+		Remaining:  remaining,
+	}, true
+}
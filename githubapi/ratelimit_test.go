@@ -0,0 +1,119 @@
+package githubapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v77/github"
+)
+
+func TestRateLimitWait(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	retryAfter := 42 * time.Second
+
+	for _, tc := range []struct {
+		name      string
+		err       error
+		wait      time.Duration
+		isLimited bool
+	}{
+		{name: "secondary limit with retry after", err: &github.AbuseRateLimitError{RetryAfter: &retryAfter}, wait: retryAfter, isLimited: true},
+		{name: "secondary limit without retry after", err: &github.AbuseRateLimitError{}, wait: defaultSecondaryRateLimitWait, isLimited: true},
+		{name: "wrapped primary limit", err: fmt.Errorf("create blob: %w", &github.RateLimitError{Rate: github.Rate{Reset: github.Timestamp{Time: now.Add(time.Minute)}}}), wait: time.Minute + rateLimitResetBuffer, isLimited: true},
+		{name: "other error", err: errors.New("connection reset")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			wait, isLimited := rateLimitWait(tc.err, now)
+			if wait != tc.wait || isLimited != tc.isLimited {
+				t.Fatalf("expected wait %s limited %t, got %s %t", tc.wait, tc.isLimited, wait, isLimited)
+			}
+		})
+	}
+}
+
+func TestObserveRateLimits(t *testing.T) {
+	t.Run("reports secondary limits with retry after", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			resp.Header().Set("Retry-After", "42")
+			resp.WriteHeader(http.StatusForbidden)
+		}))
+		t.Cleanup(server.Close)
+
+		var events []RateLimitEvent
+		client := &http.Client{Transport: ObserveRateLimits(server.Client().Transport, func(event RateLimitEvent) {
+			events = append(events, event)
+		})}
+
+		resp, err := client.Post(server.URL+"/repos/acme/payments/pulls", "application/json", nil)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		_ = resp.Body.Close()
+
+		if len(events) != 1 {
+			t.Fatalf("expected one rate limit event, got %d", len(events))
+		}
+
+		event := events[0]
+		if event.Endpoint != "POST /repos/acme/payments/pulls" || event.Wait != 42*time.Second || event.StatusCode != http.StatusForbidden {
+			t.Fatalf("unexpected rate limit event %+v", event)
+		}
+	})
+
+	t.Run("reports primary limits with reset time", func(t *testing.T) {
+		reset := time.Now().Add(time.Hour).Truncate(time.Second)
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			resp.Header().Set("X-RateLimit-Remaining", "0")
+			resp.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			resp.WriteHeader(http.StatusForbidden)
+		}))
+		t.Cleanup(server.Close)
+
+		var events []RateLimitEvent
+		client := &http.Client{Transport: ObserveRateLimits(server.Client().Transport, func(event RateLimitEvent) {
+			events = append(events, event)
+		})}
+
+		resp, err := client.Get(server.URL + "/repos/acme/payments")
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		_ = resp.Body.Close()
+
+		if len(events) != 1 || !events[0].ResetAt.Equal(reset) || events[0].Remaining != 0 {
+			t.Fatalf("unexpected rate limit events %+v", events)
+		}
+
+		if events[0].Wait <= 59*time.Minute {
+			t.Fatalf("expected wait close to an hour, got %s", events[0].Wait)
+		}
+	})
+
+	t.Run("ignores permission errors", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			resp.Header().Set("X-RateLimit-Remaining", "4999")
+			resp.WriteHeader(http.StatusForbidden)
+		}))
+		t.Cleanup(server.Close)
+
+		var events []RateLimitEvent
+		client := &http.Client{Transport: ObserveRateLimits(server.Client().Transport, func(event RateLimitEvent) {
+			events = append(events, event)
+		})}
+
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		_ = resp.Body.Close()
+
+		if len(events) != 0 {
+			t.Fatalf("expected no rate limit events, got %+v", events)
+		}
+	})
+}
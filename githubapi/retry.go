@@ -0,0 +1,71 @@
+package githubapi
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/go-github/v77/github"
+)
+
+const (
+	defaultRateLimitRetries       = 3
+	defaultSecondaryRateLimitWait = time.Minute
+	maxRateLimitWait              = 5 * time.Minute
+	rateLimitResetBuffer          = time.Second
+)
+
+// retryRateLimited calls call again when GitHub rejects it with a primary or secondary
+// rate limit, waiting as long as GitHub asks for up to client.rateLimitRetries times.
+// A wait longer than maxRateLimitWait or past the context deadline returns the rejection.
+func retryRateLimited[T any](ctx context.Context, client *Client, call func() (T, *github.Response, error)) (T, *github.Response, error) {
+	for retried := 0; ; retried++ {
+		value, resp, err := call()
+
+		wait, isLimited := rateLimitWait(err, time.Now())
+		if !isLimited || retried >= client.rateLimitRetries || wait > maxRateLimitWait {
+			return value, resp, err
+		}
+
+		if deadline, hasDeadline := ctx.Deadline(); hasDeadline && time.Now().Add(wait).After(deadline) {
+			return value, resp, err
+		}
+
+		if sleepErr := client.sleep(ctx, wait); sleepErr != nil {
+			return value, resp, errors.Join(err, sleepErr)
+		}
+	}
+}
+
+// rateLimitWait reports how long to back off after a rate limit error.
+// Secondary limits without a Retry-After wait defaultSecondaryRateLimitWait, as GitHub advises.
+func rateLimitWait(err error, now time.Time) (time.Duration, bool) {
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		if abuseErr.RetryAfter == nil {
+			return defaultSecondaryRateLimitWait, true
+		}
+
+		return max(*abuseErr.RetryAfter, 0), true
+	}
+
+	var rateErr *github.RateLimitError
+	if errors.As(err, &rateErr) {
+		return max(rateErr.Rate.Reset.Sub(now)+rateLimitResetBuffer, 0), true
+	}
+
+	return 0, false
+}
+
+// sleepContext waits for the delay or until the context is done.
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,185 @@
+package githubapi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v77/github"
+)
+
+const (
+	SigningFormatGPG = "gpg"
+	SigningFormatSSH = "ssh"
+
+	signingTimeout = time.Minute
+)
+
+// SigningOptions configures signing commits with a local key.
+// The gpg format signs as KeyID, importing the armored private key at KeyPath into a
+// throwaway keyring when set and using the default keyring otherwise; Passphrase
+// unlocks it. The ssh format signs with the private key (or, with an agent, the
+// public key) at KeyPath through ssh-keygen and cannot prompt for a passphrase.
+// Name and Email become the commit author and committer, because GitHub only
+// marks a signature verified when the committer email belongs to the key owner.
+type SigningOptions struct {
+	Format     string
+	KeyPath    string
+	KeyID      string
+	Passphrase string
+	Name       string
+	Email      string
+}
+
+// CommitSigner signs commits created through the Git Data API.
+type CommitSigner struct {
+	signer github.MessageSigner
+	name   string
+	email  string
+}
+
+// NewCommitSigner validates options and returns a signer backed by the gpg or ssh-keygen binaries.
+func NewCommitSigner(options SigningOptions) (*CommitSigner, error) {
+	name := strings.TrimSpace(options.Name)
+	email := strings.TrimSpace(options.Email)
+	if name == "" || email == "" {
+		return nil, fmt.Errorf("signing identity name and email are required")
+	}
+
+	keyPath := strings.TrimSpace(options.KeyPath)
+	keyID := strings.TrimSpace(options.KeyID)
+
+	var signer github.MessageSigner
+	switch strings.TrimSpace(options.Format) {
+	case "", SigningFormatGPG:
+		if keyID == "" {
+			return nil, fmt.Errorf("gpg signing key id is required")
+		}
+
+		signer = gpgSigner{keyPath: keyPath, keyID: keyID, passphrase: options.Passphrase}
+	case SigningFormatSSH:
+		if keyPath == "" {
+			return nil, fmt.Errorf("ssh signing key path is required")
+		}
+
+		signer = sshSigner{keyPath: keyPath}
+	default:
+		return nil, fmt.Errorf("unsupported signing format %q", options.Format)
+	}
+
+	return &CommitSigner{
+		signer: signer,
+		name:   name,
+		email:  email,
+	}, nil
+}
+
+// author returns the identity signed commits are attributed to.
+func (signer *CommitSigner) author(now time.Time) *github.CommitAuthor {
+	return &github.CommitAuthor{
+		Name:  new(signer.name),
+		Email: new(signer.email),
+		Date:  &github.Timestamp{Time: now},
+	}
+}
+
+// gpgSigner produces armored detached OpenPGP signatures.
+type gpgSigner struct {
+	keyPath    string
+	keyID      string
+	passphrase string
+}
+
+// Sign writes an armored detached signature of the commit payload to w.
+func (signer gpgSigner) Sign(w io.Writer, r io.Reader) error {
+	ctx, cancel := context.WithTimeout(context.Background(), signingTimeout)
+	defer cancel()
+
+	args := []string{"--batch", "--no-tty", "--armor", "--detach-sign", "--local-user", signer.keyID}
+	if signer.keyPath != "" {
+		home, err := os.MkdirTemp("", "cpgo-gnupg-")
+		if err != nil {
+			return fmt.Errorf("create gpg home: %w", err)
+		}
+		defer os.RemoveAll(home)
+
+		if err := runSigningCommand(ctx, nil, io.Discard, nil, "gpg", "--batch", "--no-tty", "--homedir", home, "--import", signer.keyPath); err != nil {
+			return fmt.Errorf("import gpg signing key: %w", err)
+		}
+
+		args = append([]string{"--homedir", home}, args...)
+	}
+
+	var extraFiles []*os.File
+	if signer.passphrase != "" {
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			return fmt.Errorf("create gpg passphrase pipe: %w", err)
+		}
+		defer reader.Close()
+
+		go func() {
+			_, _ = io.WriteString(writer, signer.passphrase)
+			_ = writer.Close()
+		}()
+
+		// The passphrase travels over fd 3 so it never appears in argv or the environment.
+		extraFiles = []*os.File{reader}
+		args = append([]string{"--pinentry-mode", "loopback", "--passphrase-fd", "3"}, args...)
+	}
+
+	if err := runSigningCommand(ctx, r, w, extraFiles, "gpg", args...); err != nil {
+		return fmt.Errorf("gpg sign commit: %w", err)
+	}
+
+	return nil
+}
+
+// sshSigner produces SSH signatures in the git namespace.
+type sshSigner struct {
+	keyPath string
+}
+
+// Sign writes an armored SSH signature of the commit payload to w.
+func (signer sshSigner) Sign(w io.Writer, r io.Reader) error {
+	ctx, cancel := context.WithTimeout(context.Background(), signingTimeout)
+	defer cancel()
+
+	if err := runSigningCommand(ctx, r, w, nil, "ssh-keygen", "-Y", "sign", "-n", "git", "-f", signer.keyPath); err != nil {
+		return fmt.Errorf("ssh sign commit: %w", err)
+	}
+
+	return nil
+}
+
+// runSigningCommand runs a signing binary, folding its stderr into the error.
+func runSigningCommand(
+	ctx context.Context,
+	stdin io.Reader,
+	stdout io.Writer,
+	extraFiles []*os.File,
+	name string,
+	args ...string,
+) error {
+	var stderr bytes.Buffer
+	command := exec.CommandContext(ctx, name, args...)
+	command.Stdin = stdin
+	command.Stdout = stdout
+	command.Stderr = &stderr
+	command.ExtraFiles = extraFiles
+
+	if err := command.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return fmt.Errorf("%w: %s", err, message)
+		}
+
+		return err
+	}
+
+	return nil
+}
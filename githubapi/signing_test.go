@@ -0,0 +1,104 @@
+package githubapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v77/github"
+
+	"cpgo"
+)
+
+func TestNewCommitSigner(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		options SigningOptions
+		isValid bool
+	}{
+		{name: "gpg key id", options: SigningOptions{KeyID: "ABCD1234", Name: "cpgo-bot", Email: "bot@example.com"}, isValid: true},
+		{name: "ssh key path", options: SigningOptions{Format: SigningFormatSSH, KeyPath: "/keys/id_ed25519", Name: "cpgo-bot", Email: "bot@example.com"}, isValid: true},
+		{name: "missing identity", options: SigningOptions{KeyID: "ABCD1234"}},
+		{name: "gpg without key id", options: SigningOptions{KeyPath: "/keys/private.asc", Name: "cpgo-bot", Email: "bot@example.com"}},
+		{name: "ssh without key path", options: SigningOptions{Format: SigningFormatSSH, Name: "cpgo-bot", Email: "bot@example.com"}},
+		{name: "unknown format", options: SigningOptions{Format: "x509", KeyID: "ABCD1234", Name: "cpgo-bot", Email: "bot@example.com"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewCommitSigner(tc.options)
+			if (err == nil) != tc.isValid {
+				t.Fatalf("expected valid %t, got %v", tc.isValid, err)
+			}
+		})
+	}
+}
+
+func TestClientCreateCommitSigned(t *testing.T) {
+	var payload struct {
+		Signature string `json:"signature"`
+		Author    struct {
+			Name  string `json:"name"`
+			Email string `json:"email"`
+		} `json:"author"`
+		Committer struct {
+			Email string `json:"email"`
+		} `json:"committer"`
+	}
+
+	githubClient := newGitHubClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/repos/acme/payments/git/commits" {
+			t.Fatalf("unexpected request path: %s", req.URL.Path)
+		}
+
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			t.Fatalf("decode create commit request: %v", err)
+		}
+
+		_, _ = response.Write([]byte(`{"sha":"commit-sha"}`))
+	}))
+
+	var signedPayload string
+	client, err := NewClient(githubClient, &CommitSigner{
+		signer: github.MessageSignerFunc(func(w io.Writer, r io.Reader) error {
+			content, err := io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+
+			signedPayload = string(content)
+			_, err = io.WriteString(w, "-----BEGIN PGP SIGNATURE-----")
+			return err
+		}),
+		name:  "cpgo-bot",
+		email: "bot@example.com",
+	})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+
+	commitSHA, err := client.createCommit(context.Background(), cpgo.UpsertFileRequest{
+		Repository:    cpgo.RepositoryRef{Owner: "acme", Name: "payments"},
+		CommitMessage: "perf(pgo): refresh pgo profile",
+	}, "tree-sha", "base-commit")
+	if err != nil {
+		t.Fatalf("create commit: %v", err)
+	}
+
+	if commitSHA != "commit-sha" {
+		t.Fatalf("expected commit-sha, got %s", commitSHA)
+	}
+
+	if payload.Signature != "-----BEGIN PGP SIGNATURE-----" {
+		t.Fatalf("expected signature in request, got %q", payload.Signature)
+	}
+
+	if payload.Author.Name != "cpgo-bot" || payload.Author.Email != "bot@example.com" || payload.Committer.Email != "bot@example.com" {
+		t.Fatalf("expected signing identity as author and committer, got %+v", payload)
+	}
+
+	if !strings.Contains(signedPayload, "tree tree-sha\nparent base-commit\nauthor cpgo-bot <bot@example.com>") {
+		t.Fatalf("expected git commit payload to be signed, got %q", signedPayload)
+	}
+}
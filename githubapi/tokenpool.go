@@ -0,0 +1,177 @@
+package githubapi
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenState tracks the last observed rate limit of one token.
+type tokenState struct {
+	token     string
+	limited   bool
+	resetAt   time.Time
+	remaining int
+}
+
+// TokenPoolTransport spreads requests across tokens and steers away from rate-limited ones.
+type TokenPoolTransport struct {
+	base   http.RoundTripper
+	now    func() time.Time
+	mu     sync.Mutex
+	tokens []tokenState
+	next   int
+}
+
+// NewTokenPoolTransport validates tokens and wraps base with round-robin authentication.
+func NewTokenPoolTransport(base http.RoundTripper, tokens []string) (*TokenPoolTransport, error) {
+	states := make([]tokenState, 0, len(tokens))
+	for _, token := range tokens {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			return nil, fmt.Errorf("token pool entries must not be empty")
+		}
+
+		states = append(states, tokenState{token: token, remaining: -1})
+	}
+
+	if len(states) == 0 {
+		return nil, fmt.Errorf("token pool requires at least one token")
+	}
+
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &TokenPoolTransport{
+		base:   base,
+		now:    time.Now,
+		tokens: states,
+	}, nil
+}
+
+// RoundTrip authenticates with the next available token and retries rate-limited responses on others.
+func (transport *TokenPoolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tried := make(map[int]bool, len(transport.tokens))
+
+	for {
+		index := transport.pick(tried)
+		tried[index] = true
+
+		attempt := req.Clone(req.Context())
+		if len(tried) > 1 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("rewind request body: %w", err)
+			}
+
+			attempt.Body = body
+		}
+		attempt.Header.Set("Authorization", "Bearer "+transport.token(index))
+
+		resp, err := transport.base.RoundTrip(attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		isLimited := transport.record(index, resp)
+		canRetry := req.Body == nil || req.GetBody != nil
+		if !isLimited || !canRetry || len(tried) == len(transport.tokens) {
+			return resp, nil
+		}
+
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+}
+
+// pick selects the next untried token round-robin, preferring tokens that are not limited.
+func (transport *TokenPoolTransport) pick(tried map[int]bool) int {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	now := transport.now()
+	fallback := -1
+	for offset := range transport.tokens {
+		index := (transport.next + offset) % len(transport.tokens)
+		if tried[index] {
+			continue
+		}
+
+		state := &transport.tokens[index]
+		if state.limited && now.After(state.resetAt) {
+			state.limited = false
+		}
+
+		if !state.limited {
+			transport.next = (index + 1) % len(transport.tokens)
+			return index
+		}
+
+		if fallback < 0 || state.resetAt.Before(transport.tokens[fallback].resetAt) {
+			fallback = index
+		}
+	}
+
+	// Every remaining token is limited; use the one that recovers soonest.
+	transport.next = (fallback + 1) % len(transport.tokens)
+	return fallback
+}
+
+func (transport *TokenPoolTransport) token(index int) string {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	return transport.tokens[index].token
+}
+
+// record updates token state from rate limit headers and reports whether the response was limited.
+func (transport *TokenPoolTransport) record(index int, resp *http.Response) bool {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+
+	state := &transport.tokens[index]
+	now := transport.now()
+
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		state.remaining = remaining
+	}
+
+	resetAt := now
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		resetAt = time.Unix(reset, 0)
+	}
+
+	retryAfter, hasRetryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+	if hasRetryAfter {
+		resetAt = now.Add(retryAfter)
+	}
+
+	isLimitStatus := resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests
+	if isLimitStatus && (state.remaining == 0 || hasRetryAfter) {
+		state.limited = true
+		state.resetAt = resetAt
+		return true
+	}
+
+	if state.remaining == 0 {
+		// The quota is spent even though this call succeeded; prefer other tokens until reset.
+		state.limited = true
+		state.resetAt = resetAt
+	}
+
+	return false
+}
+
+func parseRetryAfter(raw string) (time.Duration, bool) {
+	seconds, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+
+	return time.Duration(seconds) * time.Second, true
+}
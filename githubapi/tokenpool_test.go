@@ -0,0 +1,93 @@
+package githubapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTokenPoolTransport(t *testing.T) {
+	t.Run("rotates tokens round-robin", func(t *testing.T) {
+		var seen []string
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			seen = append(seen, req.Header.Get("Authorization"))
+		}))
+		t.Cleanup(server.Close)
+
+		transport, err := NewTokenPoolTransport(server.Client().Transport, []string{"a", "b"})
+		if err != nil {
+			t.Fatalf("new token pool transport: %v", err)
+		}
+
+		client := &http.Client{Transport: transport}
+		for range 3 {
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			_ = resp.Body.Close()
+		}
+
+		expected := []string{"Bearer a", "Bearer b", "Bearer a"}
+		for index := range expected {
+			if seen[index] != expected[index] {
+				t.Fatalf("expected tokens %v, got %v", expected, seen)
+			}
+		}
+	})
+
+	t.Run("retries rate limited request with another token and avoids it until reset", func(t *testing.T) {
+		reset := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+
+		var seen []string
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			seen = append(seen, req.Header.Get("Authorization"))
+			if req.Header.Get("Authorization") == "Bearer a" {
+				resp.Header().Set("X-RateLimit-Remaining", "0")
+				resp.Header().Set("X-RateLimit-Reset", reset)
+				resp.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			resp.Header().Set("X-RateLimit-Remaining", "100")
+		}))
+		t.Cleanup(server.Close)
+
+		transport, err := NewTokenPoolTransport(server.Client().Transport, []string{"a", "b"})
+		if err != nil {
+			t.Fatalf("new token pool transport: %v", err)
+		}
+
+		client := &http.Client{Transport: transport}
+		for range 2 {
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected retried request to succeed, got %d", resp.StatusCode)
+			}
+		}
+
+		expected := []string{"Bearer a", "Bearer b", "Bearer b"}
+		if len(seen) != len(expected) {
+			t.Fatalf("expected tokens %v, got %v", expected, seen)
+		}
+
+		for index := range expected {
+			if seen[index] != expected[index] {
+				t.Fatalf("expected tokens %v, got %v", expected, seen)
+			}
+		}
+	})
+
+	t.Run("rejects empty pool", func(t *testing.T) {
+		if _, err := NewTokenPoolTransport(nil, nil); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+}
@@ -0,0 +1,847 @@
+package gitlabapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"cpgo"
+)
+
+const (
+	defaultBaseURL           = "https://gitlab.com/api/v4"
+	defaultHTTPClientTimeout = 30 * time.Second
+	listPageSize             = 100
+	errorPreviewBytes        = 4 * 1024
+	draftTitlePrefix         = "Draft: "
+)
+
+// Options configures access to the GitLab REST API.
+// BaseURL is the API root of a self-managed instance, e.g. https://gitlab.example.com/api/v4;
+// empty means gitlab.com.
+type Options struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// Client implements repository and pull request ports via GitLab REST APIs.
+// RepositoryRef.Owner is the full namespace path, so projects in subgroups use
+// Owner "group/subgroup". Pull requests map to merge requests and their numbers to
+// merge request IIDs. GitLab always commits as the token user, so a committer
+// identity cannot be set, and symlinks cannot be written through the API.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+}
+
+var _ cpgo.BranchWriter = (*Client)(nil)
+var _ cpgo.PullRequestService = (*Client)(nil)
+var _ cpgo.TagWriter = (*Client)(nil)
+var _ cpgo.StatusReporter = (*Client)(nil)
+
+// apiError is a non-2xx GitLab API response.
+type apiError struct {
+	StatusCode int
+	Message    string
+}
+
+func (err *apiError) Error() string {
+	if err.Message == "" {
+		return fmt.Sprintf("unexpected status %d", err.StatusCode)
+	}
+
+	return fmt.Sprintf("unexpected status %d: %s", err.StatusCode, err.Message)
+}
+
+type project struct {
+	DefaultBranch string `json:"default_branch"`
+}
+
+type branch struct {
+	Commit commit `json:"commit"`
+}
+
+type tag struct {
+	Commit commit `json:"commit"`
+}
+
+type commit struct {
+	ID            string    `json:"id"`
+	CommittedDate time.Time `json:"committed_date"`
+}
+
+// commitAction is one file operation of a commit created through the Commits API.
+type commitAction struct {
+	Action   string `json:"action"`
+	FilePath string `json:"file_path"`
+	Content  string `json:"content,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type createCommitRequest struct {
+	Branch        string         `json:"branch"`
+	StartBranch   string         `json:"start_branch,omitempty"`
+	Force         bool           `json:"force,omitempty"`
+	CommitMessage string         `json:"commit_message"`
+	AuthorName    string         `json:"author_name,omitempty"`
+	AuthorEmail   string         `json:"author_email,omitempty"`
+	Actions       []commitAction `json:"actions"`
+}
+
+type mergeRequest struct {
+	IID             int    `json:"iid"`
+	Title           string `json:"title"`
+	Description     string `json:"description"`
+	WebURL          string `json:"web_url"`
+	SourceBranch    string `json:"source_branch"`
+	SourceProjectID int64  `json:"source_project_id"`
+	TargetProjectID int64  `json:"target_project_id"`
+}
+
+type createMergeRequestRequest struct {
+	SourceBranch string  `json:"source_branch"`
+	TargetBranch string  `json:"target_branch"`
+	Title        string  `json:"title"`
+	Description  string  `json:"description"`
+	Labels       string  `json:"labels,omitempty"`
+	ReviewerIDs  []int64 `json:"reviewer_ids,omitempty"`
+	AssigneeIDs  []int64 `json:"assignee_ids,omitempty"`
+}
+
+type user struct {
+	ID int64 `json:"id"`
+}
+
+// NewClient validates options and returns a GitLab API client.
+func NewClient(options Options) (*Client, error) {
+	token := strings.TrimSpace(options.Token)
+	if token == "" {
+		return nil, fmt.Errorf("gitlab token is required")
+	}
+
+	baseURL := strings.TrimRight(strings.TrimSpace(options.BaseURL), "/")
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	parsed, err := url.Parse(baseURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("gitlab base url must include scheme and host")
+	}
+
+	return &Client{
+		httpClient: withDefaultTimeout(options.HTTPClient),
+		baseURL:    baseURL,
+		token:      token,
+	}, nil
+}
+
+// DefaultBranch returns the configured project default branch.
+func (client *Client) DefaultBranch(ctx context.Context, repository cpgo.RepositoryRef) (string, error) {
+	if err := validateRepositoryRef(repository); err != nil {
+		return "", err
+	}
+
+	var decoded project
+	if _, err := client.doJSON(ctx, http.MethodGet, projectPath(repository), nil, nil, &decoded); err != nil {
+		return "", fmt.Errorf("get project: %w", err)
+	}
+
+	defaultBranch := strings.TrimSpace(decoded.DefaultBranch)
+	if defaultBranch == "" {
+		return "", fmt.Errorf("project default branch is empty")
+	}
+
+	return defaultBranch, nil
+}
+
+// ReadFile returns raw file bytes from a branch through the Repository Files API.
+func (client *Client) ReadFile(ctx context.Context, req cpgo.ReadFileRequest) (cpgo.ReadFileResult, error) {
+	if err := validateRepositoryRef(req.Repository); err != nil {
+		return cpgo.ReadFileResult{}, err
+	}
+
+	if strings.TrimSpace(req.Branch) == "" {
+		return cpgo.ReadFileResult{}, fmt.Errorf("branch is required")
+	}
+
+	if strings.TrimSpace(req.Path) == "" {
+		return cpgo.ReadFileResult{}, fmt.Errorf("path is required")
+	}
+
+	resp, err := client.send(ctx, http.MethodGet, filePath(req.Repository, req.Path)+"/raw", url.Values{"ref": {req.Branch}}, nil)
+	if err != nil {
+		if isNotFound(err) {
+			return cpgo.ReadFileResult{HasFile: false}, nil
+		}
+
+		return cpgo.ReadFileResult{}, fmt.Errorf("get file %s: %w", req.Path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return cpgo.ReadFileResult{}, fmt.Errorf("read file %s: %w", req.Path, err)
+	}
+
+	return cpgo.ReadFileResult{
+		Content: content,
+		HasFile: true,
+	}, nil
+}
+
+// DeleteBranch deletes the branch, reporting false when the branch was already missing.
+func (client *Client) DeleteBranch(ctx context.Context, repository cpgo.RepositoryRef, branchName string) (bool, error) {
+	if err := validateRepositoryRef(repository); err != nil {
+		return false, err
+	}
+
+	if strings.TrimSpace(branchName) == "" {
+		return false, fmt.Errorf("branch is required")
+	}
+
+	_, err := client.doJSON(ctx, http.MethodDelete, branchPath(repository, branchName), nil, nil, nil)
+	if isNotFound(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("delete branch: %w", err)
+	}
+
+	return true, nil
+}
+
+// LastCommitTime returns the committed time of the branch head commit.
+func (client *Client) LastCommitTime(ctx context.Context, repository cpgo.RepositoryRef, branchName string) (time.Time, bool, error) {
+	if err := validateRepositoryRef(repository); err != nil {
+		return time.Time{}, false, err
+	}
+
+	if strings.TrimSpace(branchName) == "" {
+		return time.Time{}, false, fmt.Errorf("branch is required")
+	}
+
+	head, hasBranch, err := client.getBranch(ctx, repository, branchName)
+	if err != nil || !hasBranch {
+		return time.Time{}, false, err
+	}
+
+	return head.Commit.CommittedDate, true, nil
+}
+
+// BranchHead returns the commit SHA at the head of the branch.
+func (client *Client) BranchHead(ctx context.Context, repository cpgo.RepositoryRef, branchName string) (string, bool, error) {
+	if err := validateRepositoryRef(repository); err != nil {
+		return "", false, err
+	}
+
+	if strings.TrimSpace(branchName) == "" {
+		return "", false, fmt.Errorf("branch is required")
+	}
+
+	head, hasBranch, err := client.getBranch(ctx, repository, branchName)
+	if err != nil || !hasBranch {
+		return "", false, err
+	}
+
+	return head.Commit.ID, true, nil
+}
+
+// UpsertFileAndForceBranch writes every file operation in one commit through the Commits API.
+// A force update, or a missing head branch, starts the commit from the base branch and
+// overwrites the head branch; otherwise the commit is added on top of the existing head branch.
+func (client *Client) UpsertFileAndForceBranch(ctx context.Context, req cpgo.UpsertFileRequest) (cpgo.UpsertFileResult, error) {
+	if err := validateRepositoryRef(req.Repository); err != nil {
+		return cpgo.UpsertFileResult{}, err
+	}
+
+	if strings.TrimSpace(req.BaseBranch) == "" {
+		return cpgo.UpsertFileResult{}, fmt.Errorf("base branch is required")
+	}
+
+	if strings.TrimSpace(req.HeadBranch) == "" {
+		return cpgo.UpsertFileResult{}, fmt.Errorf("head branch is required")
+	}
+
+	if strings.TrimSpace(req.Path) == "" {
+		return cpgo.UpsertFileResult{}, fmt.Errorf("path is required")
+	}
+
+	if strings.TrimSpace(req.CommitMessage) == "" {
+		return cpgo.UpsertFileResult{}, fmt.Errorf("commit message is required")
+	}
+
+	if !req.Committer.IsZero() {
+		return cpgo.UpsertFileResult{}, fmt.Errorf("gitlab commits as the token user and cannot set a committer identity")
+	}
+
+	changes := append([]cpgo.FileChange{{Path: req.Path, Content: req.Content}}, req.AdditionalFiles...)
+	for _, change := range changes {
+		if strings.TrimSpace(change.Path) == "" {
+			return cpgo.UpsertFileResult{}, fmt.Errorf("additional file path is required")
+		}
+
+		if change.Symlink {
+			return cpgo.UpsertFileResult{}, fmt.Errorf("gitlab cannot write symlink %s through the api", change.Path)
+		}
+	}
+
+	_, hasHead, err := client.getBranch(ctx, req.Repository, req.HeadBranch)
+	if err != nil {
+		return cpgo.UpsertFileResult{}, err
+	}
+
+	payload := createCommitRequest{
+		Branch:        req.HeadBranch,
+		CommitMessage: req.CommitMessage,
+		AuthorName:    req.Author.Name,
+		AuthorEmail:   req.Author.Email,
+	}
+
+	parentRef := req.HeadBranch
+	if !hasHead || req.ForceUpdate {
+		payload.StartBranch = req.BaseBranch
+		payload.Force = hasHead
+		parentRef = req.BaseBranch
+	}
+
+	payload.Actions, err = client.commitActions(ctx, req.Repository, parentRef, changes)
+	if err != nil {
+		return cpgo.UpsertFileResult{}, err
+	}
+
+	var created commit
+	if _, err := client.doJSON(ctx, http.MethodPost, projectPath(req.Repository)+"/repository/commits", nil, payload, &created); err != nil {
+		return cpgo.UpsertFileResult{}, fmt.Errorf("create commit: %w", err)
+	}
+
+	if strings.TrimSpace(created.ID) == "" {
+		return cpgo.UpsertFileResult{}, fmt.Errorf("created commit has empty sha")
+	}
+
+	return cpgo.UpsertFileResult{
+		CommitSHA:       created.ID,
+		IsBranchCreated: !hasHead,
+	}, nil
+}
+
+// commitActions maps file changes to create, update and delete actions against the parent ref,
+// because GitLab rejects creating an existing file or updating a missing one.
+func (client *Client) commitActions(ctx context.Context, repository cpgo.RepositoryRef, parentRef string, changes []cpgo.FileChange) ([]commitAction, error) {
+	actions := make([]commitAction, 0, len(changes))
+	for _, change := range changes {
+		exists, err := client.fileExists(ctx, repository, parentRef, change.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case change.Delete && !exists:
+			continue
+		case change.Delete:
+			actions = append(actions, commitAction{Action: "delete", FilePath: change.Path})
+			continue
+		}
+
+		action := commitAction{
+			Action:   "create",
+			FilePath: change.Path,
+			Content:  base64.StdEncoding.EncodeToString(change.Content),
+			Encoding: "base64",
+		}
+		if exists {
+			action.Action = "update"
+		}
+
+		actions = append(actions, action)
+	}
+
+	return actions, nil
+}
+
+// fileExists checks for a file on a ref with a metadata-only request.
+func (client *Client) fileExists(ctx context.Context, repository cpgo.RepositoryRef, ref string, path string) (bool, error) {
+	resp, err := client.send(ctx, http.MethodHead, filePath(repository, path), url.Values{"ref": {ref}}, nil)
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("get file %s: %w", path, err)
+	}
+	_ = resp.Body.Close()
+
+	return true, nil
+}
+
+// CreateTag creates a lightweight tag, tolerating a tag that already points at the commit.
+func (client *Client) CreateTag(ctx context.Context, req cpgo.CreateTagRequest) error {
+	if err := validateRepositoryRef(req.Repository); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(req.Name) == "" {
+		return fmt.Errorf("tag name is required")
+	}
+
+	if strings.TrimSpace(req.CommitSHA) == "" {
+		return fmt.Errorf("commit sha is required")
+	}
+
+	tagsPath := projectPath(req.Repository) + "/repository/tags"
+	_, err := client.doJSON(ctx, http.MethodPost, tagsPath, nil, map[string]string{
+		"tag_name": req.Name,
+		"ref":      req.CommitSHA,
+	}, nil)
+	if err == nil {
+		return nil
+	}
+
+	var existing tag
+	if _, getErr := client.doJSON(ctx, http.MethodGet, tagsPath+"/"+url.PathEscape(req.Name), nil, nil, &existing); getErr == nil && existing.Commit.ID == req.CommitSHA {
+		return nil
+	}
+
+	return fmt.Errorf("create tag: %w", err)
+}
+
+// ReportStatus creates a commit status on the current head commit of a branch.
+// GitHub failure and error states map to GitLab's failed state.
+func (client *Client) ReportStatus(ctx context.Context, req cpgo.ReportStatusRequest) error {
+	if err := validateRepositoryRef(req.Repository); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(req.Branch) == "" {
+		return fmt.Errorf("branch is required")
+	}
+
+	if strings.TrimSpace(req.Context) == "" {
+		return fmt.Errorf("status context is required")
+	}
+
+	head, hasBranch, err := client.getBranch(ctx, req.Repository, req.Branch)
+	if err != nil {
+		return err
+	}
+
+	if !hasBranch || strings.TrimSpace(head.Commit.ID) == "" {
+		return fmt.Errorf("branch %s has no head commit", req.Branch)
+	}
+
+	state := req.State
+	if state == "failure" || state == "error" {
+		state = "failed"
+	}
+
+	status := map[string]string{
+		"state":       state,
+		"name":        req.Context,
+		"description": req.Description,
+	}
+	if req.TargetURL != "" {
+		status["target_url"] = req.TargetURL
+	}
+
+	if _, err := client.doJSON(ctx, http.MethodPost, projectPath(req.Repository)+"/statuses/"+head.Commit.ID, nil, status, nil); err != nil {
+		if isForbidden(err) {
+			return fmt.Errorf("create commit status: %w: %v", cpgo.ErrStatusNotPermitted, err)
+		}
+
+		return fmt.Errorf("create commit status: %w", err)
+	}
+
+	return nil
+}
+
+// FindOpenByHead resolves an open merge request by target/source branch, paging through every match
+// and preferring the first whose description carries a managed marker over the first match.
+func (client *Client) FindOpenByHead(ctx context.Context, req cpgo.FindPullRequestRequest) (*cpgo.PullRequest, error) {
+	if err := validateRepositoryRef(req.Repository); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(req.BaseBranch) == "" {
+		return nil, fmt.Errorf("base branch is required")
+	}
+
+	if strings.TrimSpace(req.HeadBranch) == "" {
+		return nil, fmt.Errorf("head branch is required")
+	}
+
+	mergeRequests, err := client.listOpenMergeRequests(ctx, req.Repository, url.Values{
+		"target_branch": {req.BaseBranch},
+		"source_branch": {req.HeadBranch},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var first *cpgo.PullRequest
+	for _, mergeRequest := range mergeRequests {
+		if mergeRequest.SourceProjectID != mergeRequest.TargetProjectID {
+			continue
+		}
+
+		match := toPullRequest(mergeRequest)
+		if containsAny(match.Body, req.ManagedMarkers) {
+			return &match, nil
+		}
+
+		if first == nil {
+			first = &match
+		}
+	}
+
+	return first, nil
+}
+
+func containsAny(body string, markers []string) bool {
+	for _, marker := range markers {
+		if marker != "" && strings.Contains(body, marker) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ListOpenByHeadPrefix pages through open merge requests against the target branch and keeps same-project sources with the prefix.
+func (client *Client) ListOpenByHeadPrefix(ctx context.Context, req cpgo.ListPullRequestsRequest) ([]cpgo.PullRequest, error) {
+	if err := validateRepositoryRef(req.Repository); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(req.BaseBranch) == "" {
+		return nil, fmt.Errorf("base branch is required")
+	}
+
+	if strings.TrimSpace(req.HeadBranchPrefix) == "" {
+		return nil, fmt.Errorf("head branch prefix is required")
+	}
+
+	mergeRequests, err := client.listOpenMergeRequests(ctx, req.Repository, url.Values{
+		"target_branch": {req.BaseBranch},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []cpgo.PullRequest
+	for _, mergeRequest := range mergeRequests {
+		if mergeRequest.SourceProjectID != mergeRequest.TargetProjectID {
+			continue
+		}
+
+		if strings.HasPrefix(mergeRequest.SourceBranch, req.HeadBranchPrefix) {
+			matches = append(matches, toPullRequest(mergeRequest))
+		}
+	}
+
+	return matches, nil
+}
+
+// listOpenMergeRequests pages through open merge requests matching filters.
+func (client *Client) listOpenMergeRequests(ctx context.Context, repository cpgo.RepositoryRef, filters url.Values) ([]mergeRequest, error) {
+	query := url.Values{}
+	for key, values := range filters {
+		query[key] = values
+	}
+	query.Set("state", "opened")
+	query.Set("per_page", strconv.Itoa(listPageSize))
+
+	var mergeRequests []mergeRequest
+	for {
+		var page []mergeRequest
+		resp, err := client.doJSON(ctx, http.MethodGet, projectPath(repository)+"/merge_requests", query, nil, &page)
+		if err != nil {
+			return nil, fmt.Errorf("list merge requests: %w", err)
+		}
+
+		mergeRequests = append(mergeRequests, page...)
+
+		nextPage := strings.TrimSpace(resp.Header.Get("X-Next-Page"))
+		if nextPage == "" {
+			return mergeRequests, nil
+		}
+
+		query.Set("page", nextPage)
+	}
+}
+
+// Close comments on a merge request and then closes it.
+func (client *Client) Close(ctx context.Context, req cpgo.ClosePullRequestRequest) error {
+	if err := validateRepositoryRef(req.Repository); err != nil {
+		return err
+	}
+
+	if req.Number <= 0 {
+		return fmt.Errorf("pull request number must be positive")
+	}
+
+	mergeRequestPath := fmt.Sprintf("%s/merge_requests/%d", projectPath(req.Repository), req.Number)
+	if strings.TrimSpace(req.Comment) != "" {
+		if _, err := client.doJSON(ctx, http.MethodPost, mergeRequestPath+"/notes", nil, map[string]string{"body": req.Comment}, nil); err != nil {
+			return fmt.Errorf("comment on merge request !%d: %w", req.Number, err)
+		}
+	}
+
+	if _, err := client.doJSON(ctx, http.MethodPut, mergeRequestPath, nil, map[string]string{"state_event": "close"}, nil); err != nil {
+		return fmt.Errorf("close merge request !%d: %w", req.Number, err)
+	}
+
+	return nil
+}
+
+// Create opens a merge request; a draft gets GitLab's Draft: title prefix.
+// Reviewers and assignees are usernames resolved to user ids, and lookup
+// failures become warnings because the merge request is useful without them.
+func (client *Client) Create(ctx context.Context, req cpgo.CreatePullRequestRequest) (cpgo.PullRequest, error) {
+	if err := validateRepositoryRef(req.Repository); err != nil {
+		return cpgo.PullRequest{}, err
+	}
+
+	if strings.TrimSpace(req.BaseBranch) == "" {
+		return cpgo.PullRequest{}, fmt.Errorf("base branch is required")
+	}
+
+	if strings.TrimSpace(req.HeadBranch) == "" {
+		return cpgo.PullRequest{}, fmt.Errorf("head branch is required")
+	}
+
+	if strings.TrimSpace(req.Title) == "" {
+		return cpgo.PullRequest{}, fmt.Errorf("pull request title is required")
+	}
+
+	if strings.TrimSpace(req.Body) == "" {
+		return cpgo.PullRequest{}, fmt.Errorf("pull request body is required")
+	}
+
+	if err := client.checkLabelsExist(ctx, req.Repository, req.RequiredLabels); err != nil {
+		return cpgo.PullRequest{}, err
+	}
+
+	var warnings []string
+	reviewerIDs, reviewerWarnings := client.userIDs(ctx, req.Reviewers)
+	warnings = append(warnings, reviewerWarnings...)
+	for _, team := range req.TeamReviewers {
+		warnings = append(warnings, fmt.Sprintf("request team reviewer %s: gitlab has no team reviewers", team))
+	}
+
+	assigneeIDs, assigneeWarnings := client.userIDs(ctx, req.Assignees)
+	warnings = append(warnings, assigneeWarnings...)
+
+	title := req.Title
+	if req.Draft {
+		title = draftTitlePrefix + title
+	}
+
+	var created mergeRequest
+	_, err := client.doJSON(ctx, http.MethodPost, projectPath(req.Repository)+"/merge_requests", nil, createMergeRequestRequest{
+		SourceBranch: req.HeadBranch,
+		TargetBranch: req.BaseBranch,
+		Title:        title,
+		Description:  req.Body,
+		Labels:       strings.Join(append(slices.Clone(req.RequiredLabels), req.Labels...), ","),
+		ReviewerIDs:  reviewerIDs,
+		AssigneeIDs:  assigneeIDs,
+	}, &created)
+	if err != nil {
+		return cpgo.PullRequest{}, fmt.Errorf("create merge request: %w", err)
+	}
+
+	pullRequest := toPullRequest(created)
+	pullRequest.Warnings = warnings
+
+	return pullRequest, nil
+}
+
+// userIDs resolves usernames to user ids, reporting unresolved names as warnings.
+func (client *Client) userIDs(ctx context.Context, usernames []string) ([]int64, []string) {
+	var ids []int64
+	var warnings []string
+	for _, username := range usernames {
+		var users []user
+		_, err := client.doJSON(ctx, http.MethodGet, "users", url.Values{"username": {username}}, nil, &users)
+		switch {
+		case err != nil:
+			warnings = append(warnings, fmt.Sprintf("look up gitlab user %s: %v", username, err))
+		case len(users) == 0:
+			warnings = append(warnings, fmt.Sprintf("look up gitlab user %s: not found", username))
+		default:
+			ids = append(ids, users[0].ID)
+		}
+	}
+
+	return ids, warnings
+}
+
+// checkLabelsExist fails for labels missing from the project, which GitLab would otherwise create silently.
+func (client *Client) checkLabelsExist(ctx context.Context, repository cpgo.RepositoryRef, labels []string) error {
+	for _, label := range labels {
+		_, err := client.doJSON(ctx, http.MethodGet, projectPath(repository)+"/labels/"+url.PathEscape(label), nil, nil, nil)
+		if isNotFound(err) {
+			return fmt.Errorf("pull request label %q does not exist in %s/%s", label, repository.Owner, repository.Name)
+		}
+
+		if err != nil {
+			return fmt.Errorf("get label %q: %w", label, err)
+		}
+	}
+
+	return nil
+}
+
+// getBranch reads a branch, reporting false when it does not exist.
+func (client *Client) getBranch(ctx context.Context, repository cpgo.RepositoryRef, branchName string) (branch, bool, error) {
+	var decoded branch
+	if _, err := client.doJSON(ctx, http.MethodGet, branchPath(repository, branchName), nil, nil, &decoded); err != nil {
+		if isNotFound(err) {
+			return branch{}, false, nil
+		}
+
+		return branch{}, false, fmt.Errorf("get branch %s: %w", branchName, err)
+	}
+
+	return decoded, true, nil
+}
+
+// doJSON sends a request with an optional JSON body and decodes a JSON response into out when set.
+func (client *Client) doJSON(ctx context.Context, method string, path string, query url.Values, body any, out any) (*http.Response, error) {
+	resp, err := client.send(ctx, method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return resp, nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	return resp, nil
+}
+
+// send performs an authenticated API request, turning non-2xx responses into apiError.
+func (client *Client) send(ctx context.Context, method string, path string, query url.Values, body any) (*http.Response, error) {
+	requestURL, err := url.Parse(client.baseURL + "/" + path)
+	if err != nil {
+		return nil, fmt.Errorf("build gitlab url: %w", err)
+	}
+	requestURL.RawQuery = query.Encode()
+
+	var payload io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("encode gitlab request: %w", err)
+		}
+
+		payload = bytes.NewReader(encoded)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, requestURL.String(), payload)
+	if err != nil {
+		return nil, fmt.Errorf("build gitlab request: %w", err)
+	}
+
+	httpReq.Header.Set("PRIVATE-TOKEN", client.token)
+	httpReq.Header.Set("Accept", "application/json")
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer func() { _ = resp.Body.Close() }()
+
+		preview, _ := io.ReadAll(io.LimitReader(resp.Body, errorPreviewBytes))
+		return nil, &apiError{
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(preview)),
+		}
+	}
+
+	return resp, nil
+}
+
+// projectPath addresses a project by its URL-encoded namespace path.
+func projectPath(repository cpgo.RepositoryRef) string {
+	return "projects/" + url.PathEscape(repository.Owner+"/"+repository.Name)
+}
+
+func branchPath(repository cpgo.RepositoryRef, branchName string) string {
+	return projectPath(repository) + "/repository/branches/" + url.PathEscape(branchName)
+}
+
+func filePath(repository cpgo.RepositoryRef, path string) string {
+	return projectPath(repository) + "/repository/files/" + url.PathEscape(path)
+}
+
+func toPullRequest(mergeRequest mergeRequest) cpgo.PullRequest {
+	return cpgo.PullRequest{
+		Number:     mergeRequest.IID,
+		Title:      mergeRequest.Title,
+		Body:       mergeRequest.Description,
+		URL:        mergeRequest.WebURL,
+		HeadBranch: mergeRequest.SourceBranch,
+	}
+}
+
+func isNotFound(err error) bool {
+	var gitlabError *apiError
+	return errors.As(err, &gitlabError) && gitlabError.StatusCode == http.StatusNotFound
+}
+
+func isForbidden(err error) bool {
+	var gitlabError *apiError
+	return errors.As(err, &gitlabError) && gitlabError.StatusCode == http.StatusForbidden
+}
+
+func validateRepositoryRef(repository cpgo.RepositoryRef) error {
+	if strings.TrimSpace(repository.Owner) == "" {
+		return fmt.Errorf("repository owner is required")
+	}
+
+	if strings.TrimSpace(repository.Name) == "" {
+		return fmt.Errorf("repository name is required")
+	}
+
+	return nil
+}
+
+func withDefaultTimeout(httpClient *http.Client) *http.Client {
+	if httpClient == nil {
+		return &http.Client{
+			Timeout: defaultHTTPClientTimeout,
+		}
+	}
+
+	httpClientCopy := *httpClient
+	if httpClientCopy.Timeout <= 0 {
+		httpClientCopy.Timeout = defaultHTTPClientTimeout
+	}
+
+	return &httpClientCopy
+}
@@ -0,0 +1,234 @@
+package gitlabapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cpgo"
+)
+
+func TestClientReadFile(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("PRIVATE-TOKEN") != "token" {
+			t.Fatalf("expected private token header")
+		}
+
+		switch req.URL.EscapedPath() {
+		case "/api/v4/projects/acme%2Fpayments/repository/files/cmd%2Fdefault.pgo/raw":
+			if req.URL.Query().Get("ref") != "main" {
+				t.Fatalf("expected ref main, got %s", req.URL.Query().Get("ref"))
+			}
+
+			_, _ = response.Write([]byte("profile-bytes"))
+		case "/api/v4/projects/acme%2Fpayments/repository/files/missing.pgo/raw":
+			response.WriteHeader(http.StatusNotFound)
+			_, _ = response.Write([]byte(`{"message":"404 File Not Found"}`))
+		default:
+			t.Fatalf("unexpected path: %s", req.URL.EscapedPath())
+		}
+	}))
+
+	repository := cpgo.RepositoryRef{Owner: "acme", Name: "payments"}
+	result, err := client.ReadFile(context.Background(), cpgo.ReadFileRequest{Repository: repository, Branch: "main", Path: "cmd/default.pgo"})
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+
+	if !result.HasFile || string(result.Content) != "profile-bytes" {
+		t.Fatalf("expected profile bytes, got %+v", result)
+	}
+
+	result, err = client.ReadFile(context.Background(), cpgo.ReadFileRequest{Repository: repository, Branch: "main", Path: "missing.pgo"})
+	if err != nil {
+		t.Fatalf("read missing file: %v", err)
+	}
+
+	if result.HasFile {
+		t.Fatalf("expected missing file result")
+	}
+}
+
+func TestClientUpsertFileAndForceBranch(t *testing.T) {
+	var payload createCommitRequest
+	client := newTestClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		switch req.Method + " " + req.URL.EscapedPath() {
+		case "GET /api/v4/projects/acme%2Fpayments/repository/branches/cpgo":
+			_, _ = response.Write([]byte(`{"name":"cpgo","commit":{"id":"head-commit"}}`))
+		case "HEAD /api/v4/projects/acme%2Fpayments/repository/files/default.pgo":
+			if req.URL.Query().Get("ref") != "main" {
+				t.Fatalf("expected existence checks against the base branch, got %s", req.URL.Query().Get("ref"))
+			}
+		case "HEAD /api/v4/projects/acme%2Fpayments/repository/files/perf%2Fnotes.txt",
+			"HEAD /api/v4/projects/acme%2Fpayments/repository/files/old.pgo":
+			response.WriteHeader(http.StatusNotFound)
+		case "POST /api/v4/projects/acme%2Fpayments/repository/commits":
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode commit request: %v", err)
+			}
+
+			_, _ = response.Write([]byte(`{"id":"new-commit"}`))
+		default:
+			t.Fatalf("unexpected request: %s %s", req.Method, req.URL.EscapedPath())
+		}
+	}))
+
+	result, err := client.UpsertFileAndForceBranch(context.Background(), cpgo.UpsertFileRequest{
+		Repository: cpgo.RepositoryRef{Owner: "acme", Name: "payments"},
+		BaseBranch: "main",
+		HeadBranch: "cpgo",
+		Path:       "default.pgo",
+		Content:    []byte("profile"),
+		AdditionalFiles: []cpgo.FileChange{
+			{Path: "perf/notes.txt", Content: []byte("notes")},
+			{Path: "old.pgo", Delete: true},
+		},
+		CommitMessage: "perf(pgo): refresh pgo profile",
+		Author:        cpgo.CommitIdentity{Name: "cpgo-bot", Email: "bot@example.com"},
+		ForceUpdate:   true,
+	})
+	if err != nil {
+		t.Fatalf("upsert file: %v", err)
+	}
+
+	if result.CommitSHA != "new-commit" || result.IsBranchCreated {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	if payload.Branch != "cpgo" || payload.StartBranch != "main" || !payload.Force || payload.AuthorEmail != "bot@example.com" {
+		t.Fatalf("expected a forced commit from main onto cpgo, got %+v", payload)
+	}
+
+	if len(payload.Actions) != 2 || payload.Actions[0].Action != "update" || payload.Actions[1].Action != "create" || payload.Actions[1].Encoding != "base64" {
+		t.Fatalf("expected update and create actions without deleting a missing file, got %+v", payload.Actions)
+	}
+}
+
+func TestClientUpsertFileRejectsUnsupportedChanges(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		t.Fatalf("unexpected request: %s %s", req.Method, req.URL.EscapedPath())
+	}))
+
+	for _, tc := range []struct {
+		name string
+		req  cpgo.UpsertFileRequest
+	}{
+		{name: "committer", req: cpgo.UpsertFileRequest{Committer: cpgo.CommitIdentity{Name: "cpgo-bot", Email: "bot@example.com"}}},
+		{name: "symlink", req: cpgo.UpsertFileRequest{AdditionalFiles: []cpgo.FileChange{{Path: "default.pgo", Content: []byte("default-abc.pgo"), Symlink: true}}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.req.Repository = cpgo.RepositoryRef{Owner: "acme", Name: "payments"}
+			tc.req.BaseBranch = "main"
+			tc.req.HeadBranch = "cpgo"
+			tc.req.Path = "default-abc.pgo"
+			tc.req.CommitMessage = "perf(pgo): refresh pgo profile"
+
+			if _, err := client.UpsertFileAndForceBranch(context.Background(), tc.req); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func TestClientFindOpenByHead(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		if query.Get("state") != "opened" || query.Get("target_branch") != "main" || query.Get("source_branch") != "cpgo" {
+			t.Fatalf("unexpected merge request filters %s", req.URL.RawQuery)
+		}
+
+		if query.Get("page") == "2" {
+			_, _ = response.Write([]byte(`[{"iid":8,"description":"Refresh.\n\n<!-- managed-by:cpgo -->","source_branch":"cpgo","source_project_id":1,"target_project_id":1}]`))
+			return
+		}
+
+		response.Header().Set("X-Next-Page", "2")
+		_, _ = response.Write([]byte(`[
+			{"iid":6,"description":"<!-- managed-by:cpgo -->","source_branch":"cpgo","source_project_id":2,"target_project_id":1},
+			{"iid":7,"description":"Hand-written change.","source_branch":"cpgo","source_project_id":1,"target_project_id":1}
+		]`))
+	}))
+
+	pullRequest, err := client.FindOpenByHead(context.Background(), cpgo.FindPullRequestRequest{
+		Repository:     cpgo.RepositoryRef{Owner: "acme", Name: "payments"},
+		BaseBranch:     "main",
+		HeadBranch:     "cpgo",
+		ManagedMarkers: []string{"<!-- managed-by:cpgo -->"},
+	})
+	if err != nil {
+		t.Fatalf("find merge request: %v", err)
+	}
+
+	if pullRequest == nil || pullRequest.Number != 8 {
+		t.Fatalf("expected the managed same-project merge request, got %+v", pullRequest)
+	}
+}
+
+func TestClientCreate(t *testing.T) {
+	var payload createMergeRequestRequest
+	client := newTestClient(t, http.HandlerFunc(func(response http.ResponseWriter, req *http.Request) {
+		switch req.URL.EscapedPath() {
+		case "/api/v4/projects/acme%2Fpayments/labels/pgo":
+			_, _ = response.Write([]byte(`{"name":"pgo"}`))
+		case "/api/v4/users":
+			if req.URL.Query().Get("username") == "alice" {
+				_, _ = response.Write([]byte(`[{"id":11}]`))
+				return
+			}
+
+			_, _ = response.Write([]byte(`[]`))
+		case "/api/v4/projects/acme%2Fpayments/merge_requests":
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode merge request: %v", err)
+			}
+
+			_, _ = response.Write([]byte(`{"iid":9,"title":"Draft: refresh","web_url":"https://gitlab.com/acme/payments/-/merge_requests/9","source_branch":"cpgo"}`))
+		default:
+			t.Fatalf("unexpected path: %s", req.URL.EscapedPath())
+		}
+	}))
+
+	created, err := client.Create(context.Background(), cpgo.CreatePullRequestRequest{
+		Repository:     cpgo.RepositoryRef{Owner: "acme", Name: "payments"},
+		BaseBranch:     "main",
+		HeadBranch:     "cpgo",
+		Title:          "refresh",
+		Body:           "Automated PGO profile refresh.",
+		RequiredLabels: []string{"pgo"},
+		Labels:         []string{"hot:encoding/json"},
+		Reviewers:      []string{"alice", "bob"},
+		Draft:          true,
+	})
+	if err != nil {
+		t.Fatalf("create merge request: %v", err)
+	}
+
+	if payload.Title != "Draft: refresh" || payload.Labels != "pgo,hot:encoding/json" || len(payload.ReviewerIDs) != 1 || payload.ReviewerIDs[0] != 11 {
+		t.Fatalf("unexpected merge request payload %+v", payload)
+	}
+
+	if created.Number != 9 || len(created.Warnings) != 1 || !strings.Contains(created.Warnings[0], "bob") {
+		t.Fatalf("expected the merge request with an unresolved reviewer warning, got %+v", created)
+	}
+}
+
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(Options{
+		BaseURL:    server.URL + "/api/v4/",
+		Token:      "token",
+		HTTPClient: server.Client(),
+	})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+
+	return client
+}
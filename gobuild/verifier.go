@@ -0,0 +1,108 @@
+// Package gobuild verifies profiles against the Go toolchain in a local checkout.
+package gobuild
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"cpgo"
+)
+
+const (
+	// CommandBuild compiles packages with the profile.
+	CommandBuild = "build"
+	// CommandVet vets packages with the profile.
+	CommandVet = "vet"
+
+	defaultPackages = "./..."
+)
+
+// Options configures where and how the toolchain check runs.
+type Options struct {
+	Dir      string
+	Command  string
+	Packages []string
+	GoBinary string
+}
+
+// Verifier runs `go build -pgo=<profile>` against a checked-out module.
+type Verifier struct {
+	dir      string
+	command  string
+	packages []string
+	goBinary string
+}
+
+var _ cpgo.ProfileVerifier = (*Verifier)(nil)
+
+// NewVerifier validates options and returns a toolchain verifier.
+func NewVerifier(options Options) (*Verifier, error) {
+	dir := strings.TrimSpace(options.Dir)
+	if dir == "" {
+		return nil, fmt.Errorf("verify build dir is required")
+	}
+
+	command := strings.TrimSpace(options.Command)
+	if command == "" {
+		command = CommandBuild
+	}
+
+	if command != CommandBuild && command != CommandVet {
+		return nil, fmt.Errorf("unsupported verify build command %q", command)
+	}
+
+	packages := options.Packages
+	if len(packages) == 0 {
+		packages = []string{defaultPackages}
+	}
+
+	goBinary := strings.TrimSpace(options.GoBinary)
+	if goBinary == "" {
+		goBinary = "go"
+	}
+
+	return &Verifier{
+		dir:      dir,
+		command:  command,
+		packages: packages,
+		goBinary: goBinary,
+	}, nil
+}
+
+// VerifyProfile writes the profile to a temporary file and checks the toolchain accepts it.
+func (verifier *Verifier) VerifyProfile(ctx context.Context, profile []byte) error {
+	tempDir, err := os.MkdirTemp("", "cpgo-verify-*")
+	if err != nil {
+		return fmt.Errorf("create verify dir: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tempDir) }()
+
+	profilePath := filepath.Join(tempDir, "default.pgo")
+	if err := os.WriteFile(profilePath, profile, 0o600); err != nil {
+		return fmt.Errorf("write verify profile: %w", err)
+	}
+
+	args := []string{verifier.command, "-pgo=" + profilePath}
+	if verifier.command == CommandBuild {
+		// Discard build outputs so verification never writes binaries into the checkout.
+		args = append(args, "-o", os.DevNull)
+	}
+	args = append(args, verifier.packages...)
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, verifier.goBinary, args...)
+	cmd.Dir = verifier.dir
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("go %s -pgo: %w: %s", verifier.command, err, strings.TrimSpace(output.String()))
+	}
+
+	return nil
+}
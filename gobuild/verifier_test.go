@@ -0,0 +1,85 @@
+package gobuild
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+func TestVerifierVerifyProfile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "go.mod"), "module example.com/verify\n\ngo 1.21\n")
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {}\n")
+
+	verifier, err := NewVerifier(Options{Dir: dir})
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+
+	t.Run("accepts a cpu profile", func(t *testing.T) {
+		if err := verifier.VerifyProfile(context.Background(), writeProfile(t)); err != nil {
+			t.Fatalf("verify profile: %v", err)
+		}
+	})
+
+	t.Run("rejects a profile the toolchain cannot read", func(t *testing.T) {
+		err := verifier.VerifyProfile(context.Background(), []byte("not a profile"))
+		if err == nil {
+			t.Fatalf("expected verification error")
+		}
+
+		if !strings.Contains(err.Error(), "go build -pgo") {
+			t.Fatalf("expected go build context in error, got %v", err)
+		}
+	})
+}
+
+func TestNewVerifier(t *testing.T) {
+	if _, err := NewVerifier(Options{Dir: t.TempDir(), Command: "test"}); err == nil {
+		t.Fatalf("expected unsupported command error")
+	}
+
+	if _, err := NewVerifier(Options{}); err == nil {
+		t.Fatalf("expected missing dir error")
+	}
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func writeProfile(t *testing.T) []byte {
+	t.Helper()
+
+	function := &profile.Function{ID: 1, Name: "main.main", StartLine: 3}
+	location := &profile.Location{ID: 1, Line: []profile.Line{{Function: function, Line: 3}}}
+	cpuProfile := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		PeriodType: &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Period:     10_000_000,
+		Function:   []*profile.Function{function},
+		Location:   []*profile.Location{location},
+		Sample: []*profile.Sample{
+			{Value: []int64{1, 10_000_000}, Location: []*profile.Location{location}},
+		},
+	}
+
+	var raw bytes.Buffer
+	if err := cpuProfile.Write(&raw); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+
+	return raw.Bytes()
+}
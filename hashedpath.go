@@ -0,0 +1,93 @@
+package cpgo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"strings"
+)
+
+// profileHashLength is the number of hex digits of the profile hash embedded in hashed filenames.
+const profileHashLength = 12
+
+// hashedProfilePath returns pgoPath with a short content hash before the extension,
+// e.g. cmd/api/default.pgo becomes cmd/api/default-0123456789ab.pgo.
+func hashedProfilePath(pgoPath string, profile []byte) string {
+	sum := sha256.Sum256(profile)
+	extension := path.Ext(pgoPath)
+
+	return strings.TrimSuffix(pgoPath, extension) + "-" + hex.EncodeToString(sum[:])[:profileHashLength] + extension
+}
+
+// hashedProfileTarget resolves the hashed file a pointer symlink at pgoPath refers to.
+// It reports false when the pointer content is not a hashed sibling of pgoPath,
+// such as a regular profile committed before hashed filenames were enabled.
+func hashedProfileTarget(pgoPath string, pointer []byte) (string, bool) {
+	target := string(pointer)
+	if strings.Contains(target, "/") {
+		return "", false
+	}
+
+	extension := path.Ext(pgoPath)
+	stem := strings.TrimSuffix(path.Base(pgoPath), extension) + "-"
+	if !strings.HasPrefix(target, stem) || !strings.HasSuffix(target, extension) {
+		return "", false
+	}
+
+	hash := strings.TrimSuffix(strings.TrimPrefix(target, stem), extension)
+	if len(hash) != profileHashLength {
+		return "", false
+	}
+
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "", false
+	}
+
+	return path.Join(path.Dir(pgoPath), target), true
+}
+
+// profileUpsert builds the single branch write covering every pgo path, honoring hashed filenames.
+// With hashed filenames each profile lands in its hashed path, the pgo path becomes a
+// symlink to it, and the previously referenced hashed file is removed in the same commit.
+func profileUpsert(req RunRequest, repository RepositoryRef, base baseState, profile []byte) UpsertFileRequest {
+	var changes []FileChange
+	for _, target := range base.Targets {
+		changes = append(changes, targetChanges(req.Repository.HashInFilename, target, profile)...)
+	}
+
+	return UpsertFileRequest{
+		Repository:       repository,
+		BaseBranch:       base.Branch,
+		HeadBranch:       req.Repository.HeadBranch,
+		Path:             changes[0].Path,
+		Content:          changes[0].Content,
+		AdditionalFiles:  append(append([]FileChange(nil), req.ExtraFiles...), changes[1:]...),
+		CommitMessage:    req.Commit.Message,
+		Author:           req.Commit.author(),
+		Committer:        req.Commit.committer(),
+		ForceUpdate:      !req.Repository.FastForwardOnly && !req.Repository.CommitToBase,
+		RebaseOnConflict: req.Repository.RebaseOnConflict,
+	}
+}
+
+// targetChanges returns the file operations that point one pgo path at profile, profile content first.
+func targetChanges(hashInFilename bool, target profileTarget, profile []byte) []FileChange {
+	if !hashInFilename {
+		return []FileChange{{Path: target.PGOPath, Content: profile}}
+	}
+
+	hashedPath := hashedProfilePath(target.PGOPath, profile)
+	changes := []FileChange{
+		{Path: hashedPath, Content: profile},
+		{Path: target.PGOPath, Content: []byte(path.Base(hashedPath)), Symlink: true},
+	}
+
+	if target.ProfilePath != "" && target.ProfilePath != target.PGOPath && target.ProfilePath != hashedPath {
+		changes = append(changes, FileChange{
+			Path:   target.ProfilePath,
+			Delete: true,
+		})
+	}
+
+	return changes
+}
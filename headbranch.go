@@ -0,0 +1,149 @@
+package cpgo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// headBranchTemplateData holds the placeholders a templated head branch may use:
+// Date is the UTC run date, Time the UTC run timestamp, and Commit the short SHA
+// of the base branch head.
+type headBranchTemplateData struct {
+	Date   string
+	Time   string
+	Commit string
+}
+
+// isTemplatedHeadBranch reports whether a head branch contains template placeholders.
+func isTemplatedHeadBranch(branch string) bool {
+	return strings.Contains(branch, "{{")
+}
+
+// headBranchTemplatePrefix returns the static text before the first placeholder,
+// which every branch the template expands to starts with.
+func headBranchTemplatePrefix(branch string) string {
+	prefix, _, _ := strings.Cut(branch, "{{")
+	return prefix
+}
+
+// headBranchPrefix returns the prefix every branch a head branch setting produces starts with:
+// the static text of a templated branch, or the branch followed by a slash for branch_per_run.
+func headBranchPrefix(branch string) string {
+	if isTemplatedHeadBranch(branch) {
+		return headBranchTemplatePrefix(branch)
+	}
+
+	return branch + "/"
+}
+
+// runHeadBranch returns the fresh branch a branch_per_run run at now pushes.
+func runHeadBranch(branch string, now time.Time) string {
+	return headBranchPrefix(branch) + now.UTC().Format(runBranchLayout)
+}
+
+// parseHeadBranchTemplate parses a templated head branch, rejecting unknown placeholders
+// and templates without a static prefix to look up the branches they produce by.
+func parseHeadBranchTemplate(branch string) (*template.Template, error) {
+	if strings.TrimSpace(headBranchTemplatePrefix(branch)) == "" {
+		return nil, fmt.Errorf("templated head branch %q must start with a static prefix", branch)
+	}
+
+	branchTemplate, err := template.New("head_branch").Option("missingkey=error").Parse(branch)
+	if err != nil {
+		return nil, fmt.Errorf("parse head branch template: %w", err)
+	}
+
+	if err := branchTemplate.Execute(io.Discard, headBranchTemplateData{}); err != nil {
+		return nil, fmt.Errorf("head branch template: %w", err)
+	}
+
+	return branchTemplate, nil
+}
+
+// expandHeadBranch renders a templated head branch for a run at now, resolving the
+// base branch head only when the template references it.
+func (svc *Service) expandHeadBranch(ctx context.Context, repository RepositoryRef, baseBranch string, branch string, now time.Time) (string, error) {
+	branchTemplate, err := parseHeadBranchTemplate(branch)
+	if err != nil {
+		return "", err
+	}
+
+	data := headBranchTemplateData{
+		Date: now.UTC().Format(profileTagDateLayout),
+		Time: now.UTC().Format(runBranchLayout),
+	}
+
+	if strings.Contains(branch, ".Commit") {
+		commitSHA, hasBranch, err := svc.branchWriter.BranchHead(ctx, repository, baseBranch)
+		if err != nil {
+			return "", fmt.Errorf("resolve base branch head: %w", err)
+		}
+
+		if !hasBranch {
+			return "", fmt.Errorf("resolve base branch head: branch %s not found", baseBranch)
+		}
+
+		data.Commit = commitSHA
+		if len(data.Commit) > shortCommitSHALength {
+			data.Commit = data.Commit[:shortCommitSHALength]
+		}
+	}
+
+	var expanded strings.Builder
+	if err := branchTemplate.Execute(&expanded, data); err != nil {
+		return "", fmt.Errorf("expand head branch template: %w", err)
+	}
+
+	return expanded.String(), nil
+}
+
+// findHeadPullRequest resolves the run's head branch and its open pull request. A templated
+// head branch is expanded and looked up among the open pull requests sharing its static prefix,
+// since the provider cannot match a head branch that differs between runs.
+func (svc *Service) findHeadPullRequest(ctx context.Context, req RunRequest, repository RepositoryRef, baseBranch string) (string, *PullRequest, error) {
+	headBranch := req.Repository.HeadBranch
+	if !isTemplatedHeadBranch(headBranch) {
+		openPR, err := svc.pullRequests.FindOpenByHead(ctx, FindPullRequestRequest{
+			Repository:     repository,
+			BaseBranch:     baseBranch,
+			HeadBranch:     headBranch,
+			ManagedMarkers: req.PullRequest.managedMarkers(),
+		})
+		if err != nil {
+			return "", nil, fmt.Errorf("find open pull request: %w", err)
+		}
+
+		return headBranch, openPR, nil
+	}
+
+	expanded, err := svc.expandHeadBranch(ctx, repository, baseBranch, headBranch, svc.clock.Now())
+	if err != nil {
+		return "", nil, err
+	}
+
+	openPRs, err := svc.pullRequests.ListOpenByHeadPrefix(ctx, ListPullRequestsRequest{
+		Repository:       repository,
+		BaseBranch:       baseBranch,
+		HeadBranchPrefix: headBranchTemplatePrefix(headBranch),
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("find open pull request: %w", err)
+	}
+
+	var openPR *PullRequest
+	for index := range openPRs {
+		if openPRs[index].HeadBranch != expanded {
+			continue
+		}
+
+		if openPR == nil || (!req.PullRequest.isManaged(openPR.Body) && req.PullRequest.isManaged(openPRs[index].Body)) {
+			openPR = &openPRs[index]
+		}
+	}
+
+	return expanded, openPR, nil
+}
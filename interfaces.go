@@ -3,19 +3,55 @@ package cpgo
 import (
 	"context"
 	"net/url"
+	"time"
 )
 
+// Clock supplies the current time so time-based policies are testable.
+type Clock interface {
+	Now() time.Time
+}
+
 // ProfileFetcher retrieves raw CPU profile data from a source endpoint.
 type ProfileFetcher interface {
 	// FetchCPUProfile returns CPU profile bytes for one sampling request.
-	FetchCPUProfile(ctx context.Context, req FetchProfileRequest) ([]byte, error)
+	FetchCPUProfile(ctx context.Context, req FetchProfileRequest) (FetchProfileResult, error)
 }
 
-// FetchProfileRequest defines a CPU profile fetch operation.
+// ProfileType names the pprof profile a run collects.
+type ProfileType string
+
+// Supported profile types; an empty type means cpu.
+const (
+	ProfileTypeCPU   ProfileType = "cpu"
+	ProfileTypeHeap  ProfileType = "heap"
+	ProfileTypeMutex ProfileType = "mutex"
+	ProfileTypeBlock ProfileType = "block"
+)
+
+// FetchProfileRequest defines a profile fetch operation.
+// Zero Seconds requests an instantaneous profile without a sampling window.
+// Method defaults to GET; Body is sent as is, except that a {seconds}
+// placeholder in it carries Seconds in place of the seconds query.
 type FetchProfileRequest struct {
-	URL     *url.URL
-	Seconds int
-	Headers map[string]string
+	URL         *url.URL
+	Seconds     int
+	Headers     map[string]string
+	ProfileType ProfileType
+	Method      string
+	Body        []byte
+}
+
+// FetchProfileResult carries fetched profile bytes and where they came from.
+type FetchProfileResult struct {
+	Content   []byte
+	SourceURL *url.URL
+}
+
+// ProfileTransform converts a fetched payload into pprof bytes, so sources emitting
+// another format can feed the pipeline before validation.
+type ProfileTransform interface {
+	// TransformProfile returns the pprof encoding of raw.
+	TransformProfile(ctx context.Context, raw []byte) ([]byte, error)
 }
 
 // ProfileValidator verifies that a fetched payload is a usable CPU profile.
@@ -24,6 +60,92 @@ type ProfileValidator interface {
 	ValidateCPUProfile(raw []byte) error
 }
 
+// ProfileExpectations describes the capture a run requested from the profile source.
+// Seconds is the requested sample window; zero means an instantaneous profile.
+// ProfileType selects the sample type the profile must carry.
+type ProfileExpectations struct {
+	Seconds     int
+	ProfileType ProfileType
+}
+
+// ExpectationValidator is implemented by validators that can also check a profile against the requested capture.
+type ExpectationValidator interface {
+	// ValidateCPUProfileWithExpectations applies ValidateCPUProfile plus checks that depend on the request.
+	ValidateCPUProfileWithExpectations(raw []byte, expectations ProfileExpectations) error
+}
+
+// ContextValidator is implemented by validators that can also stop when the run is cancelled,
+// such as validators running external commands.
+type ContextValidator interface {
+	// ValidateCPUProfileContext applies ValidateCPUProfileWithExpectations until ctx ends.
+	ValidateCPUProfileContext(ctx context.Context, raw []byte, expectations ProfileExpectations) error
+}
+
+// SampleCounter is implemented by validators that can also count the samples in a profile.
+type SampleCounter interface {
+	// CountSamples returns the profile's sample count.
+	CountSamples(raw []byte) (int64, error)
+}
+
+// ProfileVerifier checks that a toolchain accepts a profile for PGO builds.
+type ProfileVerifier interface {
+	// VerifyProfile fails when the profile cannot be used for a PGO build.
+	VerifyProfile(ctx context.Context, profile []byte) error
+}
+
+// ProfileNormalizer canonicalizes profile encodings for equality checks.
+type ProfileNormalizer interface {
+	// NormalizeProfile returns an encoding that is equal for semantically identical profiles.
+	NormalizeProfile(raw []byte) ([]byte, error)
+}
+
+// ProfileMerger combines the base branch profile with a freshly fetched one.
+type ProfileMerger interface {
+	// MergeProfiles returns a profile holding the samples of both base and current.
+	MergeProfiles(base []byte, current []byte) ([]byte, error)
+}
+
+// ProfileComparer describes how a fetched profile differs from the base branch profile.
+type ProfileComparer interface {
+	// CompareProfiles compares previous base branch bytes with the current profile bytes.
+	CompareProfiles(previous []byte, current []byte) (ProfileComparison, error)
+}
+
+// ProfileComparison holds reviewer-facing renderings of a profile change.
+// ChangeRatio is the share of flat samples that moved between functions, from 0 to 1.
+// SampleDelta is the summed absolute per-function difference in flat sample counts.
+// Similarity is the cosine similarity of per-function flat sample counts, 1 for identical shapes.
+type ProfileComparison struct {
+	TextDiff    string
+	ChangeRatio float64
+	SampleDelta int64
+	Similarity  float64
+}
+
+// DiffPolicy enforces rules on how a profile may change relative to the base branch.
+type DiffPolicy interface {
+	// CheckProfileDiff returns an error describing every violated rule; previous is nil without a base profile.
+	CheckProfileDiff(previous []byte, current []byte) error
+}
+
+// ProfileSummarizer renders a reviewer-facing summary of how a profile changed.
+type ProfileSummarizer interface {
+	// SummarizeProfileChange returns markdown describing the change from previous to current.
+	SummarizeProfileChange(previous []byte, current []byte) (string, error)
+}
+
+// ProfileDiffer renders a plain-text diff of two profiles for terminal output.
+type ProfileDiffer interface {
+	// DiffProfiles describes the change from previous to current; previous is nil without a base profile.
+	DiffProfiles(previous []byte, current []byte) (string, error)
+}
+
+// ProfileLabeler derives pull request triage labels from a profile.
+type ProfileLabeler interface {
+	// ProfileLabels returns labels describing the profile, such as its hottest package.
+	ProfileLabels(profile []byte) ([]string, error)
+}
+
 // RepositoryRef uniquely identifies a repository.
 type RepositoryRef struct {
 	Owner string
@@ -36,11 +158,33 @@ type BranchWriter interface {
 	DefaultBranch(ctx context.Context, repository RepositoryRef) (string, error)
 	// ReadFile reads file contents from a specific branch.
 	ReadFile(ctx context.Context, req ReadFileRequest) (ReadFileResult, error)
+	// DeleteBranch removes a branch, reporting false without error when it was already missing.
+	DeleteBranch(ctx context.Context, repository RepositoryRef, branch string) (bool, error)
+	// LastCommitTime returns the committer time of the branch head, reporting false when the branch is absent.
+	LastCommitTime(ctx context.Context, repository RepositoryRef, branch string) (time.Time, bool, error)
+	// BranchHead returns the commit SHA of the branch head, reporting false when the branch is absent.
+	BranchHead(ctx context.Context, repository RepositoryRef, branch string) (string, bool, error)
 	// UpsertFileAndForceBranch writes a file commit and updates the head branch.
 	UpsertFileAndForceBranch(ctx context.Context, req UpsertFileRequest) (UpsertFileResult, error)
 }
 
+// BranchProtectionReader is implemented by branch writers that can also report a branch's protection rules.
+type BranchProtectionReader interface {
+	// BranchProtection returns the protection of a branch, reporting false when the branch
+	// is unprotected or missing, or when the token may not read its protection.
+	BranchProtection(ctx context.Context, repository RepositoryRef, branch string) (BranchProtection, bool, error)
+}
+
+// BranchProtection describes the protection rules that can reject a branch update.
+type BranchProtection struct {
+	AllowsForcePushes   bool
+	RequiresPullRequest bool
+	// HasPullRequestBypass reports users, teams or apps allowed to bypass required pull requests.
+	HasPullRequestBypass bool
+}
+
 // ReadFileRequest selects a file on a specific repository branch.
+// Reads also accept a tag name in Branch.
 type ReadFileRequest struct {
 	Repository RepositoryRef
 	Branch     string
@@ -54,13 +198,45 @@ type ReadFileResult struct {
 }
 
 // UpsertFileRequest describes a force-update operation for a branch file.
+// AdditionalFiles are written or deleted in the same commit as Path.
+// An empty Author or Committer keeps the identity the credentials default to.
+// ForceUpdate rebuilds the head branch from BaseBranch and force-pushes it; without it
+// an existing head branch gains a fast-forward commit and divergence is an error.
+// RebaseOnConflict checks that BaseBranch did not move while a commit built on it
+// was written and rebuilds the commit on the new base a bounded number of times;
+// adapters whose host builds commits from the current base ignore it.
 type UpsertFileRequest struct {
-	Repository    RepositoryRef
-	BaseBranch    string
-	HeadBranch    string
-	Path          string
-	Content       []byte
-	CommitMessage string
+	Repository       RepositoryRef
+	BaseBranch       string
+	HeadBranch       string
+	Path             string
+	Content          []byte
+	AdditionalFiles  []FileChange
+	CommitMessage    string
+	Author           CommitIdentity
+	Committer        CommitIdentity
+	ForceUpdate      bool
+	RebaseOnConflict bool
+}
+
+// CommitIdentity names the author or committer of a commit.
+type CommitIdentity struct {
+	Name  string
+	Email string
+}
+
+// IsZero reports whether the identity is unset.
+func (identity CommitIdentity) IsZero() bool {
+	return identity.Name == "" && identity.Email == ""
+}
+
+// FileChange describes one extra file operation within a single commit.
+// A symlink change stores its target path as Content.
+type FileChange struct {
+	Path    string
+	Content []byte
+	Delete  bool
+	Symlink bool
 }
 
 // UpsertFileResult reports the branch update outcome.
@@ -69,34 +245,96 @@ type UpsertFileResult struct {
 	IsBranchCreated bool
 }
 
+// TagWriter creates named restore points for committed profiles.
+type TagWriter interface {
+	// CreateTag points a lightweight tag at a commit.
+	CreateTag(ctx context.Context, req CreateTagRequest) error
+}
+
+// CreateTagRequest names a lightweight tag for one commit.
+type CreateTagRequest struct {
+	Repository RepositoryRef
+	Name       string
+	CommitSHA  string
+}
+
+// StatusReporter publishes the run outcome as a commit status.
+type StatusReporter interface {
+	// ReportStatus sets a commit status on the head commit of a branch.
+	ReportStatus(ctx context.Context, req ReportStatusRequest) error
+}
+
+// ReportStatusRequest describes one commit status on a branch head.
+// State is a GitHub status state such as success or failure.
+type ReportStatusRequest struct {
+	Repository  RepositoryRef
+	Branch      string
+	State       string
+	Context     string
+	Description string
+	TargetURL   string
+}
+
 // PullRequestService manages pull requests for the cpgo branch.
 type PullRequestService interface {
 	// FindOpenByHead finds the open PR that matches base/head pair.
 	FindOpenByHead(ctx context.Context, req FindPullRequestRequest) (*PullRequest, error)
 	// Create opens a new pull request for the prepared branch.
 	Create(ctx context.Context, req CreatePullRequestRequest) (PullRequest, error)
+	// ListOpenByHeadPrefix lists open PRs whose head branch starts with a prefix.
+	ListOpenByHeadPrefix(ctx context.Context, req ListPullRequestsRequest) ([]PullRequest, error)
+	// Close comments on and closes a pull request.
+	Close(ctx context.Context, req ClosePullRequestRequest) error
 }
 
 // FindPullRequestRequest targets a PR lookup by repository branches.
+// When several open PRs match, the first whose body contains one of
+// ManagedMarkers is preferred over the first match.
 type FindPullRequestRequest struct {
+	Repository     RepositoryRef
+	BaseBranch     string
+	HeadBranch     string
+	ManagedMarkers []string
+}
+
+// ListPullRequestsRequest targets open PRs by base branch and head branch prefix.
+type ListPullRequestsRequest struct {
+	Repository       RepositoryRef
+	BaseBranch       string
+	HeadBranchPrefix string
+}
+
+// ClosePullRequestRequest closes a PR with an explanatory comment.
+type ClosePullRequestRequest struct {
 	Repository RepositoryRef
-	BaseBranch string
-	HeadBranch string
+	Number     int
+	Comment    string
 }
 
 // PullRequest holds the subset of PR metadata used by cpgo.
+// Warnings describe best-effort follow-ups, such as reviewer requests, that failed after creation.
 type PullRequest struct {
-	Number int
-	Title  string
-	Body   string
-	URL    string
+	Number     int
+	Title      string
+	Body       string
+	URL        string
+	HeadBranch string
+	Warnings   []string
 }
 
 // CreatePullRequestRequest contains fields for opening a PR.
+// RequiredLabels must already exist in the repository, so a typo fails the
+// run before the PR is opened; Labels are created on demand.
 type CreatePullRequestRequest struct {
-	Repository RepositoryRef
-	BaseBranch string
-	HeadBranch string
-	Title      string
-	Body       string
+	Repository     RepositoryRef
+	BaseBranch     string
+	HeadBranch     string
+	Title          string
+	Body           string
+	RequiredLabels []string
+	Labels         []string
+	Reviewers      []string
+	TeamReviewers  []string
+	Assignees      []string
+	Draft          bool
 }
@@ -0,0 +1,216 @@
+package parcaio
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cpgo"
+	"cpgo/pprofio"
+)
+
+const (
+	queryPath                = "/parca.query.v1alpha1.QueryService/Query"
+	defaultRange             = time.Hour
+	defaultHTTPClientTimeout = 45 * time.Second
+	// responseEnvelopeBytes bounds the JSON around the base64 pprof report.
+	responseEnvelopeBytes = 4 * 1024
+)
+
+// Options selects which profiles a Parca server merges into one pprof payload.
+// A zero MaxBytes applies pprofio.DefaultMaxProfileBytes.
+type Options struct {
+	Query       string
+	Range       time.Duration
+	BearerToken string
+	HTTPClient  *http.Client
+	MaxBytes    int64
+}
+
+// Fetcher queries a Parca server for a merged CPU profile over a time range.
+type Fetcher struct {
+	httpClient  *http.Client
+	query       string
+	timeRange   time.Duration
+	bearerToken string
+	maxBytes    int64
+	now         func() time.Time
+}
+
+var _ cpgo.ProfileFetcher = (*Fetcher)(nil)
+
+// queryRequest is the Connect JSON form of a merge query.
+type queryRequest struct {
+	Mode       string     `json:"mode"`
+	Merge      mergeQuery `json:"merge"`
+	ReportType string     `json:"reportType"`
+}
+
+type mergeQuery struct {
+	Query string `json:"query"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// queryResponse holds the pprof report returned by the query service.
+type queryResponse struct {
+	Pprof string `json:"pprof"`
+}
+
+// NewFetcher validates query options and returns a Parca fetcher.
+func NewFetcher(options Options) (*Fetcher, error) {
+	query := strings.TrimSpace(options.Query)
+	if query == "" {
+		return nil, fmt.Errorf("parca query is required")
+	}
+
+	if options.Range < 0 {
+		return nil, fmt.Errorf("parca range must be positive")
+	}
+
+	timeRange := options.Range
+	if timeRange == 0 {
+		timeRange = defaultRange
+	}
+
+	maxBytes := options.MaxBytes
+	switch {
+	case maxBytes < 0:
+		return nil, fmt.Errorf("profile max bytes must not be negative")
+	case maxBytes == 0:
+		maxBytes = pprofio.DefaultMaxProfileBytes
+	}
+
+	return &Fetcher{
+		httpClient:  withDefaultTimeout(options.HTTPClient),
+		query:       query,
+		timeRange:   timeRange,
+		bearerToken: strings.TrimSpace(options.BearerToken),
+		maxBytes:    maxBytes,
+		now:         time.Now,
+	}, nil
+}
+
+// FetchCPUProfile merges all matching profiles in the range ending now; req.URL is the server base URL.
+func (fetcher *Fetcher) FetchCPUProfile(ctx context.Context, req cpgo.FetchProfileRequest) (cpgo.FetchProfileResult, error) {
+	if req.URL == nil {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("parca server url is required")
+	}
+
+	end := fetcher.now().UTC()
+	payload, err := json.Marshal(queryRequest{
+		Mode: "MODE_MERGE",
+		Merge: mergeQuery{
+			Query: fetcher.query,
+			Start: end.Add(-fetcher.timeRange).Format(time.RFC3339),
+			End:   end.Format(time.RFC3339),
+		},
+		ReportType: "REPORT_TYPE_PPROF",
+	})
+	if err != nil {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("encode parca query: %w", err)
+	}
+
+	queryURL := *req.URL
+	queryURL.Path = strings.TrimRight(queryURL.Path, "/") + queryPath
+	queryURL.RawQuery = ""
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, queryURL.String(), bytes.NewReader(payload))
+	if err != nil {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("build parca request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	for key, value := range req.Headers {
+		if strings.TrimSpace(key) == "" {
+			continue
+		}
+
+		httpReq.Header.Set(key, value)
+	}
+
+	if fetcher.bearerToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+fetcher.bearerToken)
+	}
+
+	resp, err := fetcher.httpClient.Do(httpReq)
+	if err != nil {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("query parca: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		preview, readErr := io.ReadAll(io.LimitReader(resp.Body, 4*1024))
+		if readErr != nil {
+			return cpgo.FetchProfileResult{}, fmt.Errorf("query parca: unexpected status %s", resp.Status)
+		}
+
+		return cpgo.FetchProfileResult{}, fmt.Errorf("query parca: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(preview)))
+	}
+
+	// The report is base64 encoded, so the response may exceed the profile limit by that overhead.
+	maxResponseBytes := int64(base64.StdEncoding.EncodedLen(int(fetcher.maxBytes))) + responseEnvelopeBytes
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes+1))
+	if err != nil {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("read parca response: %w", err)
+	}
+
+	if int64(len(body)) > maxResponseBytes {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("%w: parca response exceeded %d bytes", pprofio.ErrProfileTooLarge, maxResponseBytes)
+	}
+
+	var decoded queryResponse
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("decode parca response: %w", err)
+	}
+
+	profile, err := base64.StdEncoding.DecodeString(decoded.Pprof)
+	if err != nil {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("decode parca pprof report: %w", err)
+	}
+
+	if int64(len(profile)) > fetcher.maxBytes {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("%w: parca profile has %d bytes, limit is %d", pprofio.ErrProfileTooLarge, len(profile), fetcher.maxBytes)
+	}
+
+	if len(profile) == 0 {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("parca returned an empty profile")
+	}
+
+	return cpgo.FetchProfileResult{
+		Content:   profile,
+		SourceURL: sourceURL(queryURL, fetcher.query, fetcher.timeRange),
+	}, nil
+}
+
+// sourceURL records the query endpoint together with the query and range for provenance.
+func sourceURL(queryURL url.URL, query string, timeRange time.Duration) *url.URL {
+	values := url.Values{}
+	values.Set("query", query)
+	values.Set("range", timeRange.String())
+	queryURL.RawQuery = values.Encode()
+
+	return &queryURL
+}
+
+func withDefaultTimeout(httpClient *http.Client) *http.Client {
+	if httpClient == nil {
+		return &http.Client{
+			Timeout: defaultHTTPClientTimeout,
+		}
+	}
+
+	httpClientCopy := *httpClient
+	if httpClientCopy.Timeout <= 0 {
+		httpClientCopy.Timeout = defaultHTTPClientTimeout
+	}
+
+	return &httpClientCopy
+}
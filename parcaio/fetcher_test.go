@@ -0,0 +1,123 @@
+package parcaio
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"cpgo"
+	"cpgo/pprofio"
+)
+
+func TestFetcherFetchCPUProfile(t *testing.T) {
+	t.Run("queries merged pprof report over configured range", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			if req.Method != http.MethodPost {
+				t.Fatalf("expected POST request, got %s", req.Method)
+			}
+
+			if req.URL.Path != "/parca.query.v1alpha1.QueryService/Query" {
+				t.Fatalf("unexpected path: %s", req.URL.Path)
+			}
+
+			if req.Header.Get("Authorization") != "Bearer token" {
+				t.Fatalf("expected bearer token")
+			}
+
+			var payload queryRequest
+			if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode query: %v", err)
+			}
+
+			if payload.Mode != "MODE_MERGE" || payload.ReportType != "REPORT_TYPE_PPROF" {
+				t.Fatalf("unexpected query mode or report type: %+v", payload)
+			}
+
+			if payload.Merge.Query != `parca_agent:samples:count:cpu:nanoseconds:delta{job="payments"}` {
+				t.Fatalf("unexpected query: %s", payload.Merge.Query)
+			}
+
+			if payload.Merge.Start != "2024-06-01T11:00:00Z" || payload.Merge.End != "2024-06-01T12:00:00Z" {
+				t.Fatalf("unexpected range: %s - %s", payload.Merge.Start, payload.Merge.End)
+			}
+
+			_ = json.NewEncoder(resp).Encode(queryResponse{
+				Pprof: base64.StdEncoding.EncodeToString([]byte("profile-bytes")),
+			})
+		}))
+		t.Cleanup(server.Close)
+
+		fetcher, err := NewFetcher(Options{
+			Query:       `parca_agent:samples:count:cpu:nanoseconds:delta{job="payments"}`,
+			Range:       time.Hour,
+			BearerToken: "token",
+			HTTPClient:  server.Client(),
+		})
+		if err != nil {
+			t.Fatalf("new fetcher: %v", err)
+		}
+		fetcher.now = func() time.Time {
+			return time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+		}
+
+		serverURL, err := url.Parse(server.URL)
+		if err != nil {
+			t.Fatalf("parse server url: %v", err)
+		}
+
+		result, err := fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{
+			URL: serverURL,
+		})
+		if err != nil {
+			t.Fatalf("fetch profile: %v", err)
+		}
+
+		if string(result.Content) != "profile-bytes" {
+			t.Fatalf("expected profile bytes, got %q", string(result.Content))
+		}
+
+		if result.SourceURL.Query().Get("range") != "1h0m0s" {
+			t.Fatalf("expected range in source url, got %s", result.SourceURL)
+		}
+	})
+
+	t.Run("rejects profiles larger than max bytes", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			_ = json.NewEncoder(resp).Encode(queryResponse{
+				Pprof: base64.StdEncoding.EncodeToString([]byte("profile-bytes")),
+			})
+		}))
+		t.Cleanup(server.Close)
+
+		fetcher, err := NewFetcher(Options{
+			Query:      `parca_agent:samples:count:cpu:nanoseconds:delta{job="payments"}`,
+			HTTPClient: server.Client(),
+			MaxBytes:   8,
+		})
+		if err != nil {
+			t.Fatalf("new fetcher: %v", err)
+		}
+
+		serverURL, err := url.Parse(server.URL)
+		if err != nil {
+			t.Fatalf("parse server url: %v", err)
+		}
+
+		_, err = fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{URL: serverURL})
+		if !errors.Is(err, pprofio.ErrProfileTooLarge) {
+			t.Fatalf("expected too large error, got %v", err)
+		}
+	})
+
+	t.Run("requires a query", func(t *testing.T) {
+		if _, err := NewFetcher(Options{}); err == nil {
+			t.Fatalf("expected missing query error")
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package cpgo
+
+import "context"
+
+// PlanResult describes the branch and pull request state a run would act on.
+type PlanResult struct {
+	BaseBranch        string
+	HeadBranch        string
+	PullRequestNumber int
+	PullRequestURL    string
+	HasPullRequest    bool
+	IsManaged         bool
+}
+
+// Plan resolves branches and the existing pull request without fetching or writing anything.
+func (svc *Service) Plan(ctx context.Context, req RunRequest) (PlanResult, error) {
+	normalized, err := req.normalized()
+	if err != nil {
+		return PlanResult{}, err
+	}
+
+	repository := RepositoryRef{
+		Owner: normalized.Repository.Owner,
+		Name:  normalized.Repository.Name,
+	}
+
+	baseBranch, err := svc.resolveBaseBranch(ctx, repository, normalized.Repository.BaseBranch)
+	if err != nil {
+		return PlanResult{}, err
+	}
+
+	headBranch := normalized.Repository.HeadBranch
+	var openPR *PullRequest
+	if normalized.Repository.CommitToBase {
+		// Runs commit straight to the base branch and never open a pull request.
+		headBranch = baseBranch
+	} else if normalized.Repository.BranchPerRun {
+		// Each run pushes a fresh branch, so no pull request exists for it yet.
+		headBranch = runHeadBranch(normalized.Repository.HeadBranch, svc.clock.Now())
+	} else {
+		headBranch, openPR, err = svc.findHeadPullRequest(ctx, normalized, repository, baseBranch)
+		if err != nil {
+			return PlanResult{}, err
+		}
+	}
+
+	result := PlanResult{
+		BaseBranch: baseBranch,
+		HeadBranch: headBranch,
+	}
+
+	if openPR != nil {
+		result.PullRequestNumber = openPR.Number
+		result.PullRequestURL = openPR.URL
+		result.HasPullRequest = true
+		result.IsManaged = normalized.PullRequest.isManaged(openPR.Body)
+	}
+
+	return result, nil
+}
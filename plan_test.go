@@ -0,0 +1,104 @@
+package cpgo
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestServicePlan(t *testing.T) {
+	t.Run("resolves default branch and reports managed pull request", func(t *testing.T) {
+		branchWriter := &branchWriterStub{
+			defaultBranch: "main",
+		}
+
+		pullRequests := &pullRequestServiceStub{
+			findResult: &PullRequest{
+				Number: 5,
+				Body:   "Automated PGO profile refresh.\n\n<!-- managed-by:cpgo -->",
+				URL:    "https://github.com/acme/payments/pull/5",
+			},
+		}
+
+		fetcher := &profileFetcherStub{profile: []byte("cpu")}
+		service := mustNewService(t, fetcher, &profileValidatorStub{}, branchWriter, pullRequests)
+
+		plan, err := service.Plan(context.Background(), newRunRequest(t))
+		if err != nil {
+			t.Fatalf("plan failed: %v", err)
+		}
+
+		if plan.BaseBranch != "main" || plan.HeadBranch != "cpgo" {
+			t.Fatalf("unexpected branches: %+v", plan)
+		}
+
+		if !plan.HasPullRequest || !plan.IsManaged || plan.PullRequestNumber != 5 {
+			t.Fatalf("expected managed pull request 5, got %+v", plan)
+		}
+
+		if branchWriter.hasUpsertCall {
+			t.Fatalf("expected plan to perform no writes")
+		}
+	})
+
+	t.Run("plans commits to the base branch without a pull request", func(t *testing.T) {
+		pullRequests := &pullRequestServiceStub{findResult: &PullRequest{Number: 5}}
+		service := mustNewService(t, &profileFetcherStub{}, &profileValidatorStub{}, &branchWriterStub{defaultBranch: "main"}, pullRequests)
+
+		req := newRunRequest(t)
+		req.Repository.CommitToBase = true
+		plan, err := service.Plan(context.Background(), req)
+		if err != nil {
+			t.Fatalf("plan failed: %v", err)
+		}
+
+		if plan.BaseBranch != "main" || plan.HeadBranch != "main" || plan.HasPullRequest {
+			t.Fatalf("expected a commit to main without a pull request, got %+v", plan)
+		}
+	})
+
+	t.Run("plans a fresh branch per run", func(t *testing.T) {
+		pullRequests := &pullRequestServiceStub{findResult: &PullRequest{Number: 5}}
+		service, err := NewService(Dependencies{
+			ProfileFetcher:   &profileFetcherStub{},
+			ProfileValidator: &profileValidatorStub{},
+			BranchWriter:     &branchWriterStub{defaultBranch: "main"},
+			PullRequests:     pullRequests,
+			Clock:            clockStub{now: time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)},
+		})
+		if err != nil {
+			t.Fatalf("failed to create service: %v", err)
+		}
+
+		req := newRunRequest(t)
+		req.Repository.BranchPerRun = true
+		plan, err := service.Plan(context.Background(), req)
+		if err != nil {
+			t.Fatalf("plan failed: %v", err)
+		}
+
+		if plan.HeadBranch != "cpgo/20240501-120000" || plan.HasPullRequest {
+			t.Fatalf("expected a fresh run branch without a pull request, got %+v", plan)
+		}
+	})
+
+	t.Run("reports unmanaged pull request without failing", func(t *testing.T) {
+		pullRequests := &pullRequestServiceStub{
+			findResult: &PullRequest{
+				Number: 6,
+				Body:   "manual pull request",
+			},
+		}
+
+		service := mustNewService(t, &profileFetcherStub{}, &profileValidatorStub{}, &branchWriterStub{defaultBranch: "main"}, pullRequests)
+
+		plan, err := service.Plan(context.Background(), newRunRequest(t))
+		if err != nil {
+			t.Fatalf("plan failed: %v", err)
+		}
+
+		if !plan.HasPullRequest || plan.IsManaged {
+			t.Fatalf("expected unmanaged pull request, got %+v", plan)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package pprofio
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/google/pprof/profile"
+
+	"cpgo"
+)
+
+// BaseMerger merges a fetched profile into the profile already committed on the base
+// branch, so the committed profile accumulates signal across refreshes.
+type BaseMerger struct {
+	decay float64
+}
+
+var _ cpgo.ProfileMerger = (*BaseMerger)(nil)
+
+// NewBaseMerger returns a pprof profile merger that scales the base profile's sample
+// values by decay before merging, so recent behavior dominates while old hot paths
+// fade over several refreshes; a decay of 1 merges without scaling.
+func NewBaseMerger(decay float64) (*BaseMerger, error) {
+	if !(decay > 0 && decay <= 1) {
+		return nil, fmt.Errorf("profile decay must be greater than 0 and at most 1, got %g", decay)
+	}
+
+	return &BaseMerger{decay: decay}, nil
+}
+
+// MergeProfiles parses both profiles and encodes their merge.
+// Profiles with different sample types cannot be merged.
+func (merger *BaseMerger) MergeProfiles(base []byte, current []byte) ([]byte, error) {
+	parsedBase, err := profile.ParseData(base)
+	if err != nil {
+		return nil, fmt.Errorf("parse base profile: %w", err)
+	}
+
+	// Scale drops samples whose values all round to zero, so decayed history cannot grow without bound.
+	parsedBase.Scale(merger.decay)
+
+	parsedCurrent, err := profile.ParseData(current)
+	if err != nil {
+		return nil, fmt.Errorf("parse profile: %w", err)
+	}
+
+	merged, err := profile.Merge([]*profile.Profile{parsedBase, parsedCurrent})
+	if err != nil {
+		return nil, fmt.Errorf("merge profiles: %w", err)
+	}
+
+	var raw bytes.Buffer
+	if err := merged.Write(&raw); err != nil {
+		return nil, fmt.Errorf("encode merged profile: %w", err)
+	}
+
+	return raw.Bytes(), nil
+}
@@ -0,0 +1,82 @@
+package pprofio
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+func TestBaseMergerMergeProfiles(t *testing.T) {
+	t.Run("adds the samples of both profiles", func(t *testing.T) {
+		base := writeFunctionProfile(t, map[string]int64{"main.hot": 3, "main.old": 2})
+		current := writeFunctionProfile(t, map[string]int64{"main.hot": 5})
+
+		merged, err := mustNewBaseMerger(t, 1).MergeProfiles(base, current)
+		if err != nil {
+			t.Fatalf("merge profiles: %v", err)
+		}
+
+		flat := flatSamples(t, merged)
+		if flat["main.hot"] != 8 || flat["main.old"] != 2 {
+			t.Fatalf("expected main.hot=8 and main.old=2, got %v", flat)
+		}
+	})
+
+	t.Run("decays the base profile before merging", func(t *testing.T) {
+		base := writeFunctionProfile(t, map[string]int64{"main.hot": 4, "main.old": 2})
+		current := writeFunctionProfile(t, map[string]int64{"main.hot": 5})
+
+		merged, err := mustNewBaseMerger(t, 0.4).MergeProfiles(base, current)
+		if err != nil {
+			t.Fatalf("merge profiles: %v", err)
+		}
+
+		flat := flatSamples(t, merged)
+		if flat["main.hot"] != 7 || flat["main.old"] != 1 {
+			t.Fatalf("expected main.hot=7 and main.old=1, got %v", flat)
+		}
+	})
+
+	t.Run("rejects decay outside (0, 1]", func(t *testing.T) {
+		for _, decay := range []float64{0, -0.5, 1.5, math.NaN()} {
+			if _, err := NewBaseMerger(decay); err == nil {
+				t.Fatalf("expected decay %g to be rejected", decay)
+			}
+		}
+	})
+
+	t.Run("rejects an undecodable base profile", func(t *testing.T) {
+		if _, err := mustNewBaseMerger(t, 1).MergeProfiles([]byte("not a profile"), writeFunctionProfile(t, map[string]int64{"main.hot": 1})); err == nil {
+			t.Fatalf("expected parse error")
+		}
+	})
+}
+
+func mustNewBaseMerger(t *testing.T, decay float64) *BaseMerger {
+	t.Helper()
+
+	merger, err := NewBaseMerger(decay)
+	if err != nil {
+		t.Fatalf("new base merger: %v", err)
+	}
+
+	return merger
+}
+
+// flatSamples sums the first sample value by leaf function.
+func flatSamples(t *testing.T, raw []byte) map[string]int64 {
+	t.Helper()
+
+	parsed, err := profile.ParseData(raw)
+	if err != nil {
+		t.Fatalf("parse profile: %v", err)
+	}
+
+	flat := make(map[string]int64)
+	for _, sample := range parsed.Sample {
+		flat[sample.Location[0].Line[0].Function.Name] += sample.Value[0]
+	}
+
+	return flat
+}
@@ -0,0 +1,131 @@
+package pprofio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"cpgo"
+)
+
+const (
+	defaultCommandTimeout = time.Minute
+	// commandWaitDelay bounds how long a killed command's children may hold its output open.
+	commandWaitDelay = time.Second
+)
+
+// CommandValidatorOptions configures an external validation command.
+type CommandValidatorOptions struct {
+	Command string
+	Timeout time.Duration
+}
+
+// CommandValidator enforces custom profile policies by piping the profile to a shell command.
+type CommandValidator struct {
+	command string
+	timeout time.Duration
+}
+
+var _ cpgo.ContextValidator = (*CommandValidator)(nil)
+
+// NewCommandValidator returns a validator that runs options.Command through sh -c.
+func NewCommandValidator(options CommandValidatorOptions) (*CommandValidator, error) {
+	command := strings.TrimSpace(options.Command)
+	if command == "" {
+		return nil, fmt.Errorf("validation command is required")
+	}
+
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = defaultCommandTimeout
+	}
+
+	return &CommandValidator{
+		command: command,
+		timeout: timeout,
+	}, nil
+}
+
+// ValidateCPUProfile fails when the command exits nonzero, reporting its stderr.
+func (validator *CommandValidator) ValidateCPUProfile(raw []byte) error {
+	return validator.ValidateCPUProfileContext(context.Background(), raw, cpgo.ProfileExpectations{})
+}
+
+// ValidateCPUProfileContext runs the command like ValidateCPUProfile, killing it when ctx ends.
+func (validator *CommandValidator) ValidateCPUProfileContext(parent context.Context, raw []byte, _ cpgo.ProfileExpectations) error {
+	ctx, cancel := context.WithTimeout(parent, validator.timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", validator.command)
+	cmd.Stdin = bytes.NewReader(raw)
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = commandWaitDelay
+
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+
+		if err := parent.Err(); err != nil {
+			return fmt.Errorf("validation command stopped: %w", err)
+		}
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("validation command timed out after %s", validator.timeout)
+		}
+
+		return fmt.Errorf("validation command rejected profile: %s", message)
+	}
+
+	return nil
+}
+
+// ChainValidators runs validators in order and returns the first failure.
+func ChainValidators(validators ...cpgo.ProfileValidator) cpgo.ProfileValidator {
+	return validatorChain(validators)
+}
+
+type validatorChain []cpgo.ProfileValidator
+
+// ValidateCPUProfile applies each validator in order.
+func (chain validatorChain) ValidateCPUProfile(raw []byte) error {
+	for _, validator := range chain {
+		if err := validator.ValidateCPUProfile(raw); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateCPUProfileWithExpectations applies each validator in order, passing expectations to those that accept them.
+func (chain validatorChain) ValidateCPUProfileWithExpectations(raw []byte, expectations cpgo.ProfileExpectations) error {
+	return chain.ValidateCPUProfileContext(context.Background(), raw, expectations)
+}
+
+// ValidateCPUProfileContext applies each validator in order, passing ctx and expectations to those that accept them.
+func (chain validatorChain) ValidateCPUProfileContext(ctx context.Context, raw []byte, expectations cpgo.ProfileExpectations) error {
+	for _, validator := range chain {
+		var err error
+		switch typed := validator.(type) {
+		case cpgo.ContextValidator:
+			err = typed.ValidateCPUProfileContext(ctx, raw, expectations)
+		case cpgo.ExpectationValidator:
+			err = typed.ValidateCPUProfileWithExpectations(raw, expectations)
+		default:
+			err = validator.ValidateCPUProfile(raw)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
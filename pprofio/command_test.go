@@ -0,0 +1,72 @@
+package pprofio
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"cpgo"
+)
+
+func TestCommandValidatorValidateCPUProfile(t *testing.T) {
+	t.Run("pipes the profile to the command", func(t *testing.T) {
+		validator, err := NewCommandValidator(CommandValidatorOptions{
+			Command: `test "$(cat)" = "profile-bytes"`,
+		})
+		if err != nil {
+			t.Fatalf("new command validator: %v", err)
+		}
+
+		if err := validator.ValidateCPUProfile([]byte("profile-bytes")); err != nil {
+			t.Fatalf("validate profile: %v", err)
+		}
+	})
+
+	t.Run("reports stderr on nonzero exit", func(t *testing.T) {
+		validator, err := NewCommandValidator(CommandValidatorOptions{
+			Command: "echo 'profile lacks service symbols' >&2; exit 1",
+		})
+		if err != nil {
+			t.Fatalf("new command validator: %v", err)
+		}
+
+		err = validator.ValidateCPUProfile([]byte("profile-bytes"))
+		if err == nil || !strings.Contains(err.Error(), "profile lacks service symbols") {
+			t.Fatalf("expected stderr in validation error, got %v", err)
+		}
+	})
+
+	t.Run("stops the command when the run is cancelled", func(t *testing.T) {
+		validator, err := NewCommandValidator(CommandValidatorOptions{Command: "sleep 30"})
+		if err != nil {
+			t.Fatalf("new command validator: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		start := time.Now()
+		err = validator.ValidateCPUProfileContext(ctx, []byte("profile-bytes"), cpgo.ProfileExpectations{})
+		if !errors.Is(err, context.Canceled) || time.Since(start) > 10*time.Second {
+			t.Fatalf("expected the command to stop on cancellation, got %v", err)
+		}
+	})
+}
+
+func TestChainValidators(t *testing.T) {
+	failing, err := NewCommandValidator(CommandValidatorOptions{Command: "exit 1"})
+	if err != nil {
+		t.Fatalf("new command validator: %v", err)
+	}
+
+	chain := ChainValidators(NewValidator(), failing)
+	if err := chain.ValidateCPUProfile(writeProfile(t, "")); err == nil {
+		t.Fatalf("expected chained command validator to fail")
+	}
+
+	if err := chain.ValidateCPUProfile(nil); err == nil || !strings.Contains(err.Error(), "empty") {
+		t.Fatalf("expected built-in validator to run first, got %v", err)
+	}
+}
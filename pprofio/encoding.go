@@ -0,0 +1,156 @@
+package pprofio
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ErrTruncatedDownload reports a profile response that ended before its declared length.
+// Unlike a malformed profile, a truncated download may succeed when retried.
+var ErrTruncatedDownload = errors.New("truncated profile download")
+
+// ErrProfileTooLarge reports a profile response exceeding the configured size limit.
+var ErrProfileTooLarge = errors.New("profile response too large")
+
+// ErrUnexpectedContentType reports a response that is a web page rather than a profile,
+// typically an auth proxy's login page or a wrong profile URL.
+var ErrUnexpectedContentType = errors.New("profile endpoint returned a web page instead of a profile")
+
+// DefaultMaxProfileBytes bounds the decoded size of a fetched profile when no limit is configured.
+const DefaultMaxProfileBytes int64 = 64 << 20
+
+// readProfileBody reads a profile response of at most maxBytes decoded bytes, undoing any
+// Content-Encoding. The transport only decompresses responses to requests whose
+// Accept-Encoding it set itself, so explicitly configured headers leave the body encoded.
+func readProfileBody(resp *http.Response, maxBytes int64) ([]byte, error) {
+	if err := checkProfileContentType(resp); err != nil {
+		return nil, err
+	}
+
+	if resp.ContentLength > maxBytes {
+		return nil, fmt.Errorf("%w: response declares %d bytes, limit is %d", ErrProfileTooLarge, resp.ContentLength, maxBytes)
+	}
+
+	counter := &countingReader{reader: resp.Body}
+	body, err := decodedBody(counter, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, truncationError(resp, counter.count, err)
+	}
+	defer func() { _ = body.Close() }()
+
+	// Reading one byte past the limit tells an oversized body from one ending exactly at it.
+	profile, err := io.ReadAll(io.LimitReader(body, maxBytes+1))
+	if err != nil {
+		return nil, truncationError(resp, counter.count, fmt.Errorf("read profile response: %w", err))
+	}
+
+	if int64(len(profile)) > maxBytes {
+		return nil, fmt.Errorf("%w: exceeded %d bytes after receiving %d bytes", ErrProfileTooLarge, maxBytes, counter.count)
+	}
+
+	if resp.ContentLength > 0 && counter.count < resp.ContentLength {
+		return nil, fmt.Errorf("%w: received %d of %d bytes", ErrTruncatedDownload, counter.count, resp.ContentLength)
+	}
+
+	if len(profile) == 0 {
+		return nil, fmt.Errorf("profile response is empty")
+	}
+
+	return profile, nil
+}
+
+// checkProfileContentType rejects HTML responses. Every other content type passes, including
+// text formats a profile.transform may convert and the types servers that leave it unset get sniffed.
+func checkProfileContentType(resp *http.Response) error {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil
+	}
+
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil
+	}
+
+	source := "the profile url"
+	if resp.Request != nil && resp.Request.URL != nil {
+		// The final URL shows where redirects ended; its query may carry credentials.
+		source = resp.Request.URL.Scheme + "://" + resp.Request.URL.Host + resp.Request.URL.Path
+	}
+
+	return fmt.Errorf("%w: got %s from %s; check the profile url and its credentials, since an auth proxy may have redirected to a login page",
+		ErrUnexpectedContentType, mediaType, source)
+}
+
+// truncationError marks err as a truncated download when the body ended early.
+func truncationError(resp *http.Response, received int64, err error) error {
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+
+	if resp.ContentLength > 0 {
+		return fmt.Errorf("%w: received %d of %d bytes: %w", ErrTruncatedDownload, received, resp.ContentLength, err)
+	}
+
+	return fmt.Errorf("%w: received %d bytes: %w", ErrTruncatedDownload, received, err)
+}
+
+// countingReader counts the raw bytes read from a response body.
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (reader *countingReader) Read(p []byte) (int, error) {
+	n, err := reader.reader.Read(p)
+	reader.count += int64(n)
+
+	return n, err
+}
+
+// decodedBody wraps body in a decompressor for the given Content-Encoding.
+func decodedBody(body io.Reader, contentEncoding string) (io.ReadCloser, error) {
+	switch encoding := strings.ToLower(strings.TrimSpace(contentEncoding)); encoding {
+	case "", "identity":
+		return io.NopCloser(body), nil
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("decode gzip profile response: %w", err)
+		}
+
+		return reader, nil
+	case "deflate":
+		// HTTP deflate is zlib-wrapped, but some servers send raw deflate streams.
+		buffered := bufio.NewReader(body)
+		header, err := buffered.Peek(2)
+		if err != nil {
+			return nil, fmt.Errorf("decode deflate profile response: %w", err)
+		}
+
+		if isZlibHeader(header) {
+			reader, err := zlib.NewReader(buffered)
+			if err != nil {
+				return nil, fmt.Errorf("decode deflate profile response: %w", err)
+			}
+
+			return reader, nil
+		}
+
+		return flate.NewReader(buffered), nil
+	default:
+		return nil, fmt.Errorf("unsupported profile response content encoding %q", contentEncoding)
+	}
+}
+
+// isZlibHeader reports whether two bytes form a valid zlib stream header.
+func isZlibHeader(header []byte) bool {
+	return header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
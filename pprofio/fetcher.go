@@ -1,9 +1,13 @@
 package pprofio
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand/v2"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -13,11 +17,48 @@ import (
 	"cpgo"
 )
 
-const defaultHTTPClientTimeout = 45 * time.Second
+const (
+	defaultHTTPClientTimeout = 45 * time.Second
+	defaultRetryBaseDelay    = time.Second
+	defaultRetryMaxDelay     = 30 * time.Second
+	pprofIndexPath           = "/debug/pprof"
+	secondsPlaceholder       = "{seconds}"
+)
+
+// ErrRequestTimeout reports a profile request that exceeded the HTTP client timeout
+// while the caller's context was still live. Unlike a cancelled or expired context,
+// a timed out request may succeed when retried.
+var ErrRequestTimeout = errors.New("profile request timed out")
+
+// pprofEndpoints maps profile types to their net/http/pprof endpoint names.
+var pprofEndpoints = map[cpgo.ProfileType]string{
+	cpgo.ProfileTypeCPU:   "profile",
+	cpgo.ProfileTypeHeap:  "heap",
+	cpgo.ProfileTypeMutex: "mutex",
+	cpgo.ProfileTypeBlock: "block",
+}
+
+// RetrySettings controls how transient fetch failures are retried.
+// Connection errors, truncated downloads, 429 and 5xx responses are retried with exponential
+// backoff and jitter; a MaxAttempts of one or less disables retries.
+type RetrySettings struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// FetcherOptions configures retries and the response size limit of a Fetcher.
+// A zero MaxBytes applies DefaultMaxProfileBytes.
+type FetcherOptions struct {
+	Retry    RetrySettings
+	MaxBytes int64
+}
 
 // Fetcher collects CPU profiles from remote pprof HTTP endpoints.
 type Fetcher struct {
 	httpClient *http.Client
+	retry      RetrySettings
+	maxBytes   int64
 }
 
 var _ cpgo.ProfileFetcher = (*Fetcher)(nil)
@@ -26,59 +67,249 @@ var _ cpgo.ProfileFetcher = (*Fetcher)(nil)
 func NewFetcher(httpClient *http.Client) *Fetcher {
 	return &Fetcher{
 		httpClient: withDefaultTimeout(httpClient),
+		retry:      RetrySettings{MaxAttempts: 1},
+		maxBytes:   DefaultMaxProfileBytes,
 	}
 }
 
-// FetchCPUProfile requests a single CPU profile sample window.
-func (fetcher *Fetcher) FetchCPUProfile(ctx context.Context, req cpgo.FetchProfileRequest) ([]byte, error) {
-	if req.URL == nil {
-		return nil, fmt.Errorf("profile url is required")
+// NewFetcherWithRetry returns a fetcher that retries transient failures.
+func NewFetcherWithRetry(httpClient *http.Client, retry RetrySettings) (*Fetcher, error) {
+	return NewFetcherWithOptions(httpClient, FetcherOptions{Retry: retry})
+}
+
+// NewFetcherWithOptions returns a fetcher with the given retry and size limit settings.
+func NewFetcherWithOptions(httpClient *http.Client, options FetcherOptions) (*Fetcher, error) {
+	retry := options.Retry
+	if retry.MaxAttempts < 0 {
+		return nil, fmt.Errorf("profile retry max attempts must not be negative")
 	}
 
-	if req.Seconds <= 0 {
-		return nil, fmt.Errorf("profile seconds must be positive")
+	if retry.BaseDelay < 0 || retry.MaxDelay < 0 {
+		return nil, fmt.Errorf("profile retry delays must not be negative")
 	}
 
-	profileURL := withProfileSeconds(*req.URL, req.Seconds)
+	if retry.MaxAttempts == 0 {
+		retry.MaxAttempts = 1
+	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, profileURL.String(), nil)
+	if retry.BaseDelay == 0 {
+		retry.BaseDelay = defaultRetryBaseDelay
+	}
+
+	if retry.MaxDelay == 0 {
+		retry.MaxDelay = defaultRetryMaxDelay
+	}
+
+	if retry.MaxDelay < retry.BaseDelay {
+		return nil, fmt.Errorf("profile retry max delay must be at least the base delay")
+	}
+
+	maxBytes, err := maxBytesOrDefault(options.MaxBytes)
 	if err != nil {
-		return nil, fmt.Errorf("build profile request: %w", err)
+		return nil, err
 	}
 
-	for key, value := range req.Headers {
-		if strings.TrimSpace(key) == "" {
-			continue
+	return &Fetcher{
+		httpClient: withDefaultTimeout(httpClient),
+		retry:      retry,
+		maxBytes:   maxBytes,
+	}, nil
+}
+
+// maxBytesOrDefault applies DefaultMaxProfileBytes to an unset size limit.
+func maxBytesOrDefault(maxBytes int64) (int64, error) {
+	switch {
+	case maxBytes < 0:
+		return 0, fmt.Errorf("profile max bytes must not be negative")
+	case maxBytes == 0:
+		return DefaultMaxProfileBytes, nil
+	default:
+		return maxBytes, nil
+	}
+}
+
+// FetchCPUProfile requests a single CPU profile sample window, or an instantaneous profile when seconds is zero.
+// A URL pointing at the /debug/pprof index is completed with the endpoint for the requested profile type.
+func (fetcher *Fetcher) FetchCPUProfile(ctx context.Context, req cpgo.FetchProfileRequest) (cpgo.FetchProfileResult, error) {
+	if req.URL == nil {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("profile url is required")
+	}
+
+	if req.Seconds < 0 {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("profile seconds must not be negative")
+	}
+
+	profileURL, err := withProfileEndpoint(*req.URL, req.ProfileType)
+	if err != nil {
+		return cpgo.FetchProfileResult{}, err
+	}
+
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	body := req.Body
+	if req.Seconds > 0 {
+		if bytes.Contains(body, []byte(secondsPlaceholder)) {
+			body = bytes.ReplaceAll(body, []byte(secondsPlaceholder), []byte(strconv.Itoa(req.Seconds)))
+		} else {
+			profileURL = withProfileSeconds(profileURL, req.Seconds)
 		}
+	}
 
-		httpReq.Header.Set(key, value)
+	var attempt int
+	for {
+		attempt++
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return cpgo.FetchProfileResult{}, fmt.Errorf("fetch profile: %w", ctxErr)
+		}
+
+		profile, err := fetcher.fetchOnce(ctx, method, profileURL.String(), req.Headers, body)
+		if err == nil {
+			return cpgo.FetchProfileResult{
+				Content:   profile,
+				SourceURL: &profileURL,
+			}, nil
+		}
+
+		var transient *transientError
+		isTransient := errors.As(err, &transient)
+		if !isTransient || attempt >= fetcher.retry.MaxAttempts {
+			return cpgo.FetchProfileResult{}, fetcher.attemptsError(attempt, err)
+		}
+
+		if waitErr := sleepContext(ctx, backoffDelay(fetcher.retry, attempt)); waitErr != nil {
+			return cpgo.FetchProfileResult{}, fetcher.attemptsError(attempt, errors.Join(err, waitErr))
+		}
+	}
+}
+
+// fetchOnce performs one profile request, marking failures worth retrying as transient.
+func (fetcher *Fetcher) fetchOnce(ctx context.Context, method string, target string, headers map[string]string, body []byte) ([]byte, error) {
+	httpReq, err := newProfileRequest(ctx, method, target, headers, body)
+	if err != nil {
+		return nil, err
 	}
 
 	resp, err := fetcher.httpClient.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("fetch profile: %w", err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("fetch profile: %w", ctxErr)
+		}
+
+		// The client's own timeout also matches context.DeadlineExceeded, so it is
+		// reported through ErrRequestTimeout without wrapping the transport error.
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, &transientError{err: fmt.Errorf("fetch profile: %w: %v", ErrRequestTimeout, err)}
+		}
+
+		return nil, &transientError{err: fmt.Errorf("fetch profile: %w", err)}
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		preview, readErr := io.ReadAll(io.LimitReader(resp.Body, 4*1024))
-		if readErr != nil {
-			return nil, fmt.Errorf("fetch profile: unexpected status %s", resp.Status)
+		statusErr := unexpectedStatus("fetch profile", resp)
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+			return nil, &transientError{err: statusErr}
 		}
 
-		return nil, fmt.Errorf("fetch profile: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(preview)))
+		return nil, statusErr
+	}
+
+	profile, err := readProfileBody(resp, fetcher.maxBytes)
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return nil, fmt.Errorf("read profile: %w", ctxErr)
+	}
+
+	if errors.Is(err, ErrTruncatedDownload) {
+		return nil, &transientError{err: err}
+	}
+
+	return profile, err
+}
+
+// attemptsError annotates err with the attempt count when retries are enabled.
+func (fetcher *Fetcher) attemptsError(attempts int, err error) error {
+	if fetcher.retry.MaxAttempts <= 1 {
+		return err
+	}
+
+	return fmt.Errorf("profile fetch failed after %d attempt(s): %w", attempts, err)
+}
+
+// transientError marks a fetch failure that may succeed when retried.
+type transientError struct {
+	err error
+}
+
+func (err *transientError) Error() string {
+	return err.err.Error()
+}
+
+func (err *transientError) Unwrap() error {
+	return err.err
+}
+
+// backoffDelay returns the jittered exponential delay before the next attempt.
+func backoffDelay(retry RetrySettings, attempt int) time.Duration {
+	delay := retry.BaseDelay
+	for retried := 1; retried < attempt && delay < retry.MaxDelay; retried++ {
+		delay *= 2
+	}
+	delay = min(delay, retry.MaxDelay)
+
+	// Equal jitter keeps at least half the delay so retries still back off.
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+// sleepContext waits for the delay or until the context is done.
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// newProfileRequest builds a request carrying the configured headers and an optional body.
+func newProfileRequest(ctx context.Context, method string, target string, headers map[string]string, body []byte) (*http.Request, error) {
+	var reader io.Reader
+	if len(body) > 0 {
+		reader = bytes.NewReader(body)
 	}
 
-	profile, err := io.ReadAll(resp.Body)
+	httpReq, err := http.NewRequestWithContext(ctx, method, target, reader)
 	if err != nil {
-		return nil, fmt.Errorf("read profile response: %w", err)
+		return nil, fmt.Errorf("build profile request: %w", err)
 	}
 
-	if len(profile) == 0 {
-		return nil, fmt.Errorf("profile response is empty")
+	for key, value := range headers {
+		if strings.TrimSpace(key) == "" {
+			continue
+		}
+
+		httpReq.Header.Set(key, value)
 	}
 
-	return profile, nil
+	return httpReq, nil
+}
+
+// unexpectedStatus reports a non-success response with a short body preview.
+func unexpectedStatus(operation string, resp *http.Response) error {
+	preview, readErr := io.ReadAll(io.LimitReader(resp.Body, 4*1024))
+	if readErr != nil {
+		return fmt.Errorf("%s: unexpected status %s", operation, resp.Status)
+	}
+
+	return fmt.Errorf("%s: unexpected status %s: %s", operation, resp.Status, strings.TrimSpace(string(preview)))
 }
 
 func withDefaultTimeout(httpClient *http.Client) *http.Client {
@@ -96,6 +327,26 @@ func withDefaultTimeout(httpClient *http.Client) *http.Client {
 	return &httpClientCopy
 }
 
+// withProfileEndpoint appends the profile type's endpoint to a /debug/pprof index URL and leaves other URLs alone.
+func withProfileEndpoint(baseURL url.URL, profileType cpgo.ProfileType) (url.URL, error) {
+	if profileType == "" {
+		profileType = cpgo.ProfileTypeCPU
+	}
+
+	endpoint, ok := pprofEndpoints[profileType]
+	if !ok {
+		return url.URL{}, fmt.Errorf("unsupported profile type %q", profileType)
+	}
+
+	if strings.HasSuffix(strings.TrimSuffix(baseURL.Path, "/"), pprofIndexPath) {
+		baseURL.Path = strings.TrimSuffix(baseURL.Path, "/") + "/" + endpoint
+	}
+
+	return baseURL, nil
+}
+
+// withProfileSeconds sets the pprof seconds query parameter; it only applies to HTTP endpoints,
+// since file and object store sources serve profiles that were already captured.
 func withProfileSeconds(baseURL url.URL, seconds int) url.URL {
 	query := baseURL.Query()
 	query.Set("seconds", strconv.Itoa(seconds))
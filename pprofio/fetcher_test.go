@@ -1,11 +1,19 @@
 package pprofio
 
 import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"cpgo"
 )
@@ -46,8 +54,299 @@ func TestFetcherFetchCPUProfile(t *testing.T) {
 			t.Fatalf("fetch profile: %v", err)
 		}
 
-		if string(profile) != "profile-bytes" {
-			t.Fatalf("expected profile bytes, got %q", string(profile))
+		if string(profile.Content) != "profile-bytes" {
+			t.Fatalf("expected profile bytes, got %q", string(profile.Content))
+		}
+
+		if profile.SourceURL.Query().Get("seconds") != "17" {
+			t.Fatalf("expected source url to carry seconds query, got %s", profile.SourceURL.String())
+		}
+	})
+
+	t.Run("rejects an html login page behind a redirect", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/debug/pprof/profile" {
+				http.Redirect(resp, req, "/login?next=profile", http.StatusFound)
+				return
+			}
+
+			resp.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = resp.Write([]byte("<!doctype html><title>Sign in</title>"))
+		}))
+		t.Cleanup(server.Close)
+
+		profileURL, err := url.Parse(server.URL + "/debug/pprof/profile")
+		if err != nil {
+			t.Fatalf("parse profile url: %v", err)
+		}
+
+		_, err = NewFetcher(server.Client()).FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{URL: profileURL, Seconds: 1})
+		if !errors.Is(err, ErrUnexpectedContentType) || !strings.Contains(err.Error(), "text/html from "+server.URL+"/login;") {
+			t.Fatalf("expected an html response error naming the login page, got %v", err)
+		}
+	})
+
+	t.Run("accepts missing, generic and text content types", func(t *testing.T) {
+		for _, contentType := range []string{"", "application/octet-stream", "text/plain; charset=utf-8", "text/csv"} {
+			server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, _ *http.Request) {
+				resp.Header()["Content-Type"] = []string{contentType}
+				_, _ = resp.Write([]byte("profile-bytes"))
+			}))
+			t.Cleanup(server.Close)
+
+			profileURL, err := url.Parse(server.URL + "/debug/pprof/profile")
+			if err != nil {
+				t.Fatalf("parse profile url: %v", err)
+			}
+
+			if _, err := NewFetcher(server.Client()).FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{URL: profileURL, Seconds: 1}); err != nil {
+				t.Fatalf("expected content type %q to be accepted, got %v", contentType, err)
+			}
+		}
+	})
+
+	t.Run("posts the configured body verbatim", func(t *testing.T) {
+		const body = `{"namespace":"payments","pod":"api-0"}`
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			if req.Method != http.MethodPost {
+				t.Fatalf("expected POST request, got %s", req.Method)
+			}
+
+			received, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatalf("read request body: %v", err)
+			}
+
+			if string(received) != body {
+				t.Fatalf("expected body %s, got %s", body, received)
+			}
+
+			if req.URL.Query().Get("seconds") != "17" {
+				t.Fatalf("expected seconds query without a body placeholder, got %s", req.URL.RawQuery)
+			}
+
+			_, _ = resp.Write([]byte("profile-bytes"))
+		}))
+		t.Cleanup(server.Close)
+
+		profileURL, err := url.Parse(server.URL + "/captures")
+		if err != nil {
+			t.Fatalf("parse profile url: %v", err)
+		}
+
+		_, err = NewFetcher(server.Client()).FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{
+			URL:     profileURL,
+			Seconds: 17,
+			Method:  http.MethodPost,
+			Body:    []byte(body),
+		})
+		if err != nil {
+			t.Fatalf("fetch profile: %v", err)
+		}
+	})
+
+	t.Run("carries seconds in the body placeholder", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			received, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatalf("read request body: %v", err)
+			}
+
+			if string(received) != `{"pod":"api-0","seconds":17}` {
+				t.Fatalf("expected seconds in the body, got %s", received)
+			}
+
+			if req.URL.Query().Has("seconds") {
+				t.Fatalf("expected no seconds query, got %s", req.URL.RawQuery)
+			}
+
+			_, _ = resp.Write([]byte("profile-bytes"))
+		}))
+		t.Cleanup(server.Close)
+
+		profileURL, err := url.Parse(server.URL + "/captures")
+		if err != nil {
+			t.Fatalf("parse profile url: %v", err)
+		}
+
+		_, err = NewFetcher(server.Client()).FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{
+			URL:     profileURL,
+			Seconds: 17,
+			Method:  http.MethodPost,
+			Body:    []byte(`{"pod":"api-0","seconds":{seconds}}`),
+		})
+		if err != nil {
+			t.Fatalf("fetch profile: %v", err)
+		}
+	})
+
+	t.Run("decodes compressed responses", func(t *testing.T) {
+		rawProfile := writeProfile(t, "cpu")
+		encoders := map[string]func(io.Writer) io.WriteCloser{
+			"gzip":    func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+			"deflate": func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+		}
+
+		for encoding, newEncoder := range encoders {
+			server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+				resp.Header().Set("Content-Encoding", encoding)
+				encoder := newEncoder(resp)
+				_, _ = encoder.Write(rawProfile)
+				_ = encoder.Close()
+			}))
+			t.Cleanup(server.Close)
+
+			profileURL, err := url.Parse(server.URL + "/debug/pprof/profile")
+			if err != nil {
+				t.Fatalf("parse profile url: %v", err)
+			}
+
+			profile, err := NewFetcher(server.Client()).FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{
+				URL:     profileURL,
+				Seconds: 1,
+				Headers: map[string]string{
+					"Accept-Encoding": encoding,
+				},
+			})
+			if err != nil {
+				t.Fatalf("fetch %s profile: %v", encoding, err)
+			}
+
+			if !bytes.Equal(profile.Content, rawProfile) {
+				t.Fatalf("expected decoded %s profile bytes", encoding)
+			}
+
+			if err := NewValidator().ValidateCPUProfile(profile.Content); err != nil {
+				t.Fatalf("validate decoded %s profile: %v", encoding, err)
+			}
+		}
+	})
+
+	t.Run("reports truncated downloads as retryable", func(t *testing.T) {
+		var requests int
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			requests++
+			if requests == 1 {
+				resp.Header().Set("Content-Length", "100")
+				_, _ = resp.Write([]byte("partial"))
+				return
+			}
+
+			_, _ = resp.Write([]byte("profile-bytes"))
+		}))
+		t.Cleanup(server.Close)
+
+		profileURL, err := url.Parse(server.URL + "/debug/pprof/profile")
+		if err != nil {
+			t.Fatalf("parse profile url: %v", err)
+		}
+
+		_, err = NewFetcher(server.Client()).FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{URL: profileURL, Seconds: 1})
+		if !errors.Is(err, ErrTruncatedDownload) || !strings.Contains(err.Error(), "received 7 of 100 bytes") {
+			t.Fatalf("expected truncated download error, got %v", err)
+		}
+
+		requests = 0
+		fetcher, err := NewFetcherWithRetry(server.Client(), RetrySettings{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+		if err != nil {
+			t.Fatalf("new fetcher: %v", err)
+		}
+
+		profile, err := fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{URL: profileURL, Seconds: 1})
+		if err != nil || string(profile.Content) != "profile-bytes" {
+			t.Fatalf("expected retry after truncation, got %q %v", profile.Content, err)
+		}
+	})
+
+	t.Run("enforces the maximum profile size", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			size := 8
+			if req.URL.Query().Get("size") == "large" {
+				size = 9
+			}
+
+			if req.URL.Query().Get("length") == "declared" {
+				resp.Header().Set("Content-Length", strconv.Itoa(size))
+			}
+
+			// Flushing first streams the body chunked, without a declared length.
+			resp.(http.Flusher).Flush()
+			_, _ = resp.Write(bytes.Repeat([]byte("x"), size))
+		}))
+		t.Cleanup(server.Close)
+
+		fetcher, err := NewFetcherWithOptions(server.Client(), FetcherOptions{MaxBytes: 8})
+		if err != nil {
+			t.Fatalf("new fetcher: %v", err)
+		}
+
+		fetch := func(query string) ([]byte, error) {
+			profileURL, err := url.Parse(server.URL + "/debug/pprof/heap?" + query)
+			if err != nil {
+				t.Fatalf("parse profile url: %v", err)
+			}
+
+			result, err := fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{URL: profileURL, ProfileType: cpgo.ProfileTypeHeap})
+			return result.Content, err
+		}
+
+		if profile, err := fetch("size=exact"); err != nil || len(profile) != 8 {
+			t.Fatalf("expected a profile at the limit to succeed, got %d bytes and %v", len(profile), err)
+		}
+
+		_, err = fetch("size=large")
+		if !errors.Is(err, ErrProfileTooLarge) || errors.Is(err, ErrTruncatedDownload) || !strings.Contains(err.Error(), "after receiving 9 bytes") {
+			t.Fatalf("expected a size limit error with the received size, got %v", err)
+		}
+
+		_, err = fetch("size=large&length=declared")
+		if !errors.Is(err, ErrProfileTooLarge) || !strings.Contains(err.Error(), "declares 9 bytes") {
+			t.Fatalf("expected a declared size limit error, got %v", err)
+		}
+	})
+
+	t.Run("omits seconds query for instantaneous profiles", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Has("seconds") {
+				t.Fatalf("expected no seconds query, got %s", req.URL.RawQuery)
+			}
+
+			_, _ = resp.Write([]byte("profile-bytes"))
+		}))
+		t.Cleanup(server.Close)
+
+		profileURL, err := url.Parse(server.URL + "/debug/pprof/heap")
+		if err != nil {
+			t.Fatalf("parse profile url: %v", err)
+		}
+
+		fetcher := NewFetcher(server.Client())
+		if _, err := fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{URL: profileURL}); err != nil {
+			t.Fatalf("fetch profile: %v", err)
+		}
+	})
+
+	t.Run("completes pprof index urls with the profile type endpoint", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			if req.URL.Path != "/debug/pprof/mutex" {
+				t.Fatalf("expected mutex endpoint, got %s", req.URL.Path)
+			}
+
+			_, _ = resp.Write([]byte("profile-bytes"))
+		}))
+		t.Cleanup(server.Close)
+
+		profileURL, err := url.Parse(server.URL + "/debug/pprof/")
+		if err != nil {
+			t.Fatalf("parse profile url: %v", err)
+		}
+
+		fetcher := NewFetcher(server.Client())
+		if _, err := fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{
+			URL:         profileURL,
+			ProfileType: cpgo.ProfileTypeMutex,
+		}); err != nil {
+			t.Fatalf("fetch profile: %v", err)
 		}
 	})
 
@@ -71,4 +370,181 @@ func TestFetcherFetchCPUProfile(t *testing.T) {
 			t.Fatalf("expected fetch error")
 		}
 	})
+	t.Run("retries transient failures until success", func(t *testing.T) {
+		var calls int
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			calls++
+			if calls < 3 {
+				http.Error(resp, "overloaded", http.StatusServiceUnavailable)
+				return
+			}
+
+			_, _ = resp.Write([]byte("profile-bytes"))
+		}))
+		t.Cleanup(server.Close)
+
+		fetcher, err := NewFetcherWithRetry(server.Client(), RetrySettings{
+			MaxAttempts: 3,
+			BaseDelay:   time.Millisecond,
+			MaxDelay:    2 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("new fetcher: %v", err)
+		}
+
+		profileURL, err := url.Parse(server.URL + "/debug/pprof/profile")
+		if err != nil {
+			t.Fatalf("parse profile url: %v", err)
+		}
+
+		if _, err := fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{URL: profileURL, Seconds: 1}); err != nil {
+			t.Fatalf("fetch profile: %v", err)
+		}
+
+		if calls != 3 {
+			t.Fatalf("expected 3 attempts, got %d", calls)
+		}
+	})
+
+	t.Run("reports attempts after exhausting retries", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			http.Error(resp, "slow down", http.StatusTooManyRequests)
+		}))
+		t.Cleanup(server.Close)
+
+		fetcher, err := NewFetcherWithRetry(server.Client(), RetrySettings{
+			MaxAttempts: 2,
+			BaseDelay:   time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("new fetcher: %v", err)
+		}
+
+		profileURL, err := url.Parse(server.URL + "/debug/pprof/profile")
+		if err != nil {
+			t.Fatalf("parse profile url: %v", err)
+		}
+
+		_, err = fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{URL: profileURL, Seconds: 1})
+		if err == nil || !strings.Contains(err.Error(), "after 2 attempt(s)") {
+			t.Fatalf("expected attempt count in error, got %v", err)
+		}
+	})
+
+	t.Run("does not retry client errors", func(t *testing.T) {
+		var calls int
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			calls++
+			http.Error(resp, "unauthorized", http.StatusUnauthorized)
+		}))
+		t.Cleanup(server.Close)
+
+		fetcher, err := NewFetcherWithRetry(server.Client(), RetrySettings{
+			MaxAttempts: 5,
+			BaseDelay:   time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("new fetcher: %v", err)
+		}
+
+		profileURL, err := url.Parse(server.URL + "/debug/pprof/profile")
+		if err != nil {
+			t.Fatalf("parse profile url: %v", err)
+		}
+
+		_, err = fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{URL: profileURL, Seconds: 1})
+		if err == nil || !strings.Contains(err.Error(), "after 1 attempt(s)") {
+			t.Fatalf("expected single attempt error, got %v", err)
+		}
+
+		if calls != 1 {
+			t.Fatalf("expected one attempt, got %d", calls)
+		}
+	})
+
+	t.Run("stops when the context is cancelled mid-flight", func(t *testing.T) {
+		started := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			close(started)
+			<-req.Context().Done()
+		}))
+		t.Cleanup(server.Close)
+
+		fetcher, err := NewFetcherWithRetry(server.Client(), RetrySettings{
+			MaxAttempts: 3,
+			BaseDelay:   time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("new fetcher: %v", err)
+		}
+
+		profileURL, err := url.Parse(server.URL + "/debug/pprof/profile")
+		if err != nil {
+			t.Fatalf("parse profile url: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-started
+			cancel()
+		}()
+
+		_, err = fetcher.FetchCPUProfile(ctx, cpgo.FetchProfileRequest{URL: profileURL, Seconds: 30})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+
+		if !strings.Contains(err.Error(), "after 1 attempt(s)") {
+			t.Fatalf("expected no retry after cancellation, got %v", err)
+		}
+	})
+
+	t.Run("does not issue a request with a done context", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			t.Fatalf("unexpected request: %s", req.URL)
+		}))
+		t.Cleanup(server.Close)
+
+		profileURL, err := url.Parse(server.URL + "/debug/pprof/profile")
+		if err != nil {
+			t.Fatalf("parse profile url: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err = NewFetcher(server.Client()).FetchCPUProfile(ctx, cpgo.FetchProfileRequest{URL: profileURL, Seconds: 1})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	})
+
+	t.Run("distinguishes client timeouts from context deadlines", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			<-req.Context().Done()
+		}))
+		t.Cleanup(server.Close)
+
+		httpClient := server.Client()
+		httpClient.Timeout = 20 * time.Millisecond
+
+		profileURL, err := url.Parse(server.URL + "/debug/pprof/profile")
+		if err != nil {
+			t.Fatalf("parse profile url: %v", err)
+		}
+
+		_, err = NewFetcher(httpClient).FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{URL: profileURL, Seconds: 1})
+		if !errors.Is(err, ErrRequestTimeout) || errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected a client timeout, got %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		t.Cleanup(cancel)
+
+		httpClient.Timeout = time.Minute
+		_, err = NewFetcher(httpClient).FetchCPUProfile(ctx, cpgo.FetchProfileRequest{URL: profileURL, Seconds: 1})
+		if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrRequestTimeout) {
+			t.Fatalf("expected the context deadline, got %v", err)
+		}
+	})
 }
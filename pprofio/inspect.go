@@ -0,0 +1,43 @@
+package pprofio
+
+import (
+	"time"
+
+	"github.com/google/pprof/profile"
+)
+
+// ProfileInfo summarizes a decoded profile for logging and diagnostics.
+// SampleTypes and PeriodType are rendered as `type/unit`.
+type ProfileInfo struct {
+	Samples     int64
+	Duration    time.Duration
+	Period      int64
+	PeriodType  string
+	SampleTypes []string
+	Locations   int
+}
+
+// profileInfo summarizes an already decoded profile.
+func profileInfo(parsed *profile.Profile) ProfileInfo {
+	sampleTypes := make([]string, 0, len(parsed.SampleType))
+	for _, valueType := range parsed.SampleType {
+		sampleTypes = append(sampleTypes, valueTypeName(valueType))
+	}
+
+	return ProfileInfo{
+		Samples:     sampleCount(parsed),
+		Duration:    time.Duration(parsed.DurationNanos),
+		Period:      parsed.Period,
+		PeriodType:  valueTypeName(parsed.PeriodType),
+		SampleTypes: sampleTypes,
+		Locations:   len(parsed.Location),
+	}
+}
+
+func valueTypeName(valueType *profile.ValueType) string {
+	if valueType == nil {
+		return ""
+	}
+
+	return valueType.Type + "/" + valueType.Unit
+}
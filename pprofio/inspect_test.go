@@ -0,0 +1,33 @@
+package pprofio
+
+import "testing"
+
+func TestValidatorInspectCPUProfile(t *testing.T) {
+	t.Run("reports profile metadata", func(t *testing.T) {
+		info, err := NewValidator().InspectCPUProfile(writeProfile(t, ""))
+		if err != nil {
+			t.Fatalf("inspect profile: %v", err)
+		}
+
+		if info.Samples != 1 || info.Locations != 1 {
+			t.Fatalf("expected 1 sample at 1 location, got %+v", info)
+		}
+
+		if len(info.SampleTypes) != 2 || info.SampleTypes[0] != "samples/count" || info.SampleTypes[1] != "cpu/nanoseconds" {
+			t.Fatalf("expected samples and cpu sample types, got %v", info.SampleTypes)
+		}
+	})
+
+	t.Run("applies validation checks", func(t *testing.T) {
+		validator := mustNewValidator(t, ValidatorOptions{MinSamples: 10})
+		if _, err := validator.InspectCPUProfile(writeProfile(t, "")); err == nil {
+			t.Fatalf("expected min samples error")
+		}
+	})
+
+	t.Run("rejects invalid profiles", func(t *testing.T) {
+		if _, err := NewValidator().InspectCPUProfile([]byte("not-a-profile")); err == nil {
+			t.Fatalf("expected parse error")
+		}
+	})
+}
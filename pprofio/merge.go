@@ -0,0 +1,166 @@
+package pprofio
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/google/pprof/profile"
+
+	"cpgo"
+)
+
+// MergeOptions configures multi-window profile collection.
+// Samples is the number of consecutive windows to fetch; MinSamples is how
+// many of them must succeed for the run to continue and defaults to Samples.
+// DropOutliers discards that many windows with the least and the most total
+// CPU time before merging, so one anomalous window cannot skew the result.
+type MergeOptions struct {
+	Samples      int
+	MinSamples   int
+	DropOutliers int
+}
+
+// MergingFetcher fetches several profile windows and merges them into one profile.
+type MergingFetcher struct {
+	fetcher      cpgo.ProfileFetcher
+	samples      int
+	minSamples   int
+	dropOutliers int
+}
+
+var _ cpgo.ProfileFetcher = (*MergingFetcher)(nil)
+
+// NewMergingFetcher wraps a fetcher so each fetch collects and merges several windows.
+func NewMergingFetcher(fetcher cpgo.ProfileFetcher, options MergeOptions) (*MergingFetcher, error) {
+	if fetcher == nil {
+		return nil, fmt.Errorf("profile fetcher is required")
+	}
+
+	if options.Samples < 1 {
+		return nil, fmt.Errorf("profile samples must be at least 1")
+	}
+
+	minSamples := options.MinSamples
+	if minSamples == 0 {
+		minSamples = options.Samples
+	}
+
+	if minSamples < 1 || minSamples > options.Samples {
+		return nil, fmt.Errorf("profile min samples must be between 1 and %d", options.Samples)
+	}
+
+	if options.DropOutliers < 0 {
+		return nil, fmt.Errorf("profile drop outliers must not be negative")
+	}
+
+	if 2*options.DropOutliers >= minSamples {
+		return nil, fmt.Errorf("profile drop outliers %d would discard all %d required windows", options.DropOutliers, minSamples)
+	}
+
+	return &MergingFetcher{
+		fetcher:      fetcher,
+		samples:      options.Samples,
+		minSamples:   minSamples,
+		dropOutliers: options.DropOutliers,
+	}, nil
+}
+
+// FetchCPUProfile fetches the configured number of windows one after another and merges them.
+func (fetcher *MergingFetcher) FetchCPUProfile(ctx context.Context, req cpgo.FetchProfileRequest) (cpgo.FetchProfileResult, error) {
+	var (
+		result   cpgo.FetchProfileResult
+		profiles []*profile.Profile
+		failures []error
+	)
+
+	for sample := 1; sample <= fetcher.samples; sample++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return cpgo.FetchProfileResult{}, fmt.Errorf("fetch profile sample %d: %w", sample, ctxErr)
+		}
+
+		fetched, err := fetcher.fetcher.FetchCPUProfile(ctx, req)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return cpgo.FetchProfileResult{}, fmt.Errorf("fetch profile sample %d: %w", sample, err)
+			}
+
+			failures = append(failures, fmt.Errorf("sample %d: %w", sample, err))
+			continue
+		}
+
+		parsed, err := profile.ParseData(fetched.Content)
+		if err != nil {
+			failures = append(failures, fmt.Errorf("sample %d: parse profile: %w", sample, err))
+			continue
+		}
+
+		if len(profiles) == 0 {
+			result = fetched
+		}
+		profiles = append(profiles, parsed)
+	}
+
+	if len(profiles) < fetcher.minSamples {
+		return cpgo.FetchProfileResult{}, fmt.Errorf(
+			"collected %d of %d profile samples, need %d: %w",
+			len(profiles),
+			fetcher.samples,
+			fetcher.minSamples,
+			errors.Join(failures...),
+		)
+	}
+
+	if len(profiles) == 1 {
+		return result, nil
+	}
+
+	merged, err := profile.Merge(withoutOutliers(profiles, fetcher.dropOutliers))
+	if err != nil {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("merge profile samples: %w", err)
+	}
+
+	var raw bytes.Buffer
+	if err := merged.Write(&raw); err != nil {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("encode merged profile: %w", err)
+	}
+
+	result.Content = raw.Bytes()
+	return result, nil
+}
+
+// withoutOutliers drops the windows with the lowest and highest total CPU time.
+func withoutOutliers(profiles []*profile.Profile, dropOutliers int) []*profile.Profile {
+	if dropOutliers == 0 {
+		return profiles
+	}
+
+	totals := make(map[*profile.Profile]int64, len(profiles))
+	for _, parsed := range profiles {
+		totals[parsed] = cpuTotal(parsed)
+	}
+
+	sorted := slices.Clone(profiles)
+	slices.SortStableFunc(sorted, func(left *profile.Profile, right *profile.Profile) int {
+		return cmp.Compare(totals[left], totals[right])
+	})
+
+	return sorted[dropOutliers : len(sorted)-dropOutliers]
+}
+
+// cpuTotal sums the cpu value across all samples of a window.
+func cpuTotal(parsed *profile.Profile) int64 {
+	index := cpuValueIndex(parsed)
+
+	var total int64
+	for _, sample := range parsed.Sample {
+		if index < len(sample.Value) {
+			total += sample.Value[index]
+		}
+	}
+
+	return total
+}
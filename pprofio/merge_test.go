@@ -0,0 +1,170 @@
+package pprofio
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+
+	"cpgo"
+)
+
+func TestMergingFetcherFetchCPUProfile(t *testing.T) {
+	t.Run("merges every fetched window", func(t *testing.T) {
+		source := &sequenceFetcher{responses: []sequenceResponse{
+			{content: writeFunctionProfile(t, map[string]int64{"main.hot": 3})},
+			{content: writeFunctionProfile(t, map[string]int64{"main.hot": 5})},
+		}}
+
+		fetcher, err := NewMergingFetcher(source, MergeOptions{Samples: 2})
+		if err != nil {
+			t.Fatalf("new merging fetcher: %v", err)
+		}
+
+		result, err := fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{})
+		if err != nil {
+			t.Fatalf("fetch profile: %v", err)
+		}
+
+		merged, err := profile.ParseData(result.Content)
+		if err != nil {
+			t.Fatalf("parse merged profile: %v", err)
+		}
+
+		var total int64
+		for _, sample := range merged.Sample {
+			total += sample.Value[0]
+		}
+
+		if total != 8 {
+			t.Fatalf("expected merged sample count 8, got %d", total)
+		}
+	})
+
+	t.Run("continues when enough windows succeed", func(t *testing.T) {
+		source := &sequenceFetcher{responses: []sequenceResponse{
+			{err: errors.New("connection reset")},
+			{content: writeFunctionProfile(t, map[string]int64{"main.hot": 5})},
+		}}
+
+		fetcher, err := NewMergingFetcher(source, MergeOptions{Samples: 2, MinSamples: 1})
+		if err != nil {
+			t.Fatalf("new merging fetcher: %v", err)
+		}
+
+		if _, err := fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{}); err != nil {
+			t.Fatalf("fetch profile: %v", err)
+		}
+	})
+
+	t.Run("fails when too few windows succeed", func(t *testing.T) {
+		source := &sequenceFetcher{responses: []sequenceResponse{
+			{err: errors.New("connection reset")},
+			{content: writeFunctionProfile(t, map[string]int64{"main.hot": 5})},
+		}}
+
+		fetcher, err := NewMergingFetcher(source, MergeOptions{Samples: 2})
+		if err != nil {
+			t.Fatalf("new merging fetcher: %v", err)
+		}
+
+		_, err = fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{})
+		if err == nil || !strings.Contains(err.Error(), "connection reset") {
+			t.Fatalf("expected sample failure, got %v", err)
+		}
+	})
+
+	t.Run("stops fetching windows once the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		source := &sequenceFetcher{responses: []sequenceResponse{
+			{content: writeFunctionProfile(t, map[string]int64{"main.hot": 3})},
+			{content: writeFunctionProfile(t, map[string]int64{"main.hot": 5})},
+		}, afterFetch: cancel}
+
+		fetcher, err := NewMergingFetcher(source, MergeOptions{Samples: 2, MinSamples: 1})
+		if err != nil {
+			t.Fatalf("new merging fetcher: %v", err)
+		}
+
+		_, err = fetcher.FetchCPUProfile(ctx, cpgo.FetchProfileRequest{})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+
+		if source.calls != 1 {
+			t.Fatalf("expected one fetched window, got %d", source.calls)
+		}
+	})
+
+	t.Run("drops the lowest and highest windows", func(t *testing.T) {
+		source := &sequenceFetcher{responses: []sequenceResponse{
+			{content: writeFunctionProfile(t, map[string]int64{"main.hot": 4})},
+			{content: writeFunctionProfile(t, map[string]int64{"runtime.gcBgMarkWorker": 90})},
+			{content: writeFunctionProfile(t, map[string]int64{"main.hot": 5})},
+			{content: writeFunctionProfile(t, map[string]int64{"main.idle": 1})},
+		}}
+
+		fetcher, err := NewMergingFetcher(source, MergeOptions{Samples: 4, DropOutliers: 1})
+		if err != nil {
+			t.Fatalf("new merging fetcher: %v", err)
+		}
+
+		result, err := fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{})
+		if err != nil {
+			t.Fatalf("fetch profile: %v", err)
+		}
+
+		merged, err := profile.ParseData(result.Content)
+		if err != nil {
+			t.Fatalf("parse merged profile: %v", err)
+		}
+
+		var total int64
+		for _, sample := range merged.Sample {
+			if name := sample.Location[0].Line[0].Function.Name; name != "main.hot" {
+				t.Fatalf("expected outlier window %s to be dropped", name)
+			}
+			total += sample.Value[0]
+		}
+
+		if total != 9 {
+			t.Fatalf("expected merged sample count 9, got %d", total)
+		}
+	})
+
+	t.Run("rejects min samples above samples", func(t *testing.T) {
+		if _, err := NewMergingFetcher(&sequenceFetcher{}, MergeOptions{Samples: 2, MinSamples: 3}); err == nil {
+			t.Fatalf("expected invalid min samples to be rejected")
+		}
+	})
+
+	t.Run("rejects dropping every required window", func(t *testing.T) {
+		if _, err := NewMergingFetcher(&sequenceFetcher{}, MergeOptions{Samples: 4, MinSamples: 2, DropOutliers: 1}); err == nil {
+			t.Fatalf("expected excessive drop outliers to be rejected")
+		}
+	})
+}
+
+type sequenceResponse struct {
+	content []byte
+	err     error
+}
+
+// sequenceFetcher returns its responses in order, one per fetch.
+type sequenceFetcher struct {
+	responses  []sequenceResponse
+	calls      int
+	afterFetch func()
+}
+
+func (fetcher *sequenceFetcher) FetchCPUProfile(context.Context, cpgo.FetchProfileRequest) (cpgo.FetchProfileResult, error) {
+	response := fetcher.responses[fetcher.calls]
+	fetcher.calls++
+	if fetcher.afterFetch != nil {
+		fetcher.afterFetch()
+	}
+
+	return cpgo.FetchProfileResult{Content: response.content}, response.err
+}
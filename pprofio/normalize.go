@@ -0,0 +1,82 @@
+package pprofio
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/pprof/profile"
+
+	"cpgo"
+)
+
+// Normalizer re-encodes profiles canonically so that captures differing only in
+// serialization details, such as string table order or the capture time, compare equal.
+type Normalizer struct{}
+
+var _ cpgo.ProfileNormalizer = (*Normalizer)(nil)
+
+// NewNormalizer returns a pprof profile normalizer.
+func NewNormalizer() *Normalizer {
+	return &Normalizer{}
+}
+
+// NormalizeProfile drops the capture time, orders samples by stack and labels,
+// merges duplicates and re-serializes the result.
+func (normalizer *Normalizer) NormalizeProfile(raw []byte) ([]byte, error) {
+	parsed, err := profile.ParseData(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse profile: %w", err)
+	}
+
+	parsed.TimeNanos = 0
+
+	keys := make(map[*profile.Sample]string, len(parsed.Sample))
+	for _, sample := range parsed.Sample {
+		keys[sample] = sampleKey(sample)
+	}
+	slices.SortStableFunc(parsed.Sample, func(a, b *profile.Sample) int {
+		return strings.Compare(keys[a], keys[b])
+	})
+
+	// Compact renumbers functions, locations and mappings in sample order, so the
+	// sorted samples make the encoding independent of the original ID assignment.
+	var normalized bytes.Buffer
+	if err := parsed.Compact().WriteUncompressed(&normalized); err != nil {
+		return nil, fmt.Errorf("encode normalized profile: %w", err)
+	}
+
+	return normalized.Bytes(), nil
+}
+
+// sampleKey describes a sample by its symbolized stack, addresses and labels.
+func sampleKey(sample *profile.Sample) string {
+	var key strings.Builder
+	for _, location := range sample.Location {
+		key.WriteString(strconv.FormatUint(location.Address, 16))
+		for _, line := range location.Line {
+			if line.Function != nil {
+				key.WriteString(" " + line.Function.Name + " " + line.Function.Filename)
+			}
+			key.WriteString(":" + strconv.FormatInt(line.Line, 10))
+		}
+		key.WriteString(";")
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(sample.Label)) {
+		key.WriteString("|" + name + "=" + strings.Join(sample.Label[name], ","))
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(sample.NumLabel)) {
+		key.WriteString("|" + name + "=" + fmt.Sprint(sample.NumLabel[name]))
+	}
+
+	for _, value := range sample.Value {
+		key.WriteString("|" + strconv.FormatInt(value, 10))
+	}
+
+	return key.String()
+}
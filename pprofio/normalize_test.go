@@ -0,0 +1,89 @@
+package pprofio
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+func TestNormalizerNormalizeProfile(t *testing.T) {
+	t.Run("equalizes byte-different encodings of one profile", func(t *testing.T) {
+		first := writeOrderedProfile(t, 1_700_000_000, []string{"main.hot", "main.warm", "runtime.mallocgc"})
+		second := writeOrderedProfile(t, 1_800_000_000, []string{"runtime.mallocgc", "main.warm", "main.hot"})
+		if bytes.Equal(first, second) {
+			t.Fatalf("expected the test profiles to differ in bytes")
+		}
+
+		normalizer := NewNormalizer()
+		normalizedFirst, err := normalizer.NormalizeProfile(first)
+		if err != nil {
+			t.Fatalf("normalize first profile: %v", err)
+		}
+
+		normalizedSecond, err := normalizer.NormalizeProfile(second)
+		if err != nil {
+			t.Fatalf("normalize second profile: %v", err)
+		}
+
+		if !bytes.Equal(normalizedFirst, normalizedSecond) {
+			t.Fatalf("expected equal normalized profiles")
+		}
+	})
+
+	t.Run("keeps different samples apart", func(t *testing.T) {
+		normalizer := NewNormalizer()
+		normalizedHot, err := normalizer.NormalizeProfile(writeFunctionProfile(t, map[string]int64{"main.hot": 3}))
+		if err != nil {
+			t.Fatalf("normalize profile: %v", err)
+		}
+
+		normalizedHotter, err := normalizer.NormalizeProfile(writeFunctionProfile(t, map[string]int64{"main.hot": 5}))
+		if err != nil {
+			t.Fatalf("normalize profile: %v", err)
+		}
+
+		if bytes.Equal(normalizedHot, normalizedHotter) {
+			t.Fatalf("expected different sample counts to stay different")
+		}
+	})
+
+	t.Run("rejects undecodable profiles", func(t *testing.T) {
+		if _, err := NewNormalizer().NormalizeProfile([]byte("not a profile")); err == nil {
+			t.Fatalf("expected parse error")
+		}
+	})
+}
+
+// writeOrderedProfile encodes one leaf sample per function, assigning IDs in the given order.
+func writeOrderedProfile(t *testing.T, timeNanos int64, functions []string) []byte {
+	t.Helper()
+
+	cpuProfile := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		TimeNanos: timeNanos,
+	}
+
+	for index, name := range functions {
+		id := uint64(index + 1)
+		function := &profile.Function{ID: id, Name: name}
+		location := &profile.Location{ID: id, Line: []profile.Line{{Function: function}}}
+
+		cpuProfile.Function = append(cpuProfile.Function, function)
+		cpuProfile.Location = append(cpuProfile.Location, location)
+		cpuProfile.Sample = append(cpuProfile.Sample, &profile.Sample{
+			Value:    []int64{int64(len(name)), int64(len(name)) * 10_000_000},
+			Location: []*profile.Location{location},
+		})
+	}
+
+	var raw bytes.Buffer
+	if err := cpuProfile.Write(&raw); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+
+	return raw.Bytes()
+}
@@ -0,0 +1,130 @@
+package pprofio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"cpgo"
+)
+
+// IdentityTransform passes payloads through unchanged, for sources that already emit pprof.
+type IdentityTransform struct{}
+
+var _ cpgo.ProfileTransform = IdentityTransform{}
+
+// TransformProfile returns raw as is.
+func (IdentityTransform) TransformProfile(_ context.Context, raw []byte) ([]byte, error) {
+	return raw, nil
+}
+
+// CommandTransformOptions configures an external transform command.
+type CommandTransformOptions struct {
+	Command string
+	Timeout time.Duration
+}
+
+// CommandTransform converts payloads by piping them to a shell command that writes pprof bytes to stdout.
+type CommandTransform struct {
+	command string
+	timeout time.Duration
+}
+
+var _ cpgo.ProfileTransform = (*CommandTransform)(nil)
+
+// NewCommandTransform returns a transform that runs options.Command through sh -c.
+func NewCommandTransform(options CommandTransformOptions) (*CommandTransform, error) {
+	command := strings.TrimSpace(options.Command)
+	if command == "" {
+		return nil, fmt.Errorf("transform command is required")
+	}
+
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = defaultCommandTimeout
+	}
+
+	return &CommandTransform{
+		command: command,
+		timeout: timeout,
+	}, nil
+}
+
+// TransformProfile runs the command with raw on stdin and returns its stdout, killing it when ctx ends.
+func (transform *CommandTransform) TransformProfile(parent context.Context, raw []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(parent, transform.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", transform.command)
+	cmd.Stdin = bytes.NewReader(raw)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = commandWaitDelay
+
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+
+		if err := parent.Err(); err != nil {
+			return nil, fmt.Errorf("transform command stopped: %w", err)
+		}
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("transform command timed out after %s", transform.timeout)
+		}
+
+		return nil, fmt.Errorf("transform command failed: %s", message)
+	}
+
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("transform command wrote no profile to stdout")
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// TransformingFetcher converts every fetched payload to pprof before it reaches validation.
+type TransformingFetcher struct {
+	fetcher   cpgo.ProfileFetcher
+	transform cpgo.ProfileTransform
+}
+
+var _ cpgo.ProfileFetcher = (*TransformingFetcher)(nil)
+
+// NewTransformingFetcher wraps a fetcher so its payloads pass through transform.
+func NewTransformingFetcher(fetcher cpgo.ProfileFetcher, transform cpgo.ProfileTransform) (*TransformingFetcher, error) {
+	if fetcher == nil {
+		return nil, fmt.Errorf("profile fetcher is required")
+	}
+
+	if transform == nil {
+		return nil, fmt.Errorf("profile transform is required")
+	}
+
+	return &TransformingFetcher{
+		fetcher:   fetcher,
+		transform: transform,
+	}, nil
+}
+
+// FetchCPUProfile fetches one payload and returns its transformed content.
+func (fetcher *TransformingFetcher) FetchCPUProfile(ctx context.Context, req cpgo.FetchProfileRequest) (cpgo.FetchProfileResult, error) {
+	result, err := fetcher.fetcher.FetchCPUProfile(ctx, req)
+	if err != nil {
+		return cpgo.FetchProfileResult{}, err
+	}
+
+	result.Content, err = fetcher.transform.TransformProfile(ctx, result.Content)
+	if err != nil {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("transform profile: %w", err)
+	}
+
+	return result, nil
+}
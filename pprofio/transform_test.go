@@ -0,0 +1,103 @@
+package pprofio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"cpgo"
+)
+
+func TestTransformingFetcherFetchCPUProfile(t *testing.T) {
+	t.Run("returns the transformed payload", func(t *testing.T) {
+		source := &sequenceFetcher{responses: []sequenceResponse{{content: []byte("custom-format")}}}
+		fetcher, err := NewTransformingFetcher(source, transformFunc(func(raw []byte) ([]byte, error) {
+			return bytes.ToUpper(raw), nil
+		}))
+		if err != nil {
+			t.Fatalf("new transforming fetcher: %v", err)
+		}
+
+		result, err := fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{})
+		if err != nil {
+			t.Fatalf("fetch profile: %v", err)
+		}
+
+		if string(result.Content) != "CUSTOM-FORMAT" {
+			t.Fatalf("expected transformed content, got %q", result.Content)
+		}
+	})
+
+	t.Run("reports transform failures", func(t *testing.T) {
+		source := &sequenceFetcher{responses: []sequenceResponse{{content: []byte("custom-format")}}}
+		fetcher, err := NewTransformingFetcher(source, transformFunc(func([]byte) ([]byte, error) {
+			return nil, errors.New("unknown record")
+		}))
+		if err != nil {
+			t.Fatalf("new transforming fetcher: %v", err)
+		}
+
+		if _, err := fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{}); err == nil {
+			t.Fatalf("expected transform error")
+		}
+	})
+
+	t.Run("passes payloads through the identity transform", func(t *testing.T) {
+		raw := writeFunctionProfile(t, map[string]int64{"main.hot": 3})
+		transformed, err := IdentityTransform{}.TransformProfile(context.Background(), raw)
+		if err != nil || !bytes.Equal(transformed, raw) {
+			t.Fatalf("expected unchanged payload, got %v", err)
+		}
+	})
+}
+
+func TestCommandTransformTransformProfile(t *testing.T) {
+	t.Run("returns the command output", func(t *testing.T) {
+		transform, err := NewCommandTransform(CommandTransformOptions{Command: "tr a-z A-Z"})
+		if err != nil {
+			t.Fatalf("new command transform: %v", err)
+		}
+
+		transformed, err := transform.TransformProfile(context.Background(), []byte("folded;stacks 3"))
+		if err != nil || string(transformed) != "FOLDED;STACKS 3" {
+			t.Fatalf("expected upper-cased payload, got %q %v", transformed, err)
+		}
+	})
+
+	t.Run("reports stderr of failing commands", func(t *testing.T) {
+		transform, err := NewCommandTransform(CommandTransformOptions{Command: "echo 'unknown record' >&2; exit 3"})
+		if err != nil {
+			t.Fatalf("new command transform: %v", err)
+		}
+
+		if _, err := transform.TransformProfile(context.Background(), []byte("x")); err == nil || !strings.Contains(err.Error(), "unknown record") {
+			t.Fatalf("expected command stderr, got %v", err)
+		}
+	})
+
+	t.Run("rejects empty output", func(t *testing.T) {
+		transform, err := NewCommandTransform(CommandTransformOptions{Command: "cat >/dev/null"})
+		if err != nil {
+			t.Fatalf("new command transform: %v", err)
+		}
+
+		if _, err := transform.TransformProfile(context.Background(), []byte("x")); err == nil {
+			t.Fatalf("expected empty output error")
+		}
+	})
+
+	t.Run("requires a command", func(t *testing.T) {
+		if _, err := NewCommandTransform(CommandTransformOptions{Command: " "}); err == nil {
+			t.Fatalf("expected missing command error")
+		}
+	})
+}
+
+// transformFunc adapts a function to cpgo.ProfileTransform.
+type transformFunc func(raw []byte) ([]byte, error)
+
+func (transform transformFunc) TransformProfile(_ context.Context, raw []byte) ([]byte, error) {
+	return transform(raw)
+}
@@ -0,0 +1,243 @@
+package pprofio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cpgo"
+)
+
+const (
+	captureIDPlaceholder = "{id}"
+	cleanupTimeout       = 10 * time.Second
+)
+
+// TwoStepOptions configures asynchronous start-then-collect profile capture.
+// A zero MaxBytes applies DefaultMaxProfileBytes.
+type TwoStepOptions struct {
+	StartMethod  string
+	FetchURL     *url.URL
+	FetchMethod  string
+	PollInterval time.Duration
+	CancelURL    *url.URL
+	CancelMethod string
+	MaxBytes     int64
+}
+
+// TwoStepFetcher starts a capture with one request and collects it with another.
+type TwoStepFetcher struct {
+	httpClient *http.Client
+	options    TwoStepOptions
+}
+
+var _ cpgo.ProfileFetcher = (*TwoStepFetcher)(nil)
+
+// startResponse holds the optional capture identifier returned by the start call.
+type startResponse struct {
+	ID string `json:"id"`
+}
+
+// NewTwoStepFetcher applies method defaults and returns a two-step fetcher.
+func NewTwoStepFetcher(httpClient *http.Client, options TwoStepOptions) (*TwoStepFetcher, error) {
+	if options.PollInterval < 0 {
+		return nil, fmt.Errorf("poll interval must not be negative")
+	}
+
+	options.StartMethod = methodOrDefault(options.StartMethod, http.MethodPost)
+	options.FetchMethod = methodOrDefault(options.FetchMethod, http.MethodGet)
+	options.CancelMethod = methodOrDefault(options.CancelMethod, http.MethodDelete)
+
+	maxBytes, err := maxBytesOrDefault(options.MaxBytes)
+	if err != nil {
+		return nil, err
+	}
+	options.MaxBytes = maxBytes
+
+	return &TwoStepFetcher{
+		httpClient: withDefaultTimeout(httpClient),
+		options:    options,
+	}, nil
+}
+
+// FetchCPUProfile starts a capture at req.URL, then polls the fetch URL until the profile is ready.
+// The result's SourceURL is the download URL, not the trigger URL; the service redacts it before reporting.
+func (fetcher *TwoStepFetcher) FetchCPUProfile(ctx context.Context, req cpgo.FetchProfileRequest) (cpgo.FetchProfileResult, error) {
+	if req.URL == nil {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("profile url is required")
+	}
+
+	if req.Seconds <= 0 {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("profile seconds must be positive")
+	}
+
+	startURL := withProfileSeconds(*req.URL, req.Seconds)
+	fetchURL, captureID, err := fetcher.start(ctx, startURL, req.Headers)
+	if err != nil {
+		return cpgo.FetchProfileResult{}, err
+	}
+
+	profile, err := fetcher.collect(ctx, fetchURL, req.Headers)
+	if err != nil {
+		if cleanupErr := fetcher.cancel(ctx, captureID, req.Headers); cleanupErr != nil {
+			return cpgo.FetchProfileResult{}, errors.Join(err, cleanupErr)
+		}
+
+		return cpgo.FetchProfileResult{}, err
+	}
+
+	return cpgo.FetchProfileResult{
+		Content:   profile,
+		SourceURL: fetchURL,
+	}, nil
+}
+
+// start issues the capture request and resolves where the result will be served.
+func (fetcher *TwoStepFetcher) start(ctx context.Context, startURL url.URL, headers map[string]string) (*url.URL, string, error) {
+	httpReq, err := newProfileRequest(ctx, fetcher.options.StartMethod, startURL.String(), headers, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := fetcher.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, "", fmt.Errorf("start profile capture: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, "", unexpectedStatus("start profile capture", resp)
+	}
+
+	var started startResponse
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&started); err != nil {
+			return nil, "", fmt.Errorf("decode profile capture start response: %w", err)
+		}
+	}
+
+	if fetcher.options.FetchURL != nil {
+		fetchURL := *fetcher.options.FetchURL
+		if strings.Contains(fetchURL.Path, captureIDPlaceholder) {
+			if started.ID == "" {
+				return nil, "", fmt.Errorf("fetch url references %s but start response has no id", captureIDPlaceholder)
+			}
+
+			fetchURL.Path = strings.ReplaceAll(fetchURL.Path, captureIDPlaceholder, url.PathEscape(started.ID))
+			fetchURL.RawPath = ""
+		}
+
+		return &fetchURL, started.ID, nil
+	}
+
+	location, err := resp.Location()
+	if err != nil {
+		return nil, "", fmt.Errorf("profile capture start response has no location and no fetch url is configured")
+	}
+
+	return location, started.ID, nil
+}
+
+// collect polls the fetch URL while the capture reports 202 Accepted.
+func (fetcher *TwoStepFetcher) collect(ctx context.Context, fetchURL *url.URL, headers map[string]string) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		profile, pending, err := fetcher.fetchOnce(ctx, fetchURL, headers)
+		if err != nil || !pending {
+			return profile, err
+		}
+
+		if fetcher.options.PollInterval == 0 {
+			return nil, fmt.Errorf("collect profile: capture still pending and polling is disabled")
+		}
+
+		timer := time.NewTimer(fetcher.options.PollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("collect profile after %d polls: %w", attempt, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// fetchOnce performs one collection attempt, reporting whether the capture is still pending.
+func (fetcher *TwoStepFetcher) fetchOnce(ctx context.Context, fetchURL *url.URL, headers map[string]string) ([]byte, bool, error) {
+	httpReq, err := newProfileRequest(ctx, fetcher.options.FetchMethod, fetchURL.String(), headers, nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	resp, err := fetcher.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, false, fmt.Errorf("collect profile: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusAccepted {
+		return nil, true, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, unexpectedStatus("collect profile", resp)
+	}
+
+	profile, err := readProfileBody(resp, fetcher.options.MaxBytes)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return profile, false, nil
+}
+
+// cancel asks the backend to discard a started capture; it is a no-op without a cancel URL.
+func (fetcher *TwoStepFetcher) cancel(ctx context.Context, captureID string, headers map[string]string) error {
+	if fetcher.options.CancelURL == nil {
+		return nil
+	}
+
+	cancelURL := *fetcher.options.CancelURL
+	if strings.Contains(cancelURL.Path, captureIDPlaceholder) {
+		if captureID == "" {
+			return nil
+		}
+
+		cancelURL.Path = strings.ReplaceAll(cancelURL.Path, captureIDPlaceholder, url.PathEscape(captureID))
+		cancelURL.RawPath = ""
+	}
+
+	// The run context may already be cancelled, which is often why cleanup is needed.
+	cleanupContext, cancel := context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+	defer cancel()
+
+	httpReq, err := newProfileRequest(cleanupContext, fetcher.options.CancelMethod, cancelURL.String(), headers, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := fetcher.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("cancel profile capture: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= http.StatusBadRequest && resp.StatusCode != http.StatusNotFound {
+		return unexpectedStatus("cancel profile capture", resp)
+	}
+
+	return nil
+}
+
+func methodOrDefault(method string, defaultMethod string) string {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if method == "" {
+		return defaultMethod
+	}
+
+	return method
+}
@@ -0,0 +1,149 @@
+package pprofio
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"cpgo"
+)
+
+func TestTwoStepFetcherFetchCPUProfile(t *testing.T) {
+	t.Run("starts capture and polls until profile is ready", func(t *testing.T) {
+		polls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			switch {
+			case req.Method == http.MethodPost && req.URL.Path == "/captures":
+				if req.URL.Query().Get("seconds") != "5" {
+					t.Fatalf("expected seconds query on start request, got %s", req.URL.RawQuery)
+				}
+
+				resp.Header().Set("Content-Type", "application/json")
+				_, _ = resp.Write([]byte(`{"id":"c-1"}`))
+			case req.Method == http.MethodGet && req.URL.Path == "/captures/c-1/profile":
+				polls++
+				if polls == 1 {
+					resp.WriteHeader(http.StatusAccepted)
+					return
+				}
+
+				_, _ = resp.Write([]byte("profile-bytes"))
+			default:
+				t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		fetcher, err := NewTwoStepFetcher(server.Client(), TwoStepOptions{
+			FetchURL:     mustParseURL(t, server.URL+"/captures/{id}/profile"),
+			PollInterval: time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("new two-step fetcher: %v", err)
+		}
+
+		result, err := fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{
+			URL:     mustParseURL(t, server.URL+"/captures"),
+			Seconds: 5,
+		})
+		if err != nil {
+			t.Fatalf("fetch profile: %v", err)
+		}
+
+		if string(result.Content) != "profile-bytes" {
+			t.Fatalf("expected profile bytes, got %q", string(result.Content))
+		}
+
+		if polls != 2 {
+			t.Fatalf("expected two polls, got %d", polls)
+		}
+
+		if result.SourceURL.String() != server.URL+"/captures/c-1/profile" {
+			t.Fatalf("expected download url as source, got %s", result.SourceURL)
+		}
+	})
+
+	t.Run("reports the start response location as source", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			switch {
+			case req.Method == http.MethodPost && req.URL.Path == "/captures":
+				resp.Header().Set("Location", "/downloads/c-3?token=secret")
+				resp.WriteHeader(http.StatusCreated)
+			case req.Method == http.MethodGet && req.URL.Path == "/downloads/c-3":
+				_, _ = resp.Write([]byte("profile-bytes"))
+			default:
+				t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		fetcher, err := NewTwoStepFetcher(server.Client(), TwoStepOptions{})
+		if err != nil {
+			t.Fatalf("new two-step fetcher: %v", err)
+		}
+
+		result, err := fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{
+			URL:     mustParseURL(t, server.URL+"/captures"),
+			Seconds: 5,
+		})
+		if err != nil {
+			t.Fatalf("fetch profile: %v", err)
+		}
+
+		if result.SourceURL.Path != "/downloads/c-3" || result.SourceURL.Query().Get("token") != "secret" {
+			t.Fatalf("expected download url as source, got %s", result.SourceURL)
+		}
+	})
+
+	t.Run("cancels the capture when collection fails", func(t *testing.T) {
+		cancelled := false
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			switch {
+			case req.Method == http.MethodPost:
+				resp.Header().Set("Content-Type", "application/json")
+				_, _ = resp.Write([]byte(`{"id":"c-2"}`))
+			case req.Method == http.MethodGet:
+				http.Error(resp, "capture failed", http.StatusInternalServerError)
+			case req.Method == http.MethodDelete && req.URL.Path == "/captures/c-2":
+				cancelled = true
+			default:
+				t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		fetcher, err := NewTwoStepFetcher(server.Client(), TwoStepOptions{
+			FetchURL:  mustParseURL(t, server.URL+"/captures/{id}/profile"),
+			CancelURL: mustParseURL(t, server.URL+"/captures/{id}"),
+		})
+		if err != nil {
+			t.Fatalf("new two-step fetcher: %v", err)
+		}
+
+		_, err = fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{
+			URL:     mustParseURL(t, server.URL+"/captures"),
+			Seconds: 5,
+		})
+		if err == nil {
+			t.Fatalf("expected collection error")
+		}
+
+		if !cancelled {
+			t.Fatalf("expected capture cancellation")
+		}
+	})
+}
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parse url: %v", err)
+	}
+
+	return parsed
+}
@@ -2,36 +2,471 @@ package pprofio
 
 import (
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/google/pprof/profile"
 
 	"cpgo"
 )
 
+// Validation strictness presets bundling common option combinations.
+const (
+	StrictnessLenient  = "lenient"
+	StrictnessStandard = "standard"
+	StrictnessStrict   = "strict"
+)
+
+const (
+	standardMinSamples           = 100
+	strictMaxAge                 = 24 * time.Hour
+	strictMinSymbolizedFraction  = 0.9
+	strictMaxSingleFunctionRatio = 0.8
+	cpuSampleType                = "cpu"
+	unknownFunctionName          = "<unknown>"
+)
+
+// DefaultCPUSampleTypes are the `type/unit` sample types a cpu profile may carry, one of which it must.
+var DefaultCPUSampleTypes = []string{"samples/count", "cpu/nanoseconds"}
+
+// profileSampleTypes maps non-cpu profile types to the sample type their profiles must carry.
+var profileSampleTypes = map[cpgo.ProfileType]string{
+	cpgo.ProfileTypeHeap:  "inuse_space",
+	cpgo.ProfileTypeMutex: "delay",
+	cpgo.ProfileTypeBlock: "delay",
+}
+
+// PresetOption names a check a strictness preset can configure.
+type PresetOption string
+
+const (
+	PresetRequireCPUSampleType   PresetOption = "require_cpu_sample_type"
+	PresetMinSamples             PresetOption = "min_samples"
+	PresetMaxAge                 PresetOption = "max_age"
+	PresetMinSymbolizedFraction  PresetOption = "min_symbolized_fraction"
+	PresetMaxSingleFunctionRatio PresetOption = "max_single_function_ratio"
+)
+
+// ValidatorOptions tunes optional profile checks beyond basic decoding.
+// Strictness selects a preset; non-zero or explicit individual options override it.
+type ValidatorOptions struct {
+	Strictness string
+	// Explicit lists options set on purpose; they override the preset even when zero or false,
+	// so a config can relax or disable a check the preset enables.
+	Explicit []PresetOption
+	// DefaultSampleType is the expected default sample type; empty disables the check.
+	DefaultSampleType string
+	// RequireCPUSampleType rejects cpu profiles carrying none of CPUSampleTypes, so heap or
+	// allocation profiles are not committed as cpu profiles; non-empty CPUSampleTypes enable it.
+	RequireCPUSampleType bool
+	// CPUSampleTypes lists the accepted `type/unit` sample types; empty means DefaultCPUSampleTypes.
+	CPUSampleTypes []string
+	// MinSamples is the minimum total sample count; zero disables the check.
+	MinSamples int64
+	// MaxAge rejects profiles captured longer ago; zero disables the check.
+	MaxAge time.Duration
+	// MinSymbolizedFraction is the minimum share of symbolized locations; zero disables the check.
+	MinSymbolizedFraction float64
+	// MaxSingleFunctionRatio caps the flat share of the hottest function; zero disables the check.
+	MaxSingleFunctionRatio float64
+	// MinDurationFraction is the minimum measured duration as a share of the requested seconds; zero disables the check.
+	MinDurationFraction float64
+}
+
 // Validator ensures profile payloads are valid pprof data with samples.
-type Validator struct{}
+type Validator struct {
+	options ValidatorOptions
+	now     func() time.Time
+}
 
 var _ cpgo.ProfileValidator = (*Validator)(nil)
+var _ cpgo.ExpectationValidator = (*Validator)(nil)
+var _ cpgo.SampleCounter = (*Validator)(nil)
 
 // NewValidator returns a pprof payload validator.
 func NewValidator() *Validator {
-	return &Validator{}
+	return &Validator{
+		now: time.Now,
+	}
+}
+
+// NewValidatorWithOptions returns a pprof payload validator with optional checks.
+func NewValidatorWithOptions(options ValidatorOptions) (*Validator, error) {
+	resolved, err := resolveStrictness(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Validator{
+		options: resolved,
+		now:     time.Now,
+	}, nil
+}
+
+// resolveStrictness applies the strictness preset beneath explicitly configured options.
+func resolveStrictness(options ValidatorOptions) (ValidatorOptions, error) {
+	options.Strictness = strings.ToLower(strings.TrimSpace(options.Strictness))
+	options.DefaultSampleType = strings.TrimSpace(options.DefaultSampleType)
+
+	cpuSampleTypes := make([]string, 0, len(options.CPUSampleTypes))
+	for _, sampleType := range options.CPUSampleTypes {
+		sampleType = strings.TrimSpace(sampleType)
+		if valueType, unit, ok := strings.Cut(sampleType, "/"); !ok || valueType == "" || unit == "" {
+			return ValidatorOptions{}, fmt.Errorf("profile sample type %q must be written as type/unit", sampleType)
+		}
+
+		cpuSampleTypes = append(cpuSampleTypes, sampleType)
+	}
+	options.CPUSampleTypes = cpuSampleTypes
+
+	var preset ValidatorOptions
+	switch options.Strictness {
+	case "", StrictnessLenient:
+	case StrictnessStandard:
+		preset = ValidatorOptions{
+			RequireCPUSampleType: true,
+			MinSamples:           standardMinSamples,
+		}
+	case StrictnessStrict:
+		preset = ValidatorOptions{
+			RequireCPUSampleType:   true,
+			MinSamples:             standardMinSamples,
+			MaxAge:                 strictMaxAge,
+			MinSymbolizedFraction:  strictMinSymbolizedFraction,
+			MaxSingleFunctionRatio: strictMaxSingleFunctionRatio,
+		}
+	default:
+		return ValidatorOptions{}, fmt.Errorf("unsupported profile strictness %q", options.Strictness)
+	}
+
+	if options.MinDurationFraction < 0 || options.MinDurationFraction > 1 {
+		return ValidatorOptions{}, fmt.Errorf("profile min duration fraction must be between 0 and 1")
+	}
+
+	isExplicit := func(option PresetOption) bool {
+		return slices.Contains(options.Explicit, option)
+	}
+
+	if !isExplicit(PresetRequireCPUSampleType) {
+		options.RequireCPUSampleType = options.RequireCPUSampleType || len(options.CPUSampleTypes) > 0 || preset.RequireCPUSampleType
+	}
+
+	if options.MinSamples == 0 && !isExplicit(PresetMinSamples) {
+		options.MinSamples = preset.MinSamples
+	}
+
+	if options.MaxAge == 0 && !isExplicit(PresetMaxAge) {
+		options.MaxAge = preset.MaxAge
+	}
+
+	if options.MinSymbolizedFraction == 0 && !isExplicit(PresetMinSymbolizedFraction) {
+		options.MinSymbolizedFraction = preset.MinSymbolizedFraction
+	}
+
+	if options.MaxSingleFunctionRatio == 0 && !isExplicit(PresetMaxSingleFunctionRatio) {
+		options.MaxSingleFunctionRatio = preset.MaxSingleFunctionRatio
+	}
+
+	return options, nil
 }
 
 // ValidateCPUProfile verifies pprof encoding and minimum sample presence.
 func (validator *Validator) ValidateCPUProfile(raw []byte) error {
+	return validator.ValidateCPUProfileWithExpectations(raw, cpgo.ProfileExpectations{})
+}
+
+// ValidateCPUProfileWithExpectations also rejects profiles measured over much less than the requested window.
+func (validator *Validator) ValidateCPUProfileWithExpectations(raw []byte, expectations cpgo.ProfileExpectations) error {
+	_, err := validator.inspect(raw, expectations)
+	return err
+}
+
+// InspectCPUProfile validates the profile like ValidateCPUProfile and reports its metadata.
+func (validator *Validator) InspectCPUProfile(raw []byte) (ProfileInfo, error) {
+	return validator.inspect(raw, cpgo.ProfileExpectations{})
+}
+
+// InspectProfile validates the profile like ValidateCPUProfileWithExpectations and reports its metadata.
+func (validator *Validator) InspectProfile(raw []byte, expectations cpgo.ProfileExpectations) (ProfileInfo, error) {
+	return validator.inspect(raw, expectations)
+}
+
+// CountSamples returns the profile's sample count without validating it.
+func (validator *Validator) CountSamples(raw []byte) (int64, error) {
+	parsed, err := profile.ParseData(raw)
+	if err != nil {
+		return 0, fmt.Errorf("parse cpu profile: %w", err)
+	}
+
+	return sampleCount(parsed), nil
+}
+
+// inspect decodes the profile once, runs every configured check and summarizes it.
+func (validator *Validator) inspect(raw []byte, expectations cpgo.ProfileExpectations) (ProfileInfo, error) {
 	if len(raw) == 0 {
-		return fmt.Errorf("cpu profile is empty")
+		return ProfileInfo{}, fmt.Errorf("cpu profile is empty")
 	}
 
 	parsed, err := profile.ParseData(raw)
 	if err != nil {
-		return fmt.Errorf("parse cpu profile: %w", err)
+		return ProfileInfo{}, fmt.Errorf("parse cpu profile: %w", err)
 	}
 
 	if len(parsed.Sample) == 0 {
-		return fmt.Errorf("cpu profile has no samples")
+		return ProfileInfo{}, fmt.Errorf("cpu profile has no samples")
+	}
+
+	if err := validator.validateDefaultSampleType(parsed); err != nil {
+		return ProfileInfo{}, err
+	}
+
+	if err := validator.validateSampleType(parsed, expectations.ProfileType); err != nil {
+		return ProfileInfo{}, err
+	}
+
+	if err := validator.validateMinSamples(parsed); err != nil {
+		return ProfileInfo{}, err
+	}
+
+	if err := validator.validateAge(parsed); err != nil {
+		return ProfileInfo{}, err
+	}
+
+	if err := validator.validateSymbolizedFraction(parsed); err != nil {
+		return ProfileInfo{}, err
+	}
+
+	if err := validator.validateSingleFunctionRatio(parsed); err != nil {
+		return ProfileInfo{}, err
+	}
+
+	if err := validator.validateDuration(parsed, expectations.Seconds); err != nil {
+		return ProfileInfo{}, err
+	}
+
+	return profileInfo(parsed), nil
+}
+
+// validateDuration rejects profiles cut short of the requested sample window.
+func (validator *Validator) validateDuration(parsed *profile.Profile, seconds int) error {
+	minFraction := validator.options.MinDurationFraction
+	if minFraction <= 0 || seconds <= 0 {
+		return nil
+	}
+
+	expected := time.Duration(seconds) * time.Second
+	actual := time.Duration(parsed.DurationNanos)
+	if float64(actual) < minFraction*float64(expected) {
+		return fmt.Errorf("cpu profile covers %s of the requested %s, minimum %.0f%%", actual, expected, minFraction*100)
 	}
 
 	return nil
 }
+
+// validateDefaultSampleType rejects a declared default that is not the expected type.
+func (validator *Validator) validateDefaultSampleType(parsed *profile.Profile) error {
+	expected := validator.options.DefaultSampleType
+	if expected == "" || parsed.DefaultSampleType == "" {
+		return nil
+	}
+
+	if parsed.DefaultSampleType != expected {
+		return fmt.Errorf("cpu profile default sample type is %q, expected %q", parsed.DefaultSampleType, expected)
+	}
+
+	return nil
+}
+
+// validateSampleType rejects profiles missing the sample type of the requested profile type.
+// Non-cpu profiles are always checked, so a cpu profile is never committed in their place.
+func (validator *Validator) validateSampleType(parsed *profile.Profile, profileType cpgo.ProfileType) error {
+	if profileType == "" || profileType == cpgo.ProfileTypeCPU {
+		if !validator.options.RequireCPUSampleType {
+			return nil
+		}
+
+		return validator.validateCPUSampleTypes(parsed)
+	}
+
+	expected, ok := profileSampleTypes[profileType]
+	if !ok {
+		return fmt.Errorf("unsupported profile type %q", profileType)
+	}
+
+	for _, valueType := range parsed.SampleType {
+		if valueType.Type == expected {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s profile has no %q sample type", profileTypeName(profileType), expected)
+}
+
+// validateCPUSampleTypes rejects cpu profiles carrying none of the accepted sample types,
+// such as a heap profile fetched from the wrong endpoint.
+func (validator *Validator) validateCPUSampleTypes(parsed *profile.Profile) error {
+	accepted := validator.options.CPUSampleTypes
+	if len(accepted) == 0 {
+		accepted = DefaultCPUSampleTypes
+	}
+
+	actual := make([]string, 0, len(parsed.SampleType))
+	for _, valueType := range parsed.SampleType {
+		name := valueTypeName(valueType)
+		if slices.Contains(accepted, name) {
+			return nil
+		}
+
+		actual = append(actual, name)
+	}
+
+	return fmt.Errorf("cpu profile has sample types %s, expected one of %s",
+		strings.Join(actual, ", "), strings.Join(accepted, ", "))
+}
+
+func profileTypeName(profileType cpgo.ProfileType) cpgo.ProfileType {
+	if profileType == "" {
+		return cpgo.ProfileTypeCPU
+	}
+
+	return profileType
+}
+
+// validateMinSamples rejects captures too short or idle to be representative.
+func (validator *Validator) validateMinSamples(parsed *profile.Profile) error {
+	minSamples := validator.options.MinSamples
+	if minSamples <= 0 {
+		return nil
+	}
+
+	count := sampleCount(parsed)
+	if count < minSamples {
+		return fmt.Errorf("cpu profile has %d samples, minimum %d", count, minSamples)
+	}
+
+	return nil
+}
+
+// validateAge rejects stale profiles based on their recorded capture time.
+func (validator *Validator) validateAge(parsed *profile.Profile) error {
+	maxAge := validator.options.MaxAge
+	if maxAge <= 0 || parsed.TimeNanos == 0 {
+		return nil
+	}
+
+	age := validator.now().Sub(time.Unix(0, parsed.TimeNanos))
+	if age > maxAge {
+		return fmt.Errorf("cpu profile is %s old, maximum %s", age.Round(time.Second), maxAge)
+	}
+
+	return nil
+}
+
+// validateSymbolizedFraction rejects profiles whose locations mostly lack function names.
+func (validator *Validator) validateSymbolizedFraction(parsed *profile.Profile) error {
+	minFraction := validator.options.MinSymbolizedFraction
+	if minFraction <= 0 || len(parsed.Location) == 0 {
+		return nil
+	}
+
+	var symbolized int
+	for _, location := range parsed.Location {
+		if len(location.Line) > 0 && location.Line[0].Function != nil && location.Line[0].Function.Name != "" {
+			symbolized++
+		}
+	}
+
+	fraction := float64(symbolized) / float64(len(parsed.Location))
+	if fraction < minFraction {
+		return fmt.Errorf("cpu profile has %.1f%% symbolized locations, minimum %.1f%%", fraction*100, minFraction*100)
+	}
+
+	return nil
+}
+
+// validateSingleFunctionRatio rejects idle or pathological captures dominated by one function.
+func (validator *Validator) validateSingleFunctionRatio(parsed *profile.Profile) error {
+	maxRatio := validator.options.MaxSingleFunctionRatio
+	if maxRatio <= 0 {
+		return nil
+	}
+
+	index := cpuValueIndex(parsed)
+	flatByFunction := make(map[string]int64)
+	var total int64
+	for _, sample := range parsed.Sample {
+		if index >= len(sample.Value) {
+			continue
+		}
+
+		value := sample.Value[index]
+		total += value
+		flatByFunction[leafFunctionName(sample)] += value
+	}
+
+	if total <= 0 {
+		return nil
+	}
+
+	var topFunction string
+	var topValue int64
+	for name, value := range flatByFunction {
+		if value > topValue || (value == topValue && name < topFunction) {
+			topFunction = name
+			topValue = value
+		}
+	}
+
+	ratio := float64(topValue) / float64(total)
+	if ratio > maxRatio {
+		return fmt.Errorf("cpu profile is dominated by %s (%.1f%% of samples, maximum %.1f%%)", topFunction, ratio*100, maxRatio*100)
+	}
+
+	return nil
+}
+
+// cpuValueIndex picks the cpu sample value, falling back to the last sample type like pprof.
+func cpuValueIndex(parsed *profile.Profile) int {
+	for index, valueType := range parsed.SampleType {
+		if valueType.Type == cpuSampleType {
+			return index
+		}
+	}
+
+	return max(len(parsed.SampleType)-1, 0)
+}
+
+// leafFunctionName names the innermost frame of a sample.
+func leafFunctionName(sample *profile.Sample) string {
+	if len(sample.Location) == 0 {
+		return unknownFunctionName
+	}
+
+	location := sample.Location[0]
+	if len(location.Line) == 0 || location.Line[0].Function == nil || location.Line[0].Function.Name == "" {
+		return fmt.Sprintf("0x%x", location.Address)
+	}
+
+	return location.Line[0].Function.Name
+}
+
+// sampleCount sums the samples/count value, falling back to the number of sample records.
+func sampleCount(parsed *profile.Profile) int64 {
+	for index, valueType := range parsed.SampleType {
+		if valueType.Type != "samples" {
+			continue
+		}
+
+		var total int64
+		for _, sample := range parsed.Sample {
+			if index < len(sample.Value) {
+				total += sample.Value[index]
+			}
+		}
+
+		return total
+	}
+
+	return int64(len(parsed.Sample))
+}
@@ -2,9 +2,13 @@ package pprofio
 
 import (
 	"bytes"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/pprof/profile"
+
+	"cpgo"
 )
 
 func TestValidatorValidateCPUProfile(t *testing.T) {
@@ -41,6 +45,210 @@ func TestValidatorValidateCPUProfile(t *testing.T) {
 		}
 	})
 
+	t.Run("rejects unexpected default sample type", func(t *testing.T) {
+		validator := mustNewValidator(t, ValidatorOptions{
+			DefaultSampleType: "cpu",
+		})
+
+		err := validator.ValidateCPUProfile(writeProfile(t, "alloc_space"))
+		if err == nil {
+			t.Fatalf("expected validation error")
+		}
+
+		if !strings.Contains(err.Error(), `"alloc_space"`) {
+			t.Fatalf("expected actual default sample type in error, got %v", err)
+		}
+	})
+
+	t.Run("accepts expected or unset default sample type", func(t *testing.T) {
+		validator := mustNewValidator(t, ValidatorOptions{
+			DefaultSampleType: "cpu",
+		})
+
+		for _, defaultSampleType := range []string{"cpu", ""} {
+			if err := validator.ValidateCPUProfile(writeProfile(t, defaultSampleType)); err != nil {
+				t.Fatalf("validate profile with default %q: %v", defaultSampleType, err)
+			}
+		}
+	})
+
+	t.Run("rejects profile dominated by a single function", func(t *testing.T) {
+		validator := mustNewValidator(t, ValidatorOptions{
+			MaxSingleFunctionRatio: 0.9,
+		})
+
+		err := validator.ValidateCPUProfile(writeFunctionProfile(t, map[string]int64{
+			"runtime.gopark": 95,
+			"main.handle":    5,
+		}))
+		if err == nil {
+			t.Fatalf("expected validation error")
+		}
+
+		if !strings.Contains(err.Error(), "runtime.gopark") {
+			t.Fatalf("expected offending function in error, got %v", err)
+		}
+	})
+
+	t.Run("accepts profile below single function ratio", func(t *testing.T) {
+		validator := mustNewValidator(t, ValidatorOptions{
+			MaxSingleFunctionRatio: 0.9,
+		})
+
+		err := validator.ValidateCPUProfile(writeFunctionProfile(t, map[string]int64{
+			"runtime.gopark": 60,
+			"main.handle":    40,
+		}))
+		if err != nil {
+			t.Fatalf("validate profile: %v", err)
+		}
+	})
+
+	t.Run("standard strictness requires minimum samples", func(t *testing.T) {
+		validator := mustNewValidator(t, ValidatorOptions{
+			Strictness: StrictnessStandard,
+		})
+
+		err := validator.ValidateCPUProfile(writeFunctionProfile(t, map[string]int64{
+			"main.handle": 10,
+		}))
+		if err == nil || !strings.Contains(err.Error(), "minimum 100") {
+			t.Fatalf("expected minimum samples error, got %v", err)
+		}
+	})
+
+	t.Run("individual options override strictness preset", func(t *testing.T) {
+		validator := mustNewValidator(t, ValidatorOptions{
+			Strictness: StrictnessStrict,
+			MinSamples: 1,
+		})
+
+		err := validator.ValidateCPUProfile(writeFunctionProfile(t, map[string]int64{
+			"main.handle": 5,
+			"main.encode": 5,
+		}))
+		if err != nil {
+			t.Fatalf("validate profile: %v", err)
+		}
+	})
+
+	t.Run("explicit zero options disable preset checks", func(t *testing.T) {
+		validator := mustNewValidator(t, ValidatorOptions{
+			Strictness: StrictnessStrict,
+			MinSamples: 1,
+			Explicit:   []PresetOption{PresetMaxSingleFunctionRatio},
+		})
+
+		err := validator.ValidateCPUProfile(writeFunctionProfile(t, map[string]int64{
+			"main.spin": 99,
+			"main.idle": 1,
+		}))
+		if err != nil {
+			t.Fatalf("validate profile: %v", err)
+		}
+	})
+
+	t.Run("rejects unknown strictness", func(t *testing.T) {
+		if _, err := NewValidatorWithOptions(ValidatorOptions{Strictness: "paranoid"}); err == nil {
+			t.Fatalf("expected strictness error")
+		}
+	})
+
+	t.Run("rejects profiles shorter than the requested window", func(t *testing.T) {
+		parsed, err := profile.ParseData(writeProfile(t, ""))
+		if err != nil {
+			t.Fatalf("parse profile: %v", err)
+		}
+		parsed.DurationNanos = int64(12 * time.Second)
+
+		var raw bytes.Buffer
+		if err := parsed.Write(&raw); err != nil {
+			t.Fatalf("write profile: %v", err)
+		}
+
+		validator := mustNewValidator(t, ValidatorOptions{MinDurationFraction: 0.8})
+		err = validator.ValidateCPUProfileWithExpectations(raw.Bytes(), cpgo.ProfileExpectations{Seconds: 30})
+		if err == nil || !strings.Contains(err.Error(), "12s of the requested 30s") {
+			t.Fatalf("expected duration error, got %v", err)
+		}
+
+		if err := validator.ValidateCPUProfileWithExpectations(raw.Bytes(), cpgo.ProfileExpectations{Seconds: 15}); err != nil {
+			t.Fatalf("expected profile within fraction to pass, got %v", err)
+		}
+
+		if err := validator.ValidateCPUProfile(raw.Bytes()); err != nil {
+			t.Fatalf("expected check to be skipped without expectations, got %v", err)
+		}
+	})
+
+	t.Run("requires the sample type of non-cpu profiles", func(t *testing.T) {
+		validator := NewValidator()
+
+		err := validator.ValidateCPUProfileWithExpectations(writeProfile(t, ""), cpgo.ProfileExpectations{ProfileType: cpgo.ProfileTypeHeap})
+		if err == nil || !strings.Contains(err.Error(), `heap profile has no "inuse_space" sample type`) {
+			t.Fatalf("expected missing heap sample type error, got %v", err)
+		}
+
+		if err := validator.ValidateCPUProfileWithExpectations(writeProfile(t, ""), cpgo.ProfileExpectations{ProfileType: cpgo.ProfileTypeCPU}); err != nil {
+			t.Fatalf("expected lenient cpu profile to pass, got %v", err)
+		}
+	})
+
+	t.Run("rejects profiles without cpu sample types", func(t *testing.T) {
+		heapProfile := &profile.Profile{
+			SampleType: []*profile.ValueType{
+				{Type: "alloc_objects", Unit: "count"},
+				{Type: "inuse_space", Unit: "bytes"},
+			},
+			Sample: []*profile.Sample{{Value: []int64{1, 512}}},
+		}
+
+		var raw bytes.Buffer
+		if err := heapProfile.Write(&raw); err != nil {
+			t.Fatalf("write heap profile: %v", err)
+		}
+
+		standard, err := NewValidatorWithOptions(ValidatorOptions{Strictness: StrictnessStandard, MinSamples: 1})
+		if err != nil {
+			t.Fatalf("new validator: %v", err)
+		}
+
+		err = standard.ValidateCPUProfile(raw.Bytes())
+		if err == nil || !strings.Contains(err.Error(), "alloc_objects/count, inuse_space/bytes") {
+			t.Fatalf("expected actual sample types in error, got %v", err)
+		}
+
+		if err := NewValidator().ValidateCPUProfile(raw.Bytes()); err != nil {
+			t.Fatalf("expected lenient validation to skip the sample type check, got %v", err)
+		}
+
+		disabled, err := NewValidatorWithOptions(ValidatorOptions{
+			Strictness: StrictnessStandard,
+			Explicit:   []PresetOption{PresetRequireCPUSampleType},
+			MinSamples: 1,
+		})
+		if err != nil {
+			t.Fatalf("new validator: %v", err)
+		}
+
+		if err := disabled.ValidateCPUProfile(raw.Bytes()); err != nil {
+			t.Fatalf("expected an explicit false to disable the sample type check, got %v", err)
+		}
+
+		validator, err := NewValidatorWithOptions(ValidatorOptions{CPUSampleTypes: []string{"inuse_space/bytes"}})
+		if err != nil {
+			t.Fatalf("new validator: %v", err)
+		}
+
+		if err := validator.ValidateCPUProfile(raw.Bytes()); err != nil {
+			t.Fatalf("expected configured sample type to pass, got %v", err)
+		}
+
+		if _, err := NewValidatorWithOptions(ValidatorOptions{CPUSampleTypes: []string{"cpu"}}); err == nil {
+			t.Fatalf("expected sample type without unit to be rejected")
+		}
+	})
+
 	t.Run("rejects invalid profile payload", func(t *testing.T) {
 		validator := NewValidator()
 		if err := validator.ValidateCPUProfile([]byte("not-a-profile")); err == nil {
@@ -48,3 +256,106 @@ func TestValidatorValidateCPUProfile(t *testing.T) {
 		}
 	})
 }
+
+func TestValidatorCountSamples(t *testing.T) {
+	samples, err := NewValidator().CountSamples(writeFunctionProfile(t, map[string]int64{"main.hot": 30, "main.warm": 12}))
+	if err != nil {
+		t.Fatalf("count samples: %v", err)
+	}
+
+	if samples != 42 {
+		t.Fatalf("expected 42 samples, got %d", samples)
+	}
+}
+
+func writeProfile(t *testing.T, defaultSampleType string) []byte {
+	t.Helper()
+
+	location := &profile.Location{
+		ID: 1,
+	}
+
+	cpuProfile := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{
+				Type: "samples",
+				Unit: "count",
+			},
+			{
+				Type: "cpu",
+				Unit: "nanoseconds",
+			},
+		},
+		DefaultSampleType: defaultSampleType,
+		Location:          []*profile.Location{location},
+		Sample: []*profile.Sample{
+			{
+				Value:    []int64{1, 10_000_000},
+				Location: []*profile.Location{location},
+			},
+		},
+	}
+
+	var raw bytes.Buffer
+	if err := cpuProfile.Write(&raw); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+
+	return raw.Bytes()
+}
+
+// writeFunctionProfile encodes a CPU profile with one leaf sample per function.
+func writeFunctionProfile(t *testing.T, flatByFunction map[string]int64) []byte {
+	t.Helper()
+
+	cpuProfile := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{
+				Type: "samples",
+				Unit: "count",
+			},
+			{
+				Type: "cpu",
+				Unit: "nanoseconds",
+			},
+		},
+	}
+
+	var id uint64
+	for name, value := range flatByFunction {
+		id++
+		function := &profile.Function{
+			ID:   id,
+			Name: name,
+		}
+		location := &profile.Location{
+			ID:   id,
+			Line: []profile.Line{{Function: function}},
+		}
+
+		cpuProfile.Function = append(cpuProfile.Function, function)
+		cpuProfile.Location = append(cpuProfile.Location, location)
+		cpuProfile.Sample = append(cpuProfile.Sample, &profile.Sample{
+			Value:    []int64{value, value * 10_000_000},
+			Location: []*profile.Location{location},
+		})
+	}
+
+	var raw bytes.Buffer
+	if err := cpuProfile.Write(&raw); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+
+	return raw.Bytes()
+}
+
+func mustNewValidator(t *testing.T, options ValidatorOptions) *Validator {
+	t.Helper()
+
+	validator, err := NewValidatorWithOptions(options)
+	if err != nil {
+		t.Fatalf("new validator: %v", err)
+	}
+
+	return validator
+}
@@ -0,0 +1,207 @@
+package profilediff
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/google/pprof/profile"
+
+	"cpgo"
+)
+
+const (
+	defaultTopFunctions = 50
+	defaultMaxDiffBytes = 16 * 1024
+	truncationNotice    = "... diff truncated"
+)
+
+// Options bounds the size of rendered profile comparisons.
+type Options struct {
+	TopFunctions int
+	MaxDiffBytes int
+}
+
+// Comparer renders reviewer-facing comparisons between two pprof profiles.
+type Comparer struct {
+	options Options
+}
+
+var _ cpgo.ProfileComparer = (*Comparer)(nil)
+
+// NewComparer returns a comparer with defaults applied to unset options.
+func NewComparer(options Options) *Comparer {
+	if options.TopFunctions <= 0 {
+		options.TopFunctions = defaultTopFunctions
+	}
+
+	if options.MaxDiffBytes <= 0 {
+		options.MaxDiffBytes = defaultMaxDiffBytes
+	}
+
+	return &Comparer{
+		options: options,
+	}
+}
+
+// CompareProfiles diffs the `-top` style listings of the previous and current profiles.
+func (comparer *Comparer) CompareProfiles(previous []byte, current []byte) (cpgo.ProfileComparison, error) {
+	previousProfile, err := profile.ParseData(previous)
+	if err != nil {
+		return cpgo.ProfileComparison{}, fmt.Errorf("parse previous profile: %w", err)
+	}
+
+	currentProfile, err := profile.ParseData(current)
+	if err != nil {
+		return cpgo.ProfileComparison{}, fmt.Errorf("parse current profile: %w", err)
+	}
+
+	textDiff := unifiedDiff(
+		"previous",
+		"current",
+		renderTop(previousProfile, comparer.options.TopFunctions),
+		renderTop(currentProfile, comparer.options.TopFunctions),
+	)
+
+	return cpgo.ProfileComparison{
+		TextDiff:    truncate(textDiff, comparer.options.MaxDiffBytes),
+		ChangeRatio: changeRatio(previousProfile, currentProfile),
+		SampleDelta: sampleDelta(previousProfile, currentProfile),
+		Similarity:  cosineSimilarity(previousProfile, currentProfile),
+	}, nil
+}
+
+// changeRatio is the total variation distance between the flat sample shares of two profiles.
+func changeRatio(previous *profile.Profile, current *profile.Profile) float64 {
+	previousShares := flatShares(previous)
+	currentShares := flatShares(current)
+
+	var distance float64
+	for name, share := range previousShares {
+		distance += math.Abs(share - currentShares[name])
+	}
+
+	for name, share := range currentShares {
+		if _, ok := previousShares[name]; !ok {
+			distance += share
+		}
+	}
+
+	return distance / 2
+}
+
+// sampleDelta sums the absolute per-function differences in flat sample counts.
+func sampleDelta(previous *profile.Profile, current *profile.Profile) int64 {
+	previousCounts := flatCounts(previous)
+	currentCounts := flatCounts(current)
+
+	var delta int64
+	for name, count := range previousCounts {
+		delta += absInt64(count - currentCounts[name])
+	}
+
+	for name, count := range currentCounts {
+		if _, ok := previousCounts[name]; !ok {
+			delta += absInt64(count)
+		}
+	}
+
+	return delta
+}
+
+// cosineSimilarity compares the per-function flat sample count vectors of two profiles.
+// Two empty profiles are identical; an empty profile shares nothing with a sampled one.
+func cosineSimilarity(previous *profile.Profile, current *profile.Profile) float64 {
+	previousCounts := flatCounts(previous)
+	currentCounts := flatCounts(current)
+
+	var dot, previousNorm, currentNorm float64
+	for name, count := range previousCounts {
+		dot += float64(count) * float64(currentCounts[name])
+		previousNorm += float64(count) * float64(count)
+	}
+
+	for _, count := range currentCounts {
+		currentNorm += float64(count) * float64(count)
+	}
+
+	if previousNorm == 0 && currentNorm == 0 {
+		return 1
+	}
+
+	if previousNorm == 0 || currentNorm == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(previousNorm) * math.Sqrt(currentNorm))
+}
+
+// flatCounts maps each function to its flat sample count.
+func flatCounts(parsed *profile.Profile) map[string]int64 {
+	stats, _ := functionStats(parsed, sampleCountIndex(parsed))
+
+	counts := make(map[string]int64, len(stats))
+	for _, stat := range stats {
+		if stat.Flat != 0 {
+			counts[stat.Name] = stat.Flat
+		}
+	}
+
+	return counts
+}
+
+// sampleCountIndex prefers the samples/count value and falls back to the default sample type.
+func sampleCountIndex(parsed *profile.Profile) int {
+	for index, valueType := range parsed.SampleType {
+		if valueType.Type == "samples" {
+			return index
+		}
+	}
+
+	return sampleIndex(parsed)
+}
+
+func absInt64(value int64) int64 {
+	if value < 0 {
+		return -value
+	}
+
+	return value
+}
+
+// flatShares maps each function to its share of total flat samples.
+func flatShares(parsed *profile.Profile) map[string]float64 {
+	stats, total := functionStats(parsed, sampleIndex(parsed))
+
+	shares := make(map[string]float64, len(stats))
+	if total == 0 {
+		return shares
+	}
+
+	for _, stat := range stats {
+		if stat.Flat != 0 {
+			shares[stat.Name] = float64(stat.Flat) / float64(total)
+		}
+	}
+
+	return shares
+}
+
+// truncate cuts text at a line boundary so it fits within maxBytes.
+func truncate(text string, maxBytes int) string {
+	if len(text) <= maxBytes {
+		return text
+	}
+
+	limit := maxBytes - len(truncationNotice) - 1
+	if limit < 0 {
+		limit = 0
+	}
+
+	cut := strings.LastIndexByte(text[:limit], '\n')
+	if cut < 0 {
+		return truncationNotice + "\n"
+	}
+
+	return text[:cut+1] + truncationNotice + "\n"
+}
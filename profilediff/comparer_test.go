@@ -0,0 +1,161 @@
+package profilediff
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+func TestComparerCompareProfiles(t *testing.T) {
+	t.Run("renders a unified diff of top listings", func(t *testing.T) {
+		comparer := NewComparer(Options{})
+
+		previous := writeProfile(t, map[string]int64{
+			"main.encode": 70,
+			"main.decode": 30,
+		})
+		current := writeProfile(t, map[string]int64{
+			"main.encode": 20,
+			"main.decode": 80,
+		})
+
+		comparison, err := comparer.CompareProfiles(previous, current)
+		if err != nil {
+			t.Fatalf("compare profiles: %v", err)
+		}
+
+		if !strings.HasPrefix(comparison.TextDiff, "--- previous\n+++ current\n") {
+			t.Fatalf("expected unified diff header, got %q", comparison.TextDiff)
+		}
+
+		if !strings.Contains(comparison.TextDiff, "-") || !strings.Contains(comparison.TextDiff, "main.decode") {
+			t.Fatalf("expected changed function lines, got %q", comparison.TextDiff)
+		}
+	})
+
+	t.Run("returns empty diff for equivalent profiles", func(t *testing.T) {
+		comparer := NewComparer(Options{})
+
+		raw := writeProfile(t, map[string]int64{"main.encode": 10})
+		comparison, err := comparer.CompareProfiles(raw, raw)
+		if err != nil {
+			t.Fatalf("compare profiles: %v", err)
+		}
+
+		if comparison.TextDiff != "" {
+			t.Fatalf("expected empty diff, got %q", comparison.TextDiff)
+		}
+
+		if math.Abs(comparison.Similarity-1) > 1e-9 {
+			t.Fatalf("expected similarity 1, got %v", comparison.Similarity)
+		}
+	})
+
+	t.Run("measures the share of samples that moved", func(t *testing.T) {
+		comparer := NewComparer(Options{})
+
+		comparison, err := comparer.CompareProfiles(
+			writeProfile(t, map[string]int64{"main.encode": 75, "main.decode": 25}),
+			writeProfile(t, map[string]int64{"main.encode": 50, "main.decode": 25, "main.handle": 25}),
+		)
+		if err != nil {
+			t.Fatalf("compare profiles: %v", err)
+		}
+
+		if math.Abs(comparison.ChangeRatio-0.25) > 1e-9 {
+			t.Fatalf("expected change ratio 0.25, got %v", comparison.ChangeRatio)
+		}
+
+		if comparison.SampleDelta != 50 {
+			t.Fatalf("expected sample delta 50, got %d", comparison.SampleDelta)
+		}
+
+		// (75*50 + 25*25) / (sqrt(75²+25²) * sqrt(50²+25²+25²))
+		expectedSimilarity := 4375 / (math.Sqrt(6250) * math.Sqrt(3750))
+		if math.Abs(comparison.Similarity-expectedSimilarity) > 1e-9 {
+			t.Fatalf("expected similarity %v, got %v", expectedSimilarity, comparison.Similarity)
+		}
+	})
+
+	t.Run("bounds the diff size", func(t *testing.T) {
+		comparer := NewComparer(Options{MaxDiffBytes: 128})
+
+		previousValues := make(map[string]int64)
+		currentValues := make(map[string]int64)
+		for index := range 40 {
+			name := "main.function" + strings.Repeat("x", index)
+			previousValues[name] = int64(index + 1)
+			currentValues[name] = int64(100 - index)
+		}
+
+		comparison, err := comparer.CompareProfiles(writeProfile(t, previousValues), writeProfile(t, currentValues))
+		if err != nil {
+			t.Fatalf("compare profiles: %v", err)
+		}
+
+		if len(comparison.TextDiff) > 128 {
+			t.Fatalf("expected diff within 128 bytes, got %d", len(comparison.TextDiff))
+		}
+
+		if !strings.HasSuffix(comparison.TextDiff, truncationNotice+"\n") {
+			t.Fatalf("expected truncation notice, got %q", comparison.TextDiff)
+		}
+	})
+
+	t.Run("returns error for invalid previous profile", func(t *testing.T) {
+		comparer := NewComparer(Options{})
+
+		_, err := comparer.CompareProfiles([]byte("not-a-profile"), writeProfile(t, map[string]int64{"main.encode": 1}))
+		if err == nil {
+			t.Fatalf("expected parse error")
+		}
+	})
+}
+
+// writeProfile encodes a CPU profile with one leaf sample per function.
+func writeProfile(t *testing.T, flatByFunction map[string]int64) []byte {
+	t.Helper()
+
+	cpuProfile := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{
+				Type: "samples",
+				Unit: "count",
+			},
+			{
+				Type: "cpu",
+				Unit: "nanoseconds",
+			},
+		},
+	}
+
+	var id uint64
+	for name, value := range flatByFunction {
+		id++
+		function := &profile.Function{
+			ID:   id,
+			Name: name,
+		}
+		location := &profile.Location{
+			ID:   id,
+			Line: []profile.Line{{Function: function}},
+		}
+
+		cpuProfile.Function = append(cpuProfile.Function, function)
+		cpuProfile.Location = append(cpuProfile.Location, location)
+		cpuProfile.Sample = append(cpuProfile.Sample, &profile.Sample{
+			Value:    []int64{value, value * 10_000_000},
+			Location: []*profile.Location{location},
+		})
+	}
+
+	var raw bytes.Buffer
+	if err := cpuProfile.Write(&raw); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+
+	return raw.Bytes()
+}
@@ -0,0 +1,162 @@
+package profilediff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/pprof/profile"
+
+	"cpgo"
+)
+
+const (
+	defaultFlatDiffTopFunctions = 20
+	noExistingProfile           = "no existing profile\n"
+)
+
+// FlatDiffer renders the flat value changes of the hottest functions for terminal output.
+type FlatDiffer struct {
+	topFunctions int
+}
+
+var _ cpgo.ProfileDiffer = (*FlatDiffer)(nil)
+
+// NewFlatDiffer returns a differ covering the top functions of each profile, 20 by default.
+func NewFlatDiffer(topFunctions int) *FlatDiffer {
+	if topFunctions <= 0 {
+		topFunctions = defaultFlatDiffTopFunctions
+	}
+
+	return &FlatDiffer{
+		topFunctions: topFunctions,
+	}
+}
+
+// flatDiffRow is one function's flat value in the base and candidate profiles.
+type flatDiffRow struct {
+	Name      string
+	Base      int64
+	Candidate int64
+	InBase    bool
+	InCurrent bool
+}
+
+// DiffProfiles lists the top functions of either profile, largest flat change first.
+// Lines start with - for functions only in the base profile, + for functions only in
+// the candidate and a space for functions in both.
+func (differ *FlatDiffer) DiffProfiles(previous []byte, current []byte) (string, error) {
+	if previous == nil {
+		return noExistingProfile, nil
+	}
+
+	previousProfile, err := profile.ParseData(previous)
+	if err != nil {
+		return "", fmt.Errorf("parse previous profile: %w", err)
+	}
+
+	currentProfile, err := profile.ParseData(current)
+	if err != nil {
+		return "", fmt.Errorf("parse current profile: %w", err)
+	}
+
+	index := sampleIndex(currentProfile)
+	unit := ""
+	if index >= 0 && index < len(currentProfile.SampleType) {
+		unit = currentProfile.SampleType[index].Unit
+	}
+
+	previousStats, _ := functionStats(previousProfile, sampleIndex(previousProfile))
+	currentStats, _ := functionStats(currentProfile, index)
+
+	rows := make(map[string]*flatDiffRow)
+	row := func(name string) *flatDiffRow {
+		if rows[name] == nil {
+			rows[name] = &flatDiffRow{Name: name}
+		}
+
+		return rows[name]
+	}
+
+	for position, stat := range previousStats {
+		if position < differ.topFunctions {
+			row(stat.Name)
+		}
+	}
+
+	for position, stat := range currentStats {
+		if position < differ.topFunctions {
+			row(stat.Name)
+		}
+	}
+
+	for _, stat := range previousStats {
+		if existing := rows[stat.Name]; existing != nil {
+			existing.Base, existing.InBase = stat.Flat, true
+		}
+	}
+
+	for _, stat := range currentStats {
+		if existing := rows[stat.Name]; existing != nil {
+			existing.Candidate, existing.InCurrent = stat.Flat, true
+		}
+	}
+
+	sorted := make([]flatDiffRow, 0, len(rows))
+	for _, entry := range rows {
+		sorted = append(sorted, *entry)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		left := absInt64(sorted[i].Candidate - sorted[i].Base)
+		right := absInt64(sorted[j].Candidate - sorted[j].Base)
+		if left != right {
+			return left > right
+		}
+
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	var builder strings.Builder
+	builder.WriteString("--- base\n+++ candidate\n")
+	_, _ = fmt.Fprintf(&builder, " %10s %10s %10s  %s\n", "base", "candidate", "delta", "function")
+	for _, entry := range sorted {
+		prefix := " "
+		switch {
+		case !entry.InBase:
+			prefix = "+"
+		case !entry.InCurrent:
+			prefix = "-"
+		}
+
+		_, _ = fmt.Fprintf(
+			&builder,
+			"%s%10s %10s %10s  %s\n",
+			prefix,
+			formatPresentValue(entry.Base, entry.InBase, unit),
+			formatPresentValue(entry.Candidate, entry.InCurrent, unit),
+			formatDelta(entry.Candidate-entry.Base, unit),
+			entry.Name,
+		)
+	}
+
+	return builder.String(), nil
+}
+
+// formatPresentValue formats a flat value, or - for a function missing from the profile.
+func formatPresentValue(value int64, isPresent bool, unit string) string {
+	if !isPresent {
+		return "-"
+	}
+
+	return formatValue(value, unit)
+}
+
+// formatDelta formats a signed flat value change.
+func formatDelta(delta int64, unit string) string {
+	if delta > 0 {
+		return "+" + formatValue(delta, unit)
+	}
+
+	return formatValue(delta, unit)
+}
@@ -0,0 +1,42 @@
+package profilediff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFlatDifferDiffProfiles(t *testing.T) {
+	t.Run("lists flat changes of the top functions", func(t *testing.T) {
+		diff, err := NewFlatDiffer(3).DiffProfiles(
+			writeProfile(t, map[string]int64{"main.encode": 60, "main.decode": 30, "main.idle": 10}),
+			writeProfile(t, map[string]int64{"main.encode": 30, "main.decode": 30, "main.handle": 40}),
+		)
+		if err != nil {
+			t.Fatalf("diff profiles: %v", err)
+		}
+
+		expected := []string{
+			"--- base",
+			"+++ candidate",
+			"       base  candidate      delta  function",
+			"+         -      400ms     +400ms  main.handle",
+			"      600ms      300ms     -300ms  main.encode",
+			"-     100ms          -     -100ms  main.idle",
+			"      300ms      300ms         0s  main.decode",
+		}
+		if strings.TrimSuffix(diff, "\n") != strings.Join(expected, "\n") {
+			t.Fatalf("unexpected diff:\n%s", diff)
+		}
+	})
+
+	t.Run("reports a missing base profile", func(t *testing.T) {
+		diff, err := NewFlatDiffer(0).DiffProfiles(nil, writeProfile(t, map[string]int64{"main.handle": 40}))
+		if err != nil {
+			t.Fatalf("diff profiles: %v", err)
+		}
+
+		if diff != "no existing profile\n" {
+			t.Fatalf("expected missing base notice, got %q", diff)
+		}
+	})
+}
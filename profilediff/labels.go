@@ -0,0 +1,77 @@
+package profilediff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/pprof/profile"
+
+	"cpgo"
+)
+
+const (
+	hotLabelPrefix = "hot:"
+	// maxLabelLength is the GitHub label name limit.
+	maxLabelLength = 50
+)
+
+// Labeler derives triage labels from the hottest package of a profile.
+type Labeler struct{}
+
+var _ cpgo.ProfileLabeler = (*Labeler)(nil)
+
+// NewLabeler returns a hot package labeler.
+func NewLabeler() *Labeler {
+	return &Labeler{}
+}
+
+// ProfileLabels returns a `hot:<package>` label for the package with the largest flat share.
+func (labeler *Labeler) ProfileLabels(raw []byte) ([]string, error) {
+	parsed, err := profile.ParseData(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse profile: %w", err)
+	}
+
+	stats, _ := functionStats(parsed, sampleIndex(parsed))
+
+	flatByPackage := make(map[string]int64)
+	var hottestPackage string
+	for _, stat := range stats {
+		packageName := functionPackage(stat.Name)
+		if packageName == "" {
+			continue
+		}
+
+		flatByPackage[packageName] += stat.Flat
+		if hottestPackage == "" || flatByPackage[packageName] > flatByPackage[hottestPackage] ||
+			(flatByPackage[packageName] == flatByPackage[hottestPackage] && packageName < hottestPackage) {
+			hottestPackage = packageName
+		}
+	}
+
+	if hottestPackage == "" || flatByPackage[hottestPackage] <= 0 {
+		return nil, nil
+	}
+
+	label := hotLabelPrefix + hottestPackage
+	if len(label) > maxLabelLength {
+		label = label[:maxLabelLength]
+	}
+
+	return []string{label}, nil
+}
+
+// functionPackage extracts the import path from a symbol such as encoding/json.(*decodeState).object.
+func functionPackage(functionName string) string {
+	if strings.HasPrefix(functionName, "0x") {
+		return ""
+	}
+
+	lastSlash := strings.LastIndex(functionName, "/")
+	dot := strings.Index(functionName[lastSlash+1:], ".")
+	if dot < 0 {
+		return ""
+	}
+
+	return functionName[:lastSlash+1+dot]
+}
@@ -0,0 +1,35 @@
+package profilediff
+
+import "testing"
+
+func TestLabelerProfileLabels(t *testing.T) {
+	labeler := NewLabeler()
+
+	labels, err := labeler.ProfileLabels(writeProfile(t, map[string]int64{
+		"encoding/json.(*decodeState).object": 40,
+		"encoding/json.Unmarshal":             30,
+		"main.handle":                         50,
+	}))
+	if err != nil {
+		t.Fatalf("profile labels: %v", err)
+	}
+
+	if len(labels) != 1 || labels[0] != "hot:encoding/json" {
+		t.Fatalf("expected hot:encoding/json, got %v", labels)
+	}
+}
+
+func TestFunctionPackage(t *testing.T) {
+	cases := map[string]string{
+		"encoding/json.(*decodeState).object": "encoding/json",
+		"github.com/acme/svc/pkg.Handle":      "github.com/acme/svc/pkg",
+		"runtime.mallocgc":                    "runtime",
+		"0x4010":                              "",
+	}
+
+	for functionName, want := range cases {
+		if got := functionPackage(functionName); got != want {
+			t.Fatalf("functionPackage(%q) = %q, want %q", functionName, got, want)
+		}
+	}
+}
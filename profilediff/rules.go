@@ -0,0 +1,106 @@
+package profilediff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/pprof/profile"
+
+	"cpgo"
+)
+
+// Rule asserts how one function must appear in a new profile.
+// Present requires the function to have samples; MinCumPercent requires its
+// cumulative share of samples to stay at or above the given percentage.
+type Rule struct {
+	Function      string
+	Present       bool
+	MinCumPercent float64
+}
+
+// Policy checks profile changes against configured rules.
+type Policy struct {
+	rules []Rule
+}
+
+var _ cpgo.DiffPolicy = (*Policy)(nil)
+
+// NewPolicy validates rules and returns a policy enforcing them.
+func NewPolicy(rules []Rule) (*Policy, error) {
+	for index, rule := range rules {
+		if strings.TrimSpace(rule.Function) == "" {
+			return nil, fmt.Errorf("diff rule %d: function is required", index)
+		}
+
+		if rule.MinCumPercent < 0 || rule.MinCumPercent > 100 {
+			return nil, fmt.Errorf("diff rule %d: min cum percent must be between 0 and 100", index)
+		}
+
+		if !rule.Present && rule.MinCumPercent == 0 {
+			return nil, fmt.Errorf("diff rule %d: rule for %s asserts nothing", index, rule.Function)
+		}
+	}
+
+	return &Policy{
+		rules: rules,
+	}, nil
+}
+
+// CheckProfileDiff reports every rule the current profile violates, citing the previous share when known.
+func (policy *Policy) CheckProfileDiff(previous []byte, current []byte) error {
+	currentProfile, err := profile.ParseData(current)
+	if err != nil {
+		return fmt.Errorf("parse current profile: %w", err)
+	}
+	currentShares := cumPercents(currentProfile)
+
+	var previousShares map[string]float64
+	if len(previous) > 0 {
+		if previousProfile, err := profile.ParseData(previous); err == nil {
+			previousShares = cumPercents(previousProfile)
+		}
+	}
+
+	var violations []error
+	for _, rule := range policy.rules {
+		share, isPresent := currentShares[rule.Function]
+		if rule.Present && !isPresent {
+			violations = append(violations, fmt.Errorf("%s is no longer sampled%s", rule.Function, previousShareNote(previousShares, rule.Function)))
+			continue
+		}
+
+		if rule.MinCumPercent > 0 && share < rule.MinCumPercent {
+			violations = append(violations, fmt.Errorf("%s cum %.2f%% is below %.2f%%%s",
+				rule.Function, share, rule.MinCumPercent, previousShareNote(previousShares, rule.Function)))
+		}
+	}
+
+	return errors.Join(violations...)
+}
+
+// cumPercents maps each sampled function to its cumulative share of samples in percent.
+func cumPercents(parsed *profile.Profile) map[string]float64 {
+	stats, total := functionStats(parsed, sampleIndex(parsed))
+
+	percents := make(map[string]float64, len(stats))
+	if total == 0 {
+		return percents
+	}
+
+	for _, stat := range stats {
+		if stat.Cum != 0 {
+			percents[stat.Name] = float64(stat.Cum) / float64(total) * 100
+		}
+	}
+
+	return percents
+}
+
+func previousShareNote(previousShares map[string]float64, function string) string {
+	if previousShares == nil {
+		return ""
+	}
+
+	return fmt.Sprintf(" (was %.2f%%)", previousShares[function])
+}
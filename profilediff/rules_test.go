@@ -0,0 +1,54 @@
+package profilediff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheckProfileDiff(t *testing.T) {
+	previous := writeProfile(t, map[string]int64{"main.checkout": 20, "main.encode": 80})
+
+	t.Run("accepts profiles satisfying every rule", func(t *testing.T) {
+		policy, err := NewPolicy([]Rule{
+			{Function: "main.checkout", Present: true},
+			{Function: "main.encode", MinCumPercent: 50},
+		})
+		if err != nil {
+			t.Fatalf("new policy: %v", err)
+		}
+
+		if err := policy.CheckProfileDiff(previous, previous); err != nil {
+			t.Fatalf("check profile diff: %v", err)
+		}
+	})
+
+	t.Run("reports every violated rule with the previous share", func(t *testing.T) {
+		policy, err := NewPolicy([]Rule{
+			{Function: "main.checkout", Present: true},
+			{Function: "main.encode", MinCumPercent: 95},
+		})
+		if err != nil {
+			t.Fatalf("new policy: %v", err)
+		}
+
+		err = policy.CheckProfileDiff(previous, writeProfile(t, map[string]int64{"main.encode": 90, "main.idle": 10}))
+		if err == nil {
+			t.Fatalf("expected rule violations")
+		}
+
+		for _, expected := range []string{
+			"main.checkout is no longer sampled (was 20.00%)",
+			"main.encode cum 90.00% is below 95.00% (was 80.00%)",
+		} {
+			if !strings.Contains(err.Error(), expected) {
+				t.Fatalf("expected %q in %v", expected, err)
+			}
+		}
+	})
+
+	t.Run("rejects rules that assert nothing", func(t *testing.T) {
+		if _, err := NewPolicy([]Rule{{Function: "main.checkout"}}); err == nil {
+			t.Fatalf("expected empty rule to be rejected")
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package profilediff
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/google/pprof/profile"
+
+	"cpgo"
+)
+
+const defaultSummaryTopFunctions = 10
+
+// Summarizer renders a markdown table of the functions whose flat share moved the most.
+type Summarizer struct {
+	topFunctions int
+}
+
+var _ cpgo.ProfileSummarizer = (*Summarizer)(nil)
+
+// NewSummarizer returns a summarizer covering the top functions of each profile, 10 by default.
+func NewSummarizer(topFunctions int) *Summarizer {
+	if topFunctions <= 0 {
+		topFunctions = defaultSummaryTopFunctions
+	}
+
+	return &Summarizer{
+		topFunctions: topFunctions,
+	}
+}
+
+// summaryRow is one function's flat share before and after, in percent.
+type summaryRow struct {
+	Name   string
+	Before float64
+	After  float64
+}
+
+// SummarizeProfileChange tabulates the top functions by flat share in either profile, largest movers first.
+func (summarizer *Summarizer) SummarizeProfileChange(previous []byte, current []byte) (string, error) {
+	previousProfile, err := profile.ParseData(previous)
+	if err != nil {
+		return "", fmt.Errorf("parse previous profile: %w", err)
+	}
+
+	currentProfile, err := profile.ParseData(current)
+	if err != nil {
+		return "", fmt.Errorf("parse current profile: %w", err)
+	}
+
+	previousShares := flatShares(previousProfile)
+	currentShares := flatShares(currentProfile)
+
+	names := make(map[string]bool)
+	for _, name := range topByShare(previousShares, summarizer.topFunctions) {
+		names[name] = true
+	}
+
+	for _, name := range topByShare(currentShares, summarizer.topFunctions) {
+		names[name] = true
+	}
+
+	rows := make([]summaryRow, 0, len(names))
+	for name := range names {
+		rows = append(rows, summaryRow{
+			Name:   name,
+			Before: previousShares[name] * 100,
+			After:  currentShares[name] * 100,
+		})
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		left := math.Abs(rows[i].After - rows[i].Before)
+		right := math.Abs(rows[j].After - rows[j].Before)
+		if left != right {
+			return left > right
+		}
+
+		return rows[i].Name < rows[j].Name
+	})
+
+	var builder strings.Builder
+	builder.WriteString("| Function | Before flat% | After flat% | Δ |\n")
+	builder.WriteString("| --- | ---: | ---: | ---: |\n")
+	for _, row := range rows {
+		_, _ = fmt.Fprintf(&builder, "| `%s` | %.2f%% | %.2f%% | %+.2f |\n",
+			strings.ReplaceAll(row.Name, "|", `\|`), row.Before, row.After, row.After-row.Before)
+	}
+
+	return builder.String(), nil
+}
+
+// topByShare returns up to limit function names with the largest shares.
+func topByShare(shares map[string]float64, limit int) []string {
+	names := make([]string, 0, len(shares))
+	for name := range shares {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		if shares[names[i]] != shares[names[j]] {
+			return shares[names[i]] > shares[names[j]]
+		}
+
+		return names[i] < names[j]
+	})
+
+	if len(names) > limit {
+		names = names[:limit]
+	}
+
+	return names
+}
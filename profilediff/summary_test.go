@@ -0,0 +1,30 @@
+package profilediff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSummarizerSummarizeProfileChange(t *testing.T) {
+	summarizer := NewSummarizer(2)
+
+	summary, err := summarizer.SummarizeProfileChange(
+		writeProfile(t, map[string]int64{"main.encode": 60, "main.decode": 30, "main.idle": 10}),
+		writeProfile(t, map[string]int64{"main.encode": 30, "main.decode": 30, "main.handle": 40}),
+	)
+	if err != nil {
+		t.Fatalf("summarize profile change: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(summary), "\n")
+	expected := []string{
+		"| Function | Before flat% | After flat% | Δ |",
+		"| --- | ---: | ---: | ---: |",
+		"| `main.handle` | 0.00% | 40.00% | +40.00 |",
+		"| `main.encode` | 60.00% | 30.00% | -30.00 |",
+		"| `main.decode` | 30.00% | 30.00% | +0.00 |",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected summary:\n%s", summary)
+	}
+}
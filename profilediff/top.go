@@ -0,0 +1,166 @@
+package profilediff
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/pprof/profile"
+)
+
+const cpuSampleType = "cpu"
+
+// functionStat aggregates flat and cumulative sample values for one function.
+type functionStat struct {
+	Name string
+	Flat int64
+	Cum  int64
+}
+
+// sampleIndex selects the value index pprof would report by default.
+func sampleIndex(parsed *profile.Profile) int {
+	preferred := []string{parsed.DefaultSampleType, cpuSampleType}
+	for _, sampleType := range preferred {
+		if sampleType == "" {
+			continue
+		}
+
+		for index, valueType := range parsed.SampleType {
+			if valueType.Type == sampleType {
+				return index
+			}
+		}
+	}
+
+	return len(parsed.SampleType) - 1
+}
+
+// functionStats aggregates per-function values sorted by descending flat value.
+func functionStats(parsed *profile.Profile, index int) ([]functionStat, int64) {
+	byName := make(map[string]*functionStat)
+	var total int64
+
+	for _, sample := range parsed.Sample {
+		if index < 0 || index >= len(sample.Value) {
+			continue
+		}
+
+		value := sample.Value[index]
+		total += value
+
+		seen := make(map[string]bool)
+		for locationIndex, location := range sample.Location {
+			for lineIndex, name := range locationFunctions(location) {
+				stat, ok := byName[name]
+				if !ok {
+					stat = &functionStat{Name: name}
+					byName[name] = stat
+				}
+
+				if locationIndex == 0 && lineIndex == 0 {
+					stat.Flat += value
+				}
+
+				if !seen[name] {
+					seen[name] = true
+					stat.Cum += value
+				}
+			}
+		}
+	}
+
+	stats := make([]functionStat, 0, len(byName))
+	for _, stat := range byName {
+		stats = append(stats, *stat)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Flat != stats[j].Flat {
+			return stats[i].Flat > stats[j].Flat
+		}
+
+		if stats[i].Cum != stats[j].Cum {
+			return stats[i].Cum > stats[j].Cum
+		}
+
+		return stats[i].Name < stats[j].Name
+	})
+
+	return stats, total
+}
+
+// locationFunctions lists function names for a location, innermost inline frame first.
+func locationFunctions(location *profile.Location) []string {
+	if location == nil {
+		return nil
+	}
+
+	if len(location.Line) == 0 {
+		return []string{fmt.Sprintf("0x%x", location.Address)}
+	}
+
+	names := make([]string, 0, len(location.Line))
+	for _, line := range location.Line {
+		if line.Function == nil || line.Function.Name == "" {
+			names = append(names, fmt.Sprintf("0x%x", location.Address))
+			continue
+		}
+
+		names = append(names, line.Function.Name)
+	}
+
+	return names
+}
+
+// renderTop produces a `go tool pprof -top` style listing of the hottest functions.
+func renderTop(parsed *profile.Profile, limit int) string {
+	index := sampleIndex(parsed)
+	stats, total := functionStats(parsed, index)
+
+	unit := ""
+	if index >= 0 && index < len(parsed.SampleType) {
+		unit = parsed.SampleType[index].Unit
+	}
+
+	var builder strings.Builder
+	_, _ = fmt.Fprintf(&builder, "%10s %7s %7s %10s %7s  %s\n", "flat", "flat%", "sum%", "cum", "cum%", "function")
+
+	var sum int64
+	for position, stat := range stats {
+		if limit > 0 && position >= limit {
+			break
+		}
+
+		sum += stat.Flat
+		_, _ = fmt.Fprintf(
+			&builder,
+			"%10s %6.2f%% %6.2f%% %10s %6.2f%%  %s\n",
+			formatValue(stat.Flat, unit),
+			percent(stat.Flat, total),
+			percent(sum, total),
+			formatValue(stat.Cum, unit),
+			percent(stat.Cum, total),
+			stat.Name,
+		)
+	}
+
+	return builder.String()
+}
+
+func percent(value int64, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(value) * 100 / float64(total)
+}
+
+func formatValue(value int64, unit string) string {
+	if unit == "nanoseconds" {
+		return time.Duration(value).Round(time.Millisecond).String()
+	}
+
+	return strconv.FormatInt(value, 10)
+}
@@ -0,0 +1,181 @@
+package profilediff
+
+import (
+	"fmt"
+	"strings"
+)
+
+const diffContextLines = 3
+
+// diffOp is one line-level edit operation.
+type diffOp struct {
+	Kind byte
+	Text string
+}
+
+// unifiedDiff renders a unified diff between two line-oriented texts.
+func unifiedDiff(fromName string, toName string, from string, to string) string {
+	fromLines := splitLines(from)
+	toLines := splitLines(to)
+	ops := diffLines(fromLines, toLines)
+
+	hasChange := false
+	for _, op := range ops {
+		if op.Kind != ' ' {
+			hasChange = true
+			break
+		}
+	}
+
+	if !hasChange {
+		return ""
+	}
+
+	var builder strings.Builder
+	_, _ = fmt.Fprintf(&builder, "--- %s\n+++ %s\n", fromName, toName)
+
+	for start := 0; start < len(ops); {
+		hunkStart, hunkEnd, ok := nextHunk(ops, start)
+		if !ok {
+			break
+		}
+
+		writeHunk(&builder, ops, hunkStart, hunkEnd)
+		start = hunkEnd
+	}
+
+	return builder.String()
+}
+
+// diffLines computes a minimal edit script using a longest-common-subsequence table.
+func diffLines(from []string, to []string) []diffOp {
+	lcs := make([][]int, len(from)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(to)+1)
+	}
+
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(from)+len(to))
+	i, j := 0, 0
+	for i < len(from) && j < len(to) {
+		switch {
+		case from[i] == to[j]:
+			ops = append(ops, diffOp{Kind: ' ', Text: from[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{Kind: '-', Text: from[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{Kind: '+', Text: to[j]})
+			j++
+		}
+	}
+
+	for ; i < len(from); i++ {
+		ops = append(ops, diffOp{Kind: '-', Text: from[i]})
+	}
+
+	for ; j < len(to); j++ {
+		ops = append(ops, diffOp{Kind: '+', Text: to[j]})
+	}
+
+	return ops
+}
+
+// nextHunk finds the next run of changes, padded with surrounding context.
+func nextHunk(ops []diffOp, start int) (int, int, bool) {
+	first := -1
+	for index := start; index < len(ops); index++ {
+		if ops[index].Kind != ' ' {
+			first = index
+			break
+		}
+	}
+
+	if first < 0 {
+		return 0, 0, false
+	}
+
+	end := first
+	unchanged := 0
+	for index := first; index < len(ops); index++ {
+		if ops[index].Kind != ' ' {
+			end = index + 1
+			unchanged = 0
+			continue
+		}
+
+		unchanged++
+		if unchanged > 2*diffContextLines {
+			break
+		}
+	}
+
+	hunkStart := max(first-diffContextLines, start)
+	hunkEnd := min(end+diffContextLines, len(ops))
+
+	return hunkStart, hunkEnd, true
+}
+
+// writeHunk emits one hunk header and its lines.
+func writeHunk(builder *strings.Builder, ops []diffOp, start int, end int) {
+	fromLine, toLine := 1, 1
+	for _, op := range ops[:start] {
+		if op.Kind != '+' {
+			fromLine++
+		}
+
+		if op.Kind != '-' {
+			toLine++
+		}
+	}
+
+	fromCount, toCount := 0, 0
+	for _, op := range ops[start:end] {
+		if op.Kind != '+' {
+			fromCount++
+		}
+
+		if op.Kind != '-' {
+			toCount++
+		}
+	}
+
+	_, _ = fmt.Fprintf(builder, "@@ -%s +%s @@\n", hunkRange(fromLine, fromCount), hunkRange(toLine, toCount))
+	for _, op := range ops[start:end] {
+		builder.WriteByte(op.Kind)
+		builder.WriteString(op.Text)
+		builder.WriteByte('\n')
+	}
+}
+
+func hunkRange(line int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", line-1)
+	}
+
+	if count == 1 {
+		return fmt.Sprintf("%d", line)
+	}
+
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+func splitLines(text string) []string {
+	trimmed := strings.TrimRight(text, "\n")
+	if trimmed == "" {
+		return nil
+	}
+
+	return strings.Split(trimmed, "\n")
+}
@@ -0,0 +1,34 @@
+package profilediff
+
+import (
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	t.Run("renders hunks with context", func(t *testing.T) {
+		diff := unifiedDiff("a", "b", "one\ntwo\nthree\n", "one\n2\nthree\n")
+
+		expected := "--- a\n+++ b\n@@ -1,3 +1,3 @@\n one\n-two\n+2\n three\n"
+		if diff != expected {
+			t.Fatalf("unexpected diff:\n%s", diff)
+		}
+	})
+
+	t.Run("splits distant changes into separate hunks", func(t *testing.T) {
+		from := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+		to := "x\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ny\n"
+
+		diff := unifiedDiff("a", "b", from, to)
+
+		expected := "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+y\n"
+		if diff != expected {
+			t.Fatalf("unexpected diff:\n%s", diff)
+		}
+	})
+
+	t.Run("returns empty string for identical input", func(t *testing.T) {
+		if diff := unifiedDiff("a", "b", "same\n", "same\n"); diff != "" {
+			t.Fatalf("expected empty diff, got %q", diff)
+		}
+	})
+}
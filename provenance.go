@@ -0,0 +1,127 @@
+package cpgo
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const redactedValue = "REDACTED"
+
+// sensitiveQueryKeys lists substrings that mark a query parameter as secret.
+var sensitiveQueryKeys = []string{
+	"token",
+	"key",
+	"secret",
+	"password",
+	"passwd",
+	"signature",
+	"credential",
+	"auth",
+}
+
+// redactURL renders a URL with user passwords and secret query values masked.
+func redactURL(source *url.URL) string {
+	if source == nil {
+		return ""
+	}
+
+	redacted := *source
+	if redacted.User != nil {
+		if _, hasPassword := redacted.User.Password(); hasPassword {
+			redacted.User = url.UserPassword(redacted.User.Username(), redactedValue)
+		} else if redacted.User.Username() != "" {
+			// A lone user name is commonly a token, as in https://<token>@host.
+			redacted.User = url.User(redactedValue)
+		}
+	}
+
+	if redacted.RawQuery != "" {
+		query := redacted.Query()
+		for key, values := range query {
+			if !isSensitiveQueryKey(key) {
+				continue
+			}
+
+			for index := range values {
+				values[index] = redactedValue
+			}
+		}
+
+		redacted.RawQuery = query.Encode()
+	}
+
+	return redacted.String()
+}
+
+func isSensitiveQueryKey(key string) bool {
+	normalizedKey := strings.ToLower(key)
+	for _, sensitiveKey := range sensitiveQueryKeys {
+		if strings.Contains(normalizedKey, sensitiveKey) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// trailerPattern matches one git trailer line, such as Signed-off-by: Jane <jane@example.com>.
+var trailerPattern = regexp.MustCompile(`^[A-Za-z0-9-]+: \S`)
+
+// profileProvenance records where and when a run collected its profile.
+// SourceURL is already redacted; zero Seconds means an instantaneous profile.
+type profileProvenance struct {
+	SourceURL   string
+	Seconds     int
+	CollectedAt time.Time
+}
+
+// appendProvenance adds a footer naming the redacted profile source, the sampling window
+// and the UTC collection time to a pull request body.
+func appendProvenance(body string, provenance profileProvenance) string {
+	if provenance.SourceURL == "" {
+		return body
+	}
+
+	details := "collected at " + provenance.CollectedAt.UTC().Format(time.RFC3339)
+	if provenance.Seconds > 0 {
+		details = fmt.Sprintf("%ds window, %s", provenance.Seconds, details)
+	}
+
+	return appendSection(body, "Profile source: `"+provenance.SourceURL+"` ("+details+")")
+}
+
+// appendProvenanceTrailers adds Profile-Source, Profile-Window and Profile-Collected-At
+// trailers to a commit message, joining an existing trailer block such as a DCO sign-off.
+func appendProvenanceTrailers(message string, provenance profileProvenance) string {
+	if provenance.SourceURL == "" {
+		return message
+	}
+
+	trailers := []string{"Profile-Source: " + provenance.SourceURL}
+	if provenance.Seconds > 0 {
+		trailers = append(trailers, fmt.Sprintf("Profile-Window: %ds", provenance.Seconds))
+	}
+	trailers = append(trailers, "Profile-Collected-At: "+provenance.CollectedAt.UTC().Format(time.RFC3339))
+
+	message = strings.TrimRight(message, "\n")
+	paragraphs := strings.Split(message, "\n\n")
+	if len(paragraphs) > 1 && isTrailerBlock(paragraphs[len(paragraphs)-1]) {
+		return message + "\n" + strings.Join(trailers, "\n")
+	}
+
+	return message + "\n\n" + strings.Join(trailers, "\n")
+}
+
+// isTrailerBlock reports whether every line of a paragraph is a git trailer.
+func isTrailerBlock(paragraph string) bool {
+	for line := range strings.SplitSeq(paragraph, "\n") {
+		if !trailerPattern.MatchString(line) {
+			return false
+		}
+	}
+
+	return true
+}
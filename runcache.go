@@ -0,0 +1,80 @@
+package cpgo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRunCacheSize = 128
+	defaultRunCacheTTL  = time.Hour
+)
+
+// RunCache remembers profiles already committed in this process so repeated runs skip GitHub writes.
+type RunCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]time.Time
+	order   []string
+}
+
+// NewRunCache returns a bounded cache; non-positive size or ttl fall back to defaults.
+func NewRunCache(size int, ttl time.Duration) *RunCache {
+	if size <= 0 {
+		size = defaultRunCacheSize
+	}
+
+	if ttl <= 0 {
+		ttl = defaultRunCacheTTL
+	}
+
+	return &RunCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]time.Time),
+	}
+}
+
+// contains reports whether the key was stored within the ttl.
+func (cache *RunCache) contains(key string, now time.Time) bool {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	storedAt, ok := cache.entries[key]
+	if !ok {
+		return false
+	}
+
+	if now.Sub(storedAt) > cache.ttl {
+		delete(cache.entries, key)
+		return false
+	}
+
+	return true
+}
+
+// store records the key, evicting the oldest entries beyond the size bound.
+func (cache *RunCache) store(key string, now time.Time) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if _, ok := cache.entries[key]; !ok {
+		cache.order = append(cache.order, key)
+	}
+	cache.entries[key] = now
+
+	for len(cache.order) > cache.size {
+		oldest := cache.order[0]
+		cache.order = cache.order[1:]
+		delete(cache.entries, oldest)
+	}
+}
+
+// runCacheKey identifies profile content committed to one repository path.
+func runCacheKey(repository RepositoryRef, path string, profile []byte) string {
+	sum := sha256.Sum256(profile)
+	return repository.Owner + "/" + repository.Name + ":" + path + "@" + hex.EncodeToString(sum[:])
+}
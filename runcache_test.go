@@ -0,0 +1,38 @@
+package cpgo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunCache(t *testing.T) {
+	now := time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("expires entries after ttl", func(t *testing.T) {
+		cache := NewRunCache(2, time.Minute)
+		cache.store("a", now)
+
+		if !cache.contains("a", now.Add(30*time.Second)) {
+			t.Fatalf("expected entry within ttl")
+		}
+
+		if cache.contains("a", now.Add(2*time.Minute)) {
+			t.Fatalf("expected entry to expire after ttl")
+		}
+	})
+
+	t.Run("evicts oldest entries beyond size", func(t *testing.T) {
+		cache := NewRunCache(2, time.Hour)
+		cache.store("a", now)
+		cache.store("b", now)
+		cache.store("c", now)
+
+		if cache.contains("a", now) {
+			t.Fatalf("expected oldest entry to be evicted")
+		}
+
+		if !cache.contains("b", now) || !cache.contains("c", now) {
+			t.Fatalf("expected newest entries to remain")
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package cpgo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// runTemplateData holds the placeholders a templated commit message or pull request
+// title and body may use: ProfileHash is the hex SHA-256 of the committed profile and
+// ShortProfileHash its first digits, as used in hashed profile filenames. Timestamp is
+// the run time in UTC and SampleCount is zero when the validator cannot count samples.
+type runTemplateData struct {
+	BaseBranch       string
+	ProfileHash      string
+	ShortProfileHash string
+	Timestamp        time.Time
+	SampleCount      int64
+}
+
+// newRunTemplateData returns the template data describing a run committing profile to baseBranch.
+func newRunTemplateData(baseBranch string, profile []byte, sampleCount int64, now time.Time) runTemplateData {
+	profileHash := profileSHA256(profile)
+
+	return runTemplateData{
+		BaseBranch:       baseBranch,
+		ProfileHash:      profileHash,
+		ShortProfileHash: profileHash[:profileHashLength],
+		Timestamp:        now.UTC(),
+		SampleCount:      sampleCount,
+	}
+}
+
+// namedRunTemplate is run request text that may hold template placeholders.
+type namedRunTemplate struct {
+	name  string
+	field string
+	text  string
+}
+
+// runTemplates lists the request text rendered with runTemplateData.
+func (req RunRequest) runTemplates() []namedRunTemplate {
+	return []namedRunTemplate{
+		{name: "commit message", field: "commit.message", text: req.Commit.Message},
+		{name: "pull request title", field: "pull_request.title", text: req.PullRequest.Title},
+		{name: "pull request body", field: "pull_request.body", text: req.PullRequest.Body},
+	}
+}
+
+// profileSHA256 returns the hex SHA-256 of profile bytes.
+func profileSHA256(profile []byte) string {
+	sum := sha256.Sum256(profile)
+	return hex.EncodeToString(sum[:])
+}
+
+// isTemplatedText reports whether text contains template placeholders.
+func isTemplatedText(text string) bool {
+	return strings.Contains(text, "{{")
+}
+
+// parseRunTemplate parses templated text, rejecting placeholders runTemplateData does not provide.
+func parseRunTemplate(name string, text string) (*template.Template, error) {
+	textTemplate, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s template: %w", name, err)
+	}
+
+	// A trial run with realistic data catches unknown fields and invalid expressions early.
+	if err := textTemplate.Execute(io.Discard, newRunTemplateData("main", nil, 0, time.Time{})); err != nil {
+		return nil, fmt.Errorf("%s template: %w", name, err)
+	}
+
+	return textTemplate, nil
+}
+
+// renderRunTemplate renders templated text with the run's data; text without placeholders is returned as is.
+func renderRunTemplate(name string, text string, data runTemplateData) (string, error) {
+	if !isTemplatedText(text) {
+		return text, nil
+	}
+
+	textTemplate, err := parseRunTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+
+	var rendered strings.Builder
+	if err := textTemplate.Execute(&rendered, data); err != nil {
+		return "", fmt.Errorf("render %s template: %w", name, err)
+	}
+
+	return rendered.String(), nil
+}
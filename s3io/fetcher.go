@@ -0,0 +1,199 @@
+package s3io
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"cpgo"
+	"cpgo/pprofio"
+)
+
+const (
+	schemeS3                 = "s3"
+	defaultRegion            = "us-east-1"
+	defaultHTTPClientTimeout = 45 * time.Second
+)
+
+// Options configures access to an S3 or S3-compatible object store.
+// A zero MaxBytes applies pprofio.DefaultMaxProfileBytes.
+type Options struct {
+	Region      string
+	Endpoint    string
+	Credentials Credentials
+	HTTPClient  *http.Client
+	MaxBytes    int64
+}
+
+// Fetcher reads pre-collected profiles from `s3://bucket/key` object URLs.
+type Fetcher struct {
+	httpClient  *http.Client
+	region      string
+	endpoint    *url.URL
+	credentials Credentials
+	maxBytes    int64
+	now         func() time.Time
+}
+
+var _ cpgo.ProfileFetcher = (*Fetcher)(nil)
+
+// NewFetcher validates options and returns an S3 object fetcher.
+func NewFetcher(options Options) (*Fetcher, error) {
+	region := strings.TrimSpace(options.Region)
+	if region == "" {
+		region = defaultRegion
+	}
+
+	var endpoint *url.URL
+	if rawEndpoint := strings.TrimSpace(options.Endpoint); rawEndpoint != "" {
+		parsed, err := url.Parse(rawEndpoint)
+		if err != nil {
+			return nil, fmt.Errorf("parse s3 endpoint: %w", err)
+		}
+
+		if parsed.Scheme == "" || parsed.Host == "" {
+			return nil, fmt.Errorf("s3 endpoint must include scheme and host")
+		}
+
+		endpoint = parsed
+	}
+
+	if options.Credentials.AccessKeyID == "" != (options.Credentials.SecretAccessKey == "") {
+		return nil, fmt.Errorf("s3 access key id and secret access key must be set together")
+	}
+
+	maxBytes := options.MaxBytes
+	switch {
+	case maxBytes < 0:
+		return nil, fmt.Errorf("profile max bytes must not be negative")
+	case maxBytes == 0:
+		maxBytes = pprofio.DefaultMaxProfileBytes
+	}
+
+	return &Fetcher{
+		httpClient:  withDefaultTimeout(options.HTTPClient),
+		region:      region,
+		endpoint:    endpoint,
+		credentials: options.Credentials,
+		maxBytes:    maxBytes,
+		now:         time.Now,
+	}, nil
+}
+
+// CredentialsFromEnv reads static credentials from the standard AWS environment variables.
+func CredentialsFromEnv() Credentials {
+	return Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// RegionFromEnv resolves the region from the standard AWS environment variables.
+func RegionFromEnv() string {
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// FetchCPUProfile downloads the profile object; the sampling window does not apply.
+func (fetcher *Fetcher) FetchCPUProfile(ctx context.Context, req cpgo.FetchProfileRequest) (cpgo.FetchProfileResult, error) {
+	if req.URL == nil {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("profile url is required")
+	}
+
+	if req.URL.Scheme != schemeS3 {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("profile url scheme must be %s, got %q", schemeS3, req.URL.Scheme)
+	}
+
+	bucket := req.URL.Host
+	key := strings.TrimPrefix(req.URL.Path, "/")
+	if bucket == "" || key == "" {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("profile url must have the form s3://bucket/key")
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, fetcher.objectURL(bucket, key).String(), nil)
+	if err != nil {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("build s3 request: %w", err)
+	}
+
+	if !fetcher.credentials.IsZero() {
+		signRequest(httpReq, fetcher.credentials, fetcher.region, fetcher.now())
+	}
+
+	resp, err := fetcher.httpClient.Do(httpReq)
+	if err != nil {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("fetch s3 object: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		preview, readErr := io.ReadAll(io.LimitReader(resp.Body, 4*1024))
+		if readErr != nil {
+			return cpgo.FetchProfileResult{}, fmt.Errorf("fetch s3 object: unexpected status %s", resp.Status)
+		}
+
+		return cpgo.FetchProfileResult{}, fmt.Errorf("fetch s3 object: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(preview)))
+	}
+
+	if resp.ContentLength > fetcher.maxBytes {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("%w: s3 object has %d bytes, limit is %d", pprofio.ErrProfileTooLarge, resp.ContentLength, fetcher.maxBytes)
+	}
+
+	// Reading one byte past the limit tells an oversized object from one ending exactly at it.
+	profile, err := io.ReadAll(io.LimitReader(resp.Body, fetcher.maxBytes+1))
+	if err != nil {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("read s3 object: %w", err)
+	}
+
+	if int64(len(profile)) > fetcher.maxBytes {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("%w: s3 object exceeded %d bytes", pprofio.ErrProfileTooLarge, fetcher.maxBytes)
+	}
+
+	if len(profile) == 0 {
+		return cpgo.FetchProfileResult{}, fmt.Errorf("s3 object is empty")
+	}
+
+	sourceURL := *req.URL
+	return cpgo.FetchProfileResult{
+		Content:   profile,
+		SourceURL: &sourceURL,
+	}, nil
+}
+
+// objectURL uses path-style addressing for custom endpoints and virtual hosts for AWS.
+func (fetcher *Fetcher) objectURL(bucket string, key string) *url.URL {
+	if fetcher.endpoint != nil {
+		objectURL := *fetcher.endpoint
+		objectURL.Path = strings.TrimRight(objectURL.Path, "/") + "/" + bucket + "/" + key
+		return &objectURL
+	}
+
+	return &url.URL{
+		Scheme: "https",
+		Host:   bucket + ".s3." + fetcher.region + ".amazonaws.com",
+		Path:   "/" + key,
+	}
+}
+
+func withDefaultTimeout(httpClient *http.Client) *http.Client {
+	if httpClient == nil {
+		return &http.Client{
+			Timeout: defaultHTTPClientTimeout,
+		}
+	}
+
+	httpClientCopy := *httpClient
+	if httpClientCopy.Timeout <= 0 {
+		httpClientCopy.Timeout = defaultHTTPClientTimeout
+	}
+
+	return &httpClientCopy
+}
@@ -0,0 +1,137 @@
+package s3io
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"cpgo"
+	"cpgo/pprofio"
+)
+
+func TestFetcherFetchCPUProfile(t *testing.T) {
+	t.Run("reads signed object from custom endpoint", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			if req.URL.Path != "/profiles/payments/cpu.pgo" {
+				t.Fatalf("unexpected object path: %s", req.URL.Path)
+			}
+
+			if !strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+				t.Fatalf("expected signed request, got %q", req.Header.Get("Authorization"))
+			}
+
+			if req.Header.Get("X-Amz-Security-Token") != "session" {
+				t.Fatalf("expected session token header")
+			}
+
+			_, _ = resp.Write([]byte("profile-bytes"))
+		}))
+		t.Cleanup(server.Close)
+
+		fetcher, err := NewFetcher(Options{
+			Region:   "eu-west-1",
+			Endpoint: server.URL,
+			Credentials: Credentials{
+				AccessKeyID:     "AKID",
+				SecretAccessKey: "secret",
+				SessionToken:    "session",
+			},
+			HTTPClient: server.Client(),
+		})
+		if err != nil {
+			t.Fatalf("new fetcher: %v", err)
+		}
+
+		profileURL := mustParseURL(t, "s3://profiles/payments/cpu.pgo")
+		result, err := fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{
+			URL:     profileURL,
+			Seconds: 30,
+		})
+		if err != nil {
+			t.Fatalf("fetch profile: %v", err)
+		}
+
+		if string(result.Content) != "profile-bytes" {
+			t.Fatalf("expected profile bytes, got %q", string(result.Content))
+		}
+
+		if result.SourceURL.String() != "s3://profiles/payments/cpu.pgo" {
+			t.Fatalf("unexpected source url: %s", result.SourceURL)
+		}
+	})
+
+	t.Run("returns error on missing object", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			http.Error(resp, "NoSuchKey", http.StatusNotFound)
+		}))
+		t.Cleanup(server.Close)
+
+		fetcher, err := NewFetcher(Options{
+			Endpoint:   server.URL,
+			HTTPClient: server.Client(),
+		})
+		if err != nil {
+			t.Fatalf("new fetcher: %v", err)
+		}
+
+		_, err = fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{
+			URL: mustParseURL(t, "s3://profiles/missing.pgo"),
+		})
+		if err == nil || !strings.Contains(err.Error(), "NoSuchKey") {
+			t.Fatalf("expected not found error, got %v", err)
+		}
+	})
+
+	t.Run("rejects objects larger than max bytes", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			resp.Header().Set("Content-Length", "16")
+			_, _ = resp.Write([]byte("0123456789abcdef"))
+		}))
+		t.Cleanup(server.Close)
+
+		fetcher, err := NewFetcher(Options{
+			Endpoint:   server.URL,
+			HTTPClient: server.Client(),
+			MaxBytes:   8,
+		})
+		if err != nil {
+			t.Fatalf("new fetcher: %v", err)
+		}
+
+		_, err = fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{
+			URL: mustParseURL(t, "s3://profiles/payments/cpu.pgo"),
+		})
+		if !errors.Is(err, pprofio.ErrProfileTooLarge) {
+			t.Fatalf("expected too large error, got %v", err)
+		}
+	})
+
+	t.Run("rejects non-s3 urls", func(t *testing.T) {
+		fetcher, err := NewFetcher(Options{})
+		if err != nil {
+			t.Fatalf("new fetcher: %v", err)
+		}
+
+		_, err = fetcher.FetchCPUProfile(context.Background(), cpgo.FetchProfileRequest{
+			URL: mustParseURL(t, "https://example.com/debug/pprof/profile"),
+		})
+		if err == nil {
+			t.Fatalf("expected scheme error")
+		}
+	})
+}
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parse url: %v", err)
+	}
+
+	return parsed
+}
@@ -0,0 +1,58 @@
+// Package secretio resolves secret references such as tokens and private keys.
+package secretio
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SecretProvider resolves a provider-specific secret reference to its value.
+type SecretProvider interface {
+	// Get returns the secret bytes for ref.
+	Get(ref string) ([]byte, error)
+}
+
+// EnvProvider reads secrets from environment variables named by the reference.
+type EnvProvider struct{}
+
+var _ SecretProvider = EnvProvider{}
+
+// Get returns the value of the environment variable ref.
+func (EnvProvider) Get(ref string) ([]byte, error) {
+	name := strings.TrimSpace(ref)
+	if name == "" {
+		return nil, fmt.Errorf("secret reference is required")
+	}
+
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return nil, fmt.Errorf("environment variable %s is not set", name)
+	}
+
+	return []byte(value), nil
+}
+
+// FileProvider reads secrets from files at the referenced paths.
+type FileProvider struct{}
+
+var _ SecretProvider = FileProvider{}
+
+// Get returns the contents of the file at ref.
+func (FileProvider) Get(ref string) ([]byte, error) {
+	path := strings.TrimSpace(ref)
+	if path == "" {
+		return nil, fmt.Errorf("secret reference is required")
+	}
+
+	value, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read secret file: %w", err)
+	}
+
+	if len(value) == 0 {
+		return nil, fmt.Errorf("secret file %s is empty", path)
+	}
+
+	return value, nil
+}
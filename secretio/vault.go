@@ -0,0 +1,126 @@
+package secretio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultVaultTimeout = 10 * time.Second
+	vaultTokenHeader    = "X-Vault-Token"
+	maxVaultErrorBytes  = 512
+)
+
+// VaultOptions configures access to a HashiCorp Vault server.
+type VaultOptions struct {
+	Address    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// VaultProvider reads secrets from Vault KV engines using refs like secret/data/cpgo#token.
+type VaultProvider struct {
+	address    *url.URL
+	token      string
+	httpClient *http.Client
+}
+
+var _ SecretProvider = (*VaultProvider)(nil)
+
+// NewVaultProvider validates options and returns a Vault-backed provider.
+func NewVaultProvider(options VaultOptions) (*VaultProvider, error) {
+	rawAddress := strings.TrimSpace(options.Address)
+	if rawAddress == "" {
+		return nil, fmt.Errorf("vault address is required")
+	}
+
+	address, err := url.Parse(rawAddress)
+	if err != nil {
+		return nil, fmt.Errorf("parse vault address: %w", err)
+	}
+
+	if address.Scheme == "" || address.Host == "" {
+		return nil, fmt.Errorf("vault address must include scheme and host")
+	}
+
+	token := strings.TrimSpace(options.Token)
+	if token == "" {
+		return nil, fmt.Errorf("vault token is required")
+	}
+
+	httpClient := options.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultVaultTimeout}
+	}
+
+	return &VaultProvider{
+		address:    address,
+		token:      token,
+		httpClient: httpClient,
+	}, nil
+}
+
+// Get reads the field after '#' from the secret at the path before it.
+// Both KV version 1 and version 2 response shapes are supported.
+func (provider *VaultProvider) Get(ref string) ([]byte, error) {
+	path, field, ok := strings.Cut(strings.TrimSpace(ref), "#")
+	path = strings.Trim(path, "/")
+	if !ok || path == "" || field == "" {
+		return nil, fmt.Errorf("vault secret reference must look like <path>#<field>")
+	}
+
+	secretURL := provider.address.JoinPath("v1", path)
+	req, err := http.NewRequest(http.MethodGet, secretURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("build vault request: %w", err)
+	}
+	req.Header.Set(vaultTokenHeader, provider.token)
+
+	resp, err := provider.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("read vault secret: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxVaultErrorBytes))
+		return nil, fmt.Errorf("read vault secret %s: unexpected status %d: %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var payload struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("decode vault secret: %w", err)
+	}
+
+	fields := payload.Data
+	if nested, ok := fields["data"]; ok {
+		// KV version 2 nests the secret fields under data.data.
+		var kv2Fields map[string]json.RawMessage
+		if err := json.Unmarshal(nested, &kv2Fields); err == nil {
+			fields = kv2Fields
+		}
+	}
+
+	rawValue, ok := fields[field]
+	if !ok {
+		return nil, fmt.Errorf("vault secret %s has no field %q", path, field)
+	}
+
+	var value string
+	if err := json.Unmarshal(rawValue, &value); err != nil {
+		return nil, fmt.Errorf("vault secret %s field %q is not a string", path, field)
+	}
+
+	if value == "" {
+		return nil, fmt.Errorf("vault secret %s field %q is empty", path, field)
+	}
+
+	return []byte(value), nil
+}
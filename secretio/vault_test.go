@@ -0,0 +1,70 @@
+package secretio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVaultProviderGet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.Header.Get(vaultTokenHeader) != "vault-token" {
+			t.Fatalf("expected vault token header")
+		}
+
+		switch req.URL.Path {
+		case "/v1/secret/data/cpgo":
+			_, _ = resp.Write([]byte(`{"data":{"data":{"github_token":"ghs_abc"},"metadata":{"version":3}}}`))
+		case "/v1/kv/cpgo":
+			_, _ = resp.Write([]byte(`{"data":{"github_token":"ghs_v1"}}`))
+		default:
+			http.NotFound(resp, req)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	provider, err := NewVaultProvider(VaultOptions{
+		Address:    server.URL,
+		Token:      "vault-token",
+		HTTPClient: server.Client(),
+	})
+	if err != nil {
+		t.Fatalf("new vault provider: %v", err)
+	}
+
+	t.Run("reads kv version 2 fields", func(t *testing.T) {
+		value, err := provider.Get("secret/data/cpgo#github_token")
+		if err != nil {
+			t.Fatalf("get secret: %v", err)
+		}
+
+		if string(value) != "ghs_abc" {
+			t.Fatalf("unexpected secret value %q", value)
+		}
+	})
+
+	t.Run("reads kv version 1 fields", func(t *testing.T) {
+		value, err := provider.Get("kv/cpgo#github_token")
+		if err != nil {
+			t.Fatalf("get secret: %v", err)
+		}
+
+		if string(value) != "ghs_v1" {
+			t.Fatalf("unexpected secret value %q", value)
+		}
+	})
+
+	t.Run("rejects missing fields and secrets", func(t *testing.T) {
+		if _, err := provider.Get("secret/data/cpgo#missing"); err == nil {
+			t.Fatalf("expected missing field error")
+		}
+
+		if _, err := provider.Get("secret/data/absent#github_token"); err == nil {
+			t.Fatalf("expected missing secret error")
+		}
+
+		if _, err := provider.Get("secret/data/cpgo"); err == nil {
+			t.Fatalf("expected reference format error")
+		}
+	})
+}
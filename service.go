@@ -5,36 +5,134 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
 )
 
 var ErrUnmanagedPullRequest = errors.New("existing pull request is not managed by cpgo")
 
+var ErrMissingBaseProfile = errors.New("base branch pgo file does not exist")
+
+var ErrProfileVerification = errors.New("profile failed toolchain verification")
+
+var ErrProfileShrank = errors.New("profile shrank beyond the allowed ratio")
+
+var ErrDiffRuleViolation = errors.New("profile violates diff rules")
+
+var ErrBranchDiverged = errors.New("branch moved and cannot be fast-forwarded")
+
+var ErrBranchProtected = errors.New("branch protection rejects the update")
+
+// errProfileCached stops the repository reads once the fetched profile is found in the run cache.
+var errProfileCached = errors.New("profile already committed by this process")
+
+const (
+	// SkipReasonUnchanged reports that the base branch already holds the fetched profile.
+	SkipReasonUnchanged = "profile_unchanged"
+	// SkipReasonRateLimited reports that the head branch was updated within the minimum update interval.
+	SkipReasonRateLimited = "rate_limited_by_policy"
+	// SkipReasonCached reports that this process already committed the same profile content.
+	SkipReasonCached = "profile_cached"
+	// SkipReasonBelowThreshold reports that the profile changed less than the configured change thresholds.
+	SkipReasonBelowThreshold = "change_below_threshold"
+	// SkipReasonOutsideSchedule reports that the run started outside the configured schedule window.
+	SkipReasonOutsideSchedule = "outside_schedule"
+)
+
+const (
+	runBranchLayout      = "20060102-150405"
+	profileTagPrefix     = "pgo/"
+	profileTagDateLayout = "2006-01-02"
+	shortCommitSHALength = 7
+)
+
 // Dependencies bundles runtime ports required by Service.
 type Dependencies struct {
-	ProfileFetcher   ProfileFetcher
-	ProfileValidator ProfileValidator
-	BranchWriter     BranchWriter
-	PullRequests     PullRequestService
+	ProfileFetcher    ProfileFetcher
+	ProfileValidator  ProfileValidator
+	BranchWriter      BranchWriter
+	PullRequests      PullRequestService
+	ProfileComparer   ProfileComparer
+	ProfileNormalizer ProfileNormalizer
+	ProfileMerger     ProfileMerger
+	TagWriter         TagWriter
+	ProfileVerifier   ProfileVerifier
+	ProfileLabeler    ProfileLabeler
+	ProfileSummarizer ProfileSummarizer
+	ProfileDiffer     ProfileDiffer
+	DiffPolicy        DiffPolicy
+	StatusReporter    StatusReporter
+	RunCache          *RunCache
+	Clock             Clock
 }
 
 // Service orchestrates one cpgo execution using injected ports.
 type Service struct {
-	profileFetcher   ProfileFetcher
-	profileValidator ProfileValidator
-	branchWriter     BranchWriter
-	pullRequests     PullRequestService
+	profileFetcher    ProfileFetcher
+	profileValidator  ProfileValidator
+	branchWriter      BranchWriter
+	pullRequests      PullRequestService
+	profileComparer   ProfileComparer
+	profileNormalizer ProfileNormalizer
+	profileMerger     ProfileMerger
+	tagWriter         TagWriter
+	profileVerifier   ProfileVerifier
+	profileLabeler    ProfileLabeler
+	profileSummarizer ProfileSummarizer
+	profileDiffer     ProfileDiffer
+	diffPolicy        DiffPolicy
+	statusReporter    StatusReporter
+	runCache          *RunCache
+	clock             Clock
 }
 
 // RunResult summarizes what changed during one run.
+// PreviousProfileBytes is the size of the base branch profile, zero when there is none.
+// ProfileHash is the hex SHA-256 of the fetched profile.
+// ProfileDiff describes a changed profile relative to the base profile in dry runs that request it.
+// Warnings lists non-fatal failures, such as reviewer requests GitHub rejected.
+// BaseBranch and HeadBranch are empty when a run cache hit skips the repository reads that resolve them.
 type RunResult struct {
-	BaseBranch           string
-	HeadBranch           string
-	PullRequestNumber    int
-	CommitSHA            string
-	IsProfileChanged     bool
-	IsPullRequestCreated bool
-	IsNoop               bool
+	BaseBranch           string     `json:"base_branch"`
+	HeadBranch           string     `json:"head_branch"`
+	PullRequestNumber    int        `json:"pr_number"`
+	PullRequestURL       string     `json:"pr_url"`
+	CommitSHA            string     `json:"commit_sha"`
+	TagName              string     `json:"tag,omitempty"`
+	ClosedPullRequests   []int      `json:"closed_prs,omitempty"`
+	ProfileSourceURL     string     `json:"profile_url"`
+	PreviousProfileBytes int        `json:"previous_profile_bytes"`
+	NewProfileBytes      int        `json:"profile_bytes"`
+	ProfileHash          string     `json:"profile_sha256,omitempty"`
+	ProfileDiff          string     `json:"profile_diff,omitempty"`
+	SkipReason           string     `json:"skip_reason,omitempty"`
+	IsProfileChanged     bool       `json:"changed"`
+	IsPullRequestCreated bool       `json:"pr_created"`
+	IsNoop               bool       `json:"noop"`
+	IsBelowThreshold     bool       `json:"below_threshold,omitempty"`
+	IsDryRun             bool       `json:"dry_run,omitempty"`
+	IsBranchDeleted      bool       `json:"branch_deleted,omitempty"`
+	IsCommittedToBase    bool       `json:"committed_to_base,omitempty"`
+	Warnings             []string   `json:"warnings,omitempty"`
+	Timings              RunTimings `json:"timings"`
+}
+
+// RunTimings records how long run phases took, in nanoseconds when encoded; skipped phases stay zero.
+// Read covers resolving the base branch, open pull request and base profiles, which overlaps Fetch.
+type RunTimings struct {
+	Fetch       time.Duration `json:"fetch_ns"`
+	Validate    time.Duration `json:"validate_ns"`
+	Read        time.Duration `json:"read_ns"`
+	Upsert      time.Duration `json:"upsert_ns"`
+	PullRequest time.Duration `json:"pull_request_ns"`
+}
+
+// since returns the time elapsed from start on the service clock.
+func (svc *Service) since(start time.Time) time.Duration {
+	return svc.clock.Now().Sub(start)
 }
 
 // NewService validates dependencies and returns an executable service.
@@ -50,116 +148,823 @@ func NewService(deps Dependencies) (*Service, error) {
 		return nil, fmt.Errorf("pull request service is required")
 	}
 
+	clock := deps.Clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+
 	return &Service{
-		profileFetcher:   deps.ProfileFetcher,
-		profileValidator: deps.ProfileValidator,
-		branchWriter:     deps.BranchWriter,
-		pullRequests:     deps.PullRequests,
+		profileFetcher:    deps.ProfileFetcher,
+		profileValidator:  deps.ProfileValidator,
+		branchWriter:      deps.BranchWriter,
+		pullRequests:      deps.PullRequests,
+		profileComparer:   deps.ProfileComparer,
+		profileNormalizer: deps.ProfileNormalizer,
+		profileMerger:     deps.ProfileMerger,
+		tagWriter:         deps.TagWriter,
+		profileVerifier:   deps.ProfileVerifier,
+		profileLabeler:    deps.ProfileLabeler,
+		profileSummarizer: deps.ProfileSummarizer,
+		profileDiffer:     deps.ProfileDiffer,
+		diffPolicy:        deps.DiffPolicy,
+		statusReporter:    deps.StatusReporter,
+		runCache:          deps.RunCache,
+		clock:             clock,
 	}, nil
 }
 
 // Run executes a full fetch-validate-write-pr cycle for one request.
+// A dry run stops before the first repository write and reports what would change.
 func (svc *Service) Run(ctx context.Context, req RunRequest) (RunResult, error) {
+	var timings RunTimings
+	result, err := svc.run(ctx, req, &timings)
+	result.Timings = timings
+	result.IsDryRun = req.DryRun
+	if err != nil || req.DryRun {
+		return result, err
+	}
+
+	if err := svc.reportStatus(ctx, req, result); err != nil {
+		if !errors.Is(err, ErrStatusNotPermitted) {
+			return result, err
+		}
+
+		// The refresh itself succeeded, so a token without status access only loses the status.
+		result.Warnings = append(result.Warnings, err.Error())
+	}
+
+	return result, nil
+}
+
+// run executes one refresh, recording phase durations in timings even when it fails.
+func (svc *Service) run(ctx context.Context, req RunRequest, timings *RunTimings) (RunResult, error) {
 	normalized, err := req.normalized()
 	if err != nil {
 		return RunResult{}, err
 	}
 
+	if normalized.Repository.TagProfiles && svc.tagWriter == nil {
+		return RunResult{}, fmt.Errorf("tag writer is required to tag profiles")
+	}
+
+	if normalized.Status.Context != "" && svc.statusReporter == nil {
+		return RunResult{}, fmt.Errorf("status reporter is required to report commit statuses")
+	}
+
+	if normalized.DryRunDiff && svc.profileDiffer == nil {
+		return RunResult{}, fmt.Errorf("profile differ is required to diff dry runs")
+	}
+
+	if normalized.Profile.MergeWithBase && svc.profileMerger == nil {
+		return RunResult{}, fmt.Errorf("profile merger is required to merge with the base profile")
+	}
+
+	headPrefix := headBranchPrefix(normalized.Repository.HeadBranch)
+	if normalized.Repository.BranchPerRun {
+		// Each run proposes a fresh branch so history is never force-pushed.
+		normalized.Repository.HeadBranch = runHeadBranch(normalized.Repository.HeadBranch, svc.clock.Now())
+	}
+
 	repository := RepositoryRef{
 		Owner: normalized.Repository.Owner,
 		Name:  normalized.Repository.Name,
 	}
 
-	profile, err := svc.profileFetcher.FetchCPUProfile(ctx, FetchProfileRequest{
-		URL:     normalized.Profile.URL,
-		Seconds: normalized.Profile.Seconds,
-		Headers: normalized.Profile.Headers,
+	// The profile capture dominates run time, so repository reads proceed alongside it.
+	var fetchResult FetchProfileResult
+	var collectedAt time.Time
+	var base baseState
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.Go(func() error {
+		var err error
+		fetchResult, collectedAt, err = svc.fetchProfile(groupCtx, normalized, timings)
+		if err != nil {
+			return err
+		}
+
+		// A cached profile needs no repository state, so the pending reads are cancelled.
+		// Profiles merged with the base depend on it and are checked after the reads instead.
+		if !normalized.Profile.MergeWithBase && svc.isCached(repository, normalized, fetchResult.Content) {
+			return errProfileCached
+		}
+
+		return nil
 	})
+	group.Go(func() error {
+		start := svc.clock.Now()
+		var err error
+		base, err = svc.readBaseState(groupCtx, normalized, repository)
+		timings.Read = svc.since(start)
+		return err
+	})
+	err = group.Wait()
+	if errors.Is(err, errProfileCached) {
+		// The branches are left empty: resolving them is the repository read the cache hit cancelled.
+		return RunResult{
+			ProfileSourceURL: redactURL(fetchResult.SourceURL),
+			NewProfileBytes:  len(fetchResult.Content),
+			ProfileHash:      profileSHA256(fetchResult.Content),
+			SkipReason:       SkipReasonCached,
+			IsNoop:           true,
+		}, nil
+	}
 	if err != nil {
-		return RunResult{}, fmt.Errorf("fetch cpu profile: %w", err)
+		return RunResult{}, err
 	}
 
-	if err := svc.profileValidator.ValidateCPUProfile(profile); err != nil {
-		return RunResult{}, fmt.Errorf("validate cpu profile: %w", err)
+	profile := fetchResult.Content
+	if normalized.Profile.MergeWithBase {
+		profile, err = svc.mergeWithBase(base, profile)
+		if err != nil {
+			return RunResult{}, err
+		}
 	}
 
-	baseBranch, err := svc.resolveBaseBranch(ctx, repository, normalized.Repository.BaseBranch)
-	if err != nil {
+	profileHash := profileSHA256(profile)
+	sourceURL := redactURL(fetchResult.SourceURL)
+	provenance := profileProvenance{
+		SourceURL:   sourceURL,
+		Seconds:     normalized.Profile.Seconds,
+		CollectedAt: collectedAt,
+	}
+	normalized.Repository.HeadBranch = base.HeadBranch
+	baseBranch := base.Branch
+	openPR := base.OpenPR
+	readResult := base.primary().Previous
+
+	cacheKey := runCacheKey(repository, strings.Join(normalized.Repository.PGOPaths, ","), profile)
+	if svc.isCached(repository, normalized, profile) {
+		return RunResult{
+			BaseBranch:           baseBranch,
+			HeadBranch:           normalized.Repository.HeadBranch,
+			PullRequestNumber:    prNumber(openPR),
+			PullRequestURL:       prURL(openPR),
+			ProfileSourceURL:     sourceURL,
+			PreviousProfileBytes: len(readResult.Content),
+			NewProfileBytes:      len(profile),
+			ProfileHash:          profileHash,
+			SkipReason:           SkipReasonCached,
+			IsNoop:               true,
+		}, nil
+	}
+
+	if svc.isCurrent(base, profile) {
+		svc.rememberProfile(cacheKey)
+
+		isBranchDeleted, err := svc.cleanupStaleBranch(ctx, normalized, repository, base)
+		if err != nil {
+			return RunResult{}, err
+		}
+
+		return RunResult{
+			BaseBranch:           baseBranch,
+			HeadBranch:           normalized.Repository.HeadBranch,
+			PullRequestNumber:    prNumber(openPR),
+			PullRequestURL:       prURL(openPR),
+			ProfileSourceURL:     sourceURL,
+			PreviousProfileBytes: len(readResult.Content),
+			NewProfileBytes:      len(profile),
+			ProfileHash:          profileHash,
+			SkipReason:           SkipReasonUnchanged,
+			IsNoop:               true,
+			IsBranchDeleted:      isBranchDeleted,
+		}, nil
+	}
+
+	if err := checkShrink(normalized.Repository, readResult, profile); err != nil {
 		return RunResult{}, err
 	}
 
-	openPR, err := svc.pullRequests.FindOpenByHead(ctx, FindPullRequestRequest{
-		Repository: repository,
-		BaseBranch: baseBranch,
-		HeadBranch: normalized.Repository.HeadBranch,
-	})
-	if err != nil {
-		return RunResult{}, fmt.Errorf("find open pull request: %w", err)
+	if err := svc.checkDiffPolicy(readResult, profile); err != nil {
+		return RunResult{}, err
 	}
 
-	if openPR != nil && !strings.Contains(openPR.Body, normalized.PullRequest.ManagedByMarker) {
-		return RunResult{}, ErrUnmanagedPullRequest
+	comparison, comparisonErr := svc.compareWithBase(readResult, profile)
+	if comparisonErr == nil && isBelowChangeThreshold(normalized.Repository, comparison) {
+		isBranchDeleted, err := svc.cleanupStaleBranch(ctx, normalized, repository, base)
+		if err != nil {
+			return RunResult{}, err
+		}
+
+		return RunResult{
+			BaseBranch:           baseBranch,
+			HeadBranch:           normalized.Repository.HeadBranch,
+			PullRequestNumber:    prNumber(openPR),
+			PullRequestURL:       prURL(openPR),
+			ProfileSourceURL:     sourceURL,
+			PreviousProfileBytes: len(readResult.Content),
+			NewProfileBytes:      len(profile),
+			ProfileHash:          profileHash,
+			SkipReason:           SkipReasonBelowThreshold,
+			IsNoop:               true,
+			IsBelowThreshold:     true,
+			IsBranchDeleted:      isBranchDeleted,
+		}, nil
 	}
 
-	readResult, err := svc.branchWriter.ReadFile(ctx, ReadFileRequest{
-		Repository: repository,
-		Branch:     baseBranch,
-		Path:       normalized.Repository.PGOPath,
-	})
-	if err != nil {
-		return RunResult{}, fmt.Errorf("read base branch pgo file: %w", err)
+	var isRecentlyUpdated bool
+	if !normalized.Repository.CommitToBase {
+		isRecentlyUpdated, err = svc.isRecentlyUpdated(ctx, repository, normalized.Repository.HeadBranch, normalized.PullRequest.MinUpdateInterval)
+		if err != nil {
+			return RunResult{}, err
+		}
 	}
 
-	if readResult.HasFile && bytes.Equal(readResult.Content, profile) {
+	if isRecentlyUpdated {
 		return RunResult{
-			BaseBranch:        baseBranch,
-			HeadBranch:        normalized.Repository.HeadBranch,
-			PullRequestNumber: prNumber(openPR),
-			IsNoop:            true,
+			BaseBranch:           baseBranch,
+			HeadBranch:           normalized.Repository.HeadBranch,
+			PullRequestNumber:    prNumber(openPR),
+			PullRequestURL:       prURL(openPR),
+			ProfileSourceURL:     sourceURL,
+			PreviousProfileBytes: len(readResult.Content),
+			NewProfileBytes:      len(profile),
+			ProfileHash:          profileHash,
+			SkipReason:           SkipReasonRateLimited,
+			IsNoop:               true,
 		}, nil
 	}
 
-	writeResult, err := svc.branchWriter.UpsertFileAndForceBranch(ctx, UpsertFileRequest{
-		Repository:    repository,
-		BaseBranch:    baseBranch,
-		HeadBranch:    normalized.Repository.HeadBranch,
-		Path:          normalized.Repository.PGOPath,
-		Content:       profile,
-		CommitMessage: normalized.Commit.Message,
-	})
+	if normalized.DryRun {
+		var profileDiff string
+		if normalized.DryRunDiff {
+			var previous []byte
+			if readResult.HasFile {
+				previous = readResult.Content
+			}
+
+			profileDiff, err = svc.profileDiffer.DiffProfiles(previous, profile)
+			if err != nil {
+				return RunResult{}, fmt.Errorf("diff profile: %w", err)
+			}
+		}
+
+		return RunResult{
+			BaseBranch:           baseBranch,
+			HeadBranch:           normalized.Repository.HeadBranch,
+			PullRequestNumber:    prNumber(openPR),
+			PullRequestURL:       prURL(openPR),
+			ProfileSourceURL:     sourceURL,
+			PreviousProfileBytes: len(readResult.Content),
+			NewProfileBytes:      len(profile),
+			ProfileHash:          profileHash,
+			ProfileDiff:          profileDiff,
+			IsProfileChanged:     true,
+		}, nil
+	}
+
+	templateData, err := svc.runTemplateData(normalized, baseBranch, profile)
+	if err != nil {
+		return RunResult{}, err
+	}
+
+	normalized.Commit.Message, err = renderRunTemplate("commit message", normalized.Commit.Message, templateData)
+	if err != nil {
+		return RunResult{}, err
+	}
+
+	if normalized.Commit.ProvenanceTrailers {
+		normalized.Commit.Message = appendProvenanceTrailers(normalized.Commit.Message, provenance)
+	}
+
+	upsert := profileUpsert(normalized, repository, base, profile)
+	warnings, err := svc.checkBranchProtection(ctx, normalized, upsert)
+	if err != nil {
+		return RunResult{}, err
+	}
+
+	upsertStart := svc.clock.Now()
+	writeResult, err := svc.branchWriter.UpsertFileAndForceBranch(ctx, upsert)
+	timings.Upsert = svc.since(upsertStart)
+	if err != nil && normalized.Repository.CommitToBase {
+		return RunResult{}, fmt.Errorf("commit to base branch %s: %w", baseBranch, err)
+	}
 	if err != nil {
 		return RunResult{}, fmt.Errorf("update pgo branch: %w", err)
 	}
 
+	if err := svc.verifyProfile(ctx, normalized, repository, base, profile); err != nil {
+		return RunResult{}, err
+	}
+
 	result := RunResult{
-		BaseBranch:       baseBranch,
-		HeadBranch:       normalized.Repository.HeadBranch,
-		CommitSHA:        writeResult.CommitSHA,
-		IsProfileChanged: true,
+		BaseBranch:           baseBranch,
+		HeadBranch:           normalized.Repository.HeadBranch,
+		CommitSHA:            writeResult.CommitSHA,
+		ProfileSourceURL:     sourceURL,
+		PreviousProfileBytes: len(readResult.Content),
+		NewProfileBytes:      len(profile),
+		ProfileHash:          profileHash,
+		IsProfileChanged:     true,
+		Warnings:             warnings,
+	}
+
+	if comparisonErr != nil {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("compare with base profile: %v", comparisonErr))
+	}
+
+	if normalized.Repository.TagProfiles {
+		tagName := profileTagName(svc.clock.Now(), writeResult.CommitSHA)
+		if err := svc.tagWriter.CreateTag(ctx, CreateTagRequest{
+			Repository: repository,
+			Name:       tagName,
+			CommitSHA:  writeResult.CommitSHA,
+		}); err != nil {
+			return RunResult{}, fmt.Errorf("tag profile commit: %w", err)
+		}
+
+		result.TagName = tagName
+	}
+
+	// The profile is only cached once nothing is left to do for it, so a failed tag or
+	// pull request is retried by the next run instead of being skipped.
+	if normalized.Repository.CommitToBase {
+		svc.rememberProfile(cacheKey)
+		result.IsCommittedToBase = true
+		return result, nil
 	}
 
 	if openPR != nil {
+		svc.rememberProfile(cacheKey)
 		result.PullRequestNumber = openPR.Number
+		result.PullRequestURL = openPR.URL
 		return result, nil
 	}
 
+	title, err := renderRunTemplate("pull request title", normalized.PullRequest.Title, templateData)
+	if err != nil {
+		return RunResult{}, err
+	}
+
+	body, err := renderRunTemplate("pull request body", normalized.PullRequest.Body, templateData)
+	if err != nil {
+		return RunResult{}, err
+	}
+
+	body = appendSection(body, svc.summarySection(readResult, profile))
+	if !normalized.PullRequest.HideComparison {
+		body = appendSection(body, comparisonSection(comparison, comparisonErr))
+	}
+	body = appendProvenance(body, provenance)
+
+	createStart := svc.clock.Now()
 	createdPR, err := svc.pullRequests.Create(ctx, CreatePullRequestRequest{
-		Repository: repository,
-		BaseBranch: baseBranch,
-		HeadBranch: normalized.Repository.HeadBranch,
-		Title:      normalized.PullRequest.Title,
-		Body:       appendMarker(normalized.PullRequest.Body, normalized.PullRequest.ManagedByMarker),
+		Repository:     repository,
+		BaseBranch:     baseBranch,
+		HeadBranch:     normalized.Repository.HeadBranch,
+		Title:          title,
+		Body:           appendMarker(body, normalized.PullRequest.ManagedByMarker),
+		RequiredLabels: normalized.PullRequest.Labels,
+		Labels:         svc.profileLabels(profile),
+		Reviewers:      normalized.PullRequest.Reviewers,
+		TeamReviewers:  normalized.PullRequest.TeamReviewers,
+		Assignees:      normalized.PullRequest.Assignees,
+		Draft:          normalized.PullRequest.Draft || isMinorChange(comparison, normalized.PullRequest.DraftBelowChangeRatio),
 	})
+	timings.PullRequest = svc.since(createStart)
 	if err != nil {
 		return RunResult{}, fmt.Errorf("create pull request: %w", err)
 	}
 
+	svc.rememberProfile(cacheKey)
+
 	result.PullRequestNumber = createdPR.Number
+	result.PullRequestURL = createdPR.URL
 	result.IsPullRequestCreated = true
+	result.Warnings = append(result.Warnings, createdPR.Warnings...)
+
+	if normalized.Repository.BranchPerRun || normalized.PullRequest.CloseSuperseded {
+		closed, err := svc.closeSuperseded(ctx, normalized, repository, baseBranch, headPrefix, createdPR)
+		result.ClosedPullRequests = closed
+		if err != nil {
+			return result, err
+		}
+	}
 
 	return result, nil
 }
 
+// closeSuperseded closes older managed PRs whose head branch shares the run's branch prefix,
+// pointing them at the new one, and deletes their branches.
+func (svc *Service) closeSuperseded(ctx context.Context, req RunRequest, repository RepositoryRef, baseBranch string, headBranchPrefix string, current PullRequest) ([]int, error) {
+	openPRs, err := svc.pullRequests.ListOpenByHeadPrefix(ctx, ListPullRequestsRequest{
+		Repository:       repository,
+		BaseBranch:       baseBranch,
+		HeadBranchPrefix: headBranchPrefix,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list superseded pull requests: %w", err)
+	}
+
+	var closed []int
+	for _, openPR := range openPRs {
+		if openPR.Number == current.Number || !req.PullRequest.isManaged(openPR.Body) {
+			continue
+		}
+
+		// Only delete branches cpgo owns; never the base branch.
+		if !strings.HasPrefix(openPR.HeadBranch, headBranchPrefix) || openPR.HeadBranch == baseBranch {
+			continue
+		}
+
+		if err := svc.pullRequests.Close(ctx, ClosePullRequestRequest{
+			Repository: repository,
+			Number:     openPR.Number,
+			Comment:    fmt.Sprintf("Superseded by #%d.", current.Number),
+		}); err != nil {
+			return closed, fmt.Errorf("close superseded pull request #%d: %w", openPR.Number, err)
+		}
+		closed = append(closed, openPR.Number)
+
+		if _, err := svc.branchWriter.DeleteBranch(ctx, repository, openPR.HeadBranch); err != nil {
+			return closed, fmt.Errorf("delete superseded branch %s: %w", openPR.HeadBranch, err)
+		}
+	}
+
+	return closed, nil
+}
+
+// verifyProfile checks the pushed profile with the toolchain, optionally restoring the base profile on failure.
+func (svc *Service) verifyProfile(ctx context.Context, req RunRequest, repository RepositoryRef, base baseState, profile []byte) error {
+	if svc.profileVerifier == nil {
+		return nil
+	}
+
+	verifyErr := svc.profileVerifier.VerifyProfile(ctx, profile)
+	if verifyErr == nil {
+		return nil
+	}
+
+	verifyErr = fmt.Errorf("%w: %w", ErrProfileVerification, verifyErr)
+	if !req.Verify.RevertOnFailure {
+		return verifyErr
+	}
+
+	primary := base.primary()
+	if !primary.File.HasFile {
+		return fmt.Errorf("%w (head branch not reverted: base branch has no pgo file)", verifyErr)
+	}
+
+	// The head branch is rebuilt from the base branch, so rewriting the base profile restores the base tree.
+	// This is forced even with FastForwardOnly, since a fast-forward commit cannot drop the other changes.
+	_, err := svc.branchWriter.UpsertFileAndForceBranch(ctx, UpsertFileRequest{
+		Repository:    repository,
+		BaseBranch:    base.Branch,
+		HeadBranch:    req.Repository.HeadBranch,
+		Path:          primary.ProfilePath,
+		Content:       primary.File.Content,
+		CommitMessage: "revert: restore base pgo profile after failed verification",
+		Author:        req.Commit.author(),
+		Committer:     req.Commit.committer(),
+		ForceUpdate:   true,
+	})
+	if err != nil {
+		return errors.Join(verifyErr, fmt.Errorf("revert head branch: %w", err))
+	}
+
+	return verifyErr
+}
+
+// checkShrink refuses profiles that are suspiciously smaller than the base branch profile.
+func checkShrink(settings RepositorySettings, base ReadFileResult, profile []byte) error {
+	if settings.MaxShrinkRatio <= 0 || settings.AllowShrink || !base.HasFile || len(base.Content) == 0 {
+		return nil
+	}
+
+	shrinkRatio := 1 - float64(len(profile))/float64(len(base.Content))
+	if shrinkRatio > settings.MaxShrinkRatio {
+		return fmt.Errorf("%w: %d bytes is %.0f%% smaller than the base profile's %d bytes (maximum %.0f%%)",
+			ErrProfileShrank, len(profile), shrinkRatio*100, len(base.Content), settings.MaxShrinkRatio*100)
+	}
+
+	return nil
+}
+
+// baseState is the repository state a run compares against.
+// HeadBranch is the branch the run writes to, with any template expanded.
+// Targets holds one entry per pgo path, the primary path first.
+type baseState struct {
+	Branch     string
+	HeadBranch string
+	OpenPR     *PullRequest
+	Targets    []profileTarget
+}
+
+// profileTarget is the base branch content behind one pgo path.
+// ProfilePath is where File was read from; with hashed filenames it is the
+// hashed file the pgo path points at rather than the pgo path itself.
+// Previous is the profile the fetched one is compared against: File, or the
+// profile on the compare ref when one is configured.
+type profileTarget struct {
+	PGOPath     string
+	ProfilePath string
+	File        ReadFileResult
+	Previous    ReadFileResult
+}
+
+// primary returns the target for the primary pgo path, which comparisons and size checks use.
+func (base baseState) primary() profileTarget {
+	return base.Targets[0]
+}
+
+// isCurrent reports whether every pgo path already holds the profile on the base branch
+// or compare ref. A base branch holding the profile is always current, since committing
+// it again would change nothing. Byte-different content still counts as current when the
+// normalizer finds both profiles identical, so re-encoding an unchanged profile does not open a pull request.
+func (svc *Service) isCurrent(base baseState, profile []byte) bool {
+	var normalized []byte
+	for _, target := range base.Targets {
+		if !svc.holdsProfile(target.File, profile, &normalized) && !svc.holdsProfile(target.Previous, profile, &normalized) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// holdsProfile reports whether file contains profile, byte for byte or after normalization.
+// normalized caches the normalized profile across calls.
+func (svc *Service) holdsProfile(file ReadFileResult, profile []byte, normalized *[]byte) bool {
+	if !file.HasFile {
+		return false
+	}
+
+	if bytes.Equal(file.Content, profile) {
+		return true
+	}
+
+	if svc.profileNormalizer == nil {
+		return false
+	}
+
+	if *normalized == nil {
+		var err error
+		*normalized, err = svc.profileNormalizer.NormalizeProfile(profile)
+		if err != nil {
+			return false
+		}
+	}
+
+	current, err := svc.profileNormalizer.NormalizeProfile(file.Content)
+	return err == nil && bytes.Equal(current, *normalized)
+}
+
+// fetchProfile captures and validates the profile, recording both durations.
+// The returned time is when the fetch completed, which is when the profile was collected.
+func (svc *Service) fetchProfile(ctx context.Context, req RunRequest, timings *RunTimings) (FetchProfileResult, time.Time, error) {
+	start := svc.clock.Now()
+	fetchResult, err := svc.profileFetcher.FetchCPUProfile(ctx, FetchProfileRequest{
+		URL:         req.Profile.URL,
+		Seconds:     req.Profile.Seconds,
+		Headers:     req.Profile.Headers,
+		ProfileType: req.Profile.Type,
+		Method:      req.Profile.Method,
+		Body:        req.Profile.Body,
+	})
+	timings.Fetch = svc.since(start)
+	if err != nil {
+		return FetchProfileResult{}, time.Time{}, fmt.Errorf("fetch %s profile: %w", req.Profile.Type, err)
+	}
+
+	collectedAt := start.Add(timings.Fetch)
+	start = svc.clock.Now()
+	err = svc.validateProfile(ctx, fetchResult.Content, req.Profile)
+	timings.Validate = svc.since(start)
+	if err != nil {
+		return FetchProfileResult{}, time.Time{}, fmt.Errorf("validate %s profile: %w", req.Profile.Type, err)
+	}
+
+	return fetchResult, collectedAt, nil
+}
+
+// checkBranchProtection fails fast when the protection of the branch the upsert writes to
+// would reject it, before any blobs or trees are built. The check is best-effort: branch
+// writers that cannot read protection are skipped and read failures become warnings.
+// Branches whose pull request rule lists bypass actors only warn, since cpgo cannot tell
+// whether its own identity is among them.
+func (svc *Service) checkBranchProtection(ctx context.Context, req RunRequest, upsert UpsertFileRequest) ([]string, error) {
+	reader, canRead := svc.branchWriter.(BranchProtectionReader)
+	if !req.Repository.PreflightChecks || !canRead {
+		return nil, nil
+	}
+
+	protection, isProtected, err := reader.BranchProtection(ctx, upsert.Repository, upsert.HeadBranch)
+	if err != nil {
+		return []string{fmt.Sprintf("check protection of branch %s: %v", upsert.HeadBranch, err)}, nil
+	}
+
+	if !isProtected {
+		return nil, nil
+	}
+
+	var warnings []string
+	switch {
+	case protection.RequiresPullRequest && protection.HasPullRequestBypass:
+		warnings = append(warnings, fmt.Sprintf("branch %s requires pull requests; the update only succeeds if cpgo may bypass them", upsert.HeadBranch))
+	case protection.RequiresPullRequest && req.Repository.CommitToBase:
+		return nil, fmt.Errorf("%w: base branch %s only accepts changes through pull requests; allow cpgo to bypass the rule or disable repository.commit_to_base", ErrBranchProtected, upsert.HeadBranch)
+	case protection.RequiresPullRequest:
+		return nil, fmt.Errorf("%w: branch %s only accepts changes through pull requests; configure an unprotected repository.head_branch", ErrBranchProtected, upsert.HeadBranch)
+	}
+
+	if upsert.ForceUpdate && !protection.AllowsForcePushes {
+		return nil, fmt.Errorf("%w: branch %s does not allow force pushes; configure an unprotected repository.head_branch or set repository.force_push: false", ErrBranchProtected, upsert.HeadBranch)
+	}
+
+	return warnings, nil
+}
+
+// mergeWithBase merges the fetched profile into the primary base branch profile, so the
+// committed profile accumulates samples across refreshes. Without a base profile the
+// fetched one is used as is.
+func (svc *Service) mergeWithBase(base baseState, profile []byte) ([]byte, error) {
+	baseFile := base.primary().File
+	if !baseFile.HasFile {
+		return profile, nil
+	}
+
+	merged, err := svc.profileMerger.MergeProfiles(baseFile.Content, profile)
+	if err != nil {
+		return nil, fmt.Errorf("merge with base profile: %w", err)
+	}
+
+	return merged, nil
+}
+
+// runTemplateData describes the run for templated commit messages and pull request text.
+// Samples are only counted when a template needs them and the validator can count them.
+func (svc *Service) runTemplateData(req RunRequest, baseBranch string, profile []byte) (runTemplateData, error) {
+	var sampleCount int64
+	counter, canCount := svc.profileValidator.(SampleCounter)
+	if canCount && slices.ContainsFunc(req.runTemplates(), func(runTemplate namedRunTemplate) bool {
+		return strings.Contains(runTemplate.text, ".SampleCount")
+	}) {
+		var err error
+		sampleCount, err = counter.CountSamples(profile)
+		if err != nil {
+			return runTemplateData{}, fmt.Errorf("count profile samples: %w", err)
+		}
+	}
+
+	return newRunTemplateData(baseBranch, profile, sampleCount, svc.clock.Now()), nil
+}
+
+// validateProfile validates the profile, checking it against the request and stopping with
+// ctx when the validator supports it.
+func (svc *Service) validateProfile(ctx context.Context, profile []byte, settings ProfileSettings) error {
+	expectations := ProfileExpectations{
+		Seconds:     settings.Seconds,
+		ProfileType: settings.Type,
+	}
+
+	switch validator := svc.profileValidator.(type) {
+	case ContextValidator:
+		return validator.ValidateCPUProfileContext(ctx, profile, expectations)
+	case ExpectationValidator:
+		return validator.ValidateCPUProfileWithExpectations(profile, expectations)
+	default:
+		return svc.profileValidator.ValidateCPUProfile(profile)
+	}
+}
+
+// cleanupStaleBranch deletes the configured head branch after a noop when no managed pull request uses it.
+// The base branch is never deleted, even when the head branch is misconfigured to match it.
+func (svc *Service) cleanupStaleBranch(ctx context.Context, req RunRequest, repository RepositoryRef, base baseState) (bool, error) {
+	headBranch := req.Repository.HeadBranch
+	if !req.Repository.CleanupStaleBranch || req.DryRun || base.OpenPR != nil || headBranch == base.Branch {
+		return false, nil
+	}
+
+	isDeleted, err := svc.branchWriter.DeleteBranch(ctx, repository, headBranch)
+	if err != nil {
+		return false, fmt.Errorf("delete stale branch %s: %w", headBranch, err)
+	}
+
+	return isDeleted, nil
+}
+
+// readBaseState resolves the base branch, the open managed PR, and the base profile file.
+func (svc *Service) readBaseState(ctx context.Context, req RunRequest, repository RepositoryRef) (baseState, error) {
+	baseBranch, err := svc.resolveBaseBranch(ctx, repository, req.Repository.BaseBranch)
+	if err != nil {
+		return baseState{}, err
+	}
+
+	headBranch := req.Repository.HeadBranch
+	var openPR *PullRequest
+	if req.Repository.CommitToBase {
+		headBranch = baseBranch
+	} else if !req.Repository.BranchPerRun {
+		headBranch, openPR, err = svc.findHeadPullRequest(ctx, req, repository, baseBranch)
+		if err != nil {
+			return baseState{}, err
+		}
+	}
+
+	if openPR != nil && !req.PullRequest.isManaged(openPR.Body) {
+		return baseState{}, ErrUnmanagedPullRequest
+	}
+
+	targets := make([]profileTarget, 0, len(req.Repository.PGOPaths))
+	for _, pgoPath := range req.Repository.PGOPaths {
+		target, err := svc.readProfileTarget(ctx, req.Repository, repository, baseBranch, pgoPath)
+		if err != nil {
+			return baseState{}, err
+		}
+
+		target.Previous = target.File
+		if compareRef := req.Repository.CompareRef; compareRef != "" {
+			// A missing profile on the compare ref reads as absent rather than failing the run.
+			settings := req.Repository
+			settings.RequireExistingBase = false
+			compareTarget, err := svc.readProfileTarget(ctx, settings, repository, compareRef, pgoPath)
+			if err != nil {
+				return baseState{}, fmt.Errorf("read compare ref %s: %w", compareRef, err)
+			}
+
+			target.Previous = compareTarget.File
+		}
+
+		targets = append(targets, target)
+	}
+
+	return baseState{
+		Branch:     baseBranch,
+		HeadBranch: headBranch,
+		OpenPR:     openPR,
+		Targets:    targets,
+	}, nil
+}
+
+// readProfileTarget reads the base branch profile behind one pgo path, following hashed filename pointers.
+func (svc *Service) readProfileTarget(ctx context.Context, settings RepositorySettings, repository RepositoryRef, baseBranch string, pgoPath string) (profileTarget, error) {
+	readResult, err := svc.branchWriter.ReadFile(ctx, ReadFileRequest{
+		Repository: repository,
+		Branch:     baseBranch,
+		Path:       pgoPath,
+	})
+	if err != nil {
+		return profileTarget{}, fmt.Errorf("read base branch pgo file: %w", err)
+	}
+
+	profilePath := pgoPath
+	if target, ok := hashedProfileTarget(profilePath, readResult.Content); settings.HashInFilename && readResult.HasFile && ok {
+		profilePath = target
+		readResult, err = svc.branchWriter.ReadFile(ctx, ReadFileRequest{
+			Repository: repository,
+			Branch:     baseBranch,
+			Path:       profilePath,
+		})
+		if err != nil {
+			return profileTarget{}, fmt.Errorf("read base branch hashed pgo file: %w", err)
+		}
+	}
+
+	if !readResult.HasFile && settings.RequireExistingBase {
+		return profileTarget{}, fmt.Errorf("%w: %s on %s", ErrMissingBaseProfile, profilePath, baseBranch)
+	}
+
+	return profileTarget{
+		PGOPath:     pgoPath,
+		ProfilePath: profilePath,
+		File:        readResult,
+	}, nil
+}
+
+// isCached reports whether this process already committed profile to the repository's pgo paths.
+func (svc *Service) isCached(repository RepositoryRef, req RunRequest, profile []byte) bool {
+	if svc.runCache == nil {
+		return false
+	}
+
+	return svc.runCache.contains(runCacheKey(repository, strings.Join(req.Repository.PGOPaths, ","), profile), svc.clock.Now())
+}
+
+// rememberProfile records profile content that is already committed for this repository path.
+func (svc *Service) rememberProfile(cacheKey string) {
+	if svc.runCache != nil {
+		svc.runCache.store(cacheKey, svc.clock.Now())
+	}
+}
+
+// isRecentlyUpdated reports whether the head branch was pushed within the minimum update interval.
+func (svc *Service) isRecentlyUpdated(ctx context.Context, repository RepositoryRef, headBranch string, minUpdateInterval time.Duration) (bool, error) {
+	if minUpdateInterval <= 0 {
+		return false, nil
+	}
+
+	lastCommitTime, hasBranch, err := svc.branchWriter.LastCommitTime(ctx, repository, headBranch)
+	if err != nil {
+		return false, fmt.Errorf("read head branch commit time: %w", err)
+	}
+
+	if !hasBranch {
+		return false, nil
+	}
+
+	return svc.clock.Now().Sub(lastCommitTime) < minUpdateInterval, nil
+}
+
 // resolveBaseBranch picks the configured base or repository default branch.
 func (svc *Service) resolveBaseBranch(ctx context.Context, repository RepositoryRef, baseBranchCfg string) (string, error) {
 	if strings.TrimSpace(baseBranchCfg) != "" {
@@ -182,6 +987,14 @@ func prNumber(existing *PullRequest) int {
 	return existing.Number
 }
 
+func prURL(existing *PullRequest) string {
+	if existing == nil {
+		return ""
+	}
+
+	return existing.URL
+}
+
 func appendMarker(body string, marker string) string {
 	if strings.Contains(body, marker) {
 		return body
@@ -193,3 +1006,21 @@ func appendMarker(body string, marker string) string {
 
 	return strings.TrimRight(body, "\n") + "\n\n" + marker
 }
+
+// profileTagName builds a dated restore point name such as pgo/2024-05-01-abc1234.
+func profileTagName(now time.Time, commitSHA string) string {
+	shortSHA := commitSHA
+	if len(shortSHA) > shortCommitSHALength {
+		shortSHA = shortSHA[:shortCommitSHALength]
+	}
+
+	return profileTagPrefix + now.UTC().Format(profileTagDateLayout) + "-" + shortSHA
+}
+
+// systemClock reads the wall clock.
+type systemClock struct{}
+
+// Now returns the current time.
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,58 @@
+package cpgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrStatusNotPermitted reports that the forge token may not set commit statuses,
+// such as a GitHub token without the statuses scope. Runs record it as a warning.
+var ErrStatusNotPermitted = errors.New("token is not permitted to set commit statuses")
+
+// Commit status states reported for the base branch profile.
+const (
+	StatusStateSuccess = "success"
+	StatusStateFailure = "failure"
+)
+
+// reportStatus marks the base branch head as current when the run left the profile
+// untouched or committed it to the base branch, and as stale when it pushed, or would
+// push, a newer profile for review.
+func (svc *Service) reportStatus(ctx context.Context, req RunRequest, result RunResult) error {
+	statusContext := strings.TrimSpace(req.Status.Context)
+	if statusContext == "" {
+		return nil
+	}
+
+	state, description := profileStatus(result)
+	if err := svc.statusReporter.ReportStatus(ctx, ReportStatusRequest{
+		Repository: RepositoryRef{
+			Owner: req.Repository.Owner,
+			Name:  req.Repository.Name,
+		},
+		Branch:      result.BaseBranch,
+		State:       state,
+		Context:     statusContext,
+		Description: description,
+		TargetURL:   result.PullRequestURL,
+	}); err != nil {
+		return fmt.Errorf("report commit status: %w", err)
+	}
+
+	return nil
+}
+
+// profileStatus maps a run outcome to a commit status state and description.
+func profileStatus(result RunResult) (string, string) {
+	if result.IsCommittedToBase || (!result.IsProfileChanged && result.SkipReason != SkipReasonRateLimited) {
+		return StatusStateSuccess, "PGO profile is current"
+	}
+
+	if result.PullRequestNumber == 0 {
+		return StatusStateFailure, "PGO profile is stale"
+	}
+
+	return StatusStateFailure, fmt.Sprintf("PGO profile is stale; refresh pending in #%d", result.PullRequestNumber)
+}